@@ -0,0 +1,220 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/admin"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+)
+
+// registerAdmin registers the handlers of the admin api.
+func (f *Funder) registerAdmin() {
+	f.admin.Handle(http.MethodGet, "/maintenance", f.listMaintenance)
+	f.admin.Handle(http.MethodPost, "/maintenance", f.addMaintenance)
+	f.admin.Handle(http.MethodDelete, "/maintenance/", f.removeMaintenance)
+	f.admin.Handle(http.MethodGet, "/approvals", f.listApprovals)
+	f.admin.Handle(http.MethodPost, "/approvals/", f.decideApproval)
+	f.admin.Handle(http.MethodGet, "/halts", f.listHalts)
+	f.admin.Handle(http.MethodPost, "/resume", f.resume)
+}
+
+// current returns the scheduler and the approvals of the running watchers. Changes are refused on
+// a standby, the leader would overwrite them when it hands over.
+func (f *Funder) current(change bool) (*funding.Scheduler, *funding.Approvals, error) {
+	if err := f.checkChange(change); err != nil {
+		return nil, nil, err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.scheduler == nil || f.approvals == nil {
+		return nil, nil, admin.NewError(http.StatusServiceUnavailable, "the watchers are not running")
+	}
+
+	return f.scheduler, f.approvals, nil
+}
+
+// currentLimiter returns the limiter of the running watchers, like current.
+func (f *Funder) currentLimiter(change bool) (*funding.Limiter, error) {
+	if err := f.checkChange(change); err != nil {
+		return nil, err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.limiter == nil {
+		return nil, admin.NewError(http.StatusServiceUnavailable, "the watchers are not running")
+	}
+
+	return f.limiter, nil
+}
+
+func (f *Funder) checkChange(change bool) error {
+	if change && f.elector != nil && !f.elector.IsLeader() {
+		return admin.NewError(http.StatusServiceUnavailable, "this instance is a standby, use the leader")
+	}
+
+	return nil
+}
+
+func (f *Funder) listMaintenance(r *http.Request, operator string) (interface{}, error) {
+	scheduler, _, err := f.current(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return scheduler.Maintenances(), nil
+}
+
+func (f *Funder) addMaintenance(r *http.Request, operator string) (interface{}, error) {
+	m := funding.Maintenance{}
+	if err := admin.ReadJSON(r, &m); err != nil {
+		return nil, err
+	}
+	if _, ok := f.chainCfg(m.Chain); !ok {
+		return nil, admin.NewError(http.StatusBadRequest, "unknown chain "+m.Chain)
+	}
+	m.Reason = strings.TrimSpace(m.Reason + " (added by " + operator + ")")
+
+	scheduler, _, err := f.current(true)
+	if err != nil {
+		return nil, err
+	}
+
+	return scheduler.AddMaintenance(m)
+}
+
+func (f *Funder) removeMaintenance(r *http.Request, operator string) (interface{}, error) {
+	scheduler, _, err := f.current(true)
+	if err != nil {
+		return nil, err
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/maintenance/")
+	if !scheduler.RemoveMaintenance(id) {
+		return nil, admin.NewError(http.StatusNotFound, "no maintenance "+id)
+	}
+
+	return map[string]string{"removed": id}, nil
+}
+
+func (f *Funder) listApprovals(r *http.Request, operator string) (interface{}, error) {
+	_, approvals, err := f.current(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return approvals.Requests(r.URL.Query().Get("status")), nil
+}
+
+// decideApproval handles POST /approvals/{id}/approve and /approvals/{id}/reject with an optional
+// comment. The operator of the token is recorded as the one who decided.
+func (f *Funder) decideApproval(r *http.Request, operator string) (interface{}, error) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/approvals/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, admin.NewError(http.StatusNotFound, "expected /approvals/{id}/approve or /approvals/{id}/reject")
+	}
+	id, action := parts[0], parts[1]
+
+	body := struct {
+		Comment string `json:"comment"`
+	}{}
+	if r.ContentLength != 0 {
+		if err := admin.ReadJSON(r, &body); err != nil {
+			return nil, err
+		}
+	}
+
+	_, approvals, err := f.current(true)
+	if err != nil {
+		return nil, err
+	}
+
+	var req *funding.ApprovalRequest
+	switch action {
+	case "approve":
+		req, err = approvals.Approve(id, operator, body.Comment)
+	case "reject":
+		req, err = approvals.Reject(id, operator, body.Comment)
+	default:
+		return nil, admin.NewError(http.StatusNotFound, "unknown action "+action)
+	}
+
+	switch {
+	case errors.Is(err, funding.ErrNoRequest):
+		return nil, admin.NewError(http.StatusNotFound, err.Error())
+	case errors.Is(err, funding.ErrDecided):
+		return nil, admin.NewError(http.StatusConflict, err.Error())
+	case err != nil:
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func (f *Funder) listHalts(r *http.Request, operator string) (interface{}, error) {
+	limiter, err := f.currentLimiter(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return limiter.Halts(), nil
+}
+
+// resume handles POST /resume with the chain whose circuit breaker is cleared, or without a chain
+// to lift the pause of every chain after an unexpected outflow.
+func (f *Funder) resume(r *http.Request, operator string) (interface{}, error) {
+	body := struct {
+		Chain string `json:"chain"`
+	}{}
+	if r.ContentLength != 0 {
+		if err := admin.ReadJSON(r, &body); err != nil {
+			return nil, err
+		}
+	}
+	if body.Chain != "" {
+		if _, ok := f.chainCfg(body.Chain); !ok {
+			return nil, admin.NewError(http.StatusBadRequest, "unknown chain "+body.Chain)
+		}
+	}
+
+	limiter, err := f.currentLimiter(true)
+	if err != nil {
+		return nil, err
+	}
+
+	if body.Chain == "" {
+		err = limiter.Unpause()
+	} else {
+		err = limiter.Resume(body.Chain)
+	}
+	switch {
+	case errors.Is(err, funding.ErrNotHalted), errors.Is(err, funding.ErrNotPaused):
+		return nil, admin.NewError(http.StatusConflict, err.Error())
+	case err != nil:
+		return nil, err
+	}
+
+	f.audit.Record(audit.Record{Chain: body.Chain, Decision: audit.Resume, Reason: "resumed by " + operator})
+	if body.Chain == "" {
+		log.Infof("Funding unpaused by %s", operator)
+		return map[string]bool{"unpaused": true}, nil
+	}
+	log.Infof("Chain %s resumed by %s", body.Chain, operator)
+
+	return map[string]string{"resumed": body.Chain}, nil
+}
+
+func (f *Funder) chainCfg(chain string) (ChainCfg, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	cfg, ok := f.cfg.Chains[chain]
+	return cfg, ok
+}
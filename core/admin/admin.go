@@ -0,0 +1,157 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sisu-network/lib/log"
+)
+
+// Cfg configures the admin api. It is disabled when Listen is empty.
+type Cfg struct {
+	Listen string `toml:"listen" json:"listen"`
+	// Tokens maps the name of each operator to their bearer token. The name identifies the operator
+	// in the logs and in the decisions they make, e.g. approvals.
+	Tokens map[string]string `toml:"tokens" json:"tokens"`
+}
+
+// Error is an error returned by a handler with its HTTP status.
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func NewError(status int, message string) *Error {
+	return &Error{Status: status, Message: message}
+}
+
+// Handler handles an authenticated request of operator. Its result is returned as JSON.
+type Handler func(r *http.Request, operator string) (interface{}, error)
+
+type route struct {
+	method  string
+	path    string
+	handler Handler
+}
+
+// Server is the HTTP admin api. Every request must carry the bearer token of an operator.
+type Server struct {
+	cfg    Cfg
+	routes []route
+	server *http.Server
+}
+
+func NewServer(cfg Cfg) *Server {
+	return &Server{cfg: cfg}
+}
+
+// Handle registers a handler. A path ending with "/" matches every path below it, the handler
+// reads the rest of the path from the request.
+func (s *Server) Handle(method, path string, handler Handler) {
+	s.routes = append(s.routes, route{method: method, path: path, handler: handler})
+}
+
+func (s *Server) Start() {
+	s.server = &http.Server{
+		Addr:              s.cfg.Listen,
+		Handler:           s,
+		ReadHeaderTimeout: time.Second * 10,
+	}
+
+	log.Infof("Starting admin api on %s", s.cfg.Listen)
+	go func() {
+		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Errorf("Admin api stopped, err = %s", err)
+		}
+	}()
+}
+
+func (s *Server) Stop() {
+	if s.server != nil {
+		s.server.Close()
+	}
+}
+
+// Operator returns the name of the operator of a bearer token, or "" if the token is unknown.
+func (s *Server) Operator(token string) string {
+	for name, expected := range s.cfg.Tokens {
+		if expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1 {
+			return name
+		}
+	}
+
+	return ""
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	operator := s.Operator(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if operator == "" {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var match *route
+	pathFound := false
+	for i, route := range s.routes {
+		if route.path != r.URL.Path && !(strings.HasSuffix(route.path, "/") && strings.HasPrefix(r.URL.Path, route.path)) {
+			continue
+		}
+		pathFound = true
+		if route.method != r.Method {
+			continue
+		}
+		// The exact path or the longest prefix wins.
+		if match == nil || len(route.path) > len(match.path) {
+			match = &s.routes[i]
+		}
+	}
+	if match == nil {
+		status := http.StatusNotFound
+		if pathFound {
+			status = http.StatusMethodNotAllowed
+		}
+		writeJSON(w, status, map[string]string{"error": http.StatusText(status)})
+		return
+	}
+
+	log.Infof("Admin api: %s %s by %s", r.Method, r.URL.Path, operator)
+	result, err := match.handler(r, operator)
+	if err != nil {
+		status := http.StatusBadRequest
+		if adminErr, ok := err.(*Error); ok {
+			status = adminErr.Status
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("Failed to write admin api response, err = %s", err)
+	}
+}
+
+// ReadJSON decodes the JSON body of a request into v.
+func ReadJSON(r *http.Request, v interface{}) error {
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return NewError(http.StatusBadRequest, "invalid body: "+err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sisu-network/sisu-account-funding/core/admin"
+	"github.com/sisu-network/sisu-account-funding/core/alert"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+)
+
+func TestResume(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		paused bool
+		halted bool
+		status int
+		// resumed is the chain of the expected audit record, "-" if none is expected.
+		resumed string
+	}{
+		{name: "unpause", paused: true, resumed: ""},
+		{name: "unpause with an empty chain", body: `{"chain":""}`, paused: true, resumed: ""},
+		{name: "not paused", status: http.StatusConflict, resumed: "-"},
+		{name: "resume a chain", body: `{"chain":"ganache1"}`, halted: true, resumed: "ganache1"},
+		{name: "chain not halted", body: `{"chain":"ganache1"}`, status: http.StatusConflict, resumed: "-"},
+		{name: "unknown chain", body: `{"chain":"ropsten"}`, status: http.StatusBadRequest, resumed: "-"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			chains := map[string]funding.LimitCfg{"ganache1": {MaxTopUps: 1, TopUpWindow: time.Hour}}
+			limiter, err := funding.NewLimiter(filepath.Join(dataDir, "limiter.json"), funding.GlobalLimitCfg{},
+				chains, alert.NewAlerter(""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.paused {
+				limiter.Pause("unexpected outflow")
+			}
+			if tt.halted {
+				limiter.Reserve("ganache1", "0xto", big.NewInt(1))
+				limiter.Reserve("ganache1", "0xto", big.NewInt(1))
+			}
+
+			auditPath := filepath.Join(dataDir, "audit.jsonl")
+			f := &Funder{
+				cfg:     &ChainsCfg{Chains: map[string]ChainCfg{"ganache1": {}}},
+				audit:   audit.NewLog(audit.Cfg{Path: auditPath}, dataDir),
+				limiter: limiter,
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/resume", strings.NewReader(tt.body))
+			_, err = f.resume(r, "alice")
+			status := 0
+			if err != nil {
+				adminErr := &admin.Error{}
+				if !errors.As(err, &adminErr) {
+					t.Fatal(err)
+				}
+				status = adminErr.Status
+			}
+			if status != tt.status {
+				t.Fatalf("got status %d, want %d, err = %v", status, tt.status, err)
+			}
+
+			if tt.status == 0 && (limiter.Paused() || limiter.Halted("ganache1")) {
+				t.Fatal("funding is still blocked")
+			}
+
+			bz, err := os.ReadFile(auditPath)
+			if tt.resumed == "-" {
+				if err == nil {
+					t.Fatalf("got audit records %s, want none", bz)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			record := audit.Record{}
+			if err := json.Unmarshal(bz, &record); err != nil {
+				t.Fatal(err)
+			}
+			if record.Decision != audit.Resume || record.Chain != tt.resumed || !strings.Contains(record.Reason, "alice") {
+				t.Fatalf("got audit record %+v", record)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sisu-network/lib/log"
+)
+
+// Alerter notifies operators about conditions that need a human to look at them.
+type Alerter interface {
+	Alert(chain string, message string)
+}
+
+type defaultAlerter struct {
+	webhook string
+	client  *http.Client
+}
+
+// NewAlerter returns an alerter that always logs the alert as critical and, when webhook is not
+// empty, also posts it as JSON to the webhook url.
+func NewAlerter(webhook string) Alerter {
+	return &defaultAlerter{
+		webhook: webhook,
+		client:  &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+func (a *defaultAlerter) Alert(chain string, message string) {
+	log.Critical("ALERT on chain ", chain, ": ", message)
+
+	if a.webhook == "" {
+		return
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"chain":   chain,
+		"message": message,
+		"time":    time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		log.Errorf("Failed to marshal alert, err = %s", err)
+		return
+	}
+
+	res, err := a.client.Post(a.webhook, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		log.Errorf("Failed to post alert to webhook, err = %s", err)
+		return
+	}
+	res.Body.Close()
+}
@@ -0,0 +1,193 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+)
+
+// The decisions taken on a watched address.
+const (
+	// Skip is recorded when the address does not need a top-up, or when its balance is unknown.
+	Skip = "skip"
+	// Fund is recorded when a top-up is sent, or failed to be sent.
+	Fund = "fund"
+	// Defer is recorded when a top-up is postponed: high gas prices, outside the funding windows,
+	// during a maintenance or while it waits for approval.
+	Defer = "defer"
+	// Blocked is recorded when a top-up is refused: spending limits, pause, rejection or standby.
+	Blocked = "blocked"
+	// Resume is recorded when an operator resumes a halted chain, or the funding of every chain
+	// paused after an unexpected outflow. The chain is empty for the latter.
+	Resume = "resume"
+)
+
+// Stdout is the path that writes the audit log to the standard output, e.g. for a log shipper
+// reading the output of the container.
+const Stdout = "-"
+
+// Cfg configures the audit log.
+type Cfg struct {
+	// Path is the file the records are appended to, audit.jsonl in the data dir by default, or "-"
+	// for the standard output.
+	Path     string `toml:"path"`
+	Disabled bool   `toml:"disabled"`
+}
+
+// Record is a funding decision on a watched address. Amounts are decimal strings in the smallest
+// unit of the chain.
+type Record struct {
+	Time    time.Time `json:"time"`
+	Chain   string    `json:"chain"`
+	Address string    `json:"address"`
+	// Label tells which account of the chain the address is, e.g. "mpc".
+	Label     string `json:"label,omitempty"`
+	Balance   string `json:"balance,omitempty"`
+	Rpc       string `json:"rpc,omitempty"`
+	Threshold string `json:"threshold,omitempty"`
+	Decision  string `json:"decision"`
+	Amount    string `json:"amount,omitempty"`
+	TxHash    string `json:"tx_hash,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	// Error is why the balance could not be read, or why a decided top-up could not be sent.
+	Error string `json:"error,omitempty"`
+}
+
+// Log is the append-only audit log of the funding decisions, one JSON record per line.
+type Log struct {
+	lock     sync.Mutex
+	filePath string
+	out      io.Writer
+}
+
+// NewLog returns the audit log of cfg, nil if it is disabled. A nil log records nothing.
+func NewLog(cfg Cfg, dataDir string) *Log {
+	if cfg.Disabled {
+		return nil
+	}
+
+	switch cfg.Path {
+	case Stdout:
+		return &Log{out: os.Stdout}
+	case "":
+		return &Log{filePath: filepath.Join(dataDir, "audit.jsonl")}
+	default:
+		return &Log{filePath: cfg.Path}
+	}
+}
+
+// Record appends a record. The decision is already taken, so a failure to write it is only
+// logged.
+func (l *Log) Record(record Record) {
+	if l == nil {
+		return
+	}
+
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	if err := l.write(record); err != nil {
+		log.Errorf("Failed to write audit record of chain %s, err = %s", record.Chain, err)
+	}
+}
+
+func (l *Log) write(record Record) error {
+	bz, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	bz = append(bz, '\n')
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.out != nil {
+		_, err := l.out.Write(bz)
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.filePath), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(bz)
+	return err
+}
+
+// Trail records the decisions of one chain.
+type Trail struct {
+	log   *Log
+	chain string
+	rpc   string
+}
+
+// Trail returns the trail of chain. rpc is recorded for the decisions that do not set theirs.
+func (l *Log) Trail(chain, rpc string) *Trail {
+	return &Trail{log: l, chain: chain, rpc: rpc}
+}
+
+// Record records a decision of the chain.
+func (t *Trail) Record(record Record) {
+	record.Chain = t.chain
+	if record.Rpc == "" {
+		record.Rpc = t.rpc
+	}
+
+	t.log.Record(record)
+}
+
+// Unavailable records that no decision could be taken because the balance could not be read.
+func (t *Trail) Unavailable(record Record, err error) {
+	record.Decision = Skip
+	record.Reason = "balance unavailable"
+	record.Error = err.Error()
+
+	t.Record(record)
+}
+
+// Funded records a top-up sent in the transaction txHash.
+func (t *Trail) Funded(record Record, txHash string) {
+	record.Decision = Fund
+	record.TxHash = txHash
+
+	t.Record(record)
+}
+
+// Refused records a top-up refused by the limiter with err.
+func (t *Trail) Refused(record Record, err error) {
+	record.Decision = RefusedDecision(err)
+	record.Reason = err.Error()
+
+	t.Record(record)
+}
+
+// Failed records a top-up that could not be sent because of err.
+func (t *Trail) Failed(record Record, err error) {
+	record.Decision = Fund
+	record.Error = err.Error()
+
+	t.Record(record)
+}
+
+// RefusedDecision returns the decision for a top-up refused by the limiter with err: deferred if
+// it waits for a funding window, the end of a maintenance or an approval, blocked otherwise.
+func RefusedDecision(err error) string {
+	if errors.Is(err, funding.ErrOutsideWindow) || errors.Is(err, funding.ErrMaintenance) ||
+		errors.Is(err, funding.ErrAwaitingApproval) {
+		return Defer
+	}
+
+	return Blocked
+}
@@ -0,0 +1,292 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrTxNotFound is returned for an output that the node does not know, or knows as spent.
+var ErrTxNotFound = errors.New("transaction output not found")
+
+// Utxo is an unspent output of an address.
+type Utxo struct {
+	TxId  string
+	Vout  uint32
+	Value int64 // in satoshi
+}
+
+// Client reads the chain and broadcasts transactions. It lets the watcher run against bitcoind,
+// an Esplora api or a stand-in in tests.
+type Client interface {
+	ListUnspent(address string) ([]Utxo, error)
+	// EstimateFeeRate returns the fee rate in sat/vB to confirm within target blocks.
+	EstimateFeeRate(target int) (float64, error)
+	Broadcast(tx []byte) (string, error)
+	// GetConfirmations returns the confirmations of an output, 0 while it is in the mempool, or
+	// ErrTxNotFound.
+	GetConfirmations(txId string, vout uint32) (int64, error)
+}
+
+func NewClient(url string, cfg Cfg) Client {
+	httpClient := &http.Client{Timeout: time.Second * 60}
+	if cfg.Api == ApiEsplora {
+		return &esploraClient{url: strings.TrimSuffix(url, "/"), client: httpClient}
+	}
+
+	return &bitcoindClient{url: url, user: cfg.RpcUser, password: cfg.RpcPassword, client: httpClient}
+}
+
+type bitcoindClient struct {
+	url      string
+	user     string
+	password string
+	client   *http.Client
+}
+
+func (c *bitcoindClient) call(method string, params []interface{}, result interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "1.0",
+		"id":      "funding",
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", c.url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	bz, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	// bitcoind returns errors with a 500 status and a JSON body.
+	rpcRes := &struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}{}
+	if err := json.Unmarshal(bz, rpcRes); err != nil {
+		return fmt.Errorf("%s failed with status %d", method, res.StatusCode)
+	}
+	if rpcRes.Error != nil {
+		return fmt.Errorf("%s failed, code = %d, message = %s", method, rpcRes.Error.Code, rpcRes.Error.Message)
+	}
+
+	return json.Unmarshal(rpcRes.Result, result)
+}
+
+// ListUnspent scans the utxo set, so it does not need the address to be in a bitcoind wallet.
+func (c *bitcoindClient) ListUnspent(address string) ([]Utxo, error) {
+	res := &struct {
+		Unspents []struct {
+			TxId   string  `json:"txid"`
+			Vout   uint32  `json:"vout"`
+			Amount float64 `json:"amount"`
+		} `json:"unspents"`
+	}{}
+	err := c.call("scantxoutset", []interface{}{"start", []string{"addr(" + address + ")"}}, res)
+	if err != nil {
+		return nil, err
+	}
+
+	utxos := make([]Utxo, 0, len(res.Unspents))
+	for _, unspent := range res.Unspents {
+		utxos = append(utxos, Utxo{
+			TxId:  unspent.TxId,
+			Vout:  unspent.Vout,
+			Value: int64(math.Round(unspent.Amount * 1e8)),
+		})
+	}
+
+	return utxos, nil
+}
+
+func (c *bitcoindClient) EstimateFeeRate(target int) (float64, error) {
+	res := &struct {
+		FeeRate float64  `json:"feerate"` // BTC/kvB
+		Errors  []string `json:"errors"`
+	}{}
+	if err := c.call("estimatesmartfee", []interface{}{target}, res); err != nil {
+		return 0, err
+	}
+	if res.FeeRate <= 0 {
+		return 0, fmt.Errorf("no fee estimate: %s", strings.Join(res.Errors, ", "))
+	}
+
+	return res.FeeRate * 1e8 / 1000, nil
+}
+
+func (c *bitcoindClient) Broadcast(tx []byte) (string, error) {
+	var txId string
+	err := c.call("sendrawtransaction", []interface{}{hex.EncodeToString(tx)}, &txId)
+
+	return txId, err
+}
+
+// GetConfirmations reads the output from the utxo set and the mempool, so it does not need the
+// transaction index of bitcoind.
+func (c *bitcoindClient) GetConfirmations(txId string, vout uint32) (int64, error) {
+	var res *struct {
+		Confirmations int64 `json:"confirmations"`
+	}
+	if err := c.call("gettxout", []interface{}{txId, vout, true}, &res); err != nil {
+		return 0, err
+	}
+	if res == nil {
+		return 0, ErrTxNotFound
+	}
+
+	return res.Confirmations, nil
+}
+
+var errNotFound = errors.New("not found")
+
+type esploraClient struct {
+	url    string
+	client *http.Client
+}
+
+func (c *esploraClient) do(method, path string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, c.url+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	bz, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s %s", errNotFound, method, path)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s failed with status %d: %s", method, path, res.StatusCode, string(bz))
+	}
+
+	return bz, nil
+}
+
+func (c *esploraClient) ListUnspent(address string) ([]Utxo, error) {
+	bz, err := c.do("GET", "/address/"+address+"/utxo", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]struct {
+		TxId  string `json:"txid"`
+		Vout  uint32 `json:"vout"`
+		Value int64  `json:"value"`
+	}, 0)
+	if err := json.Unmarshal(bz, &res); err != nil {
+		return nil, err
+	}
+
+	utxos := make([]Utxo, 0, len(res))
+	for _, unspent := range res {
+		utxos = append(utxos, Utxo{TxId: unspent.TxId, Vout: unspent.Vout, Value: unspent.Value})
+	}
+
+	return utxos, nil
+}
+
+// EstimateFeeRate picks the estimate of the largest target that is not above the requested one.
+func (c *esploraClient) EstimateFeeRate(target int) (float64, error) {
+	bz, err := c.do("GET", "/fee-estimates", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	estimates := make(map[string]float64)
+	if err := json.Unmarshal(bz, &estimates); err != nil {
+		return 0, err
+	}
+
+	best, rate := 0, float64(0)
+	for key, value := range estimates {
+		blocks, err := strconv.Atoi(key)
+		if err != nil || blocks > target {
+			continue
+		}
+		if blocks > best {
+			best, rate = blocks, value
+		}
+	}
+	if best == 0 {
+		return 0, fmt.Errorf("no fee estimate for %d blocks", target)
+	}
+
+	return rate, nil
+}
+
+func (c *esploraClient) Broadcast(tx []byte) (string, error) {
+	bz, err := c.do("POST", "/tx", strings.NewReader(hex.EncodeToString(tx)))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(bz)), nil
+}
+
+func (c *esploraClient) GetConfirmations(txId string, vout uint32) (int64, error) {
+	bz, err := c.do("GET", "/tx/"+txId+"/status", nil)
+	if errors.Is(err, errNotFound) {
+		return 0, ErrTxNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	status := &struct {
+		Confirmed   bool  `json:"confirmed"`
+		BlockHeight int64 `json:"block_height"`
+	}{}
+	if err := json.Unmarshal(bz, status); err != nil {
+		return 0, err
+	}
+	if !status.Confirmed {
+		return 0, nil
+	}
+
+	bz, err = c.do("GET", "/blocks/tip/height", nil)
+	if err != nil {
+		return 0, err
+	}
+	tip, err := strconv.ParseInt(strings.TrimSpace(string(bz)), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return tip - status.BlockHeight + 1, nil
+}
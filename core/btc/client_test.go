@@ -0,0 +1,62 @@
+package btc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEsploraGetConfirmations(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        string
+		confirmations int64
+		err           error
+	}{
+		{
+			name:          "transaction in the mempool",
+			status:        `{"confirmed":false}`,
+			confirmations: 0,
+		},
+		{
+			name:          "transaction in the tip block",
+			status:        `{"confirmed":true,"block_height":105}`,
+			confirmations: 1,
+		},
+		{
+			name:          "transaction buried under 5 blocks",
+			status:        `{"confirmed":true,"block_height":100}`,
+			confirmations: 6,
+		},
+		{
+			name: "unknown transaction",
+			err:  ErrTxNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/blocks/tip/height":
+					w.Write([]byte("105"))
+				case r.URL.Path == "/tx/abcd/status" && tt.status != "":
+					w.Write([]byte(tt.status))
+				default:
+					http.Error(w, "Transaction not found", http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			client := NewClient(server.URL, Cfg{Api: ApiEsplora})
+			confirmations, err := client.GetConfirmations("abcd", 0)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got err %v, want %v", err, tt.err)
+			}
+			if confirmations != tt.confirmations {
+				t.Fatalf("got %d confirmations, want %d", confirmations, tt.confirmations)
+			}
+		})
+	}
+}
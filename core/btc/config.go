@@ -0,0 +1,101 @@
+package btc
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+const (
+	// ApiBitcoind reads the chain through the JSON-RPC api of bitcoind.
+	ApiBitcoind = "bitcoind"
+	// ApiEsplora reads the chain through an Esplora REST api.
+	ApiEsplora = "esplora"
+
+	DefaultFeeTarget  = 6
+	DefaultMinFeeRate = float64(1)   // sat/vB
+	DefaultMaxFeeRate = float64(200) // sat/vB
+
+	DefaultConfirmations = 1
+)
+
+// Cfg holds the Bitcoin specific settings of a chain.
+type Cfg struct {
+	// Network is mainnet, testnet, regtest or signet.
+	Network string `toml:"network" json:"network"`
+	// Api is bitcoind (default) or esplora.
+	Api         string `toml:"api" json:"api"`
+	RpcUser     string `toml:"rpc_user" json:"rpc_user"`
+	RpcPassword string `toml:"rpc_password" json:"rpc_password"`
+
+	// FeeTarget is the number of blocks the funding transaction should confirm within. The
+	// estimated fee rate is clamped to [MinFeeRate, MaxFeeRate] sat/vB.
+	FeeTarget  int     `toml:"fee_target" json:"fee_target"`
+	MinFeeRate float64 `toml:"min_fee_rate" json:"min_fee_rate"`
+	MaxFeeRate float64 `toml:"max_fee_rate" json:"max_fee_rate"`
+
+	// Confirmations is the number of blocks a funding transaction needs before the address is
+	// funded again.
+	Confirmations int64 `toml:"confirmations" json:"confirmations"`
+}
+
+func (cfg Cfg) withDefaults() Cfg {
+	if cfg.Api == "" {
+		cfg.Api = ApiBitcoind
+	}
+	if cfg.FeeTarget == 0 {
+		cfg.FeeTarget = DefaultFeeTarget
+	}
+	if cfg.MinFeeRate == 0 {
+		cfg.MinFeeRate = DefaultMinFeeRate
+	}
+	if cfg.MaxFeeRate == 0 {
+		cfg.MaxFeeRate = DefaultMaxFeeRate
+	}
+	if cfg.Confirmations == 0 {
+		cfg.Confirmations = DefaultConfirmations
+	}
+
+	return cfg
+}
+
+// IsConfigured returns true if the chain config has a Bitcoin network.
+func (cfg Cfg) IsConfigured() bool {
+	return cfg.Network != ""
+}
+
+func (cfg Cfg) Validate() error {
+	if _, err := cfg.Params(); err != nil {
+		return err
+	}
+
+	switch cfg.Api {
+	case "", ApiBitcoind, ApiEsplora:
+	default:
+		return fmt.Errorf("unknown bitcoin api %s", cfg.Api)
+	}
+
+	if cfg.MinFeeRate < 0 || cfg.MaxFeeRate < 0 || (cfg.MaxFeeRate > 0 && cfg.MinFeeRate > cfg.MaxFeeRate) {
+		return fmt.Errorf("invalid fee rate range [%f, %f]", cfg.MinFeeRate, cfg.MaxFeeRate)
+	}
+	if cfg.Confirmations < 0 {
+		return fmt.Errorf("invalid confirmations %d", cfg.Confirmations)
+	}
+
+	return nil
+}
+
+func (cfg Cfg) Params() (*chaincfg.Params, error) {
+	switch cfg.Network {
+	case "mainnet":
+		return &chaincfg.MainNetParams, nil
+	case "testnet":
+		return &chaincfg.TestNet3Params, nil
+	case "regtest":
+		return &chaincfg.RegressionNetParams, nil
+	case "signet":
+		return &chaincfg.SigNetParams, nil
+	default:
+		return nil, fmt.Errorf("unknown bitcoin network %q", cfg.Network)
+	}
+}
@@ -0,0 +1,79 @@
+package btc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/store"
+)
+
+var PendingPollTime = time.Minute
+
+// pendingTx is a funding transaction that has been sent but is not confirmed yet. The balance of
+// the watched address only counts confirmed outputs, so it does not show the top-up until then.
+type pendingTx struct {
+	TxId string `json:"txid"`
+	// Vout is the output paying the watched address.
+	Vout   uint32    `json:"vout"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+func (w *watcher) loadPending() {
+	pending := &pendingTx{}
+	if err := store.Load(w.pendingFile, pending); err != nil {
+		log.Errorf("Failed to load pending transaction from %s, err = %s", w.pendingFile, err)
+		return
+	}
+
+	if pending.TxId != "" {
+		log.Infof("Resuming tracking of transaction %s on chain %s", pending.TxId, w.chain)
+		w.pending = pending
+	}
+}
+
+func (w *watcher) setPending(pending *pendingTx) {
+	w.pending = pending
+	if pending == nil {
+		pending = &pendingTx{}
+	}
+
+	if err := store.Save(w.pendingFile, pending); err != nil {
+		log.Errorf("Failed to save pending transaction to %s, err = %s", w.pendingFile, err)
+	}
+}
+
+// checkPending polls the in-flight funding transaction and returns true once there is no
+// transaction in flight anymore: its output is confirmed, or the node does not know it anymore
+// because the transaction was dropped from the mempool or its output was already spent.
+func (w *watcher) checkPending() bool {
+	if w.pending == nil {
+		return true
+	}
+
+	// The transaction stays pending while its status is unknown. Giving up on a transaction that
+	// may confirm would fund the address twice.
+	confirmations, err := w.client.GetConfirmations(w.pending.TxId, w.pending.Vout)
+	switch {
+	case errors.Is(err, ErrTxNotFound):
+		log.Warnf("Output %d of transaction %s on chain %s is not known anymore, it was dropped or spent",
+			w.pending.Vout, w.pending.TxId, w.chain)
+		w.setPending(nil)
+		return true
+
+	case err != nil:
+		log.Errorf("Failed to get transaction %s on chain %s, err = %s", w.pending.TxId, w.chain, err)
+		return false
+
+	case confirmations >= w.cfg.Confirmations:
+		log.Infof("Transaction %s on chain %s is confirmed with %d confirmations", w.pending.TxId,
+			w.chain, confirmations)
+		w.setPending(nil)
+		return true
+	}
+
+	log.Verbosef("Transaction %s on chain %s has %d/%d confirmations, sent %s ago", w.pending.TxId,
+		w.chain, confirmations, w.cfg.Confirmations, time.Since(w.pending.SentAt).Round(time.Second))
+
+	return false
+}
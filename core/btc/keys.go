@@ -0,0 +1,53 @@
+package btc
+
+import (
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/cosmos/go-bip39"
+)
+
+// GetAddress returns the P2WPKH address of an ECDSA public key, e.g. the Sisu MPC key.
+func GetAddress(pubkey []byte, params *chaincfg.Params) (*btcutil.AddressWitnessPubKeyHash, error) {
+	pubKey, err := btcec.ParsePubKey(pubkey)
+	if err != nil {
+		return nil, err
+	}
+
+	return btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey.SerializeCompressed()), params)
+}
+
+// getPrivateKey derives the faucet key from the mnemonic with the BIP84 path of the first account.
+func getPrivateKey(mnemonic string, params *chaincfg.Params) (*btcec.PrivateKey, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := hdkeychain.NewMaster(seed, params)
+	if err != nil {
+		return nil, err
+	}
+
+	coinType := uint32(1)
+	if params.Net == chaincfg.MainNetParams.Net {
+		coinType = 0
+	}
+
+	path := []uint32{
+		hdkeychain.HardenedKeyStart + 84,
+		hdkeychain.HardenedKeyStart + coinType,
+		hdkeychain.HardenedKeyStart,
+		0,
+		0,
+	}
+	for _, n := range path {
+		key, err = key.Derive(n)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key.ECPrivKey()
+}
@@ -0,0 +1,256 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// standIn is a bitcoind JSON-RPC endpoint with an in-memory utxo set. Like scantxoutset, it only
+// reports the confirmed outputs: broadcast transactions wait in the mempool until mine is called.
+type standIn struct {
+	lock    sync.Mutex
+	params  *chaincfg.Params
+	utxos   map[string][]Utxo
+	feeRate float64 // sat/vB
+	mempool []*wire.MsgTx
+	mined   []*wire.MsgTx
+}
+
+func newStandIn(t *testing.T) (*standIn, string) {
+	s := &standIn{
+		params:  &chaincfg.RegressionNetParams,
+		utxos:   make(map[string][]Utxo),
+		feeRate: 10,
+	}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+
+	return s, server.URL
+}
+
+func (s *standIn) addUtxo(address string, utxo Utxo) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.utxos[address] = append(s.utxos[address], utxo)
+}
+
+func (s *standIn) balance(address string) int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	balance := int64(0)
+	for _, utxo := range s.utxos[address] {
+		balance += utxo.Value
+	}
+
+	return balance
+}
+
+func (s *standIn) broadcast() []*wire.MsgTx {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append(append([]*wire.MsgTx{}, s.mined...), s.mempool...)
+}
+
+// drop evicts the transactions of the mempool.
+func (s *standIn) drop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.mempool = nil
+}
+
+// spend removes the outputs of address from the utxo set, as if its owner spent them.
+func (s *standIn) spend(address string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.utxos, address)
+}
+
+// mine confirms the transactions of the mempool: their inputs are removed from the utxo set and
+// their outputs added.
+func (s *standIn) mine() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, tx := range s.mempool {
+		spent := make(map[string]bool)
+		for _, txIn := range tx.TxIn {
+			spent[txIn.PreviousOutPoint.String()] = true
+		}
+		for address, utxos := range s.utxos {
+			kept := make([]Utxo, 0)
+			for _, utxo := range utxos {
+				if !spent[utxoKey(utxo)] {
+					kept = append(kept, utxo)
+				}
+			}
+			s.utxos[address] = kept
+		}
+
+		for i, txOut := range tx.TxOut {
+			_, addresses, _, err := txscript.ExtractPkScriptAddrs(txOut.PkScript, s.params)
+			if err != nil || len(addresses) != 1 {
+				continue
+			}
+			address := addresses[0].EncodeAddress()
+			s.utxos[address] = append(s.utxos[address], Utxo{
+				TxId:  tx.TxHash().String(),
+				Vout:  uint32(i),
+				Value: txOut.Value,
+			})
+		}
+	}
+
+	s.mined = append(s.mined, s.mempool...)
+	s.mempool = nil
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result interface{}
+	var rpcErr error
+	switch req.Method {
+	case "scantxoutset":
+		descriptors := make([]string, 0)
+		json.Unmarshal(req.Params[1], &descriptors)
+		address := strings.TrimSuffix(strings.TrimPrefix(descriptors[0], "addr("), ")")
+
+		unspents := make([]map[string]interface{}, 0)
+		for _, utxo := range s.utxos[address] {
+			unspents = append(unspents, map[string]interface{}{
+				"txid":   utxo.TxId,
+				"vout":   utxo.Vout,
+				"amount": float64(utxo.Value) / 1e8,
+			})
+		}
+		result = map[string]interface{}{"unspents": unspents}
+
+	case "gettxout":
+		txId, vout := "", uint32(0)
+		json.Unmarshal(req.Params[0], &txId)
+		json.Unmarshal(req.Params[1], &vout)
+		result = s.txOut(txId, vout)
+
+	case "estimatesmartfee":
+		result = map[string]float64{"feerate": s.feeRate * 1000 / 1e8}
+
+	case "sendrawtransaction":
+		rawTx := ""
+		json.Unmarshal(req.Params[0], &rawTx)
+		tx, err := s.accept(rawTx)
+		if err != nil {
+			rpcErr = err
+			break
+		}
+		s.mempool = append(s.mempool, tx)
+		result = tx.TxHash().String()
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{"code": -32601, "message": "Method not found"},
+		})
+		return
+	}
+
+	if rpcErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{"code": -26, "message": rpcErr.Error()},
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"result": result})
+}
+
+// accept decodes a raw transaction and refuses the ones spending an output that is unknown or
+// already spent by a transaction of the mempool. The caller must hold the lock.
+func (s *standIn) accept(rawTx string) (*wire.MsgTx, error) {
+	bz, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err := tx.Deserialize(bytes.NewReader(bz)); err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool)
+	for _, utxos := range s.utxos {
+		for _, utxo := range utxos {
+			known[utxoKey(utxo)] = true
+		}
+	}
+	for _, pending := range s.mempool {
+		for _, txIn := range pending.TxIn {
+			delete(known, txIn.PreviousOutPoint.String())
+		}
+	}
+	for _, txIn := range tx.TxIn {
+		if !known[txIn.PreviousOutPoint.String()] {
+			return nil, &rejectError{"bad-txns-inputs-missingorspent"}
+		}
+		if len(txIn.Witness) != 2 {
+			return nil, &rejectError{"missing witness"}
+		}
+	}
+
+	return tx, nil
+}
+
+// txOut returns the gettxout result of an output of the mempool or the utxo set, nil if it is
+// unknown or spent. Mined outputs have a single confirmation. The caller must hold the lock.
+func (s *standIn) txOut(txId string, vout uint32) interface{} {
+	for _, tx := range s.mempool {
+		if tx.TxHash().String() == txId && int(vout) < len(tx.TxOut) {
+			return map[string]interface{}{"confirmations": 0}
+		}
+	}
+	for _, utxos := range s.utxos {
+		for _, utxo := range utxos {
+			if utxo.TxId == txId && utxo.Vout == vout {
+				return map[string]interface{}{"confirmations": 1}
+			}
+		}
+	}
+
+	return nil
+}
+
+type rejectError struct {
+	reason string
+}
+
+func (e *rejectError) Error() string {
+	return e.reason
+}
+
+func utxoKey(utxo Utxo) string {
+	return fmt.Sprintf("%s:%d", utxo.TxId, utxo.Vout)
+}
@@ -0,0 +1,120 @@
+package btc
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Virtual sizes of the parts of a P2WPKH transaction, rounded up.
+const (
+	txOverheadVSize = 11
+	p2wpkhInVSize   = 68
+	p2wpkhOutVSize  = 31
+
+	// dustLimit is the smallest P2WPKH output relayed by default nodes.
+	dustLimit = int64(294)
+)
+
+func estimateFee(inputs, outputs int, feeRate float64) int64 {
+	vsize := txOverheadVSize + inputs*p2wpkhInVSize + outputs*p2wpkhOutVSize
+	return int64(math.Ceil(float64(vsize) * feeRate))
+}
+
+// selectCoins picks the utxos to spend for amount, largest first so that the faucet does not build
+// up small outputs that cost more in fees than they are worth. It returns the selected utxos, the
+// fee and the change, which is zero when it would be dust and is then left to the miners.
+func selectCoins(utxos []Utxo, amount int64, feeRate float64) ([]Utxo, int64, int64, error) {
+	sorted := make([]Utxo, len(utxos))
+	copy(sorted, utxos)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Value > sorted[j].Value
+	})
+
+	selected := make([]Utxo, 0)
+	total := int64(0)
+	for _, utxo := range sorted {
+		selected = append(selected, utxo)
+		total += utxo.Value
+
+		fee := estimateFee(len(selected), 2, feeRate)
+		if total < amount+fee {
+			continue
+		}
+
+		change := total - amount - fee
+		if change < dustLimit {
+			return selected, total - amount, 0, nil
+		}
+
+		return selected, fee, change, nil
+	}
+
+	return nil, 0, 0, fmt.Errorf("insufficient funds, have %d, need %d plus fees", total, amount)
+}
+
+// buildTransfer returns a signed transaction sending amount from the P2WPKH address of privKey to
+// recipient.
+func buildTransfer(privKey *btcec.PrivateKey, from btcutil.Address, recipient btcutil.Address,
+	utxos []Utxo, amount int64, feeRate float64) (*wire.MsgTx, int64, error) {
+	selected, fee, change, err := selectCoins(utxos, amount, feeRate)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fromScript, err := txscript.PayToAddrScript(from)
+	if err != nil {
+		return nil, 0, err
+	}
+	toScript, err := txscript.PayToAddrScript(recipient)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	prevOuts := make(map[wire.OutPoint]*wire.TxOut)
+	for _, utxo := range selected {
+		hash, err := chainhash.NewHashFromStr(utxo.TxId)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		outPoint := wire.NewOutPoint(hash, utxo.Vout)
+		tx.AddTxIn(wire.NewTxIn(outPoint, nil, nil))
+		prevOuts[*outPoint] = wire.NewTxOut(utxo.Value, fromScript)
+	}
+
+	tx.AddTxOut(wire.NewTxOut(amount, toScript))
+	if change > 0 {
+		tx.AddTxOut(wire.NewTxOut(change, fromScript))
+	}
+
+	sigHashes := txscript.NewTxSigHashes(tx, txscript.NewMultiPrevOutFetcher(prevOuts))
+	for i, txIn := range tx.TxIn {
+		prevOut := prevOuts[txIn.PreviousOutPoint]
+		witness, err := txscript.WitnessSignature(tx, sigHashes, i, prevOut.Value, fromScript,
+			txscript.SigHashAll, privKey, true)
+		if err != nil {
+			return nil, 0, err
+		}
+		txIn.Witness = witness
+	}
+
+	return tx, fee, nil
+}
+
+func serializeTx(tx *wire.MsgTx) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := tx.Serialize(buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
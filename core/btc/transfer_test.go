@@ -0,0 +1,126 @@
+package btc
+
+import (
+	"testing"
+)
+
+func TestSelectCoins(t *testing.T) {
+	utxo := func(value int64) Utxo {
+		return Utxo{TxId: "00", Value: value}
+	}
+
+	tests := []struct {
+		name    string
+		utxos   []Utxo
+		amount  int64
+		feeRate float64
+		// selected are the values of the selected utxos, in order.
+		selected []int64
+		fee      int64
+		change   int64
+		err      bool
+	}{
+		{
+			name:     "single utxo with change",
+			utxos:    []Utxo{utxo(100_000)},
+			amount:   50_000,
+			feeRate:  1,
+			selected: []int64{100_000},
+			fee:      141, // 11 + 68 + 2*31 vbytes
+			change:   49_859,
+		},
+		{
+			name:     "largest first",
+			utxos:    []Utxo{utxo(10_000), utxo(300_000), utxo(20_000)},
+			amount:   200_000,
+			feeRate:  2,
+			selected: []int64{300_000},
+			fee:      282,
+			change:   99_718,
+		},
+		{
+			name:     "several utxos",
+			utxos:    []Utxo{utxo(30_000), utxo(40_000), utxo(50_000)},
+			amount:   80_000,
+			feeRate:  1,
+			selected: []int64{50_000, 40_000},
+			fee:      209, // 11 + 2*68 + 2*31 vbytes
+			change:   9_791,
+		},
+		{
+			name:     "dust change goes to the fee",
+			utxos:    []Utxo{utxo(50_300)},
+			amount:   50_000,
+			feeRate:  1,
+			selected: []int64{50_300},
+			fee:      300,
+			change:   0,
+		},
+		{
+			name:     "exact amount plus fee",
+			utxos:    []Utxo{utxo(50_141)},
+			amount:   50_000,
+			feeRate:  1,
+			selected: []int64{50_141},
+			fee:      141,
+			change:   0,
+		},
+		{
+			name:    "fee rate rounds up",
+			utxos:   []Utxo{utxo(100_000)},
+			amount:  50_000,
+			feeRate: 1.5,
+			// 141 vbytes * 1.5 = 211.5
+			selected: []int64{100_000},
+			fee:      212,
+			change:   49_788,
+		},
+		{
+			name:    "insufficient funds",
+			utxos:   []Utxo{utxo(30_000), utxo(20_000)},
+			amount:  50_000,
+			feeRate: 1,
+			err:     true,
+		},
+		{
+			name:    "amount covered but not the fee",
+			utxos:   []Utxo{utxo(50_100)},
+			amount:  50_000,
+			feeRate: 1,
+			err:     true,
+		},
+		{name: "no utxos", amount: 1, feeRate: 1, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, fee, change, err := selectCoins(tt.utxos, tt.amount, tt.feeRate)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", selected)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(selected) != len(tt.selected) {
+				t.Fatalf("got %d utxos, want %v", len(selected), tt.selected)
+			}
+			total := int64(0)
+			for i, utxo := range selected {
+				if utxo.Value != tt.selected[i] {
+					t.Fatalf("utxo %d: got %d, want %d", i, utxo.Value, tt.selected[i])
+				}
+				total += utxo.Value
+			}
+			if fee != tt.fee || change != tt.change {
+				t.Fatalf("got fee %d and change %d, want %d and %d", fee, change, tt.fee, tt.change)
+			}
+			if total != tt.amount+fee+change {
+				t.Fatalf("inputs %d do not match amount %d + fee %d + change %d", total, tt.amount, fee, change)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package btc
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
+	"github.com/sisu-network/sisu-account-funding/core/store"
+)
+
+var (
+	SleepTime = time.Second * 60 * 30
+	// ExpectTimeout is how long a top-up may take to confirm and leave the faucet balance.
+	ExpectTimeout = time.Hour * 24
+
+	// DefaultPolicy funds 0.002 BTC whenever the balance drops below 0.001 BTC.
+	DefaultPolicy = funding.PolicyCfg{
+		Mode:      funding.ModeFixed,
+		Threshold: "100000",
+		Amount:    "200000",
+	}
+)
+
+type watcher struct {
+	mnemonic  string
+	chain     string
+	cfg       Cfg
+	params    *chaincfg.Params
+	client    Client
+	watchAddr *btcutil.AddressWitnessPubKeyHash
+	policy    *funding.Policy
+	burn      *funding.BurnTracker
+	limiter   *funding.Limiter
+	ledger    *ledger.Ledger
+	outflow   *funding.OutflowMonitor
+	audit     *audit.Trail
+	// spent holds the faucet outputs spent by our transactions that may still be reported as
+	// unspent until the transactions confirm. It is saved to spentFile so that a restart does not
+	// spend them again.
+	spent     map[string]bool
+	spentFile string
+	// pending is the last top-up until it confirms. It is saved to pendingFile so that a restart
+	// or the next leader does not fund the address again in the meantime.
+	pending     *pendingTx
+	pendingFile string
+	ctx         context.Context
+	cancel      context.CancelFunc
+	done        sync.WaitGroup
+}
+
+func NewWatcher(mnemonic string, chain string, client Client, cfg Cfg, pubkey []byte, spentFile string,
+	pendingFile string, policy *funding.Policy, burn *funding.BurnTracker, limiter *funding.Limiter, ledger *ledger.Ledger,
+	outflow *funding.OutflowMonitor, audit *audit.Trail) (*watcher, error) {
+	cfg = cfg.withDefaults()
+	params, err := cfg.Params()
+	if err != nil {
+		return nil, err
+	}
+
+	watchAddr, err := GetAddress(pubkey, params)
+	if err != nil {
+		return nil, err
+	}
+
+	spent := make(map[string]bool)
+	if err := store.Load(spentFile, &spent); err != nil {
+		return nil, fmt.Errorf("failed to load spent outputs from %s: %w", spentFile, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &watcher{
+		mnemonic:    mnemonic,
+		chain:       chain,
+		cfg:         cfg,
+		params:      params,
+		client:      client,
+		watchAddr:   watchAddr,
+		policy:      policy,
+		burn:        burn,
+		limiter:     limiter,
+		ledger:      ledger,
+		outflow:     outflow,
+		audit:       audit,
+		spent:       spent,
+		spentFile:   spentFile,
+		pendingFile: pendingFile,
+		ctx:         ctx,
+		cancel:      cancel,
+	}, nil
+}
+
+func (w *watcher) Start() {
+	log.Infof("Starting watcher for chain %s, watch address = %s", w.chain, w.watchAddr.EncodeAddress())
+	w.loadPending()
+
+	w.done.Add(1)
+	go w.loop()
+}
+
+// Stop stops the watcher and waits for the cycle in progress to finish.
+func (w *watcher) Stop() {
+	w.cancel()
+	w.done.Wait()
+}
+
+func (w *watcher) loop() {
+	defer w.done.Done()
+
+	for {
+		if w.ctx.Err() != nil {
+			return
+		}
+
+		// Do not fund again while the previous top-up is not confirmed, the balance does not count
+		// it yet.
+		if !w.checkPending() {
+			funding.Sleep(w.ctx, PendingPollTime)
+			continue
+		}
+
+		w.checkFaucet()
+
+		utxos, err := w.client.ListUnspent(w.watchAddr.EncodeAddress())
+		if err != nil {
+			log.Errorf("Failed to get utxos on chain %s, err = %s", w.chain, err)
+			w.audit.Unavailable(audit.Record{Address: w.watchAddr.EncodeAddress(), Threshold: w.policy.Threshold().String()}, err)
+		} else {
+			balance := big.NewInt(0)
+			for _, utxo := range utxos {
+				balance.Add(balance, big.NewInt(utxo.Value))
+			}
+			log.Verbosef("Balance in satoshi: %s on chain %s", balance, w.chain)
+
+			w.burn.Observe(balance)
+			shouldFund, reason := w.policy.ShouldFund(balance, w.burn)
+			record := audit.Record{
+				Address:   w.watchAddr.EncodeAddress(),
+				Balance:   balance.String(),
+				Threshold: w.policy.Threshold().String(),
+				Reason:    reason,
+			}
+			if amount := w.policy.Amount(balance); shouldFund && amount.Sign() > 0 {
+				log.Infof("Funding chain %s, reason: %s", w.chain, reason)
+				w.fund(amount, record)
+			} else {
+				record.Decision = audit.Skip
+				w.audit.Record(record)
+			}
+		}
+
+		if !funding.Sleep(w.ctx, SleepTime) {
+			return
+		}
+	}
+}
+
+// checkFaucet reports the faucet balance to the outflow monitor. Bitcoin has no account nonce, so
+// only the balance is compared.
+func (w *watcher) checkFaucet() {
+	if !w.outflow.Enabled() {
+		return
+	}
+
+	privKey, err := getPrivateKey(w.mnemonic, w.params)
+	if err != nil {
+		log.Errorf("Failed to get faucet key on chain %s, err = %s", w.chain, err)
+		return
+	}
+	faucetAddr, err := GetAddress(privKey.PubKey().SerializeCompressed(), w.params)
+	if err != nil {
+		log.Errorf("Failed to get faucet address on chain %s, err = %s", w.chain, err)
+		return
+	}
+
+	utxos, err := w.client.ListUnspent(faucetAddr.EncodeAddress())
+	if err != nil {
+		log.Errorf("Failed to get faucet utxos on chain %s, err = %s", w.chain, err)
+		return
+	}
+
+	balance := big.NewInt(0)
+	for _, utxo := range utxos {
+		balance.Add(balance, big.NewInt(utxo.Value))
+	}
+	w.outflow.Observe(funding.FaucetState{Address: faucetAddr.EncodeAddress(), Balance: balance})
+}
+
+func (w *watcher) fund(amount *big.Int, record audit.Record) {
+	record.Amount = amount.String()
+	if !amount.IsInt64() {
+		log.Errorf("Funding amount %s is out of range on chain %s", amount, w.chain)
+		w.audit.Failed(record, fmt.Errorf("amount %s is out of range", amount))
+		return
+	}
+
+	reservation, err := w.limiter.Reserve(w.chain, w.watchAddr.EncodeAddress(), amount)
+	if err != nil {
+		log.Errorf("Cannot fund chain %s, err = %s", w.chain, err)
+		w.audit.Refused(record, err)
+		return
+	}
+
+	txId, err := w.transfer(amount.Int64())
+	if err != nil {
+		reservation.Release()
+		log.Errorf("Failed to fund chain %s, err = %s", w.chain, err)
+		w.audit.Failed(record, err)
+		return
+	}
+	reservation.Commit()
+
+	w.burn.RecordTopUp()
+	log.Infof("Bitcoin txId = %s on chain %s", txId, w.chain)
+	w.audit.Funded(record, txId)
+
+	// buildTransfer pays the watched address in the first output.
+	w.setPending(&pendingTx{TxId: txId, Vout: 0, SentAt: time.Now()})
+}
+
+// transfer sends amount satoshi from the faucet to the watched address.
+func (w *watcher) transfer(amount int64) (string, error) {
+	privKey, err := getPrivateKey(w.mnemonic, w.params)
+	if err != nil {
+		return "", err
+	}
+
+	faucetAddr, err := GetAddress(privKey.PubKey().SerializeCompressed(), w.params)
+	if err != nil {
+		return "", err
+	}
+
+	utxos, err := w.client.ListUnspent(faucetAddr.EncodeAddress())
+	if err != nil {
+		return "", err
+	}
+
+	// Forget the outputs that are not reported anymore, their transactions confirmed.
+	available := make([]Utxo, 0, len(utxos))
+	reported := make(map[string]bool)
+	for _, utxo := range utxos {
+		key := fmt.Sprintf("%s:%d", utxo.TxId, utxo.Vout)
+		reported[key] = true
+		if !w.spent[key] {
+			available = append(available, utxo)
+		}
+	}
+	for key := range w.spent {
+		if !reported[key] {
+			delete(w.spent, key)
+		}
+	}
+	w.saveSpent()
+
+	feeRate, err := w.client.EstimateFeeRate(w.cfg.FeeTarget)
+	if err != nil {
+		log.Warnf("Cannot estimate fee rate on chain %s, using %f sat/vB, err = %s", w.chain,
+			w.cfg.MinFeeRate, err)
+		feeRate = w.cfg.MinFeeRate
+	}
+	if feeRate < w.cfg.MinFeeRate {
+		feeRate = w.cfg.MinFeeRate
+	}
+	if feeRate > w.cfg.MaxFeeRate {
+		feeRate = w.cfg.MaxFeeRate
+	}
+
+	tx, fee, err := buildTransfer(privKey, faucetAddr, w.watchAddr, available, amount, feeRate)
+	if err != nil {
+		return "", err
+	}
+	log.Infof("Funding %d satoshi from %s to %s on chain %s, fee = %d", amount, faucetAddr.EncodeAddress(),
+		w.watchAddr.EncodeAddress(), w.chain, fee)
+
+	bz, err := serializeTx(tx)
+	if err != nil {
+		return "", err
+	}
+
+	txId, err := w.client.Broadcast(bz)
+	if err != nil {
+		return "", err
+	}
+
+	for _, txIn := range tx.TxIn {
+		w.spent[txIn.PreviousOutPoint.String()] = true
+	}
+	w.saveSpent()
+	w.outflow.Expect(big.NewInt(amount), big.NewInt(fee))
+
+	if err := w.ledger.Record(ledger.Entry{
+		Chain:  w.chain,
+		TxHash: txId,
+		From:   faucetAddr.EncodeAddress(),
+		To:     w.watchAddr.EncodeAddress(),
+		Amount: fmt.Sprintf("%d", amount),
+		Label:  "mpc",
+	}); err != nil {
+		log.Errorf("Failed to record transfer %s on chain %s, err = %s", txId, w.chain, err)
+	}
+
+	return txId, nil
+}
+
+func (w *watcher) saveSpent() {
+	if err := store.Save(w.spentFile, w.spent); err != nil {
+		log.Errorf("Failed to save spent outputs to %s, err = %s", w.spentFile, err)
+	}
+}
@@ -0,0 +1,214 @@
+package btc
+
+import (
+	"bytes"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/sisu-network/sisu-account-funding/core/alert"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// newTestWatcher returns a regtest watcher talking to the stand-in at url, and the address of its
+// faucet. Its state is kept in dataDir.
+func newTestWatcher(t *testing.T, url, dataDir string) (*watcher, string) {
+	t.Helper()
+
+	mpcKey, _ := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{1}, 32))
+	cfg := Cfg{Network: "regtest"}
+	policy, err := funding.NewPolicy(funding.PolicyCfg{}, DefaultPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerter := alert.NewAlerter("")
+	limiter, err := funding.NewLimiter(filepath.Join(dataDir, "limiter.json"), funding.GlobalLimitCfg{}, nil, alerter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWatcher(testMnemonic, "btc-regtest", NewClient(url, cfg), cfg, mpcKey.PubKey().SerializeCompressed(),
+		filepath.Join(dataDir, "spent.json"), filepath.Join(dataDir, "pending.json"), policy, funding.NewBurnTracker(filepath.Join(dataDir, "burn.json"), 0),
+		limiter, ledger.NewLedger(filepath.Join(dataDir, "ledger.jsonl")),
+		funding.NewOutflowMonitor("btc-regtest", funding.OutflowCfg{Disabled: true}, limiter, alerter),
+		(*audit.Log)(nil).Trail("btc-regtest", url))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privKey, err := getPrivateKey(testMnemonic, w.params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	faucet, err := GetAddress(privKey.PubKey().SerializeCompressed(), w.params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w, faucet.EncodeAddress()
+}
+
+func TestTransfer(t *testing.T) {
+	standIn, url := newStandIn(t)
+	w, faucet := newTestWatcher(t, url, t.TempDir())
+	standIn.addUtxo(faucet, Utxo{TxId: strings.Repeat("11", 32), Vout: 0, Value: 1_000_000})
+
+	txId, err := w.transfer(200_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	standIn.mine()
+
+	txs := standIn.broadcast()
+	if len(txs) != 1 || txs[0].TxHash().String() != txId {
+		t.Fatalf("got %d transactions, want %s", len(txs), txId)
+	}
+	if balance := standIn.balance(w.watchAddr.EncodeAddress()); balance != 200_000 {
+		t.Fatalf("got mpc balance %d, want 200000", balance)
+	}
+	// 10 sat/vB for one input and two outputs.
+	if balance := standIn.balance(faucet); balance != 1_000_000-200_000-1410 {
+		t.Fatalf("got faucet balance %d", balance)
+	}
+}
+
+func TestTransferDoesNotSpendTwice(t *testing.T) {
+	tests := []struct {
+		name string
+		// restart rebuilds the watcher from its data dir between the transfers.
+		restart bool
+	}{
+		{name: "same watcher"},
+		{name: "after a restart", restart: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn, url := newStandIn(t)
+			dataDir := t.TempDir()
+			w, faucet := newTestWatcher(t, url, dataDir)
+			standIn.addUtxo(faucet, Utxo{TxId: strings.Repeat("11", 32), Vout: 0, Value: 1_000_000})
+			standIn.addUtxo(faucet, Utxo{TxId: strings.Repeat("22", 32), Vout: 1, Value: 300_000})
+
+			if _, err := w.transfer(500_000); err != nil {
+				t.Fatal(err)
+			}
+			if tt.restart {
+				w, _ = newTestWatcher(t, url, dataDir)
+			}
+
+			// The large output is still reported unspent until the first transfer confirms, the
+			// second transfer must use the other one.
+			if _, err := w.transfer(200_000); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.transfer(200_000); err == nil {
+				t.Fatal("spent the faucet outputs twice")
+			}
+
+			standIn.mine()
+			if len(standIn.broadcast()) != 2 {
+				t.Fatalf("got %d transactions, want 2", len(standIn.broadcast()))
+			}
+
+			// Once confirmed, the change outputs can be spent and the spent outputs are forgotten.
+			if _, err := w.transfer(200_000); err != nil {
+				t.Fatal(err)
+			}
+			if len(w.spent) != 1 {
+				t.Fatalf("got %d spent outputs, want 1", len(w.spent))
+			}
+		})
+	}
+}
+
+func TestFund(t *testing.T) {
+	standIn, url := newStandIn(t)
+	w, faucet := newTestWatcher(t, url, t.TempDir())
+	standIn.addUtxo(faucet, Utxo{TxId: strings.Repeat("11", 32), Vout: 0, Value: 1_000_000})
+
+	w.fund(big.NewInt(200_000), audit.Record{})
+	standIn.mine()
+
+	if balance := standIn.balance(w.watchAddr.EncodeAddress()); balance != 200_000 {
+		t.Fatalf("got mpc balance %d, want 200000", balance)
+	}
+}
+
+func TestCheckPending(t *testing.T) {
+	tests := []struct {
+		name string
+		// resolve is what happens to the top-up before it is checked.
+		resolve func(s *standIn, w *watcher)
+		// restart rebuilds the watcher from its data dir before the check.
+		restart bool
+		done    bool
+	}{
+		{
+			name:    "top-up in the mempool is pending",
+			resolve: func(s *standIn, w *watcher) {},
+		},
+		{
+			name:    "top-up in the mempool is still pending after a restart",
+			resolve: func(s *standIn, w *watcher) {},
+			restart: true,
+		},
+		{
+			name:    "confirmed top-up is done",
+			resolve: func(s *standIn, w *watcher) { s.mine() },
+			done:    true,
+		},
+		{
+			name:    "confirmed top-up is done after a restart",
+			resolve: func(s *standIn, w *watcher) { s.mine() },
+			restart: true,
+			done:    true,
+		},
+		{
+			name: "confirmed top-up already spent is done",
+			resolve: func(s *standIn, w *watcher) {
+				s.mine()
+				s.spend(w.watchAddr.EncodeAddress())
+			},
+			done: true,
+		},
+		{
+			name:    "dropped top-up is done",
+			resolve: func(s *standIn, w *watcher) { s.drop() },
+			done:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn, url := newStandIn(t)
+			dataDir := t.TempDir()
+			w, faucet := newTestWatcher(t, url, dataDir)
+			standIn.addUtxo(faucet, Utxo{TxId: strings.Repeat("11", 32), Vout: 0, Value: 1_000_000})
+
+			w.fund(big.NewInt(200_000), audit.Record{})
+			if w.pending == nil {
+				t.Fatal("top-up is not pending")
+			}
+
+			tt.resolve(standIn, w)
+			if tt.restart {
+				w, _ = newTestWatcher(t, url, dataDir)
+				w.loadPending()
+			}
+
+			if done := w.checkPending(); done != tt.done {
+				t.Fatalf("done = %v, want %v", done, tt.done)
+			}
+			if (w.pending == nil) != tt.done {
+				t.Fatalf("pending = %v, want done = %v", w.pending, tt.done)
+			}
+		})
+	}
+}
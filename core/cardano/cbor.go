@@ -0,0 +1,118 @@
+package cardano
+
+import (
+	"fmt"
+	"math"
+)
+
+// The CBOR major types used by transactions.
+const (
+	cborUint  = byte(0)
+	cborBytes = byte(2)
+	cborArray = byte(4)
+	cborMap   = byte(5)
+)
+
+// cborHead returns the head of a CBOR item in its shortest form.
+func cborHead(major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return []byte{major | byte(n)}
+	case n <= math.MaxUint8:
+		return []byte{major | 24, byte(n)}
+	case n <= math.MaxUint16:
+		return []byte{major | 25, byte(n >> 8), byte(n)}
+	case n <= math.MaxUint32:
+		return []byte{major | 26, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	default:
+		return []byte{major | 27, byte(n >> 56), byte(n >> 48), byte(n >> 40), byte(n >> 32),
+			byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+}
+
+func encodeUint(n uint64) []byte {
+	return cborHead(cborUint, n)
+}
+
+func encodeBytes(bz []byte) []byte {
+	return append(cborHead(cborBytes, uint64(len(bz))), bz...)
+}
+
+func encodeArray(items ...[]byte) []byte {
+	bz := cborHead(cborArray, uint64(len(items)))
+	for _, item := range items {
+		bz = append(bz, item...)
+	}
+
+	return bz
+}
+
+// encodeUintMap encodes a map with the keys 0..len(values)-1, which is the shape of transaction
+// bodies and witness sets.
+func encodeUintMap(values ...[]byte) []byte {
+	bz := cborHead(cborMap, uint64(len(values)))
+	for i, value := range values {
+		bz = append(bz, encodeUint(uint64(i))...)
+		bz = append(bz, value...)
+	}
+
+	return bz
+}
+
+// cborItemSize returns the number of bytes of the definite-length CBOR item at the start of bz.
+func cborItemSize(bz []byte) (int, error) {
+	if len(bz) == 0 {
+		return 0, fmt.Errorf("empty cbor item")
+	}
+
+	major, info := bz[0]>>5, bz[0]&0x1f
+	size, n := 1, uint64(info)
+	switch {
+	case info < 24:
+	case info <= 27:
+		width := 1 << (info - 24)
+		if len(bz) < 1+width {
+			return 0, fmt.Errorf("truncated cbor item")
+		}
+		n = 0
+		for _, b := range bz[1 : 1+width] {
+			n = n<<8 | uint64(b)
+		}
+		size += width
+	default:
+		return 0, fmt.Errorf("unsupported cbor item 0x%x", bz[0])
+	}
+
+	switch major {
+	case 2, 3: // byte and text strings
+		size += int(n)
+	case 4, 5: // arrays and maps
+		items := n
+		if major == 5 {
+			items *= 2
+		}
+		for i := uint64(0); i < items; i++ {
+			if size > len(bz) {
+				return 0, fmt.Errorf("truncated cbor item")
+			}
+			itemSize, err := cborItemSize(bz[size:])
+			if err != nil {
+				return 0, err
+			}
+			size += itemSize
+		}
+	case 6: // tags
+		itemSize, err := cborItemSize(bz[size:])
+		if err != nil {
+			return 0, err
+		}
+		size += itemSize
+	}
+
+	if size > len(bz) {
+		return 0, fmt.Errorf("truncated cbor item")
+	}
+
+	return size, nil
+}
@@ -0,0 +1,118 @@
+package cardano
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestCborHead(t *testing.T) {
+	// The examples of RFC 8949 appendix A.
+	tests := []struct {
+		n    uint64
+		want string
+	}{
+		{n: 0, want: "00"},
+		{n: 23, want: "17"},
+		{n: 24, want: "1818"},
+		{n: 255, want: "18ff"},
+		{n: 256, want: "190100"},
+		{n: 65535, want: "19ffff"},
+		{n: 65536, want: "1a00010000"},
+		{n: 1000000, want: "1a000f4240"},
+		{n: 1 << 32, want: "1b0000000100000000"},
+		{n: 1000000000000, want: "1b000000e8d4a51000"},
+	}
+
+	for _, tt := range tests {
+		if got := hex.EncodeToString(encodeUint(tt.n)); got != tt.want {
+			t.Errorf("encodeUint(%d): got %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{name: "bytes", got: encodeBytes([]byte{1, 2, 3, 4}), want: "4401020304"},
+		{name: "empty array", got: encodeArray(), want: "80"},
+		{name: "nested array", got: encodeArray(encodeUint(1), encodeArray(encodeUint(2), encodeUint(3))), want: "8201820203"},
+		{name: "uint map", got: encodeUintMap(encodeUint(1), encodeBytes(nil)), want: "a200010140"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hex.EncodeToString(tt.got); got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCborItemSize(t *testing.T) {
+	tests := []struct {
+		name string
+		item string
+		size int
+		err  bool
+	}{
+		{name: "small uint", item: "17", size: 1},
+		{name: "uint64", item: "1b000000e8d4a51000", size: 9},
+		{name: "bytes", item: "4401020304", size: 5},
+		{name: "text", item: "6449455446", size: 5},
+		{name: "nested array", item: "8201820203", size: 5},
+		{name: "map", item: "a200010140", size: 5},
+		{name: "tag", item: "c11a514b67b0", size: 6},
+		{name: "trailing data is not counted", item: "0102", size: 1},
+		{name: "truncated bytes", item: "44010203", err: true},
+		{name: "truncated array", item: "8201", err: true},
+		{name: "truncated head", item: "19ff", err: true},
+		{name: "indefinite length", item: "9fff", err: true},
+		{name: "empty", item: "", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bz, _ := hex.DecodeString(tt.item)
+			size, err := cborItemSize(bz)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got size %d", size)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != tt.size {
+				t.Fatalf("got size %d, want %d", size, tt.size)
+			}
+		})
+	}
+}
+
+func TestSignTx(t *testing.T) {
+	body := encodeUintMap(encodeArray(), encodeArray(), encodeUint(170000), encodeUint(100))
+	_, key := testKey(1)
+
+	tx, hash := signTx(body, key)
+	size, err := cborItemSize(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != len(tx) {
+		t.Fatalf("got item size %d, transaction size %d", size, len(tx))
+	}
+	// [body, witness set, true, null]
+	if tx[0] != 0x84 || !bytes.Equal(tx[1:1+len(body)], body) || !bytes.HasSuffix(tx, []byte{0xf5, 0xf6}) {
+		t.Fatalf("unexpected transaction %x", tx)
+	}
+
+	bodyHash, _ := hex.DecodeString(hash)
+	if err := verifyWitness(tx[1+len(body):], bodyHash); err != nil {
+		t.Fatal(err)
+	}
+}
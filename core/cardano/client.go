@@ -0,0 +1,193 @@
+package cardano
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when the api does not know the requested transaction.
+var ErrNotFound = fmt.Errorf("not found")
+
+// Utxo is an unspent output holding only lovelace.
+type Utxo struct {
+	TxHash   string
+	Index    uint32
+	Lovelace uint64
+	// HasAssets is true if the output also holds native tokens. The faucet does not spend such
+	// outputs since it would have to return the tokens in its change.
+	HasAssets bool
+}
+
+// Params are the protocol parameters needed to build a transfer.
+type Params struct {
+	MinFeeA          uint64
+	MinFeeB          uint64
+	CoinsPerUtxoByte uint64
+	MaxTxSize        uint64
+}
+
+// Tip is the latest block of the chain.
+type Tip struct {
+	Height int64
+	Slot   uint64
+}
+
+// Client is the part of the Blockfrost api used by the watcher. It lets the watcher run against
+// Blockfrost, a self-hosted compatible api or a stand-in in tests.
+type Client interface {
+	GetUtxos(address string) ([]Utxo, error)
+	GetParams() (*Params, error)
+	GetTip() (*Tip, error)
+	// GetTxHeight returns the height of the block including the transaction, or ErrNotFound.
+	GetTxHeight(hash string) (int64, error)
+	Submit(tx []byte) (string, error)
+}
+
+type blockfrostClient struct {
+	url       string
+	projectId string
+	client    *http.Client
+}
+
+func NewClient(url string, cfg Cfg) Client {
+	return &blockfrostClient{
+		url:       strings.TrimSuffix(url, "/"),
+		projectId: cfg.ProjectId,
+		client:    &http.Client{Timeout: time.Second * 30},
+	}
+}
+
+func (c *blockfrostClient) do(method, path, contentType string, body io.Reader, result interface{}) error {
+	req, err := http.NewRequest(method, c.url+path, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.projectId != "" {
+		req.Header.Set("project_id", c.projectId)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	bz, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s failed with status %d: %s", method, path, res.StatusCode, string(bz))
+	}
+
+	return json.Unmarshal(bz, result)
+}
+
+func (c *blockfrostClient) GetUtxos(address string) ([]Utxo, error) {
+	utxos := make([]Utxo, 0)
+	for page := 1; ; page++ {
+		res := make([]struct {
+			TxHash      string `json:"tx_hash"`
+			OutputIndex uint32 `json:"output_index"`
+			Amount      []struct {
+				Unit     string `json:"unit"`
+				Quantity string `json:"quantity"`
+			} `json:"amount"`
+		}, 0)
+		err := c.do("GET", fmt.Sprintf("/addresses/%s/utxos?page=%d", address, page), "", nil, &res)
+		if err == ErrNotFound {
+			// The address has never been used.
+			return utxos, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, output := range res {
+			utxo := Utxo{TxHash: output.TxHash, Index: output.OutputIndex}
+			for _, amount := range output.Amount {
+				if amount.Unit != "lovelace" {
+					utxo.HasAssets = true
+					continue
+				}
+
+				utxo.Lovelace, err = strconv.ParseUint(amount.Quantity, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid lovelace quantity %q", amount.Quantity)
+				}
+			}
+			utxos = append(utxos, utxo)
+		}
+
+		// Blockfrost returns at most 100 items per page.
+		if len(res) < 100 {
+			return utxos, nil
+		}
+	}
+}
+
+func (c *blockfrostClient) GetParams() (*Params, error) {
+	res := &struct {
+		MinFeeA          uint64 `json:"min_fee_a"`
+		MinFeeB          uint64 `json:"min_fee_b"`
+		CoinsPerUtxoSize string `json:"coins_per_utxo_size"`
+		MaxTxSize        uint64 `json:"max_tx_size"`
+	}{}
+	if err := c.do("GET", "/epochs/latest/parameters", "", nil, res); err != nil {
+		return nil, err
+	}
+
+	coinsPerUtxoByte, err := strconv.ParseUint(res.CoinsPerUtxoSize, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid coins_per_utxo_size %q", res.CoinsPerUtxoSize)
+	}
+
+	return &Params{
+		MinFeeA:          res.MinFeeA,
+		MinFeeB:          res.MinFeeB,
+		CoinsPerUtxoByte: coinsPerUtxoByte,
+		MaxTxSize:        res.MaxTxSize,
+	}, nil
+}
+
+func (c *blockfrostClient) GetTip() (*Tip, error) {
+	res := &struct {
+		Height int64  `json:"height"`
+		Slot   uint64 `json:"slot"`
+	}{}
+	if err := c.do("GET", "/blocks/latest", "", nil, res); err != nil {
+		return nil, err
+	}
+
+	return &Tip{Height: res.Height, Slot: res.Slot}, nil
+}
+
+func (c *blockfrostClient) GetTxHeight(hash string) (int64, error) {
+	res := &struct {
+		BlockHeight int64 `json:"block_height"`
+	}{}
+	if err := c.do("GET", "/txs/"+hash, "", nil, res); err != nil {
+		return 0, err
+	}
+
+	return res.BlockHeight, nil
+}
+
+func (c *blockfrostClient) Submit(tx []byte) (string, error) {
+	var hash string
+	err := c.do("POST", "/tx/submit", "application/cbor", bytes.NewReader(tx), &hash)
+
+	return hash, err
+}
@@ -0,0 +1,71 @@
+package cardano
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	DefaultConfirmations = 3
+	DefaultTtlSlots      = 7200 // 2 hours
+)
+
+// Cfg holds the Cardano specific settings of a chain.
+type Cfg struct {
+	// Network is mainnet, preprod or preview. It defaults to mainnet for cardano-mainnet and to
+	// preprod for the other chains.
+	Network string `toml:"network" json:"network"`
+	// ProjectId is sent in the project_id header of the Blockfrost api. Self-hosted
+	// Blockfrost-compatible apis usually do not need it.
+	ProjectId string `toml:"project_id" json:"project_id"`
+
+	// Confirmations is the number of blocks after which a transfer is considered final.
+	Confirmations int64 `toml:"confirmations" json:"confirmations"`
+	// TtlSlots is the number of slots a transfer stays valid for. A transfer that is not on chain
+	// once its ttl passed can never be, and the watcher funds again.
+	TtlSlots uint64 `toml:"ttl_slots" json:"ttl_slots"`
+}
+
+func (cfg Cfg) withDefaults(chain string) Cfg {
+	if cfg.Network == "" {
+		if chain == "cardano-mainnet" {
+			cfg.Network = "mainnet"
+		} else {
+			cfg.Network = "preprod"
+		}
+	}
+	if cfg.Confirmations == 0 {
+		cfg.Confirmations = DefaultConfirmations
+	}
+	if cfg.TtlSlots == 0 {
+		cfg.TtlSlots = DefaultTtlSlots
+	}
+
+	return cfg
+}
+
+func (cfg Cfg) Validate(chain string) error {
+	cfg = cfg.withDefaults(chain)
+	if _, err := cfg.NetworkId(chain); err != nil {
+		return err
+	}
+	if cfg.Confirmations < 0 {
+		return fmt.Errorf("invalid confirmations %d", cfg.Confirmations)
+	}
+
+	return nil
+}
+
+// NetworkId returns the network id of the address header, 1 for mainnet and 0 for the test
+// networks.
+func (cfg Cfg) NetworkId(chain string) (byte, error) {
+	cfg = cfg.withDefaults(chain)
+	switch strings.ToLower(cfg.Network) {
+	case "mainnet":
+		return 1, nil
+	case "preprod", "preview", "testnet":
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("unknown cardano network %q", cfg.Network)
+	}
+}
@@ -0,0 +1,61 @@
+package cardano
+
+import (
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/store"
+)
+
+// pendingTx is a transfer that is not final yet.
+type pendingTx struct {
+	Hash string `json:"hash"`
+	// Ttl is the slot after which the transfer can no longer be included.
+	Ttl uint64 `json:"ttl"`
+}
+
+func (w *watcher) loadPending() {
+	pending := &pendingTx{}
+	if err := store.Load(w.pendingFile, pending); err != nil {
+		log.Errorf("Failed to load pending transfer from %s, err = %s", w.pendingFile, err)
+		return
+	}
+
+	if pending.Hash != "" {
+		log.Infof("Resuming tracking of transfer %s on chain %s", pending.Hash, w.chain)
+		w.pending = pending
+	}
+}
+
+func (w *watcher) setPending(pending *pendingTx) {
+	w.pending = pending
+	if pending == nil {
+		pending = &pendingTx{}
+	}
+
+	if err := store.Save(w.pendingFile, pending); err != nil {
+		log.Errorf("Failed to save pending transfer to %s, err = %s", w.pendingFile, err)
+	}
+}
+
+// checkPending clears the pending transfer once it has enough confirmations or can no longer be
+// included.
+func (w *watcher) checkPending() {
+	tip, err := w.client.GetTip()
+	if err != nil {
+		log.Errorf("Failed to get tip on chain %s, err = %s", w.chain, err)
+		return
+	}
+
+	height, err := w.client.GetTxHeight(w.pending.Hash)
+	switch {
+	case err == ErrNotFound:
+		if tip.Slot > w.pending.Ttl {
+			log.Errorf("Transfer %s expired on chain %s", w.pending.Hash, w.chain)
+			w.setPending(nil)
+		}
+	case err != nil:
+		log.Errorf("Failed to get transfer %s on chain %s, err = %s", w.pending.Hash, w.chain, err)
+	case tip.Height-height+1 >= w.cfg.Confirmations:
+		log.Infof("Transfer %s is final on chain %s", w.pending.Hash, w.chain)
+		w.setPending(nil)
+	}
+}
@@ -0,0 +1,70 @@
+package cardano
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/cosmos/go-bip39"
+	"golang.org/x/crypto/blake2b"
+)
+
+// enterpriseKeyHeader is the header of an enterprise address with a key hash payment part and no
+// stake part, the low 4 bits hold the network id.
+const enterpriseKeyHeader = byte(0x60)
+
+// addressBytes returns the enterprise address of an ed25519 public key.
+func addressBytes(pubkey []byte, networkId byte) ([]byte, error) {
+	hash, err := blake2b.New(28, nil)
+	if err != nil {
+		return nil, err
+	}
+	hash.Write(pubkey)
+
+	return append([]byte{enterpriseKeyHeader | networkId}, hash.Sum(nil)...), nil
+}
+
+// GetAddress returns the bech32 enterprise address of an ed25519 public key.
+func GetAddress(pubkey []byte, networkId byte) (string, error) {
+	bz, err := addressBytes(pubkey, networkId)
+	if err != nil {
+		return "", err
+	}
+
+	hrp := "addr"
+	if networkId == 0 {
+		hrp = "addr_test"
+	}
+
+	return bech32.EncodeFromBase256(hrp, bz)
+}
+
+// getPrivateKey derives the faucet key from the mnemonic with the m/1852'/1815'/0'/0'/0' path.
+// The derivation is SLIP-0010, which only supports hardened indexes, so the faucet address differs
+// from the one of the same mnemonic in Cardano wallets.
+func getPrivateKey(mnemonic string) (ed25519.PrivateKey, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha512.New, []byte("ed25519 seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := sum[:32], sum[32:]
+
+	for _, index := range []uint32{1852, 1815, 0, 0, 0} {
+		data := make([]byte, 37)
+		copy(data[1:33], key)
+		binary.BigEndian.PutUint32(data[33:], index|0x80000000)
+
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+		key, chainCode = sum[:32], sum[32:]
+	}
+
+	return ed25519.NewKeyFromSeed(key), nil
+}
@@ -0,0 +1,223 @@
+package cardano
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// standIn is a stand-in for the part of the Blockfrost api used by the watcher. It checks the
+// witness of the submitted transactions but does not update the utxos: submitted transactions are
+// recorded and included in the next block.
+type standIn struct {
+	lock      sync.Mutex
+	params    Params
+	tip       Tip
+	utxos     map[string][]Utxo
+	txHeights map[string]int64
+	txs       [][]byte
+}
+
+func newStandIn(t *testing.T) (*standIn, string) {
+	s := &standIn{
+		// Mainnet parameters at the Babbage hard fork.
+		params: Params{
+			MinFeeA:          44,
+			MinFeeB:          155381,
+			CoinsPerUtxoByte: 4310,
+			MaxTxSize:        16384,
+		},
+		utxos:     make(map[string][]Utxo),
+		txHeights: make(map[string]int64),
+	}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+
+	return s, server.URL
+}
+
+// addUtxo adds an unspent output of lovelace to address.
+func (s *standIn) addUtxo(address string, lovelace uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	hash := blake2b.Sum256([]byte(fmt.Sprintf("%s:%d", address, len(s.utxos[address]))))
+	s.utxos[address] = append(s.utxos[address], Utxo{
+		TxHash:   hex.EncodeToString(hash[:]),
+		Index:    0,
+		Lovelace: lovelace,
+	})
+}
+
+// setUtxos replaces the unspent outputs of address.
+func (s *standIn) setUtxos(address string, utxos []Utxo) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.utxos[address] = utxos
+}
+
+// addBlocks moves the tip forward by n blocks of 20 slots.
+func (s *standIn) addBlocks(n int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.tip.Height += n
+	s.tip.Slot += uint64(n) * 20
+}
+
+// submitted returns the transactions submitted so far.
+func (s *standIn) submitted() [][]byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([][]byte{}, s.txs...)
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	path := r.URL.Path
+	switch {
+	case r.Method == "GET" && strings.HasPrefix(path, "/addresses/") && strings.HasSuffix(path, "/utxos"):
+		address := strings.TrimSuffix(strings.TrimPrefix(path, "/addresses/"), "/utxos")
+		if r.URL.Query().Get("page") != "" && r.URL.Query().Get("page") != "1" {
+			writeJSON(w, []interface{}{})
+			return
+		}
+		utxos, ok := s.utxos[address]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		res := make([]map[string]interface{}, 0, len(utxos))
+		for _, utxo := range utxos {
+			res = append(res, map[string]interface{}{
+				"tx_hash":      utxo.TxHash,
+				"output_index": utxo.Index,
+				"amount": []map[string]string{
+					{"unit": "lovelace", "quantity": fmt.Sprintf("%d", utxo.Lovelace)},
+				},
+			})
+		}
+		writeJSON(w, res)
+
+	case r.Method == "GET" && path == "/epochs/latest/parameters":
+		writeJSON(w, map[string]interface{}{
+			"min_fee_a":           s.params.MinFeeA,
+			"min_fee_b":           s.params.MinFeeB,
+			"coins_per_utxo_size": fmt.Sprintf("%d", s.params.CoinsPerUtxoByte),
+			"max_tx_size":         s.params.MaxTxSize,
+		})
+
+	case r.Method == "GET" && path == "/blocks/latest":
+		writeJSON(w, map[string]interface{}{"height": s.tip.Height, "slot": s.tip.Slot})
+
+	case r.Method == "GET" && strings.HasPrefix(path, "/txs/"):
+		height, ok := s.txHeights[strings.TrimPrefix(path, "/txs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"block_height": height})
+
+	case r.Method == "POST" && path == "/tx/submit":
+		tx, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// The transaction is an array whose first item is the body.
+		if len(tx) == 0 || tx[0]>>5 != cborArray {
+			http.Error(w, "transaction is not a cbor array", http.StatusBadRequest)
+			return
+		}
+		bodySize, err := cborItemSize(tx[1:])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		hash := blake2b.Sum256(tx[1 : 1+bodySize])
+		if err := verifyWitness(tx[1+bodySize:], hash[:]); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if minFee := s.params.MinFeeA*uint64(len(tx)) + s.params.MinFeeB; decodeFee(tx[1:1+bodySize]) < minFee {
+			http.Error(w, fmt.Sprintf("fee is below the min fee %d", minFee), http.StatusBadRequest)
+			return
+		}
+		txHash := hex.EncodeToString(hash[:])
+		s.txs = append(s.txs, tx)
+		s.txHeights[txHash] = s.tip.Height + 1
+		writeJSON(w, txHash)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// verifyWitness checks that the witness set at the start of bz holds a single vkey witness signing
+// the body hash.
+func verifyWitness(bz []byte, hash []byte) error {
+	// {0: [[vkey, signature]]}
+	prefix := []byte{0xa1, 0x00, 0x81, 0x82, 0x58, ed25519.PublicKeySize}
+	if len(bz) < len(prefix)+ed25519.PublicKeySize+2+ed25519.SignatureSize || !bytes.HasPrefix(bz, prefix) {
+		return fmt.Errorf("missing vkey witness")
+	}
+	pubkey := bz[len(prefix) : len(prefix)+ed25519.PublicKeySize]
+	signature := bz[len(prefix)+ed25519.PublicKeySize+2 : len(prefix)+ed25519.PublicKeySize+2+ed25519.SignatureSize]
+	if !ed25519.Verify(pubkey, hash, signature) {
+		return fmt.Errorf("invalid witness signature")
+	}
+
+	return nil
+}
+
+// decodeFee returns the fee of a transaction body, the value of its key 2.
+func decodeFee(body []byte) uint64 {
+	pos := 1
+	for i := 0; i < int(body[0]&0x1f); i++ {
+		keySize, _ := cborItemSize(body[pos:])
+		key := body[pos]
+		pos += keySize
+		valueSize, _ := cborItemSize(body[pos:])
+		if key == 2 {
+			return decodeUint(body[pos : pos+valueSize])
+		}
+		pos += valueSize
+	}
+
+	return 0
+}
+
+// decodeUint decodes a CBOR unsigned integer.
+func decodeUint(bz []byte) uint64 {
+	if len(bz) == 1 {
+		return uint64(bz[0] & 0x1f)
+	}
+
+	n := uint64(0)
+	for _, b := range bz[1:] {
+		n = n<<8 | uint64(b)
+	}
+
+	return n
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
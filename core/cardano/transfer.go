@@ -0,0 +1,144 @@
+package cardano
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// utxoOverhead is the number of bytes added to the size of an output when computing its minimum
+// lovelace (Babbage rules).
+const utxoOverhead = 160
+
+func encodeOutput(address []byte, lovelace uint64) []byte {
+	return encodeArray(encodeBytes(address), encodeUint(lovelace))
+}
+
+// minUtxo returns the smallest amount of lovelace an output holding lovelace can carry.
+func minUtxo(params *Params, address []byte, lovelace uint64) uint64 {
+	return (utxoOverhead + uint64(len(encodeOutput(address, lovelace)))) * params.CoinsPerUtxoByte
+}
+
+type transferBody struct {
+	inputs    []Utxo
+	recipient []byte
+	amount    uint64
+	change    []byte
+	// changeAmount is 0 when the change is too small for an output and is left to the fee.
+	changeAmount uint64
+	fee          uint64
+	ttl          uint64
+}
+
+func (b *transferBody) encode() ([]byte, error) {
+	inputs := make([][]byte, 0, len(b.inputs))
+	for _, utxo := range b.inputs {
+		hash, err := hex.DecodeString(utxo.TxHash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tx hash %s", utxo.TxHash)
+		}
+		inputs = append(inputs, encodeArray(encodeBytes(hash), encodeUint(uint64(utxo.Index))))
+	}
+
+	outputs := [][]byte{encodeOutput(b.recipient, b.amount)}
+	if b.changeAmount > 0 {
+		outputs = append(outputs, encodeOutput(b.change, b.changeAmount))
+	}
+
+	return encodeUintMap(
+		encodeArray(inputs...),
+		encodeArray(outputs...),
+		encodeUint(b.fee),
+		encodeUint(b.ttl),
+	), nil
+}
+
+// signTx returns the signed transaction of body and its hash.
+func signTx(body []byte, key ed25519.PrivateKey) ([]byte, string) {
+	hash := blake2b.Sum256(body)
+	signature := ed25519.Sign(key, hash[:])
+
+	witness := encodeArray(encodeBytes(key.Public().(ed25519.PublicKey)), encodeBytes(signature))
+	witnessSet := encodeUintMap(encodeArray(witness))
+
+	// [body, witness set, is valid, no auxiliary data]
+	tx := cborHead(cborArray, 4)
+	tx = append(tx, body...)
+	tx = append(tx, witnessSet...)
+	tx = append(tx, 0xf5, 0xf6)
+
+	return tx, hex.EncodeToString(hash[:])
+}
+
+// estimateFee returns the fee of the body once signed. The fee is computed with the largest
+// encoding of the fee and change so that the real transaction is never larger.
+func estimateFee(params *Params, body transferBody) (uint64, error) {
+	body.fee = math.MaxUint32
+	if body.changeAmount > 0 {
+		body.changeAmount = math.MaxUint64
+	}
+
+	bz, err := body.encode()
+	if err != nil {
+		return 0, err
+	}
+	// Sign with a throwaway key, the signature has a fixed size.
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return 0, err
+	}
+	tx, _ := signTx(bz, key)
+
+	return params.MinFeeA*uint64(len(tx)) + params.MinFeeB, nil
+}
+
+// buildTransfer picks the faucet utxos, largest first, and returns the balanced body of a
+// transfer of amount lovelace to recipient. The inputs always equal the outputs plus the fee.
+func buildTransfer(params *Params, utxos []Utxo, faucet, recipient []byte, amount uint64,
+	ttl uint64) (*transferBody, error) {
+	sorted := make([]Utxo, 0, len(utxos))
+	for _, utxo := range utxos {
+		if !utxo.HasAssets {
+			sorted = append(sorted, utxo)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Lovelace > sorted[j].Lovelace
+	})
+
+	body := &transferBody{recipient: recipient, amount: amount, change: faucet, ttl: ttl}
+	total := uint64(0)
+	for _, utxo := range sorted {
+		body.inputs = append(body.inputs, utxo)
+		total += utxo.Lovelace
+		if total <= amount {
+			continue
+		}
+
+		body.changeAmount = total - amount
+		fee, err := estimateFee(params, *body)
+		if err != nil {
+			return nil, err
+		}
+		if total < amount+fee {
+			continue
+		}
+
+		change := total - amount - fee
+		if change < minUtxo(params, faucet, change) {
+			body.changeAmount = 0
+			body.fee = total - amount
+		} else {
+			body.changeAmount = change
+			body.fee = fee
+		}
+
+		return body, nil
+	}
+
+	return nil, fmt.Errorf("insufficient funds, have %d, need %d plus fees", total, amount)
+}
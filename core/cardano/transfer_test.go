@@ -0,0 +1,211 @@
+package cardano
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// mainnetParams are the mainnet parameters at the Babbage hard fork.
+var mainnetParams = Params{MinFeeA: 44, MinFeeB: 155381, CoinsPerUtxoByte: 4310, MaxTxSize: 16384}
+
+// testKey returns the preprod enterprise address of a key derived from seed.
+func testKey(seed byte) ([]byte, ed25519.PrivateKey) {
+	key := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, 32))
+	address, _ := addressBytes(key.Public().(ed25519.PublicKey), 0)
+
+	return address, key
+}
+
+func testUtxo(i int, lovelace uint64) Utxo {
+	return Utxo{TxHash: strings.Repeat(fmt.Sprintf("%02x", i), 32), Index: uint32(i), Lovelace: lovelace}
+}
+
+func TestMinUtxo(t *testing.T) {
+	address, _ := testKey(1)
+
+	tests := []struct {
+		name     string
+		lovelace uint64
+		want     uint64
+	}{
+		// The output is the array head, 31 bytes of address and the amount.
+		{name: "one byte amount", lovelace: 10, want: (160 + 1 + 31 + 1) * 4310},
+		{name: "five bytes amount", lovelace: 1_000_000, want: (160 + 1 + 31 + 5) * 4310},
+		{name: "nine bytes amount", lovelace: 1 << 40, want: (160 + 1 + 31 + 9) * 4310},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := minUtxo(&mainnetParams, address, tt.lovelace); got != tt.want {
+				t.Fatalf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateFee(t *testing.T) {
+	faucet, key := testKey(1)
+	recipient, _ := testKey(2)
+
+	tests := []struct {
+		name string
+		body transferBody
+	}{
+		{
+			name: "with change",
+			body: transferBody{inputs: []Utxo{testUtxo(1, 100_000_000)}, amount: 20_000_000, changeAmount: 79_800_000},
+		},
+		{
+			name: "without change",
+			body: transferBody{inputs: []Utxo{testUtxo(1, 20_200_000)}, amount: 20_000_000},
+		},
+		{
+			name: "several inputs",
+			body: transferBody{
+				inputs:       []Utxo{testUtxo(1, 10_000_000), testUtxo(2, 10_000_000), testUtxo(3, 10_000_000)},
+				amount:       25_000_000,
+				changeAmount: 4_800_000,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := tt.body
+			body.recipient, body.change, body.ttl = recipient, faucet, 50_000_000
+
+			fee, err := estimateFee(&mainnetParams, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The fee pays for the signed transaction with the real fee.
+			body.fee = fee
+			bz, err := body.encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx, _ := signTx(bz, key)
+			minFee := mainnetParams.MinFeeA*uint64(len(tx)) + mainnetParams.MinFeeB
+			if fee < minFee {
+				t.Fatalf("got fee %d, below the min fee %d", fee, minFee)
+			}
+			// The estimate only pads the fee and change encodings, 12 bytes at most.
+			if fee > minFee+12*mainnetParams.MinFeeA {
+				t.Fatalf("got fee %d, min fee %d", fee, minFee)
+			}
+		})
+	}
+}
+
+func TestBuildTransfer(t *testing.T) {
+	faucet, key := testKey(1)
+	recipient, _ := testKey(2)
+	withAssets := testUtxo(9, 100_000_000)
+	withAssets.HasAssets = true
+
+	tests := []struct {
+		name   string
+		utxos  []Utxo
+		amount uint64
+		// inputs are the indexes of the expected inputs.
+		inputs []uint32
+		change bool
+		err    bool
+	}{
+		{
+			name:   "single input with change",
+			utxos:  []Utxo{testUtxo(1, 100_000_000)},
+			amount: 20_000_000,
+			inputs: []uint32{1},
+			change: true,
+		},
+		{
+			name:   "largest first",
+			utxos:  []Utxo{testUtxo(1, 5_000_000), testUtxo(2, 50_000_000), testUtxo(3, 10_000_000)},
+			amount: 20_000_000,
+			inputs: []uint32{2},
+			change: true,
+		},
+		{
+			name:   "several inputs",
+			utxos:  []Utxo{testUtxo(1, 15_000_000), testUtxo(2, 10_000_000)},
+			amount: 20_000_000,
+			inputs: []uint32{1, 2},
+			change: true,
+		},
+		{
+			name:   "change below min-UTxO goes to the fee",
+			utxos:  []Utxo{testUtxo(1, 20_300_000)},
+			amount: 20_000_000,
+			inputs: []uint32{1},
+		},
+		{
+			name:   "outputs with assets are not spent",
+			utxos:  []Utxo{withAssets, testUtxo(1, 30_000_000)},
+			amount: 20_000_000,
+			inputs: []uint32{1},
+			change: true,
+		},
+		{
+			name:   "fee not covered",
+			utxos:  []Utxo{testUtxo(1, 20_100_000)},
+			amount: 20_000_000,
+			err:    true,
+		},
+		{
+			name:   "insufficient funds",
+			utxos:  []Utxo{testUtxo(1, 10_000_000), withAssets},
+			amount: 20_000_000,
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := buildTransfer(&mainnetParams, tt.utxos, faucet, recipient, tt.amount, 50_000_000)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got inputs %v", body.inputs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(body.inputs) != len(tt.inputs) {
+				t.Fatalf("got inputs %v, want %v", body.inputs, tt.inputs)
+			}
+			total := uint64(0)
+			for i, input := range body.inputs {
+				if input.Index != tt.inputs[i] {
+					t.Fatalf("got inputs %v, want %v", body.inputs, tt.inputs)
+				}
+				total += input.Lovelace
+			}
+			if body.amount != tt.amount || total != body.amount+body.changeAmount+body.fee {
+				t.Fatalf("unbalanced transfer: inputs %d, amount %d, change %d, fee %d", total, body.amount,
+					body.changeAmount, body.fee)
+			}
+			if (body.changeAmount > 0) != tt.change {
+				t.Fatalf("got change %d", body.changeAmount)
+			}
+			if body.changeAmount > 0 && body.changeAmount < minUtxo(&mainnetParams, faucet, body.changeAmount) {
+				t.Fatalf("change %d is below the min-UTxO", body.changeAmount)
+			}
+
+			bz, err := body.encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx, _ := signTx(bz, key)
+			if minFee := mainnetParams.MinFeeA*uint64(len(tx)) + mainnetParams.MinFeeB; body.fee < minFee {
+				t.Fatalf("got fee %d, below the min fee %d", body.fee, minFee)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package cardano
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
+)
+
+var (
+	SleepTime       = time.Second * 60 * 30
+	PendingPollTime = time.Second * 20
+
+	// DefaultPolicy funds 20 ADA whenever the balance drops below 10 ADA.
+	DefaultPolicy = funding.PolicyCfg{
+		Mode:      funding.ModeFixed,
+		Threshold: "10000000",
+		Amount:    "20000000",
+	}
+)
+
+type watcher struct {
+	mnemonic  string
+	chain     string
+	cfg       Cfg
+	client    Client
+	networkId byte
+	watchAddr string
+	watchRaw  []byte
+	policy    *funding.Policy
+	burn      *funding.BurnTracker
+	limiter   *funding.Limiter
+	ledger    *ledger.Ledger
+	outflow   *funding.OutflowMonitor
+	audit     *audit.Trail
+	// pending is the last transfer until it is final. It is saved to pendingFile so that a restart
+	// or the next leader does not fund the address again in the meantime.
+	pending     *pendingTx
+	pendingFile string
+	ctx         context.Context
+	cancel      context.CancelFunc
+	done        sync.WaitGroup
+}
+
+func NewWatcher(mnemonic string, chain string, client Client, cfg Cfg, pubkey []byte, pendingFile string,
+	policy *funding.Policy, burn *funding.BurnTracker, limiter *funding.Limiter, ledger *ledger.Ledger,
+	outflow *funding.OutflowMonitor, audit *audit.Trail) (*watcher, error) {
+	cfg = cfg.withDefaults(chain)
+	networkId, err := cfg.NetworkId(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pubkey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid eddsa pubkey length %d", len(pubkey))
+	}
+	watchRaw, err := addressBytes(pubkey, networkId)
+	if err != nil {
+		return nil, err
+	}
+	watchAddr, err := GetAddress(pubkey, networkId)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &watcher{
+		mnemonic:    mnemonic,
+		chain:       chain,
+		cfg:         cfg,
+		client:      client,
+		networkId:   networkId,
+		watchAddr:   watchAddr,
+		watchRaw:    watchRaw,
+		policy:      policy,
+		burn:        burn,
+		limiter:     limiter,
+		ledger:      ledger,
+		outflow:     outflow,
+		audit:       audit,
+		pendingFile: pendingFile,
+		ctx:         ctx,
+		cancel:      cancel,
+	}, nil
+}
+
+func (w *watcher) Start() {
+	log.Infof("Starting watcher for chain %s, watch address = %s", w.chain, w.watchAddr)
+	w.loadPending()
+
+	w.done.Add(1)
+	go w.loop()
+}
+
+// Stop stops the watcher and waits for the cycle in progress to finish.
+func (w *watcher) Stop() {
+	w.cancel()
+	w.done.Wait()
+}
+
+func (w *watcher) loop() {
+	defer w.done.Done()
+
+	for {
+		if w.ctx.Err() != nil {
+			return
+		}
+
+		// Do not fund again until the previous transfer is final or expired, the balance does not
+		// include it yet.
+		if w.pending != nil {
+			w.checkPending()
+			if w.pending != nil {
+				funding.Sleep(w.ctx, PendingPollTime)
+				continue
+			}
+		}
+
+		w.checkFaucet()
+
+		utxos, err := w.client.GetUtxos(w.watchAddr)
+		if err != nil {
+			log.Errorf("Failed to get utxos on chain %s, err = %s", w.chain, err)
+			w.audit.Unavailable(audit.Record{Address: w.watchAddr, Threshold: w.policy.Threshold().String()}, err)
+		} else {
+			balance := big.NewInt(0)
+			for _, utxo := range utxos {
+				balance.Add(balance, new(big.Int).SetUint64(utxo.Lovelace))
+			}
+			log.Verbosef("Balance in lovelace: %s on chain %s", balance, w.chain)
+
+			w.burn.Observe(balance)
+			shouldFund, reason := w.policy.ShouldFund(balance, w.burn)
+			record := audit.Record{
+				Address:   w.watchAddr,
+				Balance:   balance.String(),
+				Threshold: w.policy.Threshold().String(),
+				Reason:    reason,
+			}
+			if amount := w.policy.Amount(balance); shouldFund && amount.Sign() > 0 {
+				log.Infof("Funding chain %s, reason: %s", w.chain, reason)
+				w.fund(amount, record)
+			} else {
+				record.Decision = audit.Skip
+				w.audit.Record(record)
+			}
+		}
+
+		if !funding.Sleep(w.ctx, SleepTime) {
+			return
+		}
+	}
+}
+
+// checkFaucet reports the faucet balance to the outflow monitor. Cardano addresses have no nonce,
+// so only the balance is compared.
+func (w *watcher) checkFaucet() {
+	if !w.outflow.Enabled() {
+		return
+	}
+
+	privKey, err := getPrivateKey(w.mnemonic)
+	if err != nil {
+		log.Errorf("Failed to get faucet key on chain %s, err = %s", w.chain, err)
+		return
+	}
+	faucetAddr, err := GetAddress(privKey.Public().(ed25519.PublicKey), w.networkId)
+	if err != nil {
+		log.Errorf("Failed to get faucet address on chain %s, err = %s", w.chain, err)
+		return
+	}
+
+	utxos, err := w.client.GetUtxos(faucetAddr)
+	if err != nil {
+		log.Errorf("Failed to get faucet utxos on chain %s, err = %s", w.chain, err)
+		return
+	}
+
+	balance := big.NewInt(0)
+	for _, utxo := range utxos {
+		balance.Add(balance, new(big.Int).SetUint64(utxo.Lovelace))
+	}
+	w.outflow.Observe(funding.FaucetState{Address: faucetAddr, Balance: balance})
+}
+
+func (w *watcher) fund(amount *big.Int, record audit.Record) {
+	record.Amount = amount.String()
+	if !amount.IsUint64() {
+		log.Errorf("Funding amount %s is out of range on chain %s", amount, w.chain)
+		w.audit.Failed(record, fmt.Errorf("amount %s is out of range", amount))
+		return
+	}
+
+	params, err := w.client.GetParams()
+	if err != nil {
+		log.Errorf("Failed to get protocol parameters on chain %s, err = %s", w.chain, err)
+		w.audit.Failed(record, fmt.Errorf("cannot get protocol parameters: %w", err))
+		return
+	}
+
+	// Every output must hold at least the min-UTxO amount.
+	if min := minUtxo(params, w.watchRaw, amount.Uint64()); amount.Uint64() < min {
+		log.Infof("Raising funding amount on chain %s from %s to the min-UTxO %d", w.chain, amount, min)
+		amount = new(big.Int).SetUint64(min)
+		record.Amount = amount.String()
+	}
+
+	reservation, err := w.limiter.Reserve(w.chain, w.watchAddr, amount)
+	if err != nil {
+		log.Errorf("Cannot fund chain %s, err = %s", w.chain, err)
+		w.audit.Refused(record, err)
+		return
+	}
+
+	pending, err := w.transfer(params, amount.Uint64())
+	if err != nil {
+		reservation.Release()
+		log.Errorf("Failed to fund chain %s, err = %s", w.chain, err)
+		w.audit.Failed(record, err)
+		return
+	}
+	reservation.Commit()
+
+	w.burn.RecordTopUp()
+	w.setPending(pending)
+	log.Infof("Cardano tx hash = %s on chain %s", pending.Hash, w.chain)
+	w.audit.Funded(record, pending.Hash)
+}
+
+// transfer sends lovelace from the faucet to the watched address.
+func (w *watcher) transfer(params *Params, lovelace uint64) (*pendingTx, error) {
+	privKey, err := getPrivateKey(w.mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	faucetKey := privKey.Public().(ed25519.PublicKey)
+	faucetRaw, err := addressBytes(faucetKey, w.networkId)
+	if err != nil {
+		return nil, err
+	}
+	faucetAddr, err := GetAddress(faucetKey, w.networkId)
+	if err != nil {
+		return nil, err
+	}
+
+	utxos, err := w.client.GetUtxos(faucetAddr)
+	if err != nil {
+		return nil, err
+	}
+	tip, err := w.client.GetTip()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := tip.Slot + w.cfg.TtlSlots
+	body, err := buildTransfer(params, utxos, faucetRaw, w.watchRaw, lovelace, ttl)
+	if err != nil {
+		return nil, err
+	}
+	bz, err := body.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, hash := signTx(bz, privKey)
+	if params.MaxTxSize > 0 && uint64(len(tx)) > params.MaxTxSize {
+		return nil, fmt.Errorf("transaction size %d is above the maximum %d", len(tx), params.MaxTxSize)
+	}
+
+	log.Infof("Funding %d lovelace from %s to %s on chain %s, fee = %d", lovelace, faucetAddr,
+		w.watchAddr, w.chain, body.fee)
+	submitted, err := w.client.Submit(tx)
+	if err != nil {
+		return nil, err
+	}
+	if submitted != hash {
+		log.Warnf("Api returned tx hash %s, expected %s on chain %s", submitted, hash, w.chain)
+	}
+	w.outflow.Expect(new(big.Int).SetUint64(lovelace), new(big.Int).SetUint64(body.fee))
+
+	if err := w.ledger.Record(ledger.Entry{
+		Chain:  w.chain,
+		TxHash: submitted,
+		From:   faucetAddr,
+		To:     w.watchAddr,
+		Amount: fmt.Sprintf("%d", lovelace),
+		Label:  "mpc",
+	}); err != nil {
+		log.Errorf("Failed to record transfer %s on chain %s, err = %s", submitted, w.chain, err)
+	}
+
+	return &pendingTx{Hash: submitted, Ttl: ttl}, nil
+}
@@ -0,0 +1,142 @@
+package cardano
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/sisu-network/sisu-account-funding/core/alert"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// newTestWatcher returns a preprod watcher talking to the stand-in at url, and the address of its
+// faucet. Its state is kept in dataDir.
+func newTestWatcher(t *testing.T, url, dataDir string) (*watcher, string) {
+	t.Helper()
+
+	_, mpcKey := testKey(1)
+	policy, err := funding.NewPolicy(funding.PolicyCfg{}, DefaultPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerter := alert.NewAlerter("")
+	limiter, err := funding.NewLimiter(filepath.Join(dataDir, "limiter.json"), funding.GlobalLimitCfg{}, nil, alerter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Cfg{}
+	w, err := NewWatcher(testMnemonic, "cardano-preprod", NewClient(url, cfg), cfg,
+		mpcKey.Public().(ed25519.PublicKey), filepath.Join(dataDir, "pending.json"), policy, funding.NewBurnTracker(filepath.Join(dataDir, "burn.json"), 0),
+		limiter, ledger.NewLedger(filepath.Join(dataDir, "ledger.jsonl")),
+		funding.NewOutflowMonitor("cardano-preprod", funding.OutflowCfg{Disabled: true}, limiter, alerter),
+		(*audit.Log)(nil).Trail("cardano-preprod", url))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privKey, err := getPrivateKey(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	faucet, err := GetAddress(privKey.Public().(ed25519.PublicKey), w.networkId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w, faucet
+}
+
+func TestFund(t *testing.T) {
+	tests := []struct {
+		name   string
+		faucet uint64
+		amount int64
+		// sent is the amount transferred, 0 if nothing is sent.
+		sent uint64
+	}{
+		{name: "amount above the min-UTxO", faucet: 100_000_000, amount: 20_000_000, sent: 20_000_000},
+		{name: "amount raised to the min-UTxO", faucet: 100_000_000, amount: 500_000, sent: (160 + 1 + 31 + 5) * 4310},
+		{name: "insufficient faucet", faucet: 10_000_000, amount: 20_000_000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn, url := newStandIn(t)
+			w, faucet := newTestWatcher(t, url, t.TempDir())
+			standIn.addUtxo(faucet, tt.faucet)
+
+			w.fund(big.NewInt(tt.amount), audit.Record{Address: w.watchAddr})
+
+			txs := standIn.submitted()
+			if tt.sent == 0 {
+				if len(txs) != 0 || w.pending != nil {
+					t.Fatalf("got %d transactions, want none", len(txs))
+				}
+				return
+			}
+			if len(txs) != 1 || w.pending == nil {
+				t.Fatalf("got %d transactions, want 1", len(txs))
+			}
+			if output := encodeOutput(w.watchRaw, tt.sent); !bytes.Contains(txs[0], output) {
+				t.Fatalf("transaction %x does not pay %d lovelace to the mpc address", txs[0], tt.sent)
+			}
+		})
+	}
+}
+
+func TestCheckPending(t *testing.T) {
+	tests := []struct {
+		name string
+		// submitted is false if the api never saw the transfer.
+		submitted bool
+		blocks    int64
+		// restart rebuilds the watcher from its data dir before the check.
+		restart bool
+		pending bool
+	}{
+		{name: "in the next block", submitted: true, blocks: 1, pending: true},
+		{name: "in the next block after a restart", submitted: true, blocks: 1, restart: true, pending: true},
+		{name: "final", submitted: true, blocks: 3},
+		{name: "final after a restart", submitted: true, blocks: 3, restart: true},
+		{name: "unknown before its ttl", blocks: DefaultTtlSlots / 20, pending: true},
+		{name: "expired", blocks: DefaultTtlSlots/20 + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn, url := newStandIn(t)
+			dataDir := t.TempDir()
+			w, faucet := newTestWatcher(t, url, dataDir)
+			standIn.addUtxo(faucet, 100_000_000)
+
+			if tt.submitted {
+				w.fund(big.NewInt(20_000_000), audit.Record{Address: w.watchAddr})
+				if w.pending == nil {
+					t.Fatal("the transfer was not sent")
+				}
+			} else {
+				w.pending = &pendingTx{Hash: "00", Ttl: DefaultTtlSlots}
+			}
+
+			standIn.addBlocks(tt.blocks)
+			if tt.restart {
+				w, _ = newTestWatcher(t, url, dataDir)
+				w.loadPending()
+				if w.pending == nil {
+					t.Fatal("the pending transfer was lost by the restart")
+				}
+			}
+			w.checkPending()
+			if (w.pending != nil) != tt.pending {
+				t.Fatalf("got pending %v, want %t", w.pending, tt.pending)
+			}
+		})
+	}
+}
@@ -1,12 +1,69 @@
 package core
 
+import (
+	"time"
+
+	"github.com/sisu-network/sisu-account-funding/core/admin"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/btc"
+	"github.com/sisu-network/sisu-account-funding/core/cardano"
+	"github.com/sisu-network/sisu-account-funding/core/cosmos"
+	"github.com/sisu-network/sisu-account-funding/core/eth"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/leader"
+	"github.com/sisu-network/sisu-account-funding/core/lisk"
+	"github.com/sisu-network/sisu-account-funding/core/solana"
+	"github.com/sisu-network/sisu-account-funding/core/tron"
+)
+
 type ChainCfg struct {
-	Chain string   `toml:"chain" json:"chain"`
-	Rpcs  []string `toml:"rpcs" json:"rpcs"`
-	Wss   []string `toml:"wss" json:"wss"`
+	Chain string `toml:"chain" json:"chain"`
+	// Family is the chain family funding the chain: evm, lisk, bitcoin, solana, cardano, cosmos or
+	// tron. It is detected from the chain name and config when empty.
+	Family  string            `toml:"family" json:"family"`
+	Rpcs    []string          `toml:"rpcs" json:"rpcs"`
+	Wss     []string          `toml:"wss" json:"wss"`
+	Funding funding.PolicyCfg `toml:"funding" json:"funding"`
+	Limits  funding.LimitCfg  `toml:"limits" json:"limits"`
+	// Schedule restricts top-ups to funding windows and pauses them during maintenances.
+	Schedule funding.ScheduleCfg `toml:"schedule" json:"schedule"`
+	// Approval makes the large top-ups wait for the approval of an operator.
+	Approval funding.ApprovalCfg `toml:"approval" json:"approval"`
+	// ExpectTimeout is how long a transfer of the chain may take to show up in the faucet balance
+	// before the outflow detection stops expecting it. It defaults to the one of the family, then
+	// to the one of the outflow config.
+	ExpectTimeout time.Duration `toml:"expect_timeout" json:"expect_timeout"`
+	Gas           eth.GasCfg    `toml:"gas" json:"gas"`
+	// Explorer is used by the reconciliation of EVM chains.
+	Explorer eth.ExplorerCfg `toml:"explorer" json:"explorer"`
+	// Targets are extra accounts funded on EVM chains on top of the MPC account.
+	Targets []eth.TargetCfg `toml:"targets" json:"targets"`
+	Lisk    lisk.Cfg        `toml:"lisk" json:"lisk"`
+	Btc     btc.Cfg         `toml:"btc" json:"btc"`
+	Solana  solana.Cfg      `toml:"solana" json:"solana"`
+	Cardano cardano.Cfg     `toml:"cardano" json:"cardano"`
+	Cosmos  cosmos.Cfg      `toml:"cosmos" json:"cosmos"`
+	Tron    tron.Cfg        `toml:"tron" json:"tron"`
 }
 
 type ChainsCfg struct {
+	// DataDir is where the funder persists its state between restarts.
+	DataDir      string `toml:"data_dir"`
+	AlertWebhook string `toml:"alert_webhook"`
+	// ConfigWatch is how often chains.toml is checked for changes to reload, 0 disables it. The
+	// config is also reloaded on SIGHUP.
+	ConfigWatch time.Duration          `toml:"config_watch"`
+	Limits      funding.GlobalLimitCfg `toml:"limits"`
+	// Outflow configures the detection of faucet transfers that the funder did not send.
+	Outflow funding.OutflowCfg `toml:"outflow"`
+	// Leader elects the instance that sends transactions when several funders run for redundancy.
+	Leader leader.Cfg `toml:"leader"`
+	// Admin is the HTTP api used by operators to manage maintenances and approvals.
+	Admin admin.Cfg `toml:"admin"`
+	// Approvals configures the notification of the top-ups waiting for approval.
+	Approvals funding.ApprovalsCfg `toml:"approvals"`
+	// Audit is the structured log of every funding decision.
+	Audit  audit.Cfg           `toml:"audit"`
 	Chains map[string]ChainCfg `toml:"chains"`
 }
 
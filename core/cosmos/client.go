@@ -0,0 +1,190 @@
+package cosmos
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrAccountNotFound is returned by GetAccount for accounts that never received funds.
+	ErrAccountNotFound = fmt.Errorf("account not found")
+
+	errNotFound = errors.New("not found")
+)
+
+// Client is the part of the Cosmos SDK node api used by the watcher. It lets the watcher run
+// against the REST gateway, the gRPC server or a stand-in.
+type Client interface {
+	// GetBalance returns the balance of address, 0 for an address that never received funds.
+	GetBalance(address, denom string) (*big.Int, error)
+	// GetAccount returns the account number and sequence of address, or ErrAccountNotFound.
+	GetAccount(address string) (uint64, uint64, error)
+	// Simulate returns the gas used by tx.
+	Simulate(tx []byte) (uint64, error)
+	// Broadcast submits tx and returns its hash once it passed CheckTx.
+	Broadcast(tx []byte) (string, error)
+}
+
+// NewClient returns a client of the api selected by cfg.
+func NewClient(url string, cfg Cfg) (Client, error) {
+	cfg = cfg.withDefaults()
+	if cfg.Api == ApiGrpc {
+		return newGrpcClient(url, cfg.GrpcTls)
+	}
+
+	return &restClient{url: strings.TrimSuffix(url, "/"), client: &http.Client{Timeout: time.Second * 30}}, nil
+}
+
+type restClient struct {
+	url    string
+	client *http.Client
+}
+
+func (c *restClient) do(method, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		bz, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(bz)
+	}
+
+	req, err := http.NewRequest(method, c.url+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	bz, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s %s: %s", errNotFound, method, path, string(bz))
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s failed with status %d: %s", method, path, res.StatusCode, string(bz))
+	}
+
+	return json.Unmarshal(bz, result)
+}
+
+func (c *restClient) GetBalance(address, denom string) (*big.Int, error) {
+	res := &struct {
+		Balance struct {
+			Amount string `json:"amount"`
+		} `json:"balance"`
+	}{}
+	// The bank module returns a zero balance for an unknown address, so a not found is a wrong url
+	// or proxy and must not be read as an empty account.
+	path := fmt.Sprintf("/cosmos/bank/v1beta1/balances/%s/by_denom?denom=%s", address, url.QueryEscape(denom))
+	if err := c.do("GET", path, nil, res); err != nil {
+		return nil, err
+	}
+
+	return parseAmount(res.Balance.Amount)
+}
+
+func (c *restClient) GetAccount(address string) (uint64, uint64, error) {
+	type baseAccount struct {
+		AccountNumber string `json:"account_number"`
+		Sequence      string `json:"sequence"`
+	}
+	res := &struct {
+		Account struct {
+			baseAccount
+			// Vesting and module accounts embed the base account.
+			BaseAccount *baseAccount `json:"base_account"`
+		} `json:"account"`
+	}{}
+	err := c.do("GET", "/cosmos/auth/v1beta1/accounts/"+address, nil, res)
+	if errors.Is(err, errNotFound) {
+		return 0, 0, ErrAccountNotFound
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	account := res.Account.baseAccount
+	if res.Account.BaseAccount != nil {
+		account = *res.Account.BaseAccount
+	}
+
+	accountNumber, err := strconv.ParseUint(account.AccountNumber, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid account number %q", account.AccountNumber)
+	}
+	sequence, err := strconv.ParseUint(account.Sequence, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid sequence %q", account.Sequence)
+	}
+
+	return accountNumber, sequence, nil
+}
+
+func (c *restClient) Simulate(tx []byte) (uint64, error) {
+	res := &struct {
+		GasInfo struct {
+			GasUsed string `json:"gas_used"`
+		} `json:"gas_info"`
+	}{}
+	body := map[string]string{"tx_bytes": base64.StdEncoding.EncodeToString(tx)}
+	if err := c.do("POST", "/cosmos/tx/v1beta1/simulate", body, res); err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(res.GasInfo.GasUsed, 10, 64)
+}
+
+func (c *restClient) Broadcast(tx []byte) (string, error) {
+	res := &struct {
+		TxResponse struct {
+			TxHash string `json:"txhash"`
+			Code   uint32 `json:"code"`
+			RawLog string `json:"raw_log"`
+		} `json:"tx_response"`
+	}{}
+	body := map[string]string{
+		"tx_bytes": base64.StdEncoding.EncodeToString(tx),
+		"mode":     "BROADCAST_MODE_SYNC",
+	}
+	if err := c.do("POST", "/cosmos/tx/v1beta1/txs", body, res); err != nil {
+		return "", err
+	}
+	if res.TxResponse.Code != 0 {
+		return "", fmt.Errorf("transaction %s rejected, code = %d, log = %s", res.TxResponse.TxHash,
+			res.TxResponse.Code, res.TxResponse.RawLog)
+	}
+
+	return res.TxResponse.TxHash, nil
+}
+
+func parseAmount(s string) (*big.Int, error) {
+	if s == "" {
+		return big.NewInt(0), nil
+	}
+
+	amount, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+
+	return amount, nil
+}
@@ -0,0 +1,92 @@
+package cosmos
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRestGetBalance(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance int64
+		status  int
+		want    int64
+		wantErr bool
+	}{
+		{name: "funded", balance: 1_500_000, want: 1_500_000},
+		{name: "never funded", want: 0},
+		{name: "not found", status: http.StatusNotFound, wantErr: true},
+		{name: "node error", status: http.StatusInternalServerError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn, url := newStandIn(t, "test-1", "uatom")
+			if tt.balance != 0 {
+				standIn.setBalance("cosmos1a", tt.balance)
+			}
+			standIn.setBalanceStatus("cosmos1a", tt.status)
+
+			client, err := NewClient(url, Cfg{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			balance, err := client.GetBalance("cosmos1a", "uatom")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got balance %s, want an error", balance)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if balance.Int64() != tt.want {
+				t.Fatalf("got balance %s, want %d", balance, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestGetAccountNotFound(t *testing.T) {
+	_, url := newStandIn(t, "test-1", "uatom")
+	client, err := NewClient(url, Cfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := client.GetAccount("cosmos1a"); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("got err %v, want %v", err, ErrAccountNotFound)
+	}
+}
+
+// TestGrpcNotFound checks that a NotFound is only an unknown account for the account query.
+func TestGrpcNotFound(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		return status.Error(codes.NotFound, "not found")
+	}))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	client, err := NewClient(listener.Addr().String(), Cfg{Api: ApiGrpc})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance, err := client.GetBalance("cosmos1a", "uatom"); err == nil || errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("got balance %s and err %v, want a node error", balance, err)
+	}
+	if _, _, err := client.GetAccount("cosmos1a"); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("got err %v, want %v", err, ErrAccountNotFound)
+	}
+}
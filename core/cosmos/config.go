@@ -0,0 +1,92 @@
+package cosmos
+
+import (
+	"fmt"
+	"math/big"
+)
+
+const (
+	// ApiRest queries the chain through the REST gateway (LCD) of the node.
+	ApiRest = "rest"
+	// ApiGrpc queries the chain through the gRPC server of the node.
+	ApiGrpc = "grpc"
+
+	DefaultCoinType      = uint32(118)
+	DefaultGasAdjustment = 1.3
+)
+
+// Cfg holds the Cosmos SDK specific settings of a chain.
+type Cfg struct {
+	// Prefix is the bech32 prefix of the account addresses, e.g. cosmos or sisu.
+	Prefix  string `toml:"prefix" json:"prefix"`
+	ChainId string `toml:"chain_id" json:"chain_id"`
+	// Denom is the denomination funded and used to pay fees, e.g. uatom.
+	Denom string `toml:"denom" json:"denom"`
+	// Api is rest (default) or grpc. GrpcTls enables TLS on the gRPC connection.
+	Api     string `toml:"api" json:"api"`
+	GrpcTls bool   `toml:"grpc_tls" json:"grpc_tls"`
+
+	// GasPrice is the price of a unit of gas in Denom, as a decimal, e.g. 0.025. The simulated gas
+	// is multiplied by GasAdjustment to get the gas limit.
+	GasPrice      string  `toml:"gas_price" json:"gas_price"`
+	GasAdjustment float64 `toml:"gas_adjustment" json:"gas_adjustment"`
+	// CoinType is the BIP44 coin type of the faucet key, 118 by default.
+	CoinType uint32 `toml:"coin_type" json:"coin_type"`
+	Memo     string `toml:"memo" json:"memo"`
+}
+
+func (cfg Cfg) withDefaults() Cfg {
+	if cfg.Api == "" {
+		cfg.Api = ApiRest
+	}
+	if cfg.GasPrice == "" {
+		cfg.GasPrice = "0"
+	}
+	if cfg.GasAdjustment == 0 {
+		cfg.GasAdjustment = DefaultGasAdjustment
+	}
+	if cfg.CoinType == 0 {
+		cfg.CoinType = DefaultCoinType
+	}
+
+	return cfg
+}
+
+// IsConfigured returns true if the chain config has a Cosmos address prefix.
+func (cfg Cfg) IsConfigured() bool {
+	return cfg.Prefix != ""
+}
+
+func (cfg Cfg) Validate() error {
+	cfg = cfg.withDefaults()
+	if cfg.ChainId == "" {
+		return fmt.Errorf("chain_id is required")
+	}
+	if cfg.Denom == "" {
+		return fmt.Errorf("denom is required")
+	}
+
+	switch cfg.Api {
+	case ApiRest, ApiGrpc:
+	default:
+		return fmt.Errorf("unknown cosmos api %s", cfg.Api)
+	}
+
+	if _, err := cfg.gasPrice(); err != nil {
+		return err
+	}
+	if cfg.GasAdjustment < 1 {
+		return fmt.Errorf("gas_adjustment %f is below 1", cfg.GasAdjustment)
+	}
+
+	return nil
+}
+
+func (cfg Cfg) gasPrice() (*big.Rat, error) {
+	price, ok := new(big.Rat).SetString(cfg.GasPrice)
+	if !ok || price.Sign() < 0 {
+		return nil, fmt.Errorf("invalid gas price %q", cfg.GasPrice)
+	}
+
+	return price, nil
+}
@@ -0,0 +1,194 @@
+package cosmos
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	baseAccountTypeUrl = "/cosmos.auth.v1beta1.BaseAccount"
+	broadcastModeSync  = 2
+)
+
+// rawCodec sends and receives messages that are already encoded, so that the client does not
+// need the generated Cosmos SDK types.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	return *v.(*[]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]byte) = append([]byte{}, data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "raw"
+}
+
+type grpcClient struct {
+	conn *grpc.ClientConn
+}
+
+func newGrpcClient(target string, useTls bool) (*grpcClient, error) {
+	creds := insecure.NewCredentials()
+	if useTls {
+		creds = credentials.NewClientTLSFromCert(nil, "")
+	}
+
+	conn, err := grpc.Dial(target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcClient{conn: conn}, nil
+}
+
+func (c *grpcClient) invoke(method string, req []byte) (map[protowire.Number][]field, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	res := make([]byte, 0)
+	if err := c.conn.Invoke(ctx, method, &req, &res, grpc.ForceCodec(rawCodec{})); err != nil {
+		return nil, err
+	}
+
+	return decodeFields(res)
+}
+
+func (c *grpcClient) GetBalance(address, denom string) (*big.Int, error) {
+	req := appendString(appendString(nil, 1, address), 2, denom)
+	// The bank module returns a zero balance for an unknown address, a NotFound is not one.
+	res, err := c.invoke("/cosmos.bank.v1beta1.Query/Balance", req)
+	if err != nil {
+		return nil, err
+	}
+
+	coin, err := decodeFields(first(res, 1).bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseAmount(string(first(coin, 2).bytes))
+}
+
+func (c *grpcClient) GetAccount(address string) (uint64, uint64, error) {
+	res, err := c.invoke("/cosmos.auth.v1beta1.Query/Account", appendString(nil, 1, address))
+	if status.Code(err) == codes.NotFound {
+		return 0, 0, ErrAccountNotFound
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	accountAny, err := decodeFields(first(res, 1).bytes)
+	if err != nil {
+		return 0, 0, err
+	}
+	if typeUrl := string(first(accountAny, 1).bytes); typeUrl != baseAccountTypeUrl {
+		return 0, 0, fmt.Errorf("unsupported account type %s", typeUrl)
+	}
+
+	account, err := decodeFields(first(accountAny, 2).bytes)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return first(account, 3).varint, first(account, 4).varint, nil
+}
+
+func (c *grpcClient) Simulate(tx []byte) (uint64, error) {
+	res, err := c.invoke("/cosmos.tx.v1beta1.Service/Simulate", appendBytes(nil, 2, tx))
+	if err != nil {
+		return 0, err
+	}
+
+	gasInfo, err := decodeFields(first(res, 1).bytes)
+	if err != nil {
+		return 0, err
+	}
+
+	return first(gasInfo, 2).varint, nil
+}
+
+func (c *grpcClient) Broadcast(tx []byte) (string, error) {
+	req := appendUint(appendBytes(nil, 1, tx), 2, broadcastModeSync)
+	res, err := c.invoke("/cosmos.tx.v1beta1.Service/BroadcastTx", req)
+	if err != nil {
+		return "", err
+	}
+
+	txResponse, err := decodeFields(first(res, 1).bytes)
+	if err != nil {
+		return "", err
+	}
+
+	txHash := string(first(txResponse, 2).bytes)
+	if code := first(txResponse, 4).varint; code != 0 {
+		return "", fmt.Errorf("transaction %s rejected, code = %d, log = %s", txHash, code,
+			string(first(txResponse, 6).bytes))
+	}
+
+	return txHash, nil
+}
+
+// field is a decoded protobuf field, either a varint or a length-delimited value.
+type field struct {
+	varint uint64
+	bytes  []byte
+}
+
+func decodeFields(bz []byte) (map[protowire.Number][]field, error) {
+	fields := make(map[protowire.Number][]field)
+	for len(bz) > 0 {
+		num, typ, n := protowire.ConsumeTag(bz)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		bz = bz[n:]
+
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(bz)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			fields[num] = append(fields[num], field{varint: v})
+			bz = bz[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(bz)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			fields[num] = append(fields[num], field{bytes: v})
+			bz = bz[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, bz)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			bz = bz[n:]
+		}
+	}
+
+	return fields, nil
+}
+
+// first returns the first value of a field, or the zero value if the field is absent.
+func first(fields map[protowire.Number][]field, num protowire.Number) field {
+	if len(fields[num]) == 0 {
+		return field{}
+	}
+
+	return fields[num][0]
+}
@@ -0,0 +1,50 @@
+package cosmos
+
+import (
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/cosmos/go-bip39"
+)
+
+// GetAddress returns the bech32 account address of an ECDSA public key, e.g. the Sisu MPC key.
+func GetAddress(pubkey []byte, prefix string) (string, error) {
+	pubKey, err := btcec.ParsePubKey(pubkey)
+	if err != nil {
+		return "", err
+	}
+
+	return bech32.EncodeFromBase256(prefix, btcutil.Hash160(pubKey.SerializeCompressed()))
+}
+
+// getPrivateKey derives the faucet key from the mnemonic with the m/44'/coinType'/0'/0/0 path.
+func getPrivateKey(mnemonic string, coinType uint32) (*btcec.PrivateKey, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// The network params only matter for the serialization of extended keys.
+	key, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, err
+	}
+
+	path := []uint32{
+		hdkeychain.HardenedKeyStart + 44,
+		hdkeychain.HardenedKeyStart + coinType,
+		hdkeychain.HardenedKeyStart,
+		0,
+		0,
+	}
+	for _, n := range path {
+		key, err = key.Derive(n)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key.ECPrivKey()
+}
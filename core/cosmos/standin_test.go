@@ -0,0 +1,291 @@
+package cosmos
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+)
+
+// standIn is a Cosmos SDK REST gateway with in-memory accounts. Broadcast transactions are
+// checked like the ante handler does and applied at once.
+type standIn struct {
+	lock     sync.Mutex
+	chainId  string
+	denom    string
+	gasUsed  uint64
+	balances map[string]*big.Int
+	accounts map[string]*standInAccount
+	txs      [][]byte
+	// balanceStatus is returned by the balance queries of an address instead of its balance.
+	balanceStatus map[string]int
+}
+
+type standInAccount struct {
+	number   uint64
+	sequence uint64
+}
+
+func newStandIn(t *testing.T, chainId, denom string) (*standIn, string) {
+	s := &standIn{
+		chainId:  chainId,
+		denom:    denom,
+		gasUsed:  80_000,
+		balances: make(map[string]*big.Int),
+		accounts: make(map[string]*standInAccount),
+
+		balanceStatus: make(map[string]int),
+	}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+
+	return s, server.URL
+}
+
+// setBalance creates the account of address if needed and sets its balance.
+func (s *standIn) setBalance(address string, balance int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.accounts[address] == nil {
+		s.accounts[address] = &standInAccount{number: uint64(len(s.accounts) + 7)}
+	}
+	s.balances[address] = big.NewInt(balance)
+}
+
+func (s *standIn) balance(address string) int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.balances[address] == nil {
+		return 0
+	}
+	return s.balances[address].Int64()
+}
+
+// setBalanceStatus makes the balance queries of address fail with status, 0 restores them.
+func (s *standIn) setBalanceStatus(address string, status int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.balanceStatus[address] = status
+}
+
+func (s *standIn) broadcast() [][]byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([][]byte{}, s.txs...)
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	reply := func(status int, res interface{}) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(res)
+	}
+
+	switch {
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/cosmos/bank/v1beta1/balances/"):
+		address := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/cosmos/bank/v1beta1/balances/"), "/by_denom")
+		if status := s.balanceStatus[address]; status != 0 {
+			reply(status, map[string]string{"message": "stand-in error"})
+			return
+		}
+		amount := "0"
+		if s.balances[address] != nil && r.URL.Query().Get("denom") == s.denom {
+			amount = s.balances[address].String()
+		}
+		reply(http.StatusOK, map[string]interface{}{
+			"balance": map[string]string{"denom": r.URL.Query().Get("denom"), "amount": amount},
+		})
+
+	case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/cosmos/auth/v1beta1/accounts/"):
+		account := s.accounts[strings.TrimPrefix(r.URL.Path, "/cosmos/auth/v1beta1/accounts/")]
+		if account == nil {
+			reply(http.StatusNotFound, map[string]interface{}{"code": 5, "message": "account not found"})
+			return
+		}
+		reply(http.StatusOK, map[string]interface{}{
+			"account": map[string]string{
+				"@type":          baseAccountTypeUrl,
+				"account_number": fmt.Sprint(account.number),
+				"sequence":       fmt.Sprint(account.sequence),
+			},
+		})
+
+	case r.Method == "POST" && r.URL.Path == "/cosmos/tx/v1beta1/simulate":
+		reply(http.StatusOK, map[string]interface{}{
+			"gas_info": map[string]string{"gas_used": fmt.Sprint(s.gasUsed)},
+		})
+
+	case r.Method == "POST" && r.URL.Path == "/cosmos/tx/v1beta1/txs":
+		req := struct {
+			TxBytes string `json:"tx_bytes"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			reply(http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+		tx, err := base64.StdEncoding.DecodeString(req.TxBytes)
+		if err != nil {
+			reply(http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+
+		hash := sha256.Sum256(tx)
+		txHash := strings.ToUpper(hex.EncodeToString(hash[:]))
+		code := uint32(0)
+		rawLog := ""
+		if err := s.apply(tx); err != nil {
+			code = 4
+			rawLog = err.Error()
+		} else {
+			s.txs = append(s.txs, tx)
+		}
+		reply(http.StatusOK, map[string]interface{}{
+			"tx_response": map[string]interface{}{"txhash": txHash, "code": code, "raw_log": rawLog},
+		})
+
+	default:
+		reply(http.StatusNotFound, map[string]string{"message": "Not Implemented"})
+	}
+}
+
+// apply checks the signature, sequence and fee of a TxRaw with a single MsgSend and moves the
+// coins.
+func (s *standIn) apply(tx []byte) error {
+	raw, err := decodeFields(tx)
+	if err != nil {
+		return err
+	}
+	body, authInfo, signature := first(raw, 1).bytes, first(raw, 2).bytes, first(raw, 3).bytes
+
+	bodyFields, err := decodeFields(body)
+	if err != nil {
+		return err
+	}
+	msgAny, err := decodeFields(first(bodyFields, 1).bytes)
+	if err != nil {
+		return err
+	}
+	if typeUrl := string(first(msgAny, 1).bytes); typeUrl != msgSendTypeUrl {
+		return fmt.Errorf("unexpected message %s", typeUrl)
+	}
+	msg, err := decodeFields(first(msgAny, 2).bytes)
+	if err != nil {
+		return err
+	}
+	coin, err := decodeFields(first(msg, 3).bytes)
+	if err != nil {
+		return err
+	}
+	from, to := string(first(msg, 1).bytes), string(first(msg, 2).bytes)
+	amount, err := parseAmount(string(first(coin, 2).bytes))
+	if err != nil {
+		return err
+	}
+
+	authFields, err := decodeFields(authInfo)
+	if err != nil {
+		return err
+	}
+	signerInfo, err := decodeFields(first(authFields, 1).bytes)
+	if err != nil {
+		return err
+	}
+	pubkeyAny, err := decodeFields(first(signerInfo, 1).bytes)
+	if err != nil {
+		return err
+	}
+	pubkeyFields, err := decodeFields(first(pubkeyAny, 2).bytes)
+	if err != nil {
+		return err
+	}
+	pubkey, err := btcec.ParsePubKey(first(pubkeyFields, 1).bytes)
+	if err != nil {
+		return err
+	}
+	if address, _ := GetAddress(pubkey.SerializeCompressed(), strings.SplitN(from, "1", 2)[0]); address != from {
+		return fmt.Errorf("signer %s is not the sender %s", address, from)
+	}
+
+	feeFields, err := decodeFields(first(authFields, 2).bytes)
+	if err != nil {
+		return err
+	}
+	fee := big.NewInt(0)
+	if feeCoin := first(feeFields, 1).bytes; feeCoin != nil {
+		feeCoinFields, err := decodeFields(feeCoin)
+		if err != nil {
+			return err
+		}
+		if fee, err = parseAmount(string(first(feeCoinFields, 2).bytes)); err != nil {
+			return err
+		}
+	}
+	if gasLimit := first(feeFields, 2).varint; gasLimit < s.gasUsed {
+		return fmt.Errorf("out of gas, limit %d, used %d", gasLimit, s.gasUsed)
+	}
+
+	account := s.accounts[from]
+	if account == nil {
+		return fmt.Errorf("account %s not found", from)
+	}
+	if sequence := first(signerInfo, 3).varint; sequence != account.sequence {
+		return fmt.Errorf("account sequence mismatch, expected %d, got %d", account.sequence, sequence)
+	}
+
+	signDoc := appendBytes(nil, 1, body)
+	signDoc = appendBytes(signDoc, 2, authInfo)
+	signDoc = appendString(signDoc, 3, s.chainId)
+	signDoc = appendUint(signDoc, 4, account.number)
+	if !verify(pubkey, signDoc, signature) {
+		return fmt.Errorf("signature verification failed")
+	}
+
+	total := new(big.Int).Add(amount, fee)
+	if s.balances[from] == nil || s.balances[from].Cmp(total) < 0 {
+		return fmt.Errorf("insufficient funds")
+	}
+	s.balances[from] = new(big.Int).Sub(s.balances[from], total)
+	if s.accounts[to] == nil {
+		s.accounts[to] = &standInAccount{number: uint64(len(s.accounts) + 7)}
+		s.balances[to] = big.NewInt(0)
+	}
+	s.balances[to] = new(big.Int).Add(s.balances[to], amount)
+	account.sequence++
+
+	return nil
+}
+
+// verify checks a 64 bytes r || s signature of the sha256 of signDoc.
+func verify(pubkey *btcec.PublicKey, signDoc, signature []byte) bool {
+	if len(signature) != 64 {
+		return false
+	}
+
+	var r, s btcec.ModNScalar
+	if r.SetByteSlice(signature[:32]) || s.SetByteSlice(signature[32:]) {
+		return false
+	}
+	// The SDK rejects signatures with a high s.
+	if s.IsOverHalfOrder() {
+		return false
+	}
+
+	hash := sha256.Sum256(signDoc)
+	return ecdsa.NewSignature(&r, &s).Verify(hash[:], pubkey)
+}
@@ -0,0 +1,115 @@
+package cosmos
+
+import (
+	"crypto/sha256"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	msgSendTypeUrl = "/cosmos.bank.v1beta1.MsgSend"
+	pubKeyTypeUrl  = "/cosmos.crypto.secp256k1.PubKey"
+
+	signModeDirect = 1
+)
+
+// The transaction messages are encoded by hand with protowire so that the funder does not depend
+// on the Cosmos SDK.
+
+func appendString(bz []byte, field protowire.Number, s string) []byte {
+	if s == "" {
+		return bz
+	}
+	bz = protowire.AppendTag(bz, field, protowire.BytesType)
+	return protowire.AppendString(bz, s)
+}
+
+func appendBytes(bz []byte, field protowire.Number, value []byte) []byte {
+	bz = protowire.AppendTag(bz, field, protowire.BytesType)
+	return protowire.AppendBytes(bz, value)
+}
+
+func appendUint(bz []byte, field protowire.Number, n uint64) []byte {
+	if n == 0 {
+		return bz
+	}
+	bz = protowire.AppendTag(bz, field, protowire.VarintType)
+	return protowire.AppendVarint(bz, n)
+}
+
+func encodeAny(typeUrl string, value []byte) []byte {
+	return appendBytes(appendString(nil, 1, typeUrl), 2, value)
+}
+
+func encodeCoin(denom, amount string) []byte {
+	return appendString(appendString(nil, 1, denom), 2, amount)
+}
+
+// transfer is a MsgSend from the faucet signed in direct mode.
+type transfer struct {
+	from          string
+	to            string
+	denom         string
+	amount        string
+	memo          string
+	pubkey        *btcec.PublicKey
+	sequence      uint64
+	accountNumber uint64
+	chainId       string
+	gasLimit      uint64
+	fee           string
+}
+
+func (t *transfer) bodyBytes() []byte {
+	msg := appendString(nil, 1, t.from)
+	msg = appendString(msg, 2, t.to)
+	msg = appendBytes(msg, 3, encodeCoin(t.denom, t.amount))
+
+	body := appendBytes(nil, 1, encodeAny(msgSendTypeUrl, msg))
+	return appendString(body, 2, t.memo)
+}
+
+func (t *transfer) authInfoBytes() []byte {
+	pubkey := appendBytes(nil, 1, t.pubkey.SerializeCompressed())
+	single := appendUint(nil, 1, signModeDirect)
+	modeInfo := appendBytes(nil, 1, single)
+
+	signerInfo := appendBytes(nil, 1, encodeAny(pubKeyTypeUrl, pubkey))
+	signerInfo = appendBytes(signerInfo, 2, modeInfo)
+	signerInfo = appendUint(signerInfo, 3, t.sequence)
+
+	fee := make([]byte, 0)
+	if t.fee != "" && t.fee != "0" {
+		fee = appendBytes(fee, 1, encodeCoin(t.denom, t.fee))
+	}
+	fee = appendUint(fee, 2, t.gasLimit)
+
+	authInfo := appendBytes(nil, 1, signerInfo)
+	return appendBytes(authInfo, 2, fee)
+}
+
+// encode returns the TxRaw of the transfer. Without a key, the signature is left empty as
+// expected by simulations.
+func (t *transfer) encode(key *btcec.PrivateKey) []byte {
+	body := t.bodyBytes()
+	authInfo := t.authInfoBytes()
+
+	signature := make([]byte, 0)
+	if key != nil {
+		signDoc := appendBytes(nil, 1, body)
+		signDoc = appendBytes(signDoc, 2, authInfo)
+		signDoc = appendString(signDoc, 3, t.chainId)
+		signDoc = appendUint(signDoc, 4, t.accountNumber)
+
+		hash := sha256.Sum256(signDoc)
+		// The compact signature is the recovery byte followed by r and s, with a low s.
+		compact, _ := ecdsa.SignCompact(key, hash[:], true)
+		signature = compact[1:]
+	}
+
+	tx := appendBytes(nil, 1, body)
+	tx = appendBytes(tx, 2, authInfo)
+	return appendBytes(tx, 3, signature)
+}
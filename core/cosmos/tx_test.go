@@ -0,0 +1,126 @@
+package cosmos
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// cat concatenates byte slices and strings into an expected encoding.
+func cat(parts ...interface{}) []byte {
+	bz := make([]byte, 0)
+	for _, part := range parts {
+		switch part := part.(type) {
+		case []byte:
+			bz = append(bz, part...)
+		case string:
+			bz = append(bz, part...)
+		}
+	}
+
+	return bz
+}
+
+func TestTransferEncoding(t *testing.T) {
+	key, pubkey := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{1}, 32))
+	compressed := pubkey.SerializeCompressed()
+
+	tests := []struct {
+		name         string
+		tx           transfer
+		wantBody     []byte
+		wantAuthInfo []byte
+	}{
+		{
+			name: "all fields",
+			tx: transfer{
+				from: "cosmos1a", to: "cosmos1b", denom: "uatom", amount: "5", memo: "m", pubkey: pubkey,
+				sequence: 3, accountNumber: 9, chainId: "c", gasLimit: 200_000, fee: "5000",
+			},
+			wantBody: cat(
+				[]byte{0x0a, 0x40}, // messages
+				[]byte{0x0a, 0x1c}, "/cosmos.bank.v1beta1.MsgSend",
+				[]byte{0x12, 0x20}, // MsgSend
+				[]byte{0x0a, 0x08}, "cosmos1a",
+				[]byte{0x12, 0x08}, "cosmos1b",
+				[]byte{0x1a, 0x0a}, []byte{0x0a, 0x05}, "uatom", []byte{0x12, 0x01}, "5",
+				[]byte{0x12, 0x01}, "m", // memo
+			),
+			wantAuthInfo: cat(
+				[]byte{0x0a, 0x50}, // signer info
+				[]byte{0x0a, 0x46}, // public key
+				[]byte{0x0a, 0x1f}, "/cosmos.crypto.secp256k1.PubKey",
+				[]byte{0x12, 0x23}, []byte{0x0a, 0x21}, compressed,
+				[]byte{0x12, 0x04}, []byte{0x0a, 0x02, 0x08, 0x01}, // direct sign mode
+				[]byte{0x18, 0x03}, // sequence
+				[]byte{0x12, 0x13}, // fee
+				[]byte{0x0a, 0x0d}, []byte{0x0a, 0x05}, "uatom", []byte{0x12, 0x04}, "5000",
+				[]byte{0x10, 0xc0, 0x9a, 0x0c}, // gas limit
+			),
+		},
+		{
+			name: "zero values are omitted",
+			tx: transfer{
+				from: "cosmos1a", to: "cosmos1b", denom: "uatom", amount: "5", pubkey: pubkey,
+				chainId: "c", fee: "0",
+			},
+			wantBody: cat(
+				[]byte{0x0a, 0x40},
+				[]byte{0x0a, 0x1c}, "/cosmos.bank.v1beta1.MsgSend",
+				[]byte{0x12, 0x20},
+				[]byte{0x0a, 0x08}, "cosmos1a",
+				[]byte{0x12, 0x08}, "cosmos1b",
+				[]byte{0x1a, 0x0a}, []byte{0x0a, 0x05}, "uatom", []byte{0x12, 0x01}, "5",
+			),
+			wantAuthInfo: cat(
+				[]byte{0x0a, 0x4e},
+				[]byte{0x0a, 0x46},
+				[]byte{0x0a, 0x1f}, "/cosmos.crypto.secp256k1.PubKey",
+				[]byte{0x12, 0x23}, []byte{0x0a, 0x21}, compressed,
+				[]byte{0x12, 0x04}, []byte{0x0a, 0x02, 0x08, 0x01},
+				[]byte{0x12, 0x00},
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if body := tt.tx.bodyBytes(); !bytes.Equal(body, tt.wantBody) {
+				t.Fatalf("got body %s, want %s", hex.EncodeToString(body), hex.EncodeToString(tt.wantBody))
+			}
+			if authInfo := tt.tx.authInfoBytes(); !bytes.Equal(authInfo, tt.wantAuthInfo) {
+				t.Fatalf("got auth info %s, want %s", hex.EncodeToString(authInfo), hex.EncodeToString(tt.wantAuthInfo))
+			}
+
+			// Simulations are sent with an empty signature.
+			unsigned := cat(
+				[]byte{0x0a, byte(len(tt.wantBody))}, tt.wantBody,
+				[]byte{0x12, byte(len(tt.wantAuthInfo))}, tt.wantAuthInfo,
+				[]byte{0x1a, 0x00},
+			)
+			if tx := tt.tx.encode(nil); !bytes.Equal(tx, unsigned) {
+				t.Fatalf("got unsigned tx %s, want %s", hex.EncodeToString(tx), hex.EncodeToString(unsigned))
+			}
+
+			signed := tt.tx.encode(key)
+			if len(signed) != len(unsigned)+64 || !bytes.Equal(signed[:len(unsigned)-1], unsigned[:len(unsigned)-1]) ||
+				signed[len(unsigned)-1] != 0x40 {
+				t.Fatalf("got signed tx %s", hex.EncodeToString(signed))
+			}
+
+			signDoc := cat(
+				[]byte{0x0a, byte(len(tt.wantBody))}, tt.wantBody,
+				[]byte{0x12, byte(len(tt.wantAuthInfo))}, tt.wantAuthInfo,
+				[]byte{0x1a, 0x01}, tt.tx.chainId,
+			)
+			if tt.tx.accountNumber != 0 {
+				signDoc = cat(signDoc, []byte{0x20, byte(tt.tx.accountNumber)})
+			}
+			if !verify(pubkey, signDoc, signed[len(unsigned):]) {
+				t.Fatal("signature does not verify against the sign doc")
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package cosmos
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
+)
+
+var (
+	SleepTime = time.Second * 60 * 30
+
+	// DefaultPolicy funds 5 tokens whenever the balance drops below 1 token, for a denom with 6
+	// decimals.
+	DefaultPolicy = funding.PolicyCfg{
+		Mode:      funding.ModeFixed,
+		Threshold: "1000000",
+		Amount:    "5000000",
+	}
+)
+
+type watcher struct {
+	mnemonic  string
+	chain     string
+	cfg       Cfg
+	client    Client
+	watchAddr string
+	gasPrice  *big.Rat
+	policy    *funding.Policy
+	burn      *funding.BurnTracker
+	limiter   *funding.Limiter
+	ledger    *ledger.Ledger
+	outflow   *funding.OutflowMonitor
+	audit     *audit.Trail
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      sync.WaitGroup
+}
+
+func NewWatcher(mnemonic string, chain string, client Client, cfg Cfg, pubkey []byte,
+	policy *funding.Policy, burn *funding.BurnTracker, limiter *funding.Limiter, ledger *ledger.Ledger,
+	outflow *funding.OutflowMonitor, audit *audit.Trail) (*watcher, error) {
+	cfg = cfg.withDefaults()
+	gasPrice, err := cfg.gasPrice()
+	if err != nil {
+		return nil, err
+	}
+
+	watchAddr, err := GetAddress(pubkey, cfg.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &watcher{
+		mnemonic:  mnemonic,
+		chain:     chain,
+		cfg:       cfg,
+		client:    client,
+		watchAddr: watchAddr,
+		gasPrice:  gasPrice,
+		policy:    policy,
+		burn:      burn,
+		limiter:   limiter,
+		ledger:    ledger,
+		outflow:   outflow,
+		audit:     audit,
+		ctx:       ctx,
+		cancel:    cancel,
+	}, nil
+}
+
+func (w *watcher) Start() {
+	log.Infof("Starting watcher for chain %s, watch address = %s", w.chain, w.watchAddr)
+	w.done.Add(1)
+	go w.loop()
+}
+
+// Stop stops the watcher and waits for the cycle in progress to finish.
+func (w *watcher) Stop() {
+	w.cancel()
+	w.done.Wait()
+}
+
+func (w *watcher) loop() {
+	defer w.done.Done()
+
+	for {
+		if w.ctx.Err() != nil {
+			return
+		}
+
+		w.checkFaucet()
+		w.check()
+
+		if !funding.Sleep(w.ctx, SleepTime) {
+			return
+		}
+	}
+}
+
+// check funds the watched address if its balance calls for it. A balance that cannot be read is
+// never taken for an empty account.
+func (w *watcher) check() {
+	balance, err := w.client.GetBalance(w.watchAddr, w.cfg.Denom)
+	if err != nil {
+		log.Errorf("Failed to get balance on chain %s, err = %s", w.chain, err)
+		w.audit.Unavailable(audit.Record{Address: w.watchAddr, Threshold: w.policy.Threshold().String()}, err)
+		return
+	}
+	log.Verbosef("Balance in %s: %s on chain %s", w.cfg.Denom, balance, w.chain)
+
+	w.burn.Observe(balance)
+	shouldFund, reason := w.policy.ShouldFund(balance, w.burn)
+	record := audit.Record{
+		Address:   w.watchAddr,
+		Balance:   balance.String(),
+		Threshold: w.policy.Threshold().String(),
+		Reason:    reason,
+	}
+	if amount := w.policy.Amount(balance); shouldFund && amount.Sign() > 0 {
+		log.Infof("Funding chain %s, reason: %s", w.chain, reason)
+		w.fund(amount, record)
+	} else {
+		record.Decision = audit.Skip
+		w.audit.Record(record)
+	}
+}
+
+// checkFaucet reports the faucet balance and sequence to the outflow monitor.
+func (w *watcher) checkFaucet() {
+	if !w.outflow.Enabled() {
+		return
+	}
+
+	privKey, err := getPrivateKey(w.mnemonic, w.cfg.CoinType)
+	if err != nil {
+		log.Errorf("Failed to get faucet key on chain %s, err = %s", w.chain, err)
+		return
+	}
+	faucetAddr, err := GetAddress(privKey.PubKey().SerializeCompressed(), w.cfg.Prefix)
+	if err != nil {
+		log.Errorf("Failed to get faucet address on chain %s, err = %s", w.chain, err)
+		return
+	}
+
+	_, sequence, err := w.client.GetAccount(faucetAddr)
+	if err != nil {
+		log.Errorf("Failed to get faucet account on chain %s, err = %s", w.chain, err)
+		return
+	}
+	balance, err := w.client.GetBalance(faucetAddr, w.cfg.Denom)
+	if err != nil {
+		log.Errorf("Failed to get faucet balance on chain %s, err = %s", w.chain, err)
+		return
+	}
+
+	w.outflow.Observe(funding.FaucetState{
+		Address:  faucetAddr,
+		Balance:  balance,
+		Nonce:    sequence,
+		HasNonce: true,
+	})
+}
+
+func (w *watcher) fund(amount *big.Int, record audit.Record) {
+	record.Amount = amount.String()
+	reservation, err := w.limiter.Reserve(w.chain, w.watchAddr, amount)
+	if err != nil {
+		log.Errorf("Cannot fund chain %s, err = %s", w.chain, err)
+		w.audit.Refused(record, err)
+		return
+	}
+
+	txHash, err := w.transfer(amount)
+	if err != nil {
+		reservation.Release()
+		log.Errorf("Failed to fund chain %s, err = %s", w.chain, err)
+		w.audit.Failed(record, err)
+		return
+	}
+	reservation.Commit()
+
+	w.burn.RecordTopUp()
+	log.Infof("Cosmos txHash = %s on chain %s", txHash, w.chain)
+	w.audit.Funded(record, txHash)
+}
+
+// transfer sends amount from the faucet to the watched address with a MsgSend. The gas limit is
+// the simulated gas times the gas adjustment.
+func (w *watcher) transfer(amount *big.Int) (string, error) {
+	privKey, err := getPrivateKey(w.mnemonic, w.cfg.CoinType)
+	if err != nil {
+		return "", err
+	}
+	faucetAddr, err := GetAddress(privKey.PubKey().SerializeCompressed(), w.cfg.Prefix)
+	if err != nil {
+		return "", err
+	}
+
+	accountNumber, sequence, err := w.client.GetAccount(faucetAddr)
+	if err != nil {
+		return "", fmt.Errorf("cannot get faucet account %s, err = %w", faucetAddr, err)
+	}
+
+	tx := &transfer{
+		from:          faucetAddr,
+		to:            w.watchAddr,
+		denom:         w.cfg.Denom,
+		amount:        amount.String(),
+		memo:          w.cfg.Memo,
+		pubkey:        privKey.PubKey(),
+		sequence:      sequence,
+		accountNumber: accountNumber,
+		chainId:       w.cfg.ChainId,
+	}
+
+	gasUsed, err := w.client.Simulate(tx.encode(nil))
+	if err != nil {
+		return "", fmt.Errorf("simulation failed, err = %w", err)
+	}
+	tx.gasLimit = uint64(math.Ceil(float64(gasUsed) * w.cfg.GasAdjustment))
+
+	fee := w.fee(tx.gasLimit)
+	tx.fee = fee.String()
+
+	faucetBalance, err := w.client.GetBalance(faucetAddr, w.cfg.Denom)
+	if err != nil {
+		return "", err
+	}
+	if faucetBalance.Cmp(new(big.Int).Add(amount, fee)) < 0 {
+		return "", fmt.Errorf("insufficient faucet balance %s, need %s plus fee %s", faucetBalance, amount, fee)
+	}
+
+	log.Infof("Funding %s%s from %s to %s on chain %s, gas = %d, fee = %s%s", amount, w.cfg.Denom,
+		faucetAddr, w.watchAddr, w.chain, tx.gasLimit, fee, w.cfg.Denom)
+
+	txHash, err := w.client.Broadcast(tx.encode(privKey))
+	if err != nil {
+		return "", err
+	}
+	w.outflow.Expect(amount, fee)
+
+	if err := w.ledger.Record(ledger.Entry{
+		Chain:  w.chain,
+		TxHash: txHash,
+		From:   faucetAddr,
+		To:     w.watchAddr,
+		Amount: amount.String(),
+		Label:  "mpc",
+	}); err != nil {
+		log.Errorf("Failed to record transfer %s on chain %s, err = %s", txHash, w.chain, err)
+	}
+
+	return txHash, nil
+}
+
+// fee returns the gas limit times the gas price, rounded up.
+func (w *watcher) fee(gasLimit uint64) *big.Int {
+	fee := new(big.Rat).Mul(new(big.Rat).SetInt(new(big.Int).SetUint64(gasLimit)), w.gasPrice)
+	quo, rem := new(big.Int).QuoRem(fee.Num(), fee.Denom(), new(big.Int))
+	if rem.Sign() > 0 {
+		quo.Add(quo, big.NewInt(1))
+	}
+
+	return quo
+}
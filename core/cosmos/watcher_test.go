@@ -0,0 +1,107 @@
+package cosmos
+
+import (
+	"bytes"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/sisu-network/sisu-account-funding/core/alert"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// newTestWatcher returns a watcher talking to the stand-in at url, and the address of its faucet.
+func newTestWatcher(t *testing.T, url string, cfg Cfg) (*watcher, string) {
+	t.Helper()
+
+	dataDir := t.TempDir()
+	_, mpcKey := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{1}, 32))
+	policy, err := funding.NewPolicy(funding.PolicyCfg{}, DefaultPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerter := alert.NewAlerter("")
+	limiter, err := funding.NewLimiter(filepath.Join(dataDir, "limiter.json"), funding.GlobalLimitCfg{}, nil, alerter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewClient(url, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWatcher(testMnemonic, "cosmos-test", client, cfg, mpcKey.SerializeCompressed(), policy,
+		funding.NewBurnTracker(filepath.Join(dataDir, "burn.json"), 0), limiter,
+		ledger.NewLedger(filepath.Join(dataDir, "ledger.jsonl")),
+		funding.NewOutflowMonitor("cosmos-test", funding.OutflowCfg{Disabled: true}, limiter, alerter),
+		(*audit.Log)(nil).Trail("cosmos-test", url))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privKey, err := getPrivateKey(testMnemonic, DefaultCoinType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	faucet, err := GetAddress(privKey.PubKey().SerializeCompressed(), cfg.Prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w, faucet
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance int64
+		faucet  int64
+		// status, when set, is the answer of the node to the balance queries of the watched address.
+		status int
+		// sent is the amount transferred, 0 if nothing is sent.
+		sent int64
+	}{
+		{name: "above the threshold", balance: 2_000_000, faucet: 10_000_000},
+		{name: "below the threshold", balance: 500_000, faucet: 10_000_000, sent: 5_000_000},
+		{name: "never funded", faucet: 10_000_000, sent: 5_000_000},
+		{name: "insufficient faucet balance", faucet: 5_000_000},
+		{name: "balance not found", faucet: 10_000_000, status: http.StatusNotFound},
+		{name: "node error", faucet: 10_000_000, status: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn, url := newStandIn(t, "test-1", "uatom")
+			w, faucet := newTestWatcher(t, url, Cfg{Prefix: "cosmos", ChainId: "test-1", Denom: "uatom", GasPrice: "0.025"})
+			standIn.setBalance(faucet, tt.faucet)
+			if tt.balance != 0 {
+				standIn.setBalance(w.watchAddr, tt.balance)
+			}
+			standIn.setBalanceStatus(w.watchAddr, tt.status)
+
+			w.check()
+
+			if tt.sent == 0 {
+				if txs := standIn.broadcast(); len(txs) != 0 {
+					t.Fatalf("got %d transactions, want none", len(txs))
+				}
+				return
+			}
+			if len(standIn.broadcast()) != 1 {
+				t.Fatalf("got %d transactions, want 1", len(standIn.broadcast()))
+			}
+			if balance := standIn.balance(w.watchAddr); balance != tt.balance+tt.sent {
+				t.Fatalf("got mpc balance %d, want %d", balance, tt.balance+tt.sent)
+			}
+			// 80000 gas times 1.3 at 0.025uatom.
+			if balance := standIn.balance(faucet); balance != tt.faucet-tt.sent-2600 {
+				t.Fatalf("got faucet balance %d, want %d", balance, tt.faucet-tt.sent-2600)
+			}
+		})
+	}
+}
@@ -12,7 +12,6 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 func getPrivateKey(mnemonic string) (*ecdsa.PrivateKey, common.Address) {
@@ -48,13 +47,11 @@ func getPrivateKey(mnemonic string) (*ecdsa.PrivateKey, common.Address) {
 	return privateKeyECDSA, addr
 }
 
-func getAuthTransactor(client *ethclient.Client, mnemonic string) (*bind.TransactOpts, error) {
+// getAuthTransactor returns transact options with a nonce reserved from nonces. The caller must
+// report the nonce back to nonces with Sent or Release.
+func getAuthTransactor(client Client, nonces *NonceManager, mnemonic, chain string) (*bind.TransactOpts, error) {
 	// This is the private key of the accounts0
 	privateKey, owner := getPrivateKey(mnemonic)
-	nonce, err := client.PendingNonceAt(context.Background(), owner)
-	if err != nil {
-		return nil, err
-	}
 	gasPrice, err := client.SuggestGasPrice(context.Background())
 	if err != nil {
 		return nil, err
@@ -71,6 +68,11 @@ func getAuthTransactor(client *ethclient.Client, mnemonic string) (*bind.Transac
 		return nil, err
 	}
 
+	nonce, err := nonces.Reserve(client, chain, owner)
+	if err != nil {
+		return nil, err
+	}
+
 	auth.Nonce = big.NewInt(int64(nonce))
 	auth.Value = big.NewInt(0)
 	auth.GasPrice = gasPrice
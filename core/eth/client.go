@@ -0,0 +1,71 @@
+package eth
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/sisu-network/lib/log"
+)
+
+// Client is the part of an EVM node api used by the watcher and its transfers. It lets the watcher
+// run against a node, the simulated backend of go-ethereum or a FakeClient in tests.
+type Client interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SendTransaction(ctx context.Context, tx *ethtypes.Transaction) error
+	// TransactionByHash returns ethereum.NotFound for a transaction unknown to the node.
+	TransactionByHash(ctx context.Context, hash common.Hash) (*ethtypes.Transaction, bool, error)
+	// TransactionReceipt returns ethereum.NotFound until the transaction is mined.
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*ethtypes.Receipt, error)
+}
+
+// DialClients returns a client for each url of chain. The client of an url that cannot be dialed
+// is nil, the watcher skips it.
+func DialClients(chain string, urls []string) []Client {
+	clients := make([]Client, len(urls))
+	for i, url := range urls {
+		client, err := ethclient.Dial(url)
+		if err != nil {
+			log.Errorf("Cannot dial chain %s, url = %s, err = %s", chain, url, err)
+			continue
+		}
+
+		log.Verbosef("Setting client for url %s", url)
+		clients[i] = client
+	}
+
+	return clients
+}
+
+// SimulatedClient runs the watcher against an in-process chain of go-ethereum. Every transaction
+// is mined in its own block as soon as it is sent.
+type SimulatedClient struct {
+	*backends.SimulatedBackend
+}
+
+// NewSimulatedClient returns a simulated chain whose genesis gives the balances of alloc, e.g. to
+// the faucet of a test mnemonic.
+func NewSimulatedClient(alloc core.GenesisAlloc) *SimulatedClient {
+	return &SimulatedClient{backends.NewSimulatedBackend(alloc, 30_000_000)}
+}
+
+func (c *SimulatedClient) ChainID(ctx context.Context) (*big.Int, error) {
+	return c.Blockchain().Config().ChainID, nil
+}
+
+func (c *SimulatedClient) SendTransaction(ctx context.Context, tx *ethtypes.Transaction) error {
+	if err := c.SimulatedBackend.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	c.Commit()
+
+	return nil
+}
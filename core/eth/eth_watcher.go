@@ -2,97 +2,239 @@ package eth
 
 import (
 	"context"
+	"expvar"
+	"fmt"
 	"math/big"
+	"sync"
 	"time"
 
-	ethcommon "github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/sisu-network/lib/log"
-	"go.uber.org/atomic"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
 )
 
 var (
 	SleepTime        = time.Second * 60 * 30
 	ONE_ETHER_IN_WEI = big.NewInt(1000000000000000000)
+
+	// DefaultPolicy funds 0.03 ETH whenever the balance drops below 0.1 ETH.
+	DefaultPolicy = funding.PolicyCfg{
+		Mode:      funding.ModeFixed,
+		Threshold: "100000000000000000",
+		Amount:    "30000000000000000",
+	}
+
+	// Number of top-ups deferred because of high gas prices, per chain.
+	deferredTopUps = expvar.NewMap("eth_deferred_topups")
 )
 
 type watcher struct {
-	mnemonic  string
-	chain     string
-	urls      []string
-	clients   []*ethclient.Client
-	watchAddr ethcommon.Address
-	stop      atomic.Bool
+	mnemonic string
+	chain    string
+	urls     []string
+	clients  []Client
+	targets  []*Target
+	nonces   *NonceManager
+	limiter  *funding.Limiter
+	ledger   *ledger.Ledger
+	outflow  *funding.OutflowMonitor
+	audit    *audit.Trail
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     sync.WaitGroup
 }
 
-func NewWatcher(mnemonic string, chain string, urls []string, watchAddr string) *watcher {
+// NewWatcher returns the watcher of chain. clients[i] is the client of urls[i], nil if it cannot be
+// used.
+func NewWatcher(mnemonic string, chain string, urls []string, clients []Client, targets []*Target,
+	nonces *NonceManager, limiter *funding.Limiter, ledger *ledger.Ledger, outflow *funding.OutflowMonitor,
+	audit *audit.Trail) *watcher {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &watcher{
-		mnemonic:  mnemonic,
-		chain:     chain,
-		urls:      urls,
-		clients:   make([]*ethclient.Client, len(urls)),
-		watchAddr: ethcommon.HexToAddress(watchAddr),
-		stop:      *atomic.NewBool(false),
+		mnemonic: mnemonic,
+		chain:    chain,
+		urls:     urls,
+		clients:  clients,
+		targets:  targets,
+		nonces:   nonces,
+		limiter:  limiter,
+		ledger:   ledger,
+		outflow:  outflow,
+		audit:    audit,
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 }
 
 func (w *watcher) Start() {
-	log.Infof("Starting watcher for chain %s, watch address = %s",
-		w.chain, w.watchAddr.String())
-	w.init()
+	for _, target := range w.targets {
+		log.Infof("Starting watcher for chain %s, watch address = %s (%s)",
+			w.chain, target.Address.String(), target.Label)
+	}
 
+	w.done.Add(1)
 	go w.loop()
 }
 
+// Stop stops the watcher and waits for the cycle in progress to finish.
 func (w *watcher) Stop() {
-	w.stop.Store(true)
+	w.cancel()
+	w.done.Wait()
+}
+
+func (w *watcher) loop() {
+	defer w.done.Done()
+
+	for {
+		if w.ctx.Err() != nil {
+			return
+		}
+
+		w.checkFaucet()
+
+		// Targets are funded one after another so that their transfers never race each other.
+		for _, target := range w.targets {
+			w.check(target)
+		}
+
+		if !funding.Sleep(w.ctx, SleepTime) {
+			return
+		}
+	}
 }
 
-func (w *watcher) init() {
-	for i, url := range w.urls {
-		client, err := ethclient.Dial(url)
+// checkFaucet reports the faucet state to the outflow monitor, from the first healthy client.
+func (w *watcher) checkFaucet() {
+	if !w.outflow.Enabled() {
+		return
+	}
+
+	_, faucet := getPrivateKey(w.mnemonic)
+	for i, client := range w.clients {
+		if client == nil {
+			continue
+		}
+
+		balance, err := client.BalanceAt(context.Background(), faucet, nil)
+		if err != nil {
+			log.Errorf("Failed to get faucet balance on chain %s, url = %s, err = %s", w.chain, w.urls[i], err)
+			continue
+		}
+		nonce, err := client.NonceAt(context.Background(), faucet, nil)
 		if err != nil {
-			log.Error("cannot dial source chain, url = ", url)
+			log.Errorf("Failed to get faucet nonce on chain %s, url = %s, err = %s", w.chain, w.urls[i], err)
 			continue
 		}
 
-		log.Verbosef("Setting client for url %s", url)
-		w.clients[i] = client
+		w.outflow.Observe(funding.FaucetState{
+			Address:  faucet.String(),
+			Balance:  balance,
+			Nonce:    nonce,
+			HasNonce: true,
+		})
+		return
 	}
 }
 
-func (w *watcher) loop() {
-	threshold := new(big.Int).Div(ONE_ETHER_IN_WEI, big.NewInt(10))
-	fundingAmount := big.NewInt(30_000_000_000_000_000) // 0.03 ETH
+// check queries the balance of a target from the first healthy client and tops it up if needed.
+func (w *watcher) check(target *Target) {
+	record := audit.Record{
+		Address:   target.Address.String(),
+		Label:     target.Label,
+		Threshold: target.Policy.Threshold().String(),
+	}
 
-	for {
-		if w.stop.Load() {
+	lastErr := fmt.Errorf("no rpc is connected")
+	for i, client := range w.clients {
+		if client == nil {
+			continue
+		}
+
+		record.Rpc = w.urls[i]
+		balance, err := client.BalanceAt(context.Background(), target.Address, nil)
+		if err != nil {
+			log.Errorf("Failed to get balance on chain %s, url = %s, err = %s", w.chain, w.urls[i], err)
+			lastErr = err
+			continue
+		}
+		record.Balance = balance.String()
+
+		amountFloat := new(big.Float).Quo(new(big.Float).SetInt(balance), new(big.Float).SetInt(ONE_ETHER_IN_WEI))
+		log.Verbosef("Amount in ETH: %s on chain %s for %s", amountFloat.String(), w.chain, target.Label)
+
+		target.Burn.Observe(balance)
+		shouldFund, reason := target.Policy.ShouldFund(balance, target.Burn)
+		fundingAmount := target.Policy.Amount(balance)
+		record.Reason = reason
+		if !shouldFund || fundingAmount.Sign() == 0 {
+			record.Decision = audit.Skip
+			w.audit.Record(record)
 			return
 		}
+		record.Amount = fundingAmount.String()
 
-		// Query the account balance.
-		for i, client := range w.clients {
-			balance, err := client.BalanceAt(context.Background(), w.watchAddr, nil)
-			if err != nil {
-				log.Errorf("Failed to get balance on chain %s, url = %s, err = %s", w.chain, w.urls[i], err.Error())
-				continue
-			}
+		gasPrice, err := client.SuggestGasPrice(context.Background())
+		if err != nil {
+			log.Errorf("Failed to get gas price on chain %s, url = %s, err = %s", w.chain, w.urls[i], err)
+			w.audit.Failed(record, fmt.Errorf("cannot get gas price: %w", err))
+			return
+		}
 
-			amountFloat := new(big.Float).Quo(new(big.Float).SetInt(balance), new(big.Float).SetInt(ONE_ETHER_IN_WEI))
-			log.Verbose("Amount in ETH: ", amountFloat, " on chain ", w.chain)
+		deferred, gasReason := target.Gas.ShouldDefer(balance, gasPrice)
+		if deferred {
+			log.Infof("Deferring top-up of %s on chain %s, %s", target.Label, w.chain, gasReason)
+			deferredTopUps.Add(w.chain, 1)
+			record.Decision = audit.Defer
+			record.Reason = gasReason
+			w.audit.Record(record)
+			return
+		}
+		log.Verbosef("Not deferring top-up of %s on chain %s, %s", target.Label, w.chain, gasReason)
 
-			if balance.Cmp(threshold) < 0 {
-				log.Infof("Funding chain %s", w.chain)
-				// Balance is less than the threshold. Let's top up the account.
-				err := TransferEth(client, w.mnemonic, w.chain, w.watchAddr, fundingAmount)
-				if err != nil {
-					log.Errorf("Failed to transfer eth on chain %s, err  = %s", w.chain, err.Error())
-				}
-			}
+		reservation, err := w.limiter.Reserve(w.chain, target.Address.String(), fundingAmount)
+		if err != nil {
+			log.Errorf("Cannot fund %s on chain %s, err = %s", target.Label, w.chain, err)
+			w.audit.Refused(record, err)
+			return
+		}
 
-			break
+		log.Infof("Funding %s on chain %s, reason: %s", target.Label, w.chain, reason)
+		tx, err := TransferEth(client, w.nonces, w.mnemonic, w.chain, target.Address, fundingAmount)
+		record.Decision = audit.Fund
+		if tx != nil {
+			reservation.Commit()
+			// Recorded once sent, a transfer that is slow to be mined still tops up the balance.
+			target.Burn.RecordTopUp()
+			record.TxHash = tx.Hash().String()
+
+			fee := new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(tx.Gas()))
+			w.outflow.Expect(fundingAmount, fee)
+
+			_, faucet := getPrivateKey(w.mnemonic)
+			if err := w.ledger.Record(ledger.Entry{
+				Chain:  w.chain,
+				TxHash: tx.Hash().String(),
+				From:   faucet.String(),
+				To:     target.Address.String(),
+				Amount: fundingAmount.String(),
+				Label:  target.Label,
+			}); err != nil {
+				log.Errorf("Failed to record transfer %s on chain %s, err = %s", tx.Hash(), w.chain, err)
+			}
+		}
+		if err != nil {
+			if tx == nil {
+				reservation.Release()
+			}
+			log.Errorf("Failed to transfer eth on chain %s, err = %s", w.chain, err)
+			w.audit.Failed(record, err)
+			return
 		}
+		w.audit.Record(record)
 
-		time.Sleep(SleepTime)
+		return
 	}
+
+	w.audit.Unavailable(record, lastErr)
 }
@@ -0,0 +1,483 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/sisu-network/sisu-account-funding/core/alert"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
+	"github.com/sisu-network/sisu-account-funding/core/store"
+)
+
+const (
+	testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	// rotatedMnemonic is the faucet after a key rotation.
+	rotatedMnemonic = "test test test test test test test test test test test junk"
+	testChain       = "eth-test"
+)
+
+var (
+	testChainId = big.NewInt(1337)
+	testTarget  = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	// rotatedTarget is the MPC address of the chain after a key rotation.
+	rotatedTarget = common.HexToAddress("0x2222222222222222222222222222222222222222")
+)
+
+// newTestNonces returns a nonce manager persisted in a temporary directory.
+func newTestNonces(t *testing.T) *NonceManager {
+	t.Helper()
+
+	nonces, err := NewNonceManager(filepath.Join(t.TempDir(), "nonces.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return nonces
+}
+
+// newTestTarget returns a target funded with the default policy of the chain.
+func newTestTarget(t *testing.T, address common.Address, gasCfg GasCfg) *Target {
+	t.Helper()
+
+	policy, err := funding.NewPolicy(funding.PolicyCfg{}, DefaultPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gas, err := NewGasPolicy(gasCfg, policy.Threshold())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Target{
+		Label:   "mpc",
+		Address: address,
+		Policy:  policy,
+		Burn:    funding.NewBurnTracker(filepath.Join(t.TempDir(), "burn.json"), 0),
+		Gas:     gas,
+	}
+}
+
+// newTestWatcher returns a watcher of the faucet of mnemonic that funds targets through clients.
+func newTestWatcher(t *testing.T, mnemonic string, clients []Client, targets []*Target,
+	nonces *NonceManager) *watcher {
+	t.Helper()
+
+	dataDir := t.TempDir()
+	alerter := alert.NewAlerter("")
+	limiter, err := funding.NewLimiter(filepath.Join(dataDir, "limiter.json"), funding.GlobalLimitCfg{}, nil, alerter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls := make([]string, len(clients))
+	for i := range urls {
+		urls[i] = "http://node" + string(rune('0'+i))
+	}
+
+	return NewWatcher(mnemonic, testChain, urls, clients, targets, nonces, limiter,
+		ledger.NewLedger(filepath.Join(dataDir, "ledger.jsonl")),
+		funding.NewOutflowMonitor(testChain, funding.OutflowCfg{Disabled: true}, limiter, alerter),
+		(*audit.Log)(nil).Trail(testChain, urls[0]))
+}
+
+// newFundedClient returns a fake node where the faucets of the test mnemonics hold 1 ETH.
+func newFundedClient() *FakeClient {
+	client := NewFakeClient(testChainId)
+	for _, mnemonic := range []string{testMnemonic, rotatedMnemonic} {
+		_, faucet := getPrivateKey(mnemonic)
+		client.SetBalance(faucet, ONE_ETHER_IN_WEI)
+	}
+
+	return client
+}
+
+func ether(milli int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(milli), big.NewInt(1_000_000_000_000_000))
+}
+
+func balanceOf(t *testing.T, client Client, account common.Address) *big.Int {
+	t.Helper()
+
+	balance, err := client.BalanceAt(context.Background(), account, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return balance
+}
+
+func senderOf(t *testing.T, tx *ethtypes.Transaction) common.Address {
+	t.Helper()
+
+	sender, err := ethtypes.Sender(ethtypes.LatestSignerForChainID(testChainId), tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sender
+}
+
+func TestCheckThreshold(t *testing.T) {
+	tests := []struct {
+		name     string
+		balance  *big.Int
+		gasPrice *big.Int
+		gas      GasCfg
+		// funded is the amount sent to the target, 0 if nothing is sent.
+		funded *big.Int
+	}{
+		{
+			name:    "balance above the threshold is skipped",
+			balance: ether(200),
+			funded:  big.NewInt(0),
+		},
+		{
+			name:    "balance at the threshold is skipped",
+			balance: ether(100),
+			funded:  big.NewInt(0),
+		},
+		{
+			name:    "balance below the threshold is funded",
+			balance: ether(99),
+			funded:  ether(30),
+		},
+		{
+			name:     "non-urgent top-up is deferred while gas is above the ceiling",
+			balance:  ether(80),
+			gasPrice: big.NewInt(2_000_000_000),
+			gas:      GasCfg{MaxGasPrice: "1000000000"},
+			funded:   big.NewInt(0),
+		},
+		{
+			name:     "non-urgent top-up goes through while gas is within the ceiling",
+			balance:  ether(80),
+			gasPrice: big.NewInt(1_000_000_000),
+			gas:      GasCfg{MaxGasPrice: "1000000000"},
+			funded:   ether(30),
+		},
+		{
+			name:     "urgent top-up is never deferred",
+			balance:  ether(40),
+			gasPrice: big.NewInt(2_000_000_000),
+			gas:      GasCfg{MaxGasPrice: "1000000000"},
+			funded:   ether(30),
+		},
+		{
+			name:     "critical balance can be configured",
+			balance:  ether(40),
+			gasPrice: big.NewInt(2_000_000_000),
+			gas:      GasCfg{MaxGasPrice: "1000000000", CriticalBalance: ether(10).String()},
+			funded:   big.NewInt(0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFundedClient()
+			client.SetBalance(testTarget, tt.balance)
+			if tt.gasPrice != nil {
+				client.SetGasPrice(tt.gasPrice)
+			}
+			target := newTestTarget(t, testTarget, tt.gas)
+			w := newTestWatcher(t, testMnemonic, []Client{client}, []*Target{target}, newTestNonces(t))
+
+			w.check(target)
+
+			funded := new(big.Int).Sub(balanceOf(t, client, testTarget), tt.balance)
+			if funded.Cmp(tt.funded) != 0 {
+				t.Errorf("funded %s, want %s", funded, tt.funded)
+			}
+			if sent := len(client.Sent()); (sent != 0) != (tt.funded.Sign() != 0) {
+				t.Errorf("sent %d transactions, want a transfer = %v", sent, tt.funded.Sign() != 0)
+			}
+		})
+	}
+}
+
+func TestCheckFailover(t *testing.T) {
+	down := errors.New("connection refused")
+
+	tests := []struct {
+		name string
+		// errs are the errors of the clients and nils the clients that cannot be dialed.
+		errs []error
+		nils []bool
+		// used is the index of the client that sends the transfer, -1 if none does.
+		used int
+	}{
+		{
+			name: "first healthy client is used",
+			errs: []error{nil, nil},
+			nils: []bool{false, false},
+			used: 0,
+		},
+		{
+			name: "client that is down is skipped",
+			errs: []error{down, nil},
+			nils: []bool{false, false},
+			used: 1,
+		},
+		{
+			name: "client that cannot be dialed is skipped",
+			errs: []error{nil, nil},
+			nils: []bool{true, false},
+			used: 1,
+		},
+		{
+			name: "nothing is sent when no client is usable",
+			errs: []error{down, down},
+			nils: []bool{false, true},
+			used: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakes := make([]*FakeClient, len(tt.errs))
+			clients := make([]Client, len(tt.errs))
+			for i, err := range tt.errs {
+				fakes[i] = newFundedClient()
+				fakes[i].SetBalance(testTarget, ether(50))
+				fakes[i].SetErr(err)
+				if !tt.nils[i] {
+					clients[i] = fakes[i]
+				}
+			}
+			target := newTestTarget(t, testTarget, GasCfg{})
+			w := newTestWatcher(t, testMnemonic, clients, []*Target{target}, newTestNonces(t))
+
+			w.check(target)
+
+			for i, fake := range fakes {
+				want := 0
+				if i == tt.used {
+					want = 1
+				}
+				if sent := len(fake.Sent()); sent != want {
+					t.Errorf("client %d sent %d transactions, want %d", i, sent, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckKeyRotation(t *testing.T) {
+	tests := []struct {
+		name          string
+		faucet        string
+		target        common.Address
+		wantNonce     uint64
+		wantOldNonce  uint64
+		wantRecipient common.Address
+	}{
+		{
+			name:          "same keys keep the nonce sequence",
+			faucet:        testMnemonic,
+			target:        testTarget,
+			wantNonce:     1,
+			wantOldNonce:  2,
+			wantRecipient: testTarget,
+		},
+		{
+			name:          "rotated faucet starts at its own nonce",
+			faucet:        rotatedMnemonic,
+			target:        testTarget,
+			wantNonce:     0,
+			wantOldNonce:  1,
+			wantRecipient: testTarget,
+		},
+		{
+			name:          "rotated mpc address is funded",
+			faucet:        testMnemonic,
+			target:        rotatedTarget,
+			wantNonce:     1,
+			wantOldNonce:  2,
+			wantRecipient: rotatedTarget,
+		},
+		{
+			name:          "rotated faucet funds the rotated mpc address",
+			faucet:        rotatedMnemonic,
+			target:        rotatedTarget,
+			wantNonce:     0,
+			wantOldNonce:  1,
+			wantRecipient: rotatedTarget,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFundedClient()
+			nonces := newTestNonces(t)
+
+			// The first cycle runs with the old keys.
+			client.SetBalance(testTarget, ether(50))
+			target := newTestTarget(t, testTarget, GasCfg{})
+			newTestWatcher(t, testMnemonic, []Client{client}, []*Target{target}, nonces).check(target)
+
+			// The reloaded watcher shares the nonces of the funder.
+			client.SetBalance(tt.target, ether(50))
+			rotated := newTestTarget(t, tt.target, GasCfg{})
+			newTestWatcher(t, tt.faucet, []Client{client}, []*Target{rotated}, nonces).check(rotated)
+
+			_, faucet := getPrivateKey(tt.faucet)
+			var tx *ethtypes.Transaction
+			for _, sent := range client.Sent() {
+				if senderOf(t, sent) == faucet && sent.Nonce() == tt.wantNonce {
+					tx = sent
+				}
+			}
+			if tx == nil {
+				t.Fatalf("no transaction from %s with nonce %d", faucet, tt.wantNonce)
+			}
+			if *tx.To() != tt.wantRecipient {
+				t.Errorf("recipient %s, want %s", tx.To(), tt.wantRecipient)
+			}
+			if balance := balanceOf(t, client, tt.wantRecipient); balance.Cmp(ether(80)) != 0 {
+				t.Errorf("recipient balance %s, want %s", balance, ether(80))
+			}
+
+			// The old faucet carries on from where it stopped.
+			_, oldFaucet := getPrivateKey(testMnemonic)
+			nonce, err := nonces.Reserve(client, testChain, oldFaucet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if nonce != tt.wantOldNonce {
+				t.Errorf("old faucet nonce %d, want %d", nonce, tt.wantOldNonce)
+			}
+		})
+	}
+}
+
+func TestCheckRecordsTopUp(t *testing.T) {
+	timeout, pollTime := TxTimeout, TxPollTime
+	TxTimeout, TxPollTime = time.Millisecond*50, time.Millisecond*5
+	defer func() {
+		TxTimeout, TxPollTime = timeout, pollTime
+	}()
+
+	tests := []struct {
+		name    string
+		balance *big.Int
+		hold    bool
+		topUp   bool
+	}{
+		{
+			name:    "mined transfer is a top-up",
+			balance: ether(50),
+			topUp:   true,
+		},
+		{
+			name:    "transfer slow to be mined is a top-up",
+			balance: ether(50),
+			hold:    true,
+			topUp:   true,
+		},
+		{
+			name:    "skipped target is not topped up",
+			balance: ether(200),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFundedClient()
+			client.SetBalance(testTarget, tt.balance)
+			client.HoldMining(tt.hold)
+			burnFile := filepath.Join(t.TempDir(), "burn.json")
+			target := newTestTarget(t, testTarget, GasCfg{})
+			target.Burn = funding.NewBurnTracker(burnFile, 0)
+			w := newTestWatcher(t, testMnemonic, []Client{client}, []*Target{target}, newTestNonces(t))
+
+			w.check(target)
+			client.Mine()
+			target.Burn.Observe(balanceOf(t, client, testTarget))
+
+			var readings []struct {
+				TopUp bool `json:"top_up"`
+			}
+			if err := store.Load(burnFile, &readings); err != nil {
+				t.Fatal(err)
+			}
+			if len(readings) != 2 {
+				t.Fatalf("got %d readings, want 2", len(readings))
+			}
+			if readings[1].TopUp != tt.topUp {
+				t.Errorf("top-up = %v, want %v", readings[1].TopUp, tt.topUp)
+			}
+		})
+	}
+}
+
+// TestCheckSimulated funds the target through the simulated backend of go-ethereum, which signs,
+// executes and mines the transfer like a node.
+func TestCheckSimulated(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance *big.Int
+		// funded is the amount sent to the target, 0 if nothing is sent.
+		funded *big.Int
+	}{
+		{
+			name:    "balance below the threshold is funded",
+			balance: ether(50),
+			funded:  ether(30),
+		},
+		{
+			name:    "empty target is funded",
+			balance: big.NewInt(0),
+			funded:  ether(30),
+		},
+		{
+			name:    "balance above the threshold is skipped",
+			balance: ether(200),
+			funded:  big.NewInt(0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, faucet := getPrivateKey(testMnemonic)
+			client := NewSimulatedClient(core.GenesisAlloc{
+				faucet:     {Balance: ONE_ETHER_IN_WEI},
+				testTarget: {Balance: tt.balance},
+			})
+			defer client.Close()
+			target := newTestTarget(t, testTarget, GasCfg{})
+			w := newTestWatcher(t, testMnemonic, []Client{client}, []*Target{target}, newTestNonces(t))
+
+			w.check(target)
+
+			funded := new(big.Int).Sub(balanceOf(t, client, testTarget), tt.balance)
+			if funded.Cmp(tt.funded) != 0 {
+				t.Fatalf("funded %s, want %s", funded, tt.funded)
+			}
+
+			nonce, err := client.NonceAt(context.Background(), faucet, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sent := nonce != 0; sent != (tt.funded.Sign() != 0) {
+				t.Fatalf("faucet nonce %d, want a transfer = %v", nonce, tt.funded.Sign() != 0)
+			}
+			if nonce == 0 {
+				return
+			}
+
+			// The faucet pays the transfer and its gas.
+			spent := new(big.Int).Sub(ONE_ETHER_IN_WEI, balanceOf(t, client, faucet))
+			if spent.Cmp(tt.funded) <= 0 {
+				t.Errorf("faucet spent %s, want more than %s", spent, tt.funded)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package eth
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// FakeClient is an in-memory Client to test the watcher without a node. It checks the signature,
+// nonce and balance of the transactions sent to it and mines them at once, moving their value and
+// fee from the sender, unless mining is held to test stuck transactions.
+type FakeClient struct {
+	lock     sync.Mutex
+	chainId  *big.Int
+	gasPrice *big.Int
+	balances map[common.Address]*big.Int
+	nonces   map[common.Address]uint64
+	txs      map[common.Hash]*ethtypes.Transaction
+	receipts map[common.Hash]*ethtypes.Receipt
+	// pending are the transactions sent while mining is held, in order.
+	pending []*ethtypes.Transaction
+	hold    bool
+	err     error
+}
+
+func NewFakeClient(chainId *big.Int) *FakeClient {
+	return &FakeClient{
+		chainId:  chainId,
+		gasPrice: big.NewInt(1_000_000_000),
+		balances: make(map[common.Address]*big.Int),
+		nonces:   make(map[common.Address]uint64),
+		txs:      make(map[common.Hash]*ethtypes.Transaction),
+		receipts: make(map[common.Hash]*ethtypes.Receipt),
+	}
+}
+
+// SetBalance sets the balance of account in wei.
+func (c *FakeClient) SetBalance(account common.Address, balance *big.Int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.balances[account] = new(big.Int).Set(balance)
+}
+
+// SetGasPrice sets the gas price suggested by the client.
+func (c *FakeClient) SetGasPrice(gasPrice *big.Int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.gasPrice = new(big.Int).Set(gasPrice)
+}
+
+// SetErr makes every call fail with err, to simulate a node that is down. A nil err restores it.
+func (c *FakeClient) SetErr(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.err = err
+}
+
+// HoldMining keeps the transactions sent from now on pending until Mine is called.
+func (c *FakeClient) HoldMining(hold bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.hold = hold
+}
+
+// Mine mines the pending transactions.
+func (c *FakeClient) Mine() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, tx := range c.pending {
+		c.mine(tx)
+	}
+	c.pending = nil
+}
+
+// Drop drops the pending transactions, as a node evicting them from its mempool.
+func (c *FakeClient) Drop() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, tx := range c.pending {
+		delete(c.txs, tx.Hash())
+	}
+	c.pending = nil
+}
+
+// Sent returns the transactions sent so far, mined or not.
+func (c *FakeClient) Sent() []*ethtypes.Transaction {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	sent := make([]*ethtypes.Transaction, 0, len(c.txs))
+	for _, tx := range c.txs {
+		sent = append(sent, tx)
+	}
+
+	return sent
+}
+
+func (c *FakeClient) ChainID(ctx context.Context) (*big.Int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return new(big.Int).Set(c.chainId), c.err
+}
+
+func (c *FakeClient) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return c.balance(account), nil
+}
+
+func (c *FakeClient) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.nonces[account], c.err
+}
+
+func (c *FakeClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.pendingNonce(account), c.err
+}
+
+func (c *FakeClient) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return new(big.Int).Set(c.gasPrice), nil
+}
+
+func (c *FakeClient) SendTransaction(ctx context.Context, tx *ethtypes.Transaction) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+
+	sender, err := ethtypes.Sender(ethtypes.LatestSignerForChainID(c.chainId), tx)
+	if err != nil {
+		return fmt.Errorf("invalid sender: %w", err)
+	}
+	if nonce := c.pendingNonce(sender); tx.Nonce() != nonce {
+		return fmt.Errorf("invalid nonce %d, expected %d", tx.Nonce(), nonce)
+	}
+	if c.balance(sender).Cmp(tx.Cost()) < 0 {
+		return fmt.Errorf("insufficient funds for gas * price + value")
+	}
+
+	c.txs[tx.Hash()] = tx
+	if c.hold {
+		c.pending = append(c.pending, tx)
+		return nil
+	}
+	c.mine(tx)
+
+	return nil
+}
+
+func (c *FakeClient) TransactionByHash(ctx context.Context, hash common.Hash) (*ethtypes.Transaction, bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.err != nil {
+		return nil, false, c.err
+	}
+
+	tx, ok := c.txs[hash]
+	if !ok {
+		return nil, false, ethereum.NotFound
+	}
+	_, mined := c.receipts[hash]
+
+	return tx, !mined, nil
+}
+
+func (c *FakeClient) TransactionReceipt(ctx context.Context, hash common.Hash) (*ethtypes.Receipt, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	receipt, ok := c.receipts[hash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+
+	return receipt, nil
+}
+
+// mine applies a transaction. The caller must hold the lock.
+func (c *FakeClient) mine(tx *ethtypes.Transaction) {
+	sender, _ := ethtypes.Sender(ethtypes.LatestSignerForChainID(c.chainId), tx)
+
+	// A transfer only uses the intrinsic gas, the fake has no contracts.
+	gasUsed := uint64(21000)
+	fee := new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(gasUsed))
+	c.balances[sender] = new(big.Int).Sub(c.balance(sender), new(big.Int).Add(tx.Value(), fee))
+	if to := tx.To(); to != nil {
+		c.balances[*to] = new(big.Int).Add(c.balance(*to), tx.Value())
+	}
+	c.nonces[sender] = tx.Nonce() + 1
+
+	c.receipts[tx.Hash()] = &ethtypes.Receipt{
+		Type:              tx.Type(),
+		Status:            ethtypes.ReceiptStatusSuccessful,
+		CumulativeGasUsed: gasUsed,
+		TxHash:            tx.Hash(),
+		GasUsed:           gasUsed,
+		BlockNumber:       big.NewInt(int64(len(c.receipts) + 1)),
+	}
+}
+
+// balance returns the balance of account. The caller must hold the lock.
+func (c *FakeClient) balance(account common.Address) *big.Int {
+	if balance, ok := c.balances[account]; ok {
+		return new(big.Int).Set(balance)
+	}
+
+	return big.NewInt(0)
+}
+
+// pendingNonce returns the next nonce of account including its pending transactions. The caller
+// must hold the lock.
+func (c *FakeClient) pendingNonce(account common.Address) uint64 {
+	nonce := c.nonces[account]
+	for _, tx := range c.pending {
+		if sender, _ := ethtypes.Sender(ethtypes.LatestSignerForChainID(c.chainId), tx); sender == account {
+			nonce++
+		}
+	}
+
+	return nonce
+}
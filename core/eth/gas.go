@@ -0,0 +1,64 @@
+package eth
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// GasCfg lets a chain defer non-urgent top-ups while gas is expensive. Amounts are decimal strings
+// in wei.
+type GasCfg struct {
+	// MaxGasPrice is the gas price ceiling above which non-urgent top-ups are deferred. Empty
+	// disables deferring.
+	MaxGasPrice string `toml:"max_gas_price" json:"max_gas_price"`
+	// A top-up is urgent when the balance is below CriticalBalance. It defaults to half of the
+	// funding threshold.
+	CriticalBalance string `toml:"critical_balance" json:"critical_balance"`
+}
+
+type GasPolicy struct {
+	maxGasPrice     *big.Int
+	criticalBalance *big.Int
+}
+
+func NewGasPolicy(cfg GasCfg, threshold *big.Int) (*GasPolicy, error) {
+	p := &GasPolicy{}
+
+	if cfg.MaxGasPrice != "" {
+		maxGasPrice, ok := new(big.Int).SetString(cfg.MaxGasPrice, 10)
+		if !ok || maxGasPrice.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid max gas price %q", cfg.MaxGasPrice)
+		}
+		p.maxGasPrice = maxGasPrice
+	}
+
+	if cfg.CriticalBalance != "" {
+		criticalBalance, ok := new(big.Int).SetString(cfg.CriticalBalance, 10)
+		if !ok || criticalBalance.Sign() < 0 {
+			return nil, fmt.Errorf("invalid critical balance %q", cfg.CriticalBalance)
+		}
+		p.criticalBalance = criticalBalance
+	} else {
+		p.criticalBalance = new(big.Int).Div(threshold, big.NewInt(2))
+	}
+
+	return p, nil
+}
+
+// ShouldDefer returns true if a top-up for an account with the given balance should wait for a
+// cheaper gas price. Urgent top-ups are never deferred. The returned string explains the decision.
+func (p *GasPolicy) ShouldDefer(balance, gasPrice *big.Int) (bool, string) {
+	if p.maxGasPrice == nil {
+		return false, "no gas price ceiling"
+	}
+
+	if balance.Cmp(p.criticalBalance) < 0 {
+		return false, fmt.Sprintf("urgent, balance %s is below critical level %s", balance, p.criticalBalance)
+	}
+
+	if gasPrice.Cmp(p.maxGasPrice) > 0 {
+		return true, fmt.Sprintf("gas price %s is above ceiling %s", gasPrice, p.maxGasPrice)
+	}
+
+	return false, fmt.Sprintf("gas price %s is within ceiling %s", gasPrice, p.maxGasPrice)
+}
@@ -0,0 +1,198 @@
+package eth
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/store"
+)
+
+type senderNonces struct {
+	// Next is the next nonce that has never been handed out.
+	Next uint64 `json:"next"`
+	// Used maps every nonce handed out and not yet confirmed to the hash of its transaction. The
+	// hash is empty while the transaction has not been sent yet.
+	Used map[string]string `json:"used"`
+}
+
+// NonceManager hands out nonces for all the EVM transfers of the funder. Nonces are reserved
+// locally so that concurrent transfers from the same sender never collide, and reconciled with the
+// chain so that a dropped transaction does not leave a gap forever. Its state is persisted so that
+// a restart does not reuse a nonce of an in-flight transaction.
+type NonceManager struct {
+	lock     sync.Mutex
+	filePath string
+	senders  map[string]*senderNonces
+}
+
+func NewNonceManager(filePath string) (*NonceManager, error) {
+	m := &NonceManager{
+		filePath: filePath,
+		senders:  make(map[string]*senderNonces),
+	}
+
+	if err := store.Load(filePath, &m.senders); err != nil {
+		return nil, fmt.Errorf("failed to load nonces from %s: %w", filePath, err)
+	}
+
+	// Nothing is in flight at startup, so nonces reserved but never sent before a crash are gaps.
+	for _, s := range m.senders {
+		for key, hash := range s.Used {
+			if hash == "" {
+				delete(s.Used, key)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+func nonceKey(chain string, sender common.Address) string {
+	return chain + "/" + strings.ToLower(sender.Hex())
+}
+
+// Reserve returns the nonce to use for the next transaction of sender on chain. The nonce must be
+// given back with either Sent or Release.
+func (m *NonceManager) Reserve(client Client, chain string, sender common.Address) (uint64, error) {
+	latest, err := client.NonceAt(context.Background(), sender, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest nonce: %w", err)
+	}
+	pending, err := client.PendingNonceAt(context.Background(), sender)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pending nonce: %w", err)
+	}
+
+	key := nonceKey(chain, sender)
+	dropped := m.findDropped(client, key, pending)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	s := m.senders[key]
+	if s == nil {
+		s = &senderNonces{Used: make(map[string]string)}
+		m.senders[key] = s
+	}
+
+	m.reconcile(chain, s, latest, pending, dropped)
+
+	// Fill the lowest gap first, otherwise every transaction after it stays stuck. Nonces below the
+	// pending nonce are already taken by transactions in the mempool.
+	start := latest
+	if pending > start {
+		start = pending
+	}
+	nonce := s.Next
+	for n := start; n < s.Next; n++ {
+		if _, ok := s.Used[formatNonce(n)]; !ok {
+			log.Warnf("Filling nonce gap %d of %s on chain %s", n, sender, chain)
+			nonce = n
+			break
+		}
+	}
+
+	if nonce == s.Next {
+		s.Next++
+	}
+	s.Used[formatNonce(nonce)] = ""
+	m.save()
+
+	return nonce, nil
+}
+
+// findDropped returns the nonces of the sender of key whose transaction was sent but is unknown to
+// the node, mapped to the hash of that transaction. The node is queried without holding the lock.
+func (m *NonceManager) findDropped(client Client, key string, pending uint64) map[string]string {
+	sent := make(map[string]string)
+	m.lock.Lock()
+	if s := m.senders[key]; s != nil {
+		for nonce, hash := range s.Used {
+			if n, err := strconv.ParseUint(nonce, 10, 64); err == nil && hash != "" && n >= pending {
+				sent[nonce] = hash
+			}
+		}
+	}
+	m.lock.Unlock()
+
+	dropped := make(map[string]string)
+	for nonce, hash := range sent {
+		_, _, err := client.TransactionByHash(context.Background(), common.HexToHash(hash))
+		if err == ethereum.NotFound {
+			dropped[nonce] = hash
+		}
+	}
+
+	return dropped
+}
+
+// reconcile drops confirmed and lost nonces from the local state and catches up with transactions
+// sent by someone else. The caller must hold the lock.
+func (m *NonceManager) reconcile(chain string, s *senderNonces, latest, pending uint64,
+	dropped map[string]string) {
+	for key, hash := range s.Used {
+		n, err := strconv.ParseUint(key, 10, 64)
+		if err != nil || n < latest {
+			delete(s.Used, key)
+			continue
+		}
+
+		// The node does not know a transaction we sent with this nonce, it was dropped from the
+		// mempool and the nonce is a gap again.
+		if hash != "" && dropped[key] == hash {
+			log.Warnf("Transaction %s with nonce %d on chain %s was dropped", hash, n, chain)
+			delete(s.Used, key)
+		}
+	}
+
+	if s.Next < pending {
+		s.Next = pending
+	}
+	if s.Next < latest {
+		s.Next = latest
+	}
+}
+
+// Sent records that the transaction using nonce has been sent.
+func (m *NonceManager) Sent(chain string, sender common.Address, nonce uint64, hash common.Hash) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if s := m.senders[nonceKey(chain, sender)]; s != nil {
+		s.Used[formatNonce(nonce)] = hash.Hex()
+		m.save()
+	}
+}
+
+// Release gives back a nonce whose transaction never reached the network.
+func (m *NonceManager) Release(chain string, sender common.Address, nonce uint64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	s := m.senders[nonceKey(chain, sender)]
+	if s == nil {
+		return
+	}
+
+	delete(s.Used, formatNonce(nonce))
+	if nonce+1 == s.Next {
+		s.Next = nonce
+	}
+	m.save()
+}
+
+func (m *NonceManager) save() {
+	if err := store.Save(m.filePath, m.senders); err != nil {
+		log.Errorf("Failed to save nonces to %s, err = %s", m.filePath, err)
+	}
+}
+
+func formatNonce(nonce uint64) string {
+	return strconv.FormatUint(nonce, 10)
+}
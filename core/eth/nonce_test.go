@@ -0,0 +1,194 @@
+package eth
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+var knownHash = common.HexToHash("0x01")
+
+func TestNonceReserve(t *testing.T) {
+	_, sender := getPrivateKey(testMnemonic)
+
+	tests := []struct {
+		name string
+		// steps run against the manager before the reserve under test.
+		steps func(t *testing.T, m *NonceManager, client *FakeClient)
+		// restart reloads the manager from its file before the reserve under test.
+		restart bool
+		nonce   uint64
+	}{
+		{
+			name:  "first nonce is the nonce of the node",
+			steps: func(t *testing.T, m *NonceManager, client *FakeClient) { client.nonces[sender] = 5 },
+			nonce: 5,
+		},
+		{
+			name: "reserved nonce is not handed out twice",
+			steps: func(t *testing.T, m *NonceManager, client *FakeClient) {
+				reserve(t, m, client, 0)
+			},
+			nonce: 1,
+		},
+		{
+			name: "released nonce is reused",
+			steps: func(t *testing.T, m *NonceManager, client *FakeClient) {
+				reserve(t, m, client, 0)
+				m.Release(testChain, sender, 0)
+			},
+			nonce: 0,
+		},
+		{
+			name: "released nonce below a sent one is a gap that is filled first",
+			steps: func(t *testing.T, m *NonceManager, client *FakeClient) {
+				reserve(t, m, client, 0)
+				reserve(t, m, client, 1)
+				m.Sent(testChain, sender, 1, knownHash)
+				m.Release(testChain, sender, 0)
+			},
+			nonce: 0,
+		},
+		{
+			name: "pending transaction keeps its nonce",
+			steps: func(t *testing.T, m *NonceManager, client *FakeClient) {
+				reserve(t, m, client, 0)
+				m.Sent(testChain, sender, 0, knownHash)
+			},
+			nonce: 1,
+		},
+		{
+			name: "dropped transaction is a gap",
+			steps: func(t *testing.T, m *NonceManager, client *FakeClient) {
+				reserve(t, m, client, 0)
+				m.Sent(testChain, sender, 0, common.HexToHash("0x02"))
+			},
+			nonce: 0,
+		},
+		{
+			name: "confirmed nonces are dropped",
+			steps: func(t *testing.T, m *NonceManager, client *FakeClient) {
+				reserve(t, m, client, 0)
+				m.Sent(testChain, sender, 0, knownHash)
+				client.nonces[sender] = 1
+			},
+			nonce: 1,
+		},
+		{
+			name: "nonces used outside the funder are skipped",
+			steps: func(t *testing.T, m *NonceManager, client *FakeClient) {
+				reserve(t, m, client, 0)
+				m.Sent(testChain, sender, 0, knownHash)
+				client.nonces[sender] = 3
+			},
+			nonce: 3,
+		},
+		{
+			name: "sent transaction is kept after a restart",
+			steps: func(t *testing.T, m *NonceManager, client *FakeClient) {
+				reserve(t, m, client, 0)
+				m.Sent(testChain, sender, 0, knownHash)
+			},
+			restart: true,
+			nonce:   1,
+		},
+		{
+			name: "nonce reserved but never sent is a gap after a restart",
+			steps: func(t *testing.T, m *NonceManager, client *FakeClient) {
+				reserve(t, m, client, 0)
+				reserve(t, m, client, 1)
+				m.Sent(testChain, sender, 1, knownHash)
+			},
+			restart: true,
+			nonce:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "nonces.json")
+			m, err := NewNonceManager(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := NewFakeClient(testChainId)
+			client.txs[knownHash] = ethtypes.NewTransaction(0, testTarget, nil, 21000, nil, nil)
+
+			tt.steps(t, m, client)
+			if tt.restart {
+				if m, err = NewNonceManager(filePath); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			reserve(t, m, client, tt.nonce)
+		})
+	}
+}
+
+// reserve reserves the next nonce of the faucet of the test mnemonic and checks that it is nonce.
+func reserve(t *testing.T, m *NonceManager, client Client, nonce uint64) {
+	t.Helper()
+
+	_, sender := getPrivateKey(testMnemonic)
+	got, err := m.Reserve(client, testChain, sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nonce {
+		t.Fatalf("reserved nonce %d, want %d", got, nonce)
+	}
+}
+
+// blockingClient is a node whose transaction lookups hang until unblock is closed.
+type blockingClient struct {
+	*FakeClient
+	lookups chan struct{}
+	unblock chan struct{}
+}
+
+func (c *blockingClient) TransactionByHash(ctx context.Context, hash common.Hash) (*ethtypes.Transaction, bool, error) {
+	c.lookups <- struct{}{}
+	<-c.unblock
+	return c.FakeClient.TransactionByHash(ctx, hash)
+}
+
+func TestNonceReserveDoesNotHoldLockAcrossRpc(t *testing.T) {
+	m := newTestNonces(t)
+	client := &blockingClient{
+		FakeClient: NewFakeClient(testChainId),
+		lookups:    make(chan struct{}, 1),
+		unblock:    make(chan struct{}),
+	}
+
+	// The next reserve on this chain looks up the sent transaction and hangs.
+	reserve(t, m, client.FakeClient, 0)
+	_, sender := getPrivateKey(testMnemonic)
+	m.Sent(testChain, sender, 0, knownHash)
+	hanging := make(chan struct{})
+	go func() {
+		defer close(hanging)
+		m.Reserve(client, testChain, sender)
+	}()
+	defer func() {
+		close(client.unblock)
+		<-hanging
+	}()
+	<-client.lookups
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Reserve(client, "other-chain", sender)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reserve on another chain waited for a hanging rpc")
+	}
+}
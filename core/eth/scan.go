@@ -0,0 +1,278 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/sisu-network/sisu-account-funding/core/reconcile"
+)
+
+// ExplorerCfg is an Etherscan-compatible explorer api, e.g. BscScan, used to list the faucet
+// transactions. Chains without an explorer are scanned block by block over RPC.
+type ExplorerCfg struct {
+	Url    string `toml:"url" json:"url"`
+	ApiKey string `toml:"api_key" json:"api_key"`
+}
+
+// GetFaucetAddress returns the address of the faucet key.
+func GetFaucetAddress(mnemonic string) common.Address {
+	_, addr := getPrivateKey(mnemonic)
+	return addr
+}
+
+// NewScanner returns a scanner using the explorer when configured and the first rpc otherwise.
+func NewScanner(rpcs []string, explorer ExplorerCfg) (reconcile.Scanner, error) {
+	if explorer.Url != "" {
+		return &explorerScanner{
+			url:    explorer.Url,
+			apiKey: explorer.ApiKey,
+			client: &http.Client{Timeout: time.Second * 30},
+		}, nil
+	}
+
+	if len(rpcs) == 0 {
+		return nil, fmt.Errorf("no rpc to scan")
+	}
+	client, err := ethclient.Dial(rpcs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &rpcScanner{client: client}, nil
+}
+
+type explorerScanner struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func (s *explorerScanner) get(params url.Values, result interface{}) error {
+	if s.apiKey != "" {
+		params.Set("apikey", s.apiKey)
+	}
+
+	res, err := s.client.Get(s.url + "?" + params.Encode())
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	bz, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("explorer failed with status %d: %s", res.StatusCode, string(bz))
+	}
+
+	body := &struct {
+		Status  string          `json:"status"`
+		Message string          `json:"message"`
+		Result  json.RawMessage `json:"result"`
+	}{}
+	if err := json.Unmarshal(bz, body); err != nil {
+		return err
+	}
+	if body.Status != "1" {
+		if strings.HasPrefix(body.Message, "No transactions found") {
+			return nil
+		}
+		return fmt.Errorf("explorer error: %s, %s", body.Message, string(body.Result))
+	}
+
+	return json.Unmarshal(body.Result, result)
+}
+
+func (s *explorerScanner) blockByTime(t time.Time, closest string) (uint64, error) {
+	var block string
+	err := s.get(url.Values{
+		"module":    {"block"},
+		"action":    {"getblocknobytime"},
+		"timestamp": {strconv.FormatInt(t.Unix(), 10)},
+		"closest":   {closest},
+	}, &block)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(block, 10, 64)
+}
+
+func (s *explorerScanner) Outgoing(address string, from, to time.Time) ([]reconcile.Transfer, error) {
+	startBlock, err := s.blockByTime(from, "after")
+	if err != nil {
+		return nil, err
+	}
+	endBlock, err := s.blockByTime(to, "before")
+	if err != nil {
+		return nil, err
+	}
+
+	const pageSize = 1000
+	transfers := make([]reconcile.Transfer, 0)
+	for page := 1; ; page++ {
+		txs := make([]struct {
+			Hash      string `json:"hash"`
+			From      string `json:"from"`
+			To        string `json:"to"`
+			Value     string `json:"value"`
+			TimeStamp string `json:"timeStamp"`
+			GasUsed   string `json:"gasUsed"`
+			GasPrice  string `json:"gasPrice"`
+			IsError   string `json:"isError"`
+		}, 0)
+		err := s.get(url.Values{
+			"module":     {"account"},
+			"action":     {"txlist"},
+			"address":    {address},
+			"startblock": {strconv.FormatUint(startBlock, 10)},
+			"endblock":   {strconv.FormatUint(endBlock, 10)},
+			"page":       {strconv.Itoa(page)},
+			"offset":     {strconv.Itoa(pageSize)},
+			"sort":       {"asc"},
+		}, &txs)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tx := range txs {
+			if !strings.EqualFold(tx.From, address) {
+				continue
+			}
+
+			timestamp, _ := strconv.ParseInt(tx.TimeStamp, 10, 64)
+			txTime := time.Unix(timestamp, 0)
+			if txTime.Before(from) || !txTime.Before(to) {
+				continue
+			}
+
+			gasUsed, _ := new(big.Int).SetString(tx.GasUsed, 10)
+			gasPrice, _ := new(big.Int).SetString(tx.GasPrice, 10)
+			fee := ""
+			if gasUsed != nil && gasPrice != nil {
+				fee = new(big.Int).Mul(gasUsed, gasPrice).String()
+			}
+
+			transfers = append(transfers, reconcile.Transfer{
+				TxHash: tx.Hash,
+				From:   tx.From,
+				To:     tx.To,
+				Amount: tx.Value,
+				Fee:    fee,
+				Time:   txTime,
+				Failed: tx.IsError == "1",
+			})
+		}
+
+		if len(txs) < pageSize {
+			return transfers, nil
+		}
+	}
+}
+
+// rpcScanner reads every block of the period. It is slow and meant for devnets and short periods.
+type rpcScanner struct {
+	client *ethclient.Client
+}
+
+// firstBlockAfter returns the first block with a timestamp at or after t, or latest+1 if there is
+// none.
+func (s *rpcScanner) firstBlockAfter(t time.Time, latest uint64) (uint64, error) {
+	var searchErr error
+	n := sort.Search(int(latest)+1, func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		header, err := s.client.HeaderByNumber(context.Background(), new(big.Int).SetUint64(uint64(i)))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return int64(header.Time) >= t.Unix()
+	})
+
+	return uint64(n), searchErr
+}
+
+func (s *rpcScanner) Outgoing(address string, from, to time.Time) ([]reconcile.Transfer, error) {
+	ctx := context.Background()
+	sender := common.HexToAddress(address)
+
+	chainId, err := s.client.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	signer := ethtypes.LatestSignerForChainID(chainId)
+
+	latest, err := s.client.BlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	start, err := s.firstBlockAfter(from, latest)
+	if err != nil {
+		return nil, err
+	}
+	end, err := s.firstBlockAfter(to, latest)
+	if err != nil {
+		return nil, err
+	}
+
+	transfers := make([]reconcile.Transfer, 0)
+	for number := start; number < end; number++ {
+		block, err := s.client.BlockByNumber(ctx, new(big.Int).SetUint64(number))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tx := range block.Transactions() {
+			txSender, err := ethtypes.Sender(signer, tx)
+			if err != nil || txSender != sender {
+				continue
+			}
+
+			receipt, err := s.client.TransactionReceipt(ctx, tx.Hash())
+			if err != nil {
+				return nil, err
+			}
+
+			// After London the sender pays the base fee plus the effective tip.
+			gasPrice := tx.GasPrice()
+			if baseFee := block.BaseFee(); baseFee != nil {
+				tip, err := tx.EffectiveGasTip(baseFee)
+				if err == nil {
+					gasPrice = new(big.Int).Add(baseFee, tip)
+				}
+			}
+
+			recipient := ""
+			if tx.To() != nil {
+				recipient = tx.To().String()
+			}
+
+			transfers = append(transfers, reconcile.Transfer{
+				TxHash: tx.Hash().String(),
+				From:   txSender.String(),
+				To:     recipient,
+				Amount: tx.Value().String(),
+				Fee:    new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), gasPrice).String(),
+				Time:   time.Unix(int64(block.Time()), 0),
+				Failed: receipt.Status == ethtypes.ReceiptStatusFailed,
+			})
+		}
+	}
+
+	return transfers, nil
+}
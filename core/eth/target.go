@@ -0,0 +1,23 @@
+package eth
+
+import (
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+)
+
+// TargetCfg is an extra account funded by the watcher of a chain, e.g. a relayer wallet or a
+// gateway deployer. Empty policy fields fall back to the funding policy of the chain.
+type TargetCfg struct {
+	Address string `toml:"address" json:"address"`
+	Label   string `toml:"label" json:"label"`
+	funding.PolicyCfg
+}
+
+// Target is an account watched and funded by a watcher.
+type Target struct {
+	Label   string
+	Address ethcommon.Address
+	Policy  *funding.Policy
+	Burn    *funding.BurnTracker
+	Gas     *GasPolicy
+}
@@ -9,13 +9,17 @@ import (
 	"github.com/ethereum/go-ethereum"
 	ethtypes "github.com/ethereum/go-ethereum/core/types"
 
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/sisu-network/lib/log"
 )
 
-func getSigner(client *ethclient.Client) (ethtypes.Signer, error) {
+var (
+	// TxTimeout is how long a transfer waits to be mined before it is reported as timed out.
+	TxTimeout  = time.Minute * 2
+	TxPollTime = time.Second * 3
+)
+
+func getSigner(client Client) (ethtypes.Signer, error) {
 	chainId, err := client.ChainID(context.Background())
 	if err != nil {
 		return nil, err
@@ -24,29 +28,31 @@ func getSigner(client *ethclient.Client) (ethtypes.Signer, error) {
 	return ethtypes.NewLondonSigner(chainId), nil
 }
 
-// TransferEth transfers a specific ETH amount to an address.
-func TransferEth(client *ethclient.Client, mnemonic, chain string, recipient common.Address, amount *big.Int) error {
+// TransferEth transfers a specific ETH amount to an address. It returns the transaction once sent,
+// even if it is not mined in time.
+func TransferEth(client Client, nonces *NonceManager, mnemonic, chain string, recipient common.Address,
+	amount *big.Int) (*ethtypes.Transaction, error) {
 	_, account := getPrivateKey(mnemonic)
-	log.Info("from address = ", account.String(), " to Address = ", recipient.String())
-
-	nonce, err := client.PendingNonceAt(context.Background(), account)
-	if err != nil {
-		return fmt.Errorf("Failed to get nonce, err  = %s", err.Error())
-	}
+	log.Infof("Transferring from %s to %s on chain %s", account, recipient, chain)
 
 	gasPrice, err := client.SuggestGasPrice(context.Background())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if gasPrice.Cmp(big.NewInt(0)) <= 0 {
-		return fmt.Errorf("Invalid gas price %s", gasPrice)
+		return nil, fmt.Errorf("Invalid gas price %s", gasPrice)
+	}
+
+	nonce, err := nonces.Reserve(client, chain, account)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get nonce, err = %s", err)
 	}
 
-	log.Info("Gas price = ", gasPrice, " on chain ", chain)
+	log.Infof("Gas price = %s on chain %s", gasPrice, chain)
 
 	gasLimit := uint64(22000) // in units
 	amountFloat := new(big.Float).Quo(new(big.Float).SetInt(amount), new(big.Float).SetInt(ONE_ETHER_IN_WEI))
-	log.Info("Amount in ETH: ", amountFloat, " on chain ", chain)
+	log.Infof("Amount in ETH: %s on chain %s", amountFloat.String(), chain)
 
 	var data []byte
 	tx := ethtypes.NewTransaction(nonce, recipient, amount, gasLimit, gasPrice, data)
@@ -54,44 +60,50 @@ func TransferEth(client *ethclient.Client, mnemonic, chain string, recipient com
 	signer, err := getSigner(client)
 	if err != nil {
 		log.Errorf("Failed to get signer for chain %s", chain)
-		return err
+		nonces.Release(chain, account, nonce)
+		return nil, err
 	}
 	privateKey, _ := getPrivateKey(mnemonic)
 	signedTx, err := ethtypes.SignTx(tx, signer, privateKey)
+	if err != nil {
+		nonces.Release(chain, account, nonce)
+		return nil, err
+	}
 
-	log.Info("Tx hash = ", signedTx.Hash(), " on chain ", chain)
+	log.Infof("Tx hash = %s on chain %s", signedTx.Hash(), chain)
 
 	err = client.SendTransaction(context.Background(), signedTx)
 	if err != nil {
-		return fmt.Errorf("Failed to transfer ETH on chain %s, err = %s", chain, err)
+		nonces.Release(chain, account, nonce)
+		return nil, fmt.Errorf("Failed to transfer ETH on chain %s, err = %s", chain, err)
 	}
+	nonces.Sent(chain, account, nonce, signedTx.Hash())
 
-	bind.WaitDeployed(context.Background(), client, signedTx)
-
-	return waitForTx(client, signedTx.Hash())
+	return signedTx, waitForTx(client, signedTx.Hash())
 }
 
-func waitForTx(client *ethclient.Client, hash common.Hash) error {
-	start := time.Now()
-	end := start.Add(time.Minute * 2)
+// waitForTx waits until the transaction hash is mined and returns an error if it failed.
+func waitForTx(client Client, hash common.Hash) error {
+	end := time.Now().Add(TxTimeout)
 
 	for {
 		if time.Now().After(end) {
 			return fmt.Errorf("Time out for transaction with hash %s", hash)
 		}
 
-		tx, isPending, err := client.TransactionByHash(context.Background(), hash)
+		receipt, err := client.TransactionReceipt(context.Background(), hash)
 		if err != nil && err != ethereum.NotFound {
-			return fmt.Errorf("Failed to get transaction with hash %s", hash)
+			return fmt.Errorf("Failed to get receipt of transaction with hash %s, err = %s", hash, err)
 		}
 
-		if tx == nil || isPending {
-			time.Sleep(time.Second * 3)
+		if receipt == nil {
+			time.Sleep(TxPollTime)
 			continue
 		}
+		if receipt.Status != ethtypes.ReceiptStatusSuccessful {
+			return fmt.Errorf("Transaction with hash %s failed", hash)
+		}
 
-		break
+		return nil
 	}
-
-	return nil
 }
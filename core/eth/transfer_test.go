@@ -0,0 +1,65 @@
+package eth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransferStuck(t *testing.T) {
+	timeout, pollTime := TxTimeout, TxPollTime
+	TxTimeout, TxPollTime = time.Millisecond*50, time.Millisecond*5
+	defer func() {
+		TxTimeout, TxPollTime = timeout, pollTime
+	}()
+
+	tests := []struct {
+		name string
+		// resolve is what happens to the stuck transaction before the next transfer.
+		resolve func(client *FakeClient)
+		// nonce is the nonce of the next transfer.
+		nonce uint64
+	}{
+		{
+			name:    "dropped transaction frees its nonce",
+			resolve: (*FakeClient).Drop,
+			nonce:   0,
+		},
+		{
+			name:    "mined transaction uses its nonce",
+			resolve: (*FakeClient).Mine,
+			nonce:   1,
+		},
+		{
+			name:    "pending transaction keeps its nonce",
+			resolve: func(client *FakeClient) {},
+			nonce:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFundedClient()
+			nonces := newTestNonces(t)
+
+			client.HoldMining(true)
+			stuck, err := TransferEth(client, nonces, testMnemonic, testChain, testTarget, ether(30))
+			if err == nil {
+				t.Fatal("transfer of a stuck transaction did not time out")
+			}
+			if stuck == nil || stuck.Nonce() != 0 {
+				t.Fatalf("stuck transaction = %v, want one with nonce 0", stuck)
+			}
+
+			tt.resolve(client)
+			client.HoldMining(false)
+
+			tx, err := TransferEth(client, nonces, testMnemonic, testChain, testTarget, ether(30))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tx.Nonce() != tt.nonce {
+				t.Errorf("nonce %d, want %d", tx.Nonce(), tt.nonce)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"path/filepath"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	liskcrypto "github.com/sisu-network/deyes/chains/lisk/crypto"
+	libchain "github.com/sisu-network/lib/chain"
+	"github.com/sisu-network/sisu-account-funding/core/btc"
+	"github.com/sisu-network/sisu-account-funding/core/cardano"
+	"github.com/sisu-network/sisu-account-funding/core/cosmos"
+	"github.com/sisu-network/sisu-account-funding/core/eth"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/lisk"
+	"github.com/sisu-network/sisu-account-funding/core/reconcile"
+	"github.com/sisu-network/sisu-account-funding/core/solana"
+	"github.com/sisu-network/sisu-account-funding/core/tron"
+)
+
+// The chain families funded out of the box.
+const (
+	FamilyEvm     = "evm"
+	FamilyLisk    = "lisk"
+	FamilyBitcoin = "bitcoin"
+	FamilySolana  = "solana"
+	FamilyCardano = "cardano"
+	FamilyCosmos  = "cosmos"
+	FamilyTron    = "tron"
+)
+
+func init() {
+	RegisterFamily(&Family{
+		Name:    FamilyEvm,
+		KeyType: libchain.KEY_TYPE_ECDSA,
+		Detect: func(chain string, cfg ChainCfg) bool {
+			return libchain.IsETHBasedChain(chain)
+		},
+		Validate: func(chain string, cfg ChainCfg) error {
+			_, err := eth.NewGasPolicy(cfg.Gas, big.NewInt(0))
+			return err
+		},
+		Address: func(chain string, cfg ChainCfg, pubkey []byte) (string, error) {
+			pubKey, err := ethcrypto.UnmarshalPubkey(pubkey)
+			if err != nil {
+				return "", err
+			}
+			return ethcrypto.PubkeyToAddress(*pubKey).String(), nil
+		},
+		NewWatcher: func(env *WatcherEnv) (Watcher, error) {
+			// 04cbf8c5562928f81495a55c12f89836cf744c1adcc43c45c33e97571603b979bcead13fb7f33e7cc01d2a632337725b84942d3998c933b08e5a34be8df7794e05
+			// is the test ecdsa pubkey. Use hex.DecodeString to get its bytes
+			sisuAccount := getEthAccount(map[string][]byte{libchain.KEY_TYPE_ECDSA: env.Pubkey})
+			targets, err := newEthTargets(env.DataDir, env.Chain, env.Cfg, sisuAccount)
+			if err != nil {
+				return nil, err
+			}
+			clients := eth.DialClients(env.Chain, env.Cfg.Rpcs)
+			return eth.NewWatcher(env.Mnemonic, env.Chain, env.Cfg.Rpcs, clients, targets, env.Nonces,
+				env.Limiter, env.Ledger, env.Outflow, env.Audit), nil
+		},
+		FaucetAddress: func(mnemonic string, chain string, cfg ChainCfg) (string, error) {
+			return eth.GetFaucetAddress(mnemonic).String(), nil
+		},
+		NewScanner: func(chain string, cfg ChainCfg) (reconcile.Scanner, error) {
+			return eth.NewScanner(cfg.Rpcs, cfg.Explorer)
+		},
+	})
+
+	// Use 7cbb424e0dffad3104e29c6febe3abd899b2d2b972475dabd9fbe6b62f9af2ff as hex of sample test
+	// eddsa pubkey. Use hex.DecodeString to get its bytes
+	RegisterFamily(&Family{
+		Name:    FamilyLisk,
+		KeyType: libchain.KEY_TYPE_EDDSA,
+		// Chains unknown to the chain library, like devnets and sidechains, are funded as Lisk
+		// chains when their config has a custom network.
+		Detect: func(chain string, cfg ChainCfg) bool {
+			return libchain.IsLiskChain(chain) || cfg.Lisk.IsCustomNetwork()
+		},
+		Validate: func(chain string, cfg ChainCfg) error {
+			return cfg.Lisk.Validate(chain)
+		},
+		Address: func(chain string, cfg ChainCfg, pubkey []byte) (string, error) {
+			return liskcrypto.GetLisk32AddressFromPublickey(pubkey), nil
+		},
+		NewWatcher: func(env *WatcherEnv) (Watcher, error) {
+			policy, burn, err := newPolicy(env, lisk.DefaultPolicy)
+			if err != nil {
+				return nil, err
+			}
+			return lisk.NewWatcher(env.Mnemonic, env.Chain, env.Cfg.Rpcs[0], env.Cfg.Lisk, env.Pubkey,
+				filepath.Join(env.DataDir, "pending_"+env.Chain+".json"), policy, burn, env.Limiter, env.Ledger,
+				env.Outflow, env.Audit)
+		},
+	})
+
+	RegisterFamily(&Family{
+		Name:    FamilyBitcoin,
+		KeyType: libchain.KEY_TYPE_ECDSA,
+		// The faucet balance only counts confirmed outputs, and blocks can be slow to come.
+		ExpectTimeout: btc.ExpectTimeout,
+		// Bitcoin chains are funded when their config has a bitcoin network.
+		Detect: func(chain string, cfg ChainCfg) bool {
+			return cfg.Btc.IsConfigured()
+		},
+		Validate: func(chain string, cfg ChainCfg) error {
+			return cfg.Btc.Validate()
+		},
+		Address: func(chain string, cfg ChainCfg, pubkey []byte) (string, error) {
+			params, err := cfg.Btc.Params()
+			if err != nil {
+				return "", err
+			}
+			addr, err := btc.GetAddress(pubkey, params)
+			if err != nil {
+				return "", err
+			}
+			return addr.EncodeAddress(), nil
+		},
+		NewWatcher: func(env *WatcherEnv) (Watcher, error) {
+			policy, burn, err := newPolicy(env, btc.DefaultPolicy)
+			if err != nil {
+				return nil, err
+			}
+			client := btc.NewClient(env.Cfg.Rpcs[0], env.Cfg.Btc)
+			return btc.NewWatcher(env.Mnemonic, env.Chain, client, env.Cfg.Btc, env.Pubkey,
+				filepath.Join(env.DataDir, "spent_"+env.Chain+".json"),
+				filepath.Join(env.DataDir, "pending_"+env.Chain+".json"), policy, burn,
+				env.Limiter, env.Ledger, env.Outflow, env.Audit)
+		},
+	})
+
+	// Solana uses the same eddsa key as Lisk.
+	RegisterFamily(&Family{
+		Name:    FamilySolana,
+		KeyType: libchain.KEY_TYPE_EDDSA,
+		Detect: func(chain string, cfg ChainCfg) bool {
+			return libchain.IsSolanaChain(chain)
+		},
+		Validate: func(chain string, cfg ChainCfg) error {
+			return cfg.Solana.Validate()
+		},
+		Address: func(chain string, cfg ChainCfg, pubkey []byte) (string, error) {
+			return solana.GetAddress(pubkey), nil
+		},
+		NewWatcher: func(env *WatcherEnv) (Watcher, error) {
+			policy, burn, err := newPolicy(env, solana.DefaultPolicy)
+			if err != nil {
+				return nil, err
+			}
+			client := solana.NewClient(env.Cfg.Rpcs[0], env.Cfg.Solana)
+			return solana.NewWatcher(env.Mnemonic, env.Chain, client, env.Cfg.Solana, env.Pubkey, policy,
+				burn, env.Limiter, env.Ledger, env.Outflow, env.Audit)
+		},
+	})
+
+	RegisterFamily(&Family{
+		Name:    FamilyCardano,
+		KeyType: libchain.KEY_TYPE_EDDSA,
+		Detect: func(chain string, cfg ChainCfg) bool {
+			return libchain.IsCardanoChain(chain)
+		},
+		Validate: func(chain string, cfg ChainCfg) error {
+			return cfg.Cardano.Validate(chain)
+		},
+		Address: func(chain string, cfg ChainCfg, pubkey []byte) (string, error) {
+			networkId, err := cfg.Cardano.NetworkId(chain)
+			if err != nil {
+				return "", err
+			}
+			return cardano.GetAddress(pubkey, networkId)
+		},
+		NewWatcher: func(env *WatcherEnv) (Watcher, error) {
+			policy, burn, err := newPolicy(env, cardano.DefaultPolicy)
+			if err != nil {
+				return nil, err
+			}
+			client := cardano.NewClient(env.Cfg.Rpcs[0], env.Cfg.Cardano)
+			return cardano.NewWatcher(env.Mnemonic, env.Chain, client, env.Cfg.Cardano, env.Pubkey,
+				filepath.Join(env.DataDir, "pending_"+env.Chain+".json"), policy, burn, env.Limiter, env.Ledger, env.Outflow, env.Audit)
+		},
+	})
+
+	// Cosmos SDK chains, including Sisu, are funded when their config has an address prefix.
+	RegisterFamily(&Family{
+		Name:    FamilyCosmos,
+		KeyType: libchain.KEY_TYPE_ECDSA,
+		Detect: func(chain string, cfg ChainCfg) bool {
+			return cfg.Cosmos.IsConfigured()
+		},
+		Validate: func(chain string, cfg ChainCfg) error {
+			return cfg.Cosmos.Validate()
+		},
+		Address: func(chain string, cfg ChainCfg, pubkey []byte) (string, error) {
+			return cosmos.GetAddress(pubkey, cfg.Cosmos.Prefix)
+		},
+		NewWatcher: func(env *WatcherEnv) (Watcher, error) {
+			policy, burn, err := newPolicy(env, cosmos.DefaultPolicy)
+			if err != nil {
+				return nil, err
+			}
+			client, err := cosmos.NewClient(env.Cfg.Rpcs[0], env.Cfg.Cosmos)
+			if err != nil {
+				return nil, err
+			}
+			return cosmos.NewWatcher(env.Mnemonic, env.Chain, client, env.Cfg.Cosmos, env.Pubkey, policy,
+				burn, env.Limiter, env.Ledger, env.Outflow, env.Audit)
+		},
+	})
+
+	// Tron uses the ecdsa key but not the EVM transaction format.
+	RegisterFamily(&Family{
+		Name:    FamilyTron,
+		KeyType: libchain.KEY_TYPE_ECDSA,
+		Detect: func(chain string, cfg ChainCfg) bool {
+			return tron.IsTronChain(chain)
+		},
+		Validate: func(chain string, cfg ChainCfg) error {
+			return cfg.Tron.Validate()
+		},
+		Address: func(chain string, cfg ChainCfg, pubkey []byte) (string, error) {
+			return tron.GetAddress(pubkey)
+		},
+		NewWatcher: func(env *WatcherEnv) (Watcher, error) {
+			policy, burn, err := newPolicy(env, tron.DefaultPolicy)
+			if err != nil {
+				return nil, err
+			}
+			client := tron.NewClient(env.Cfg.Rpcs[0], env.Cfg.Tron)
+			return tron.NewWatcher(env.Mnemonic, env.Chain, client, env.Cfg.Tron, env.Pubkey,
+				filepath.Join(env.DataDir, "pending_"+env.Chain+".json"), policy, burn, env.Limiter, env.Ledger,
+				env.Outflow, env.Audit)
+		},
+	})
+}
+
+// newPolicy returns the funding policy of a chain with a single funded account and its burn
+// tracker.
+func newPolicy(env *WatcherEnv, defaults funding.PolicyCfg) (*funding.Policy, *funding.BurnTracker, error) {
+	policy, err := funding.NewPolicy(env.Cfg.Funding, defaults)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid funding policy: %w", err)
+	}
+	burn := funding.NewBurnTracker(filepath.Join(env.DataDir, "burn_"+env.Chain+".json"), policy.BurnWindow())
+
+	return policy, burn, nil
+}
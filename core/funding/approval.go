@@ -0,0 +1,354 @@
+package funding
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/alert"
+	"github.com/sisu-network/sisu-account-funding/core/store"
+)
+
+var (
+	ErrAwaitingApproval = errors.New("top-up is awaiting approval")
+	ErrRejected         = errors.New("top-up was rejected")
+	ErrNoRequest        = errors.New("no such approval request")
+	ErrDecided          = errors.New("approval request is already decided")
+)
+
+// The statuses of an approval request.
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+	StatusExpired  = "expired"
+	StatusExecuted = "executed"
+)
+
+// DefaultApprovalExpiry is how long a request waits for a decision by default.
+var DefaultApprovalExpiry = time.Hour * 24
+
+// decidedRetention is how long decided requests are kept for the record.
+const decidedRetention = time.Hour * 24 * 30
+
+// ApprovalCfg makes the large top-ups of a chain wait for the approval of an operator.
+type ApprovalCfg struct {
+	// Above is the amount above which a top-up needs approval, as a decimal string in the smallest
+	// unit of the chain. Top-ups never need approval when it is empty.
+	Above string `toml:"above" json:"above"`
+	// Expiry is how long a request waits for a decision, and an approval for its top-up.
+	Expiry time.Duration `toml:"expiry" json:"expiry"`
+}
+
+func (cfg ApprovalCfg) Validate() error {
+	_, err := parseAmount(cfg.Above)
+	return err
+}
+
+// ApprovalsCfg configures the notification of the approval requests of all chains.
+type ApprovalsCfg struct {
+	// Webhook receives every new request as JSON. Requests are sent as alerts when it is empty.
+	Webhook string `toml:"webhook" json:"webhook"`
+	// Url is the url of the admin api used in the approve and reject links.
+	Url string `toml:"url" json:"url"`
+}
+
+// ApprovalRequest is a top-up waiting for, or decided by, an operator.
+type ApprovalRequest struct {
+	Id        string    `json:"id"`
+	Chain     string    `json:"chain"`
+	To        string    `json:"to"`
+	Amount    string    `json:"amount"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	DecidedBy string    `json:"decided_by,omitempty"`
+	DecidedAt time.Time `json:"decided_at,omitempty"`
+	Comment   string    `json:"comment,omitempty"`
+}
+
+type approvalRule struct {
+	above  *big.Int
+	expiry time.Duration
+}
+
+// Approvals holds the top-ups above the approval amount of their chain until an operator approves
+// them. The watcher keeps trying the top-up on every cycle: the first attempt creates a request,
+// and the first attempt after the approval that is sent executes it. Requests are persisted so
+// that a pending or approved top-up survives restarts.
+type Approvals struct {
+	lock     sync.Mutex
+	filePath string
+	cfg      ApprovalsCfg
+	rules    map[string]*approvalRule
+	requests []*ApprovalRequest
+	alerter  alert.Alerter
+	client   *http.Client
+}
+
+func NewApprovals(filePath string, cfg ApprovalsCfg, chains map[string]ApprovalCfg,
+	alerter alert.Alerter) (*Approvals, error) {
+	a := &Approvals{
+		filePath: filePath,
+		cfg:      cfg,
+		requests: make([]*ApprovalRequest, 0),
+		alerter:  alerter,
+		client:   &http.Client{Timeout: time.Second * 10},
+	}
+	if err := a.SetRules(chains); err != nil {
+		return nil, err
+	}
+
+	if err := store.Load(filePath, &a.requests); err != nil {
+		return nil, fmt.Errorf("failed to load approval requests from %s: %w", filePath, err)
+	}
+
+	return a, nil
+}
+
+// SetRules replaces the approval amounts of all chains. They are left unchanged if one is invalid.
+func (a *Approvals) SetRules(chains map[string]ApprovalCfg) error {
+	rules := make(map[string]*approvalRule)
+	for chain, cfg := range chains {
+		above, err := parseAmount(cfg.Above)
+		if err != nil {
+			return fmt.Errorf("invalid approval amount for chain %s: %w", chain, err)
+		}
+		if above == nil {
+			continue
+		}
+
+		expiry := cfg.Expiry
+		if expiry <= 0 {
+			expiry = DefaultApprovalExpiry
+		}
+		rules[chain] = &approvalRule{above: above, expiry: expiry}
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.rules = rules
+
+	return nil
+}
+
+// Check returns nil if a top-up of amount to the address to on chain can be sent now, either
+// because it does not need approval or because an operator approved it. Otherwise it creates a
+// request, unless one is already waiting, and returns ErrAwaitingApproval. It also returns the id of
+// the approved request, which stays approved until Execute is called once the top-up is sent.
+func (a *Approvals) Check(chain, to string, amount *big.Int) (string, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	rule := a.rules[chain]
+	if rule == nil || amount.Cmp(rule.above) <= 0 {
+		return "", nil
+	}
+
+	now := time.Now()
+	a.expire(now)
+
+	for _, req := range a.requests {
+		if req.Chain != chain || req.To != to {
+			continue
+		}
+
+		switch req.Status {
+		case StatusApproved:
+			approved, _ := new(big.Int).SetString(req.Amount, 10)
+			if approved != nil && amount.Cmp(approved) <= 0 {
+				log.Infof("Sending top-up %s of %s to %s on chain %s approved by %s", req.Id, amount, to,
+					chain, req.DecidedBy)
+				return req.Id, nil
+			}
+
+			// The balance dropped further since the approval, the larger top-up needs a new one.
+			req.Status = StatusExpired
+			req.Comment = fmt.Sprintf("superseded by a top-up of %s", amount)
+
+		case StatusPending:
+			return "", fmt.Errorf("%w, request %s created at %s", ErrAwaitingApproval, req.Id,
+				req.CreatedAt.Format(time.RFC3339))
+
+		case StatusRejected:
+			// A rejection holds until the request would have expired, so that the next cycle does not
+			// ask again right away.
+			if now.Before(req.ExpiresAt) {
+				return "", fmt.Errorf("%w by %s until %s: %s", ErrRejected, req.DecidedBy,
+					req.ExpiresAt.Format(time.RFC3339), req.Comment)
+			}
+		}
+	}
+
+	req := &ApprovalRequest{
+		Id:        newRequestId(),
+		Chain:     chain,
+		To:        to,
+		Amount:    amount.String(),
+		Status:    StatusPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(rule.expiry),
+	}
+	a.requests = append(a.requests, req)
+	a.save()
+
+	log.Infof("Top-up %s of %s to %s on chain %s needs approval", req.Id, amount, to, chain)
+	go a.notify(*req)
+
+	return "", fmt.Errorf("%w, request %s created", ErrAwaitingApproval, req.Id)
+}
+
+// Execute marks the approved request id as executed once its top-up is sent, so that the approval
+// is not used by another top-up.
+func (a *Approvals) Execute(id string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for _, req := range a.requests {
+		if req.Id == id && req.Status == StatusApproved {
+			req.Status = StatusExecuted
+			a.save()
+			log.Infof("Executed top-up %s of %s to %s on chain %s", req.Id, req.Amount, req.To, req.Chain)
+			return
+		}
+	}
+}
+
+// Approve approves a pending request on behalf of operator. The top-up is sent on the next cycle
+// of the watcher of the chain.
+func (a *Approvals) Approve(id, operator, comment string) (*ApprovalRequest, error) {
+	return a.decide(id, operator, comment, StatusApproved)
+}
+
+// Reject rejects a pending request on behalf of operator.
+func (a *Approvals) Reject(id, operator, comment string) (*ApprovalRequest, error) {
+	return a.decide(id, operator, comment, StatusRejected)
+}
+
+func (a *Approvals) decide(id, operator, comment, status string) (*ApprovalRequest, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.expire(time.Now())
+	for _, req := range a.requests {
+		if req.Id != id {
+			continue
+		}
+		if req.Status != StatusPending {
+			return nil, fmt.Errorf("%w, request %s is %s", ErrDecided, id, req.Status)
+		}
+
+		req.Status = status
+		req.DecidedBy = operator
+		req.DecidedAt = time.Now()
+		req.Comment = comment
+		a.save()
+		log.Infof("Top-up %s of %s to %s on chain %s is %s by %s", id, req.Amount, req.To, req.Chain, status,
+			operator)
+
+		copied := *req
+		return &copied, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrNoRequest, id)
+}
+
+// Requests returns the requests with the given status, or all of them when status is empty, the
+// newest first.
+func (a *Approvals) Requests(status string) []ApprovalRequest {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.expire(time.Now())
+	requests := make([]ApprovalRequest, 0)
+	for _, req := range a.requests {
+		if status == "" || req.Status == status {
+			requests = append(requests, *req)
+		}
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].CreatedAt.After(requests[j].CreatedAt)
+	})
+
+	return requests
+}
+
+// expire expires the requests that were not decided or executed in time and drops the old decided
+// ones. The caller must hold the lock.
+func (a *Approvals) expire(now time.Time) {
+	changed := false
+	kept := make([]*ApprovalRequest, 0, len(a.requests))
+	for _, req := range a.requests {
+		if (req.Status == StatusPending || req.Status == StatusApproved) && !now.Before(req.ExpiresAt) {
+			log.Warnf("Top-up %s of %s to %s on chain %s expired while %s", req.Id, req.Amount, req.To,
+				req.Chain, req.Status)
+			req.Status = StatusExpired
+			changed = true
+		}
+
+		if req.Status != StatusPending && req.Status != StatusApproved &&
+			now.Sub(req.CreatedAt) > decidedRetention {
+			changed = true
+			continue
+		}
+		kept = append(kept, req)
+	}
+	a.requests = kept
+
+	if changed {
+		a.save()
+	}
+}
+
+func (a *Approvals) save() {
+	if err := store.Save(a.filePath, a.requests); err != nil {
+		log.Errorf("Failed to save approval requests to %s, err = %s", a.filePath, err)
+	}
+}
+
+// notify sends a new request to the webhook with the links to approve or reject it.
+func (a *Approvals) notify(req ApprovalRequest) {
+	base := strings.TrimSuffix(a.cfg.Url, "/") + "/approvals/" + req.Id
+	if a.cfg.Webhook == "" {
+		a.alerter.Alert(req.Chain, fmt.Sprintf("top-up %s of %s to %s needs approval until %s, approve with POST %s/approve",
+			req.Id, req.Amount, req.To, req.ExpiresAt.Format(time.RFC3339), base))
+		return
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"request":     req,
+		"approve_url": base + "/approve",
+		"reject_url":  base + "/reject",
+	})
+	if err != nil {
+		log.Errorf("Failed to marshal approval request, err = %s", err)
+		return
+	}
+
+	res, err := a.client.Post(a.cfg.Webhook, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		log.Errorf("Failed to post approval request %s to webhook, err = %s", req.Id, err)
+		return
+	}
+	res.Body.Close()
+}
+
+func newRequestId() string {
+	bz := make([]byte, 8)
+	if _, err := rand.Read(bz); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(bz)
+}
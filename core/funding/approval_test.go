@@ -0,0 +1,221 @@
+package funding
+
+import (
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// approvalStep runs op on the approvals: check a top-up of amount, approve, reject or execute the
+// latest request, expire it, or restart from the saved requests.
+type approvalStep struct {
+	op      string
+	amount  int64
+	wantErr error
+	// want is the status of the latest request after the step, "" if there is none.
+	want string
+}
+
+func TestApprovals(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []approvalStep
+	}{
+		{
+			name:  "below the approval amount",
+			steps: []approvalStep{{op: "check", amount: 1000}},
+		},
+		{
+			name: "request created once",
+			steps: []approvalStep{
+				{op: "check", amount: 5000, wantErr: ErrAwaitingApproval, want: StatusPending},
+				{op: "check", amount: 5000, wantErr: ErrAwaitingApproval, want: StatusPending},
+			},
+		},
+		{
+			name: "approved top-up is executed once sent",
+			steps: []approvalStep{
+				{op: "check", amount: 5000, wantErr: ErrAwaitingApproval, want: StatusPending},
+				{op: "approve", want: StatusApproved},
+				{op: "check", amount: 5000, want: StatusApproved},
+				{op: "execute", want: StatusExecuted},
+				{op: "check", amount: 5000, wantErr: ErrAwaitingApproval, want: StatusPending},
+			},
+		},
+		{
+			name: "approval kept by a top-up that is not sent",
+			steps: []approvalStep{
+				{op: "check", amount: 5000, wantErr: ErrAwaitingApproval, want: StatusPending},
+				{op: "approve", want: StatusApproved},
+				{op: "check", amount: 5000, want: StatusApproved},
+				{op: "check", amount: 4000, want: StatusApproved},
+				{op: "execute", want: StatusExecuted},
+			},
+		},
+		{
+			name: "approval kept after a restart",
+			steps: []approvalStep{
+				{op: "check", amount: 5000, wantErr: ErrAwaitingApproval, want: StatusPending},
+				{op: "approve", want: StatusApproved},
+				{op: "restart", want: StatusApproved},
+				{op: "check", amount: 5000, want: StatusApproved},
+			},
+		},
+		{
+			name: "larger top-up needs a new approval",
+			steps: []approvalStep{
+				{op: "check", amount: 5000, wantErr: ErrAwaitingApproval, want: StatusPending},
+				{op: "approve", want: StatusApproved},
+				{op: "check", amount: 6000, wantErr: ErrAwaitingApproval, want: StatusPending},
+			},
+		},
+		{
+			name: "rejected",
+			steps: []approvalStep{
+				{op: "check", amount: 5000, wantErr: ErrAwaitingApproval, want: StatusPending},
+				{op: "reject", want: StatusRejected},
+				{op: "check", amount: 5000, wantErr: ErrRejected, want: StatusRejected},
+				{op: "expire", want: StatusRejected},
+				{op: "check", amount: 5000, wantErr: ErrAwaitingApproval, want: StatusPending},
+			},
+		},
+		{
+			name: "approval expired before the top-up was sent",
+			steps: []approvalStep{
+				{op: "check", amount: 5000, wantErr: ErrAwaitingApproval, want: StatusPending},
+				{op: "approve", want: StatusApproved},
+				{op: "expire", want: StatusExpired},
+				{op: "check", amount: 5000, wantErr: ErrAwaitingApproval, want: StatusPending},
+			},
+		},
+		{
+			name: "decided twice",
+			steps: []approvalStep{
+				{op: "check", amount: 5000, wantErr: ErrAwaitingApproval, want: StatusPending},
+				{op: "approve", want: StatusApproved},
+				{op: "reject", wantErr: ErrDecided, want: StatusApproved},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "approvals.json")
+			chains := map[string]ApprovalCfg{"eth": {Above: "2000"}}
+			a, err := NewApprovals(filePath, ApprovalsCfg{}, chains, &recordingAlerter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			latest := func() *ApprovalRequest {
+				if len(a.requests) == 0 {
+					return nil
+				}
+				return a.requests[len(a.requests)-1]
+			}
+
+			for i, step := range tt.steps {
+				var err error
+				switch step.op {
+				case "check":
+					var id string
+					id, err = a.Check("eth", "0xto", big.NewInt(step.amount))
+					if approved := err == nil && step.want == StatusApproved; approved != (id != "") {
+						t.Fatalf("step %d: got request %q", i, id)
+					}
+				case "approve":
+					_, err = a.Approve(latest().Id, "alice", "")
+				case "reject":
+					_, err = a.Reject(latest().Id, "alice", "too much")
+				case "execute":
+					a.Execute(latest().Id)
+				case "expire":
+					latest().ExpiresAt = time.Now().Add(-time.Second)
+				case "restart":
+					a, err = NewApprovals(filePath, ApprovalsCfg{}, chains, &recordingAlerter{})
+				}
+				if !errors.Is(err, step.wantErr) {
+					t.Fatalf("step %d: got err %v, want %v", i, err, step.wantErr)
+				}
+
+				// Requests lists the requests after expiring them.
+				a.Requests("")
+				status := ""
+				if req := latest(); req != nil {
+					status = req.Status
+				}
+				if status != step.want {
+					t.Fatalf("step %d: got status %q, want %q", i, status, step.want)
+				}
+			}
+		})
+	}
+}
+
+func TestApprovalsDecideUnknown(t *testing.T) {
+	a, err := NewApprovals(filepath.Join(t.TempDir(), "approvals.json"), ApprovalsCfg{}, nil, &recordingAlerter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.Approve("unknown", "alice", ""); !errors.Is(err, ErrNoRequest) {
+		t.Fatalf("got err %v, want %v", err, ErrNoRequest)
+	}
+}
+
+// TestReservationApproval checks that an approval is only used up by a reservation that is
+// committed.
+func TestReservationApproval(t *testing.T) {
+	tests := []struct {
+		name string
+		// released is true when the first transfer never reaches the network.
+		released bool
+		want     string
+	}{
+		{name: "committed", want: StatusExecuted},
+		{name: "released", released: true, want: StatusApproved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLimiter(t, GlobalLimitCfg{}, nil)
+			a, err := NewApprovals(filepath.Join(t.TempDir(), "approvals.json"), ApprovalsCfg{},
+				map[string]ApprovalCfg{"eth": {Above: "2000"}}, &recordingAlerter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			l.SetApprovals(a)
+
+			if _, err := l.Reserve("eth", "0xto", big.NewInt(5000)); !errors.Is(err, ErrAwaitingApproval) {
+				t.Fatalf("got err %v, want %v", err, ErrAwaitingApproval)
+			}
+			req := a.Requests(StatusPending)[0]
+			if _, err := a.Approve(req.Id, "alice", ""); err != nil {
+				t.Fatal(err)
+			}
+
+			reservation, err := l.Reserve("eth", "0xto", big.NewInt(5000))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.released {
+				reservation.Release()
+			} else {
+				reservation.Commit()
+			}
+
+			if requests := a.Requests(""); len(requests) != 1 || requests[0].Status != tt.want {
+				t.Fatalf("got requests %v, want one %s", requests, tt.want)
+			}
+			_, err = l.Reserve("eth", "0xto", big.NewInt(5000))
+			if tt.released && err != nil {
+				t.Fatalf("the approval was used up by a transfer that was not sent, err = %s", err)
+			}
+			if !tt.released && !errors.Is(err, ErrAwaitingApproval) {
+				t.Fatalf("got err %v, want a new approval request", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package funding
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/store"
+)
+
+const DefaultBurnWindow = time.Hour * 24
+
+type balanceReading struct {
+	Time    time.Time `json:"time"`
+	Balance string    `json:"balance"`
+	// TopUp is true when we sent funds to the account between the previous reading and this one.
+	TopUp bool `json:"top_up"`
+}
+
+// BurnTracker keeps the balance history of a watched account and estimates how fast the account
+// spends its funds.
+type BurnTracker struct {
+	lock     sync.Mutex
+	filePath string
+	window   time.Duration
+	readings []balanceReading
+	topUp    bool
+}
+
+func NewBurnTracker(filePath string, window time.Duration) *BurnTracker {
+	if window <= 0 {
+		window = DefaultBurnWindow
+	}
+
+	t := &BurnTracker{
+		filePath: filePath,
+		window:   window,
+		readings: make([]balanceReading, 0),
+	}
+
+	if err := store.Load(filePath, &t.readings); err != nil {
+		log.Errorf("Failed to load balance history from %s, err = %s", filePath, err)
+	}
+
+	return t
+}
+
+// Observe records a balance reading.
+func (t *BurnTracker) Observe(balance *big.Int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := time.Now()
+	t.readings = append(t.readings, balanceReading{
+		Time:    now,
+		Balance: balance.String(),
+		TopUp:   t.topUp,
+	})
+	t.topUp = false
+
+	kept := t.readings[:0]
+	for _, reading := range t.readings {
+		if reading.Time.After(now.Add(-t.window)) {
+			kept = append(kept, reading)
+		}
+	}
+	t.readings = kept
+
+	if err := store.Save(t.filePath, t.readings); err != nil {
+		log.Errorf("Failed to save balance history to %s, err = %s", t.filePath, err)
+	}
+}
+
+// RecordTopUp marks that we funded the account so the next interval is not used to estimate the
+// burn rate.
+func (t *BurnTracker) RecordTopUp() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.topUp = true
+}
+
+// Rate returns the average amount burned per second. Intervals that contain one of our top-ups
+// or where the balance grew are skipped. It returns false if there is not enough history.
+func (t *BurnTracker) Rate() (*big.Float, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	burned := big.NewInt(0)
+	seconds := float64(0)
+	for i := 1; i < len(t.readings); i++ {
+		prev, cur := t.readings[i-1], t.readings[i]
+		if cur.TopUp {
+			continue
+		}
+
+		prevBalance, ok1 := new(big.Int).SetString(prev.Balance, 10)
+		curBalance, ok2 := new(big.Int).SetString(cur.Balance, 10)
+		if !ok1 || !ok2 || curBalance.Cmp(prevBalance) > 0 {
+			continue
+		}
+
+		burned.Add(burned, new(big.Int).Sub(prevBalance, curBalance))
+		seconds += cur.Time.Sub(prev.Time).Seconds()
+	}
+
+	if seconds == 0 {
+		return nil, false
+	}
+
+	return new(big.Float).Quo(new(big.Float).SetInt(burned), big.NewFloat(seconds)), true
+}
+
+// TimeToEmpty projects how long the balance lasts at the current burn rate. It returns false if
+// the rate is unknown or the account is not burning anything.
+func (t *BurnTracker) TimeToEmpty(balance *big.Int) (time.Duration, bool) {
+	rate, ok := t.Rate()
+	if !ok || rate.Sign() <= 0 {
+		return 0, false
+	}
+
+	seconds, _ := new(big.Float).Quo(new(big.Float).SetInt(balance), rate).Float64()
+	if seconds > float64(time.Duration(1<<63-1)/time.Second) {
+		return 0, false
+	}
+
+	return time.Duration(seconds * float64(time.Second)), true
+}
@@ -0,0 +1,85 @@
+package funding
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBurnTrackerRate(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	reading := func(minutes int, balance string, topUp bool) balanceReading {
+		return balanceReading{Time: start.Add(time.Minute * time.Duration(minutes)), Balance: balance, TopUp: topUp}
+	}
+
+	tests := []struct {
+		name     string
+		readings []balanceReading
+		rate     float64
+		known    bool
+	}{
+		{name: "no history"},
+		{name: "single reading", readings: []balanceReading{reading(0, "1000", false)}},
+		{
+			name:     "steady burn",
+			readings: []balanceReading{reading(0, "1000", false), reading(1, "940", false), reading(2, "880", false)},
+			rate:     1,
+			known:    true,
+		},
+		{
+			name: "top-up interval is skipped",
+			readings: []balanceReading{
+				reading(0, "1000", false), reading(1, "940", false), reading(2, "5000", true),
+				reading(3, "4940", false),
+			},
+			rate:  1,
+			known: true,
+		},
+		{
+			name: "a top-up that lands with a lower balance is still skipped",
+			readings: []balanceReading{
+				reading(0, "1000", false), reading(1, "100", true), reading(2, "40", false),
+			},
+			rate:  1,
+			known: true,
+		},
+		{
+			name:     "incoming funds are skipped",
+			readings: []balanceReading{reading(0, "1000", false), reading(1, "2000", false)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewBurnTracker(filepath.Join(t.TempDir(), "burn.json"), time.Hour*24)
+			tracker.readings = tt.readings
+
+			rate, ok := tracker.Rate()
+			if ok != tt.known {
+				t.Fatalf("got known %t, want %t", ok, tt.known)
+			}
+			if !ok {
+				return
+			}
+			if got, _ := rate.Float64(); got != tt.rate {
+				t.Fatalf("got rate %f, want %f", got, tt.rate)
+			}
+		})
+	}
+}
+
+func TestBurnTrackerTopUp(t *testing.T) {
+	tracker := NewBurnTracker(filepath.Join(t.TempDir(), "burn.json"), time.Hour*24)
+
+	tracker.Observe(big.NewInt(1000))
+	tracker.RecordTopUp()
+	tracker.Observe(big.NewInt(500))
+
+	if _, ok := tracker.Rate(); ok {
+		t.Fatal("the interval with a top-up was used to estimate the rate")
+	}
+	if !tracker.readings[1].TopUp {
+		t.Fatal("the reading after the top-up is not marked")
+	}
+}
@@ -0,0 +1,589 @@
+package funding
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/alert"
+	"github.com/sisu-network/sisu-account-funding/core/store"
+)
+
+var (
+	ErrChainHalted   = errors.New("chain is halted by the circuit breaker")
+	ErrLimitExceeded = errors.New("funding limit exceeded")
+	ErrPaused        = errors.New("funding is paused")
+	ErrNotHalted     = errors.New("chain is not halted")
+	ErrNotPaused     = errors.New("funding is not paused")
+)
+
+// LimitCfg is the spending limit of a single chain. All amounts are decimal strings in the
+// smallest unit of the chain (wei, beddows, ...). An empty amount or a zero count means no limit.
+type LimitCfg struct {
+	MaxTransfer string `toml:"max_transfer" json:"max_transfer"`
+	MaxPerHour  string `toml:"max_per_hour" json:"max_per_hour"`
+	MaxPerDay   string `toml:"max_per_day" json:"max_per_day"`
+
+	// The circuit breaker trips when more than MaxTopUps top-ups happen within TopUpWindow.
+	MaxTopUps   int           `toml:"max_topups" json:"max_topups"`
+	TopUpWindow time.Duration `toml:"topup_window" json:"topup_window"`
+}
+
+// GlobalLimitCfg caps the spending across chains. Amounts of different chains are not comparable,
+// so the caps across all chains are counts and the amounts are capped per group of chains that pay
+// in the same token.
+type GlobalLimitCfg struct {
+	MaxTransfersPerHour int `toml:"max_transfers_per_hour" json:"max_transfers_per_hour"`
+	MaxTransfersPerDay  int `toml:"max_transfers_per_day" json:"max_transfers_per_day"`
+	// Groups cap the amount spent across several chains.
+	Groups []GroupLimitCfg `toml:"groups" json:"groups"`
+}
+
+// GroupLimitCfg caps the total amount spent by chains whose amounts are in the same unit, e.g. the
+// EVM chains that pay for gas in ETH. Amounts are decimal strings, an empty amount means no limit.
+type GroupLimitCfg struct {
+	Name       string   `toml:"name" json:"name"`
+	Chains     []string `toml:"chains" json:"chains"`
+	MaxPerHour string   `toml:"max_per_hour" json:"max_per_hour"`
+	MaxPerDay  string   `toml:"max_per_day" json:"max_per_day"`
+}
+
+// Gate decides whether a chain may be funded now, on top of its limits, e.g. whether this instance
+// is the leader of a group of redundant funders or the chain is in maintenance.
+type Gate interface {
+	Allow(chain string) error
+}
+
+// GateFunc adapts a function to a Gate.
+type GateFunc func(chain string) error
+
+func (f GateFunc) Allow(chain string) error {
+	return f(chain)
+}
+
+type chainLimit struct {
+	maxTransfer *big.Int
+	maxPerHour  *big.Int
+	maxPerDay   *big.Int
+	maxTopUps   int
+	topUpWindow time.Duration
+}
+
+type groupLimit struct {
+	name       string
+	chains     []string
+	maxPerHour *big.Int
+	maxPerDay  *big.Int
+}
+
+type spendRecord struct {
+	Time   time.Time `json:"time"`
+	Amount string    `json:"amount"`
+}
+
+// Halt is why and since when a chain, or the funding of every chain, is stopped.
+type Halt struct {
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+}
+
+type limiterState struct {
+	Spends map[string][]spendRecord `json:"spends"`
+	Halted map[string]Halt          `json:"halted"`
+	// Resumed is when each chain was last resumed. The circuit breaker of a chain only counts the
+	// top-ups since, otherwise it would trip again on the next top-up.
+	Resumed map[string]time.Time `json:"resumed,omitempty"`
+	// Paused stops the funding of every chain.
+	Paused *Halt `json:"paused,omitempty"`
+}
+
+// Limiter enforces the spending limits of all chains and halts a chain when one of its limits is
+// hit. Its state is persisted to a file so that a restart does not reset the counters or resume a
+// halted chain.
+type Limiter struct {
+	lock      sync.Mutex
+	filePath  string
+	global    GlobalLimitCfg
+	chains    map[string]*chainLimit
+	groups    []*groupLimit
+	state     *limiterState
+	alerter   alert.Alerter
+	gates     []Gate
+	approvals *Approvals
+}
+
+func NewLimiter(filePath string, global GlobalLimitCfg, chains map[string]LimitCfg,
+	alerter alert.Alerter) (*Limiter, error) {
+	l := &Limiter{
+		filePath: filePath,
+		state: &limiterState{
+			Spends:  make(map[string][]spendRecord),
+			Halted:  make(map[string]Halt),
+			Resumed: make(map[string]time.Time),
+		},
+		alerter: alerter,
+	}
+
+	if err := l.SetLimits(global, chains); err != nil {
+		return nil, err
+	}
+
+	if err := store.Load(filePath, l.state); err != nil {
+		return nil, fmt.Errorf("failed to load limiter state from %s: %w", filePath, err)
+	}
+	if l.state.Spends == nil {
+		l.state.Spends = make(map[string][]spendRecord)
+	}
+	if l.state.Halted == nil {
+		l.state.Halted = make(map[string]Halt)
+	}
+	if l.state.Resumed == nil {
+		l.state.Resumed = make(map[string]time.Time)
+	}
+
+	for chain, halt := range l.state.Halted {
+		log.Warnf("Chain %s is halted since %s, reason = %s", chain, halt.Time, halt.Reason)
+	}
+	if l.state.Paused != nil {
+		log.Warnf("Funding is paused since %s, reason = %s", l.state.Paused.Time, l.state.Paused.Reason)
+	}
+
+	return l, nil
+}
+
+// SetLimits replaces the limits of all chains. The limits are left unchanged if one is invalid.
+// Spends and halts are kept.
+func (l *Limiter) SetLimits(global GlobalLimitCfg, chains map[string]LimitCfg) error {
+	limits := make(map[string]*chainLimit)
+	for chain, cfg := range chains {
+		limit, err := parseLimit(cfg)
+		if err != nil {
+			return fmt.Errorf("invalid limits for chain %s: %w", chain, err)
+		}
+		limits[chain] = limit
+	}
+
+	groups := make([]*groupLimit, 0, len(global.Groups))
+	for _, cfg := range global.Groups {
+		group, err := parseGroupLimit(cfg, chains)
+		if err != nil {
+			return fmt.Errorf("invalid limits for group %s: %w", cfg.Name, err)
+		}
+		groups = append(groups, group)
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.global = global
+	l.chains = limits
+	l.groups = groups
+
+	return nil
+}
+
+// AddGate makes Reserve fail while gate does not allow funding a chain.
+func (l *Limiter) AddGate(gate Gate) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.gates = append(l.gates, gate)
+}
+
+// SetApprovals makes the large top-ups wait for the approval of an operator.
+func (l *Limiter) SetApprovals(approvals *Approvals) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.approvals = approvals
+}
+
+func parseLimit(cfg LimitCfg) (*chainLimit, error) {
+	limit := &chainLimit{
+		maxTopUps:   cfg.MaxTopUps,
+		topUpWindow: cfg.TopUpWindow,
+	}
+
+	var err error
+	if limit.maxTransfer, err = parseAmount(cfg.MaxTransfer); err != nil {
+		return nil, err
+	}
+	if limit.maxPerHour, err = parseAmount(cfg.MaxPerHour); err != nil {
+		return nil, err
+	}
+	if limit.maxPerDay, err = parseAmount(cfg.MaxPerDay); err != nil {
+		return nil, err
+	}
+
+	if limit.maxTopUps > 0 && limit.topUpWindow <= 0 {
+		return nil, fmt.Errorf("topup_window must be set when max_topups is set")
+	}
+
+	return limit, nil
+}
+
+func parseGroupLimit(cfg GroupLimitCfg, chains map[string]LimitCfg) (*groupLimit, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("name is not set")
+	}
+	if len(cfg.Chains) == 0 {
+		return nil, fmt.Errorf("chains are not set")
+	}
+	for _, chain := range cfg.Chains {
+		if _, ok := chains[chain]; !ok {
+			return nil, fmt.Errorf("unknown chain %s", chain)
+		}
+	}
+
+	group := &groupLimit{name: cfg.Name, chains: cfg.Chains}
+
+	var err error
+	if group.maxPerHour, err = parseAmount(cfg.MaxPerHour); err != nil {
+		return nil, err
+	}
+	if group.maxPerDay, err = parseAmount(cfg.MaxPerDay); err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// parseAmount parses a decimal amount. An empty string returns nil.
+func parseAmount(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	amount, ok := new(big.Int).SetString(s, 10)
+	if !ok || amount.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+
+	return amount, nil
+}
+
+// Reservation is an amount reserved by Reserve. It counts against the limits until it is released,
+// and it is committed once the transfer is sent.
+type Reservation struct {
+	limiter *Limiter
+	chain   string
+	spend   spendRecord
+	// approval is the id of the approved request the transfer uses, if it needs one.
+	approvals *Approvals
+	approval  string
+}
+
+// Reserve checks that transferring amount to the address to on chain stays within all limits and
+// is approved if it needs to be, and records the transfer as spent. The spend is recorded before
+// the transfer is sent so that a transfer that fails after reaching the network still counts
+// against the limits. The caller must release the reservation if the transfer never reaches the
+// network, and commit it once the transfer is sent.
+func (l *Limiter) Reserve(chain, to string, amount *big.Int) (*Reservation, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, gate := range l.gates {
+		if err := gate.Allow(chain); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.state.Paused != nil {
+		return nil, fmt.Errorf("%w since %s: %s", ErrPaused, l.state.Paused.Time.Format(time.RFC3339),
+			l.state.Paused.Reason)
+	}
+
+	if halt, ok := l.state.Halted[chain]; ok {
+		return nil, fmt.Errorf("%w since %s: %s", ErrChainHalted, halt.Time.Format(time.RFC3339), halt.Reason)
+	}
+
+	now := time.Now()
+	l.prune(now)
+
+	if limit := l.chains[chain]; limit != nil {
+		if limit.maxTransfer != nil && amount.Cmp(limit.maxTransfer) > 0 {
+			msg := fmt.Sprintf("transfer of %s is above the max single transfer %s", amount, limit.maxTransfer)
+			l.alerter.Alert(chain, msg)
+			return nil, fmt.Errorf("%w: %s", ErrLimitExceeded, msg)
+		}
+
+		if limit.maxPerHour != nil {
+			spent := l.spentSince(chain, now.Add(-time.Hour))
+			if new(big.Int).Add(spent, amount).Cmp(limit.maxPerHour) > 0 {
+				return nil, l.halt(chain, now, fmt.Sprintf("hourly cap %s reached, already spent %s",
+					limit.maxPerHour, spent))
+			}
+		}
+
+		if limit.maxPerDay != nil {
+			spent := l.spentSince(chain, now.Add(-time.Hour*24))
+			if new(big.Int).Add(spent, amount).Cmp(limit.maxPerDay) > 0 {
+				return nil, l.halt(chain, now, fmt.Sprintf("daily cap %s reached, already spent %s",
+					limit.maxPerDay, spent))
+			}
+		}
+
+		if limit.maxTopUps > 0 {
+			since := now.Add(-limit.topUpWindow)
+			if resumed := l.state.Resumed[chain]; resumed.After(since) {
+				since = resumed
+			}
+			count := l.countSince(chain, since)
+			if count >= limit.maxTopUps {
+				return nil, l.halt(chain, now, fmt.Sprintf("%d top-ups within %s", count, limit.topUpWindow))
+			}
+		}
+	}
+
+	if l.global.MaxTransfersPerHour > 0 && l.countAllSince(now.Add(-time.Hour)) >= l.global.MaxTransfersPerHour {
+		msg := fmt.Sprintf("global hourly cap of %d transfers reached", l.global.MaxTransfersPerHour)
+		l.alerter.Alert(chain, msg)
+		return nil, fmt.Errorf("%w: %s", ErrLimitExceeded, msg)
+	}
+
+	if l.global.MaxTransfersPerDay > 0 && l.countAllSince(now.Add(-time.Hour*24)) >= l.global.MaxTransfersPerDay {
+		msg := fmt.Sprintf("global daily cap of %d transfers reached", l.global.MaxTransfersPerDay)
+		l.alerter.Alert(chain, msg)
+		return nil, fmt.Errorf("%w: %s", ErrLimitExceeded, msg)
+	}
+
+	for _, group := range l.groups {
+		if !group.has(chain) {
+			continue
+		}
+
+		if group.maxPerHour != nil {
+			spent := l.groupSpentSince(group, now.Add(-time.Hour))
+			if new(big.Int).Add(spent, amount).Cmp(group.maxPerHour) > 0 {
+				msg := fmt.Sprintf("hourly cap %s of group %s reached, already spent %s", group.maxPerHour,
+					group.name, spent)
+				l.alerter.Alert(chain, msg)
+				return nil, fmt.Errorf("%w: %s", ErrLimitExceeded, msg)
+			}
+		}
+
+		if group.maxPerDay != nil {
+			spent := l.groupSpentSince(group, now.Add(-time.Hour*24))
+			if new(big.Int).Add(spent, amount).Cmp(group.maxPerDay) > 0 {
+				msg := fmt.Sprintf("daily cap %s of group %s reached, already spent %s", group.maxPerDay,
+					group.name, spent)
+				l.alerter.Alert(chain, msg)
+				return nil, fmt.Errorf("%w: %s", ErrLimitExceeded, msg)
+			}
+		}
+	}
+
+	// Approval comes last so that a top-up refused by a limit does not create a request. The approval
+	// is only used up by Commit.
+	approval := ""
+	if l.approvals != nil {
+		var err error
+		if approval, err = l.approvals.Check(chain, to, amount); err != nil {
+			return nil, err
+		}
+	}
+
+	spend := spendRecord{Time: now, Amount: amount.String()}
+	l.state.Spends[chain] = append(l.state.Spends[chain], spend)
+	l.save()
+
+	return &Reservation{limiter: l, chain: chain, spend: spend, approvals: l.approvals, approval: approval}, nil
+}
+
+// Commit records that the transfer of the reservation was sent. The approval it used, if any, is
+// executed and cannot be used again, while a released reservation keeps it for the next attempt.
+func (r *Reservation) Commit() {
+	if r.approval != "" {
+		r.approvals.Execute(r.approval)
+	}
+}
+
+// Release gives back a reservation whose transfer never reached the network, e.g. because an rpc
+// failed while it was built or the node refused it, so that it does not count against the limits.
+func (r *Reservation) Release() {
+	l := r.limiter
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	spends := l.state.Spends[r.chain]
+	for i, spend := range spends {
+		if spend.Time.Equal(r.spend.Time) && spend.Amount == r.spend.Amount {
+			l.state.Spends[r.chain] = append(spends[:i:i], spends[i+1:]...)
+			l.save()
+
+			log.Infof("Released reservation of %s on chain %s", r.spend.Amount, r.chain)
+			return
+		}
+	}
+}
+
+// Halted returns true if the circuit breaker of the chain has tripped.
+func (l *Limiter) Halted(chain string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	_, ok := l.state.Halted[chain]
+	return ok
+}
+
+// Halts returns the halted chains.
+func (l *Limiter) Halts() map[string]Halt {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	halts := make(map[string]Halt, len(l.state.Halted))
+	for chain, halt := range l.state.Halted {
+		halts[chain] = halt
+	}
+
+	return halts
+}
+
+// Resume clears the circuit breaker of a chain. The hourly and daily caps still count the spends
+// made before, the top-ups burst only counts the top-ups made after. It returns ErrNotHalted if the
+// chain is not halted.
+func (l *Limiter) Resume(chain string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if _, ok := l.state.Halted[chain]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotHalted, chain)
+	}
+	delete(l.state.Halted, chain)
+	l.state.Resumed[chain] = time.Now()
+	l.save()
+
+	log.Infof("Chain %s is resumed", chain)
+
+	return nil
+}
+
+// Pause stops the funding of every chain until Unpause is called. The pause survives restarts.
+func (l *Limiter) Pause(reason string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.state.Paused != nil {
+		return
+	}
+	l.state.Paused = &Halt{Time: time.Now(), Reason: reason}
+	l.save()
+
+	log.Critical("Funding of every chain is paused, reason = ", reason)
+}
+
+// Paused returns true if the funding of every chain is paused.
+func (l *Limiter) Paused() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.state.Paused != nil
+}
+
+// Unpause resumes the funding of the chains that are not halted. It returns ErrNotPaused if the
+// funding is not paused.
+func (l *Limiter) Unpause() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.state.Paused == nil {
+		return ErrNotPaused
+	}
+	l.state.Paused = nil
+	l.save()
+
+	log.Infof("Funding is unpaused")
+
+	return nil
+}
+
+func (l *Limiter) halt(chain string, now time.Time, reason string) error {
+	l.state.Halted[chain] = Halt{Time: now, Reason: reason}
+	l.save()
+
+	l.alerter.Alert(chain, "circuit breaker tripped, funding halted: "+reason)
+
+	return fmt.Errorf("%w: %s", ErrChainHalted, reason)
+}
+
+func (l *Limiter) spentSince(chain string, since time.Time) *big.Int {
+	total := big.NewInt(0)
+	for _, record := range l.state.Spends[chain] {
+		if record.Time.After(since) {
+			amount, ok := new(big.Int).SetString(record.Amount, 10)
+			if ok {
+				total.Add(total, amount)
+			}
+		}
+	}
+
+	return total
+}
+
+func (l *Limiter) countSince(chain string, since time.Time) int {
+	count := 0
+	for _, record := range l.state.Spends[chain] {
+		if record.Time.After(since) {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (l *Limiter) groupSpentSince(group *groupLimit, since time.Time) *big.Int {
+	total := big.NewInt(0)
+	for _, chain := range group.chains {
+		total.Add(total, l.spentSince(chain, since))
+	}
+
+	return total
+}
+
+func (l *Limiter) countAllSince(since time.Time) int {
+	count := 0
+	for chain := range l.state.Spends {
+		count += l.countSince(chain, since)
+	}
+
+	return count
+}
+
+func (g *groupLimit) has(chain string) bool {
+	for _, c := range g.chains {
+		if c == chain {
+			return true
+		}
+	}
+
+	return false
+}
+
+// prune drops the records that are older than any window the limiter looks at.
+func (l *Limiter) prune(now time.Time) {
+	oldest := now.Add(-time.Hour * 24)
+	for _, limit := range l.chains {
+		if now.Add(-limit.topUpWindow).Before(oldest) {
+			oldest = now.Add(-limit.topUpWindow)
+		}
+	}
+
+	for chain, records := range l.state.Spends {
+		kept := records[:0]
+		for _, record := range records {
+			if record.Time.After(oldest) {
+				kept = append(kept, record)
+			}
+		}
+		l.state.Spends[chain] = kept
+	}
+}
+
+func (l *Limiter) save() {
+	if err := store.Save(l.filePath, l.state); err != nil {
+		log.Errorf("Failed to save limiter state to %s, err = %s", l.filePath, err)
+	}
+}
@@ -0,0 +1,281 @@
+package funding
+
+import (
+	"errors"
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingAlerter keeps the alerts instead of sending them.
+type recordingAlerter struct {
+	lock   sync.Mutex
+	alerts []string
+}
+
+func (a *recordingAlerter) Alert(chain string, message string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.alerts = append(a.alerts, chain+": "+message)
+}
+
+func newTestLimiter(t *testing.T, global GlobalLimitCfg, chains map[string]LimitCfg) *Limiter {
+	t.Helper()
+
+	l, err := NewLimiter(filepath.Join(t.TempDir(), "limiter.json"), global, chains, &recordingAlerter{})
+	if err != nil {
+		t.Fatalf("NewLimiter: %s", err)
+	}
+
+	return l
+}
+
+type reservation struct {
+	chain  string
+	amount int64
+	err    error
+}
+
+func TestLimiterReserve(t *testing.T) {
+	tests := []struct {
+		name         string
+		global       GlobalLimitCfg
+		chains       map[string]LimitCfg
+		reservations []reservation
+		halted       []string
+	}{
+		{
+			name:   "max single transfer",
+			chains: map[string]LimitCfg{"eth": {MaxTransfer: "100"}},
+			reservations: []reservation{
+				{chain: "eth", amount: 100},
+				{chain: "eth", amount: 101, err: ErrLimitExceeded},
+				{chain: "eth", amount: 50},
+			},
+		},
+		{
+			name:   "hourly cap halts the chain",
+			chains: map[string]LimitCfg{"eth": {MaxPerHour: "250"}, "bsc": {}},
+			reservations: []reservation{
+				{chain: "eth", amount: 100},
+				{chain: "eth", amount: 100},
+				{chain: "eth", amount: 100, err: ErrChainHalted},
+				{chain: "eth", amount: 1, err: ErrChainHalted},
+				{chain: "bsc", amount: 1000},
+			},
+			halted: []string{"eth"},
+		},
+		{
+			name:   "daily cap halts the chain",
+			chains: map[string]LimitCfg{"eth": {MaxPerDay: "100"}},
+			reservations: []reservation{
+				{chain: "eth", amount: 100},
+				{chain: "eth", amount: 1, err: ErrChainHalted},
+			},
+			halted: []string{"eth"},
+		},
+		{
+			name:   "top-ups burst halts the chain",
+			chains: map[string]LimitCfg{"eth": {MaxTopUps: 2, TopUpWindow: time.Hour}},
+			reservations: []reservation{
+				{chain: "eth", amount: 1},
+				{chain: "eth", amount: 1},
+				{chain: "eth", amount: 1, err: ErrChainHalted},
+			},
+			halted: []string{"eth"},
+		},
+		{
+			name:   "global count",
+			global: GlobalLimitCfg{MaxTransfersPerHour: 2},
+			chains: map[string]LimitCfg{"eth": {}, "bsc": {}},
+			reservations: []reservation{
+				{chain: "eth", amount: 1},
+				{chain: "bsc", amount: 1},
+				{chain: "eth", amount: 1, err: ErrLimitExceeded},
+			},
+		},
+		{
+			name: "group amount cap",
+			global: GlobalLimitCfg{Groups: []GroupLimitCfg{
+				{Name: "ether", Chains: []string{"eth", "arbitrum"}, MaxPerDay: "150"},
+			}},
+			chains: map[string]LimitCfg{"eth": {}, "arbitrum": {}, "bsc": {}},
+			reservations: []reservation{
+				{chain: "eth", amount: 100},
+				{chain: "arbitrum", amount: 60, err: ErrLimitExceeded},
+				{chain: "arbitrum", amount: 50},
+				{chain: "bsc", amount: 1000},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLimiter(t, tt.global, tt.chains)
+
+			for i, r := range tt.reservations {
+				_, err := l.Reserve(r.chain, "0xto", big.NewInt(r.amount))
+				if !errors.Is(err, r.err) || (err != nil) != (r.err != nil) {
+					t.Fatalf("reservation %d of %d on %s: got err %v, want %v", i, r.amount, r.chain, err, r.err)
+				}
+			}
+
+			halts := l.Halts()
+			if len(halts) != len(tt.halted) {
+				t.Fatalf("got halts %v, want %v", halts, tt.halted)
+			}
+			for _, chain := range tt.halted {
+				if !l.Halted(chain) {
+					t.Errorf("chain %s is not halted", chain)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterInvalidGroup(t *testing.T) {
+	tests := []struct {
+		name  string
+		group GroupLimitCfg
+	}{
+		{name: "no name", group: GroupLimitCfg{Chains: []string{"eth"}}},
+		{name: "no chains", group: GroupLimitCfg{Name: "ether"}},
+		{name: "unknown chain", group: GroupLimitCfg{Name: "ether", Chains: []string{"ropsten"}}},
+		{name: "invalid amount", group: GroupLimitCfg{Name: "ether", Chains: []string{"eth"}, MaxPerDay: "1e18"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLimiter(filepath.Join(t.TempDir(), "limiter.json"),
+				GlobalLimitCfg{Groups: []GroupLimitCfg{tt.group}}, map[string]LimitCfg{"eth": {}},
+				&recordingAlerter{})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestLimiterResume(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "limiter.json")
+	chains := map[string]LimitCfg{"eth": {MaxTopUps: 1, TopUpWindow: time.Hour}}
+	l, err := NewLimiter(filePath, GlobalLimitCfg{}, chains, &recordingAlerter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Resume("eth"); !errors.Is(err, ErrNotHalted) {
+		t.Fatalf("got %v, want ErrNotHalted", err)
+	}
+
+	if _, err := l.Reserve("eth", "0xto", big.NewInt(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Reserve("eth", "0xto", big.NewInt(1)); !errors.Is(err, ErrChainHalted) {
+		t.Fatalf("got %v, want ErrChainHalted", err)
+	}
+
+	// The halt survives a restart.
+	l, err = NewLimiter(filePath, GlobalLimitCfg{}, chains, &recordingAlerter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Halted("eth") {
+		t.Fatal("eth is not halted after a restart")
+	}
+
+	// A resumed chain does not trip again on the top-ups made before it was resumed.
+	if err := l.Resume("eth"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Reserve("eth", "0xto", big.NewInt(1)); err != nil {
+		t.Fatalf("reserve after resume: %s", err)
+	}
+	if _, err := l.Reserve("eth", "0xto", big.NewInt(1)); !errors.Is(err, ErrChainHalted) {
+		t.Fatalf("got %v, want ErrChainHalted", err)
+	}
+}
+
+func TestLimiterUnpause(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "limiter.json")
+	l, err := NewLimiter(filePath, GlobalLimitCfg{}, map[string]LimitCfg{"eth": {}}, &recordingAlerter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Unpause(); !errors.Is(err, ErrNotPaused) {
+		t.Fatalf("got %v, want ErrNotPaused", err)
+	}
+
+	l.Pause("unexpected outflow")
+	if _, err := l.Reserve("eth", "0xto", big.NewInt(1)); !errors.Is(err, ErrPaused) {
+		t.Fatalf("got %v, want ErrPaused", err)
+	}
+
+	// The pause survives a restart.
+	l, err = NewLimiter(filePath, GlobalLimitCfg{}, map[string]LimitCfg{"eth": {}}, &recordingAlerter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Paused() {
+		t.Fatal("funding is not paused after a restart")
+	}
+
+	if err := l.Unpause(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Reserve("eth", "0xto", big.NewInt(1)); err != nil {
+		t.Fatalf("reserve after unpause: %s", err)
+	}
+}
+
+func TestReservationRelease(t *testing.T) {
+	tests := []struct {
+		name    string
+		release bool
+		err     error
+	}{
+		{
+			name: "spend of a sent transfer counts against the cap",
+			err:  ErrChainHalted,
+		},
+		{
+			name:    "released spend does not count against the cap",
+			release: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "limiter.json")
+			chains := map[string]LimitCfg{"eth": {MaxPerDay: "2"}}
+			l, err := NewLimiter(filePath, GlobalLimitCfg{}, chains, &recordingAlerter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := l.Reserve("eth", "0xto", big.NewInt(1)); err != nil {
+				t.Fatal(err)
+			}
+			reservation, err := l.Reserve("eth", "0xto", big.NewInt(1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.release {
+				reservation.Release()
+			}
+
+			// The release survives a restart.
+			l, err = NewLimiter(filePath, GlobalLimitCfg{}, chains, &recordingAlerter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := l.Reserve("eth", "0xto", big.NewInt(1)); !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package funding
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sisu-network/sisu-account-funding/core/alert"
+)
+
+// ExpectTimeout is how long a transfer sent by the funder may take to show up in the faucet
+// balance, unless the chain sets its own. Expected spends older than that no longer explain a
+// balance drop.
+var ExpectTimeout = time.Hour
+
+// OutflowCfg configures the detection of faucet outflows that the funder did not send.
+type OutflowCfg struct {
+	Disabled bool `toml:"disabled" json:"disabled"`
+	// Pause pauses the funding of every chain when an unexpected outflow is detected. All the
+	// faucet keys derive from the same mnemonic, so a leak of one is a leak of all.
+	Pause bool `toml:"pause" json:"pause"`
+	// ExpectTimeout overrides the default ExpectTimeout, e.g. for a chain whose transfers take
+	// longer to confirm.
+	ExpectTimeout time.Duration `toml:"expect_timeout" json:"expect_timeout"`
+}
+
+// FaucetState is the state of a faucet account observed at the start of a watcher cycle.
+type FaucetState struct {
+	Address string
+	Balance *big.Int
+	// Nonce is the number of transactions sent by the faucet, for chains that have one.
+	Nonce    uint64
+	HasNonce bool
+}
+
+type expectedSpend struct {
+	time   time.Time
+	amount *big.Int
+}
+
+// OutflowMonitor compares the faucet state between watcher cycles with the transfers the watcher
+// sent. A nonce that moved more than the transfers sent, or a balance that dropped more than their
+// amounts and fees, means that someone else is spending from the faucet.
+type OutflowMonitor struct {
+	lock    sync.Mutex
+	chain   string
+	cfg     OutflowCfg
+	limiter *Limiter
+	alerter alert.Alerter
+
+	last       *FaucetState
+	pendingTxs uint64
+	spends     []expectedSpend
+}
+
+func NewOutflowMonitor(chain string, cfg OutflowCfg, limiter *Limiter, alerter alert.Alerter) *OutflowMonitor {
+	return &OutflowMonitor{
+		chain:   chain,
+		cfg:     cfg,
+		limiter: limiter,
+		alerter: alerter,
+	}
+}
+
+// Enabled returns false when the detection is disabled and the watcher does not need to read the
+// faucet state.
+func (m *OutflowMonitor) Enabled() bool {
+	return !m.cfg.Disabled
+}
+
+// Expect records a transaction sent by the funder, with amount leaving the faucet and fee the
+// highest fee it can pay. It must be called once the transaction is sent.
+func (m *OutflowMonitor) Expect(amount, fee *big.Int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.pendingTxs++
+	m.spends = append(m.spends, expectedSpend{time: time.Now(), amount: new(big.Int).Add(amount, fee)})
+}
+
+// Observe compares the faucet state with the previous one.
+func (m *OutflowMonitor) Observe(state FaucetState) {
+	if !m.Enabled() {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	last := m.last
+	m.last = &state
+	if last == nil {
+		return
+	}
+
+	reasons := make([]string, 0)
+	if state.HasNonce && last.HasNonce && state.Nonce > last.Nonce {
+		sent := state.Nonce - last.Nonce
+		if sent > m.pendingTxs {
+			reasons = append(reasons, fmt.Sprintf("nonce moved from %d to %d but the funder sent %d transactions",
+				last.Nonce, state.Nonce, m.pendingTxs))
+			m.pendingTxs = 0
+		} else {
+			m.pendingTxs -= sent
+		}
+	}
+
+	expected := m.expectedSpend()
+	decrease := new(big.Int).Sub(last.Balance, state.Balance)
+	if decrease.Cmp(expected) > 0 {
+		reasons = append(reasons, fmt.Sprintf("balance dropped by %s from %s to %s but the funder spent at most %s",
+			decrease, last.Balance, state.Balance, expected))
+		m.spends = nil
+	}
+
+	if len(reasons) > 0 {
+		m.unexpected(state.Address, strings.Join(reasons, ", "))
+		return
+	}
+
+	// The drop is explained by the oldest expected spends.
+	for len(m.spends) > 0 && decrease.Sign() > 0 {
+		if m.spends[0].amount.Cmp(decrease) > 0 {
+			m.spends[0].amount = new(big.Int).Sub(m.spends[0].amount, decrease)
+			break
+		}
+		decrease.Sub(decrease, m.spends[0].amount)
+		m.spends = m.spends[1:]
+	}
+}
+
+// expectedSpend drops the expired spends and returns the total of the others.
+func (m *OutflowMonitor) expectedSpend() *big.Int {
+	timeout := m.cfg.ExpectTimeout
+	if timeout <= 0 {
+		timeout = ExpectTimeout
+	}
+
+	cutoff := time.Now().Add(-timeout)
+	kept := m.spends[:0]
+	total := big.NewInt(0)
+	for _, spend := range m.spends {
+		if spend.time.After(cutoff) {
+			kept = append(kept, spend)
+			total.Add(total, spend.amount)
+		}
+	}
+	m.spends = kept
+
+	return total
+}
+
+func (m *OutflowMonitor) unexpected(address, reason string) {
+	m.alerter.Alert(m.chain, fmt.Sprintf("unexpected outflow from faucet %s, the key may have leaked: %s",
+		address, reason))
+
+	if m.cfg.Pause {
+		m.limiter.Pause(fmt.Sprintf("unexpected outflow from faucet %s on chain %s", address, m.chain))
+	}
+}
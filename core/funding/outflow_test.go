@@ -0,0 +1,225 @@
+package funding
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// outflowStep is either a transfer sent by the funder, when sent is set, or a faucet state observed
+// by the watcher.
+type outflowStep struct {
+	sent     int64
+	fee      int64
+	balance  int64
+	nonce    uint64
+	hasNonce bool
+}
+
+func TestOutflowObserve(t *testing.T) {
+	tests := []struct {
+		name   string
+		pause  bool
+		steps  []outflowStep
+		alerts int
+		paused bool
+	}{
+		{
+			name: "expected spend",
+			steps: []outflowStep{
+				{balance: 1000, nonce: 4, hasNonce: true},
+				{sent: 100, fee: 10},
+				{balance: 895, nonce: 5, hasNonce: true},
+			},
+		},
+		{
+			name: "expected spend not mined yet",
+			steps: []outflowStep{
+				{balance: 1000, nonce: 4, hasNonce: true},
+				{sent: 100, fee: 10},
+				{balance: 1000, nonce: 4, hasNonce: true},
+				{balance: 895, nonce: 5, hasNonce: true},
+			},
+		},
+		{
+			name: "expected spends over several cycles",
+			steps: []outflowStep{
+				{balance: 1000},
+				{sent: 100, fee: 10},
+				{sent: 200, fee: 10},
+				{balance: 895},
+				{balance: 690},
+			},
+		},
+		{
+			name:  "spend used up by an earlier drop",
+			pause: true,
+			steps: []outflowStep{
+				{balance: 1000},
+				{sent: 100, fee: 10},
+				{balance: 895},
+				{balance: 800},
+			},
+			alerts: 1,
+			paused: true,
+		},
+		{
+			name:  "unexpected balance drop",
+			pause: true,
+			steps: []outflowStep{
+				{balance: 1000},
+				{balance: 500},
+			},
+			alerts: 1,
+			paused: true,
+		},
+		{
+			name:  "drop larger than the expected spend",
+			pause: true,
+			steps: []outflowStep{
+				{balance: 1000, nonce: 4, hasNonce: true},
+				{sent: 100, fee: 10},
+				{balance: 500, nonce: 5, hasNonce: true},
+			},
+			alerts: 1,
+			paused: true,
+		},
+		{
+			name:  "unexpected transaction",
+			pause: true,
+			steps: []outflowStep{
+				{balance: 1000, nonce: 4, hasNonce: true},
+				{sent: 100, fee: 10},
+				{balance: 900, nonce: 6, hasNonce: true},
+			},
+			alerts: 1,
+			paused: true,
+		},
+		{
+			name: "unexpected outflow without pause",
+			steps: []outflowStep{
+				{balance: 1000},
+				{balance: 500},
+			},
+			alerts: 1,
+		},
+		{
+			name:  "incoming funds",
+			pause: true,
+			steps: []outflowStep{
+				{balance: 1000},
+				{balance: 5000},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLimiter(t, GlobalLimitCfg{}, nil)
+			alerter := &recordingAlerter{}
+			m := NewOutflowMonitor("eth", OutflowCfg{Pause: tt.pause}, l, alerter)
+
+			for _, step := range tt.steps {
+				if step.sent != 0 {
+					m.Expect(big.NewInt(step.sent), big.NewInt(step.fee))
+					continue
+				}
+				m.Observe(FaucetState{
+					Address:  "faucet",
+					Balance:  big.NewInt(step.balance),
+					Nonce:    step.nonce,
+					HasNonce: step.hasNonce,
+				})
+			}
+
+			if len(alerter.alerts) != tt.alerts {
+				t.Fatalf("got alerts %v, want %d", alerter.alerts, tt.alerts)
+			}
+			if paused := l.Paused(); paused != tt.paused {
+				t.Fatalf("paused = %v, want %v", paused, tt.paused)
+			}
+			if _, err := l.Reserve("eth", "0xto", big.NewInt(1)); tt.paused != errors.Is(err, ErrPaused) {
+				t.Fatalf("got reserve err %v with paused = %v", err, tt.paused)
+			}
+		})
+	}
+}
+
+// TestOutflowResume checks that funding resumes once the operator unpauses it, and that the drop
+// that paused it is not reported again.
+func TestOutflowResume(t *testing.T) {
+	l := newTestLimiter(t, GlobalLimitCfg{}, nil)
+	alerter := &recordingAlerter{}
+	m := NewOutflowMonitor("eth", OutflowCfg{Pause: true}, l, alerter)
+
+	m.Observe(FaucetState{Address: "faucet", Balance: big.NewInt(1000)})
+	m.Observe(FaucetState{Address: "faucet", Balance: big.NewInt(500)})
+	if !l.Paused() {
+		t.Fatal("an unexpected drop did not pause the funding")
+	}
+	if err := l.Unpause(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := l.Reserve("eth", "0xto", big.NewInt(100)); err != nil {
+		t.Fatalf("cannot fund after the pause is lifted, err = %s", err)
+	}
+	m.Expect(big.NewInt(100), big.NewInt(10))
+	m.Observe(FaucetState{Address: "faucet", Balance: big.NewInt(395)})
+	m.Observe(FaucetState{Address: "faucet", Balance: big.NewInt(395)})
+
+	if l.Paused() {
+		t.Fatal("funding is paused again after the resume")
+	}
+	if len(alerter.alerts) != 1 {
+		t.Fatalf("got alerts %v, want 1", alerter.alerts)
+	}
+}
+
+func TestOutflowExpectTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		// age is how long ago the transfer was sent when the faucet balance drops.
+		age    time.Duration
+		paused bool
+	}{
+		{
+			name: "spend within the default timeout explains the drop",
+			age:  time.Minute * 30,
+		},
+		{
+			name:   "spend older than the default timeout does not explain the drop",
+			age:    time.Hour * 2,
+			paused: true,
+		},
+		{
+			name:    "spend within the timeout of the chain explains the drop",
+			timeout: time.Hour * 24,
+			age:     time.Hour * 2,
+		},
+		{
+			name:    "spend older than the timeout of the chain does not explain the drop",
+			timeout: time.Hour * 24,
+			age:     time.Hour * 25,
+			paused:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLimiter(t, GlobalLimitCfg{}, nil)
+			m := NewOutflowMonitor("btc", OutflowCfg{Pause: true, ExpectTimeout: tt.timeout}, l, &recordingAlerter{})
+
+			m.Observe(FaucetState{Address: "faucet", Balance: big.NewInt(1000)})
+			m.Expect(big.NewInt(100), big.NewInt(10))
+			m.spends[0].time = time.Now().Add(-tt.age)
+			m.Observe(FaucetState{Address: "faucet", Balance: big.NewInt(890)})
+
+			if paused := l.Paused(); paused != tt.paused {
+				t.Fatalf("paused = %v, want %v", paused, tt.paused)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package funding
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	// ModeFixed sends the same amount on every top-up.
+	ModeFixed = "fixed"
+	// ModeTarget sends whatever is needed to bring the balance back to the target.
+	ModeTarget = "target"
+)
+
+// PolicyCfg decides when and how much a chain is funded. Amounts are decimal strings in the
+// smallest unit of the chain. Empty fields fall back to the defaults of the chain family.
+type PolicyCfg struct {
+	Mode      string `toml:"mode" json:"mode"`
+	Threshold string `toml:"threshold" json:"threshold"`
+	Amount    string `toml:"amount" json:"amount"`
+	Target    string `toml:"target" json:"target"`
+
+	// The computed amount in target mode is rounded up to a multiple of Rounding and clamped to
+	// [MinTransfer, MaxTransfer].
+	Rounding    string `toml:"rounding" json:"rounding"`
+	MinTransfer string `toml:"min_transfer" json:"min_transfer"`
+	MaxTransfer string `toml:"max_transfer" json:"max_transfer"`
+
+	// When Horizon is set the account is also funded if its projected time-to-empty, estimated
+	// from the balance history of the last BurnWindow, falls below Horizon.
+	Horizon    time.Duration `toml:"horizon" json:"horizon"`
+	BurnWindow time.Duration `toml:"burn_window" json:"burn_window"`
+}
+
+type Policy struct {
+	mode        string
+	threshold   *big.Int
+	amount      *big.Int
+	target      *big.Int
+	rounding    *big.Int
+	minTransfer *big.Int
+	maxTransfer *big.Int
+	horizon     time.Duration
+	burnWindow  time.Duration
+}
+
+func NewPolicy(cfg PolicyCfg, defaults PolicyCfg) (*Policy, error) {
+	cfg = cfg.WithDefaults(defaults)
+
+	p := &Policy{
+		mode:       cfg.Mode,
+		horizon:    cfg.Horizon,
+		burnWindow: cfg.BurnWindow,
+	}
+	if p.mode == "" {
+		p.mode = ModeFixed
+	}
+
+	var err error
+	if p.threshold, err = parseAmount(cfg.Threshold); err != nil {
+		return nil, err
+	}
+	if p.amount, err = parseAmount(cfg.Amount); err != nil {
+		return nil, err
+	}
+	if p.target, err = parseAmount(cfg.Target); err != nil {
+		return nil, err
+	}
+	if p.rounding, err = parseAmount(cfg.Rounding); err != nil {
+		return nil, err
+	}
+	if p.minTransfer, err = parseAmount(cfg.MinTransfer); err != nil {
+		return nil, err
+	}
+	if p.maxTransfer, err = parseAmount(cfg.MaxTransfer); err != nil {
+		return nil, err
+	}
+
+	if p.threshold == nil {
+		return nil, fmt.Errorf("funding threshold is not set")
+	}
+
+	switch p.mode {
+	case ModeFixed:
+		if p.amount == nil || p.amount.Sign() == 0 {
+			return nil, fmt.Errorf("funding amount is not set")
+		}
+		if p.target != nil {
+			return nil, fmt.Errorf("funding target is set in %s mode, set mode = %q or remove it", ModeFixed, ModeTarget)
+		}
+
+	case ModeTarget:
+		if p.target == nil {
+			return nil, fmt.Errorf("funding target is not set")
+		}
+		if p.target.Cmp(p.threshold) < 0 {
+			return nil, fmt.Errorf("funding target %s is below the threshold %s", p.target, p.threshold)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown funding mode %s", p.mode)
+	}
+
+	if p.minTransfer != nil && p.maxTransfer != nil && p.minTransfer.Cmp(p.maxTransfer) > 0 {
+		return nil, fmt.Errorf("min_transfer %s is above max_transfer %s", p.minTransfer, p.maxTransfer)
+	}
+
+	return p, nil
+}
+
+// WithDefaults returns a copy of cfg where the empty fields are taken from defaults. A target
+// without a mode selects the target mode, and a config that sets the fixed mode does not take the
+// target of defaults.
+func (cfg PolicyCfg) WithDefaults(defaults PolicyCfg) PolicyCfg {
+	fixed := cfg.Mode == ModeFixed
+	if cfg.Mode == "" && cfg.Target != "" {
+		cfg.Mode = ModeTarget
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = defaults.Mode
+	}
+	if cfg.Threshold == "" {
+		cfg.Threshold = defaults.Threshold
+	}
+	if cfg.Amount == "" {
+		cfg.Amount = defaults.Amount
+	}
+	if cfg.Target == "" && !fixed {
+		cfg.Target = defaults.Target
+	}
+	if cfg.Rounding == "" {
+		cfg.Rounding = defaults.Rounding
+	}
+	if cfg.MinTransfer == "" {
+		cfg.MinTransfer = defaults.MinTransfer
+	}
+	if cfg.MaxTransfer == "" {
+		cfg.MaxTransfer = defaults.MaxTransfer
+	}
+	if cfg.Horizon == 0 {
+		cfg.Horizon = defaults.Horizon
+	}
+	if cfg.BurnWindow == 0 {
+		cfg.BurnWindow = defaults.BurnWindow
+	}
+
+	return cfg
+}
+
+func (p *Policy) Threshold() *big.Int {
+	return new(big.Int).Set(p.threshold)
+}
+
+func (p *Policy) BurnWindow() time.Duration {
+	return p.burnWindow
+}
+
+// NeedsFunding returns true if the balance is below the funding threshold.
+func (p *Policy) NeedsFunding(balance *big.Int) bool {
+	return balance.Cmp(p.threshold) < 0
+}
+
+// ShouldFund decides whether an account needs a top-up, either because its balance is below the
+// threshold or because it is projected to run out within the horizon. The returned string
+// explains the decision.
+func (p *Policy) ShouldFund(balance *big.Int, burn *BurnTracker) (bool, string) {
+	if p.NeedsFunding(balance) {
+		return true, fmt.Sprintf("balance %s is below threshold %s", balance, p.threshold)
+	}
+
+	if p.horizon > 0 && burn != nil {
+		if timeToEmpty, ok := burn.TimeToEmpty(balance); ok && timeToEmpty < p.horizon {
+			return true, fmt.Sprintf("projected time to empty %s is within horizon %s",
+				timeToEmpty.Round(time.Minute), p.horizon)
+		}
+	}
+
+	return false, fmt.Sprintf("balance %s is above threshold %s", balance, p.threshold)
+}
+
+// Amount returns how much should be sent to an account with the given balance. In target mode it
+// returns zero if the balance already reached the target.
+func (p *Policy) Amount(balance *big.Int) *big.Int {
+	if p.mode == ModeFixed {
+		return new(big.Int).Set(p.amount)
+	}
+
+	amount := new(big.Int).Sub(p.target, balance)
+	if amount.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+
+	if p.rounding != nil && p.rounding.Sign() > 0 {
+		mod := new(big.Int).Mod(amount, p.rounding)
+		if mod.Sign() > 0 {
+			amount.Add(amount, new(big.Int).Sub(p.rounding, mod))
+		}
+	}
+
+	if p.minTransfer != nil && amount.Cmp(p.minTransfer) < 0 {
+		amount.Set(p.minTransfer)
+	}
+	if p.maxTransfer != nil && amount.Cmp(p.maxTransfer) > 0 {
+		amount.Set(p.maxTransfer)
+	}
+
+	return amount
+}
@@ -0,0 +1,90 @@
+package funding
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestNewPolicyMode(t *testing.T) {
+	defaults := PolicyCfg{Mode: ModeFixed, Threshold: "100", Amount: "50"}
+
+	tests := []struct {
+		name     string
+		cfg      PolicyCfg
+		defaults PolicyCfg
+		mode     string
+		err      bool
+	}{
+		{name: "defaults", cfg: PolicyCfg{}, defaults: defaults, mode: ModeFixed},
+		{name: "target without mode", cfg: PolicyCfg{Target: "300"}, defaults: defaults, mode: ModeTarget},
+		{name: "explicit target mode", cfg: PolicyCfg{Mode: ModeTarget, Target: "300"}, defaults: defaults, mode: ModeTarget},
+		{name: "target in fixed mode", cfg: PolicyCfg{Mode: ModeFixed, Target: "300"}, defaults: defaults, err: true},
+		{name: "target mode without target", cfg: PolicyCfg{Mode: ModeTarget}, defaults: defaults, err: true},
+		{name: "target below threshold", cfg: PolicyCfg{Target: "50"}, defaults: defaults, err: true},
+		{
+			name:     "inherited target",
+			cfg:      PolicyCfg{},
+			defaults: PolicyCfg{Target: "300"}.WithDefaults(defaults),
+			mode:     ModeTarget,
+		},
+		{
+			name:     "fixed mode does not inherit the target",
+			cfg:      PolicyCfg{Mode: ModeFixed, Amount: "10"},
+			defaults: PolicyCfg{Target: "300"}.WithDefaults(defaults),
+			mode:     ModeFixed,
+		},
+		{
+			name:     "inherited target in fixed mode",
+			cfg:      PolicyCfg{},
+			defaults: PolicyCfg{Mode: ModeFixed, Target: "300"}.WithDefaults(defaults),
+			err:      true,
+		},
+		{name: "unknown mode", cfg: PolicyCfg{Mode: "double"}, defaults: defaults, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPolicy(tt.cfg, tt.defaults)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got mode %s", p.mode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.mode != tt.mode {
+				t.Fatalf("got mode %s, want %s", p.mode, tt.mode)
+			}
+		})
+	}
+}
+
+func TestPolicyAmount(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     PolicyCfg
+		balance int64
+		amount  int64
+	}{
+		{name: "fixed", cfg: PolicyCfg{Threshold: "100", Amount: "50"}, balance: 10, amount: 50},
+		{name: "fill to target", cfg: PolicyCfg{Threshold: "100", Target: "300"}, balance: 10, amount: 290},
+		{name: "above target", cfg: PolicyCfg{Threshold: "100", Target: "300"}, balance: 400, amount: 0},
+		{name: "rounded up", cfg: PolicyCfg{Threshold: "100", Target: "300", Rounding: "100"}, balance: 10, amount: 300},
+		{name: "min transfer", cfg: PolicyCfg{Threshold: "100", Target: "300", MinTransfer: "50"}, balance: 290, amount: 50},
+		{name: "max transfer", cfg: PolicyCfg{Threshold: "100", Target: "300", MaxTransfer: "200"}, balance: 10, amount: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPolicy(tt.cfg, PolicyCfg{Mode: ModeFixed})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if amount := p.Amount(big.NewInt(tt.balance)); amount.Cmp(big.NewInt(tt.amount)) != 0 {
+				t.Fatalf("got amount %s, want %d", amount, tt.amount)
+			}
+		})
+	}
+}
@@ -0,0 +1,386 @@
+package funding
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/alert"
+	"github.com/sisu-network/sisu-account-funding/core/store"
+)
+
+var (
+	ErrOutsideWindow = errors.New("outside the funding windows of the chain")
+	ErrMaintenance   = errors.New("chain is in maintenance")
+)
+
+// ScheduleCfg restricts when a chain may be funded.
+type ScheduleCfg struct {
+	// Windows are cron expressions "minute hour day-of-month month day-of-week". Top-ups are only
+	// sent during the minutes matched by one of them, e.g. "* 9-16 * * MON-FRI" for business
+	// hours. The watchers check their chain every 30 minutes, so a window must match every minute
+	// of its hours. No window means that top-ups are always allowed.
+	Windows []string `toml:"windows" json:"windows"`
+	// Timezone is the IANA name of the timezone of the windows, UTC by default.
+	Timezone string `toml:"timezone" json:"timezone"`
+	// Maintenance are the planned maintenances of the chain, more can be added with the admin api.
+	Maintenance []Maintenance `toml:"maintenance" json:"maintenance"`
+}
+
+// Maintenance is a period during which a chain is monitored but not funded, e.g. its upgrade.
+type Maintenance struct {
+	// Id is set for the maintenances added with the admin api.
+	Id     string    `toml:"-" json:"id,omitempty"`
+	Chain  string    `toml:"-" json:"chain"`
+	Start  time.Time `toml:"start" json:"start"`
+	End    time.Time `toml:"end" json:"end"`
+	Reason string    `toml:"reason" json:"reason"`
+}
+
+func (m Maintenance) active(now time.Time) bool {
+	return !now.Before(m.Start) && now.Before(m.End)
+}
+
+func (cfg ScheduleCfg) Validate() error {
+	_, err := parseSchedule(cfg)
+	return err
+}
+
+type schedule struct {
+	windows     []*cronExpr
+	location    *time.Location
+	maintenance []Maintenance
+}
+
+func parseSchedule(cfg ScheduleCfg) (*schedule, error) {
+	s := &schedule{location: time.UTC, maintenance: append([]Maintenance{}, cfg.Maintenance...)}
+	if cfg.Timezone != "" {
+		location, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", cfg.Timezone, err)
+		}
+		s.location = location
+	}
+
+	for _, window := range cfg.Windows {
+		expr, err := parseCron(window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %w", window, err)
+		}
+		// A window of a few minutes would open and close between two checks of the watcher.
+		if expr.minute != allMinutes {
+			return nil, fmt.Errorf("invalid window %q: the minute field must be * as the chain is only checked every 30 minutes", window)
+		}
+		s.windows = append(s.windows, expr)
+	}
+
+	for _, m := range cfg.Maintenance {
+		if !m.End.After(m.Start) {
+			return nil, fmt.Errorf("maintenance %q ends before it starts", m.Reason)
+		}
+	}
+
+	return s, nil
+}
+
+// Scheduler allows the top-ups of each chain only within its funding windows and outside its
+// maintenances. Maintenances added with the admin api are persisted so that they survive restarts.
+type Scheduler struct {
+	lock      sync.Mutex
+	filePath  string
+	schedules map[string]*schedule
+	// added are the maintenances added with the admin api.
+	added   []Maintenance
+	alerter alert.Alerter
+	// alerted has the maintenances for which a deferred top-up was already alerted.
+	alerted map[string]bool
+}
+
+func NewScheduler(filePath string, chains map[string]ScheduleCfg, alerter alert.Alerter) (*Scheduler, error) {
+	s := &Scheduler{
+		filePath: filePath,
+		added:    make([]Maintenance, 0),
+		alerter:  alerter,
+		alerted:  make(map[string]bool),
+	}
+	if err := s.SetSchedules(chains); err != nil {
+		return nil, err
+	}
+
+	if err := store.Load(filePath, &s.added); err != nil {
+		return nil, fmt.Errorf("failed to load maintenances from %s: %w", filePath, err)
+	}
+
+	return s, nil
+}
+
+// SetSchedules replaces the schedules of all chains. They are left unchanged if one is invalid.
+func (s *Scheduler) SetSchedules(chains map[string]ScheduleCfg) error {
+	schedules := make(map[string]*schedule)
+	for chain, cfg := range chains {
+		schedule, err := parseSchedule(cfg)
+		if err != nil {
+			return fmt.Errorf("invalid schedule for chain %s: %w", chain, err)
+		}
+		for i := range schedule.maintenance {
+			schedule.maintenance[i].Chain = chain
+		}
+		schedules[chain] = schedule
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.schedules = schedules
+
+	return nil
+}
+
+// Allow returns an error if chain cannot be funded now. A top-up deferred by a maintenance is
+// alerted once per maintenance, so that someone can fund the chain by hand if it cannot wait.
+func (s *Scheduler) Allow(chain string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	for _, m := range s.maintenances(now) {
+		if m.Chain != chain || !m.active(now) {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s/%s", chain, m.Start, m.End)
+		if !s.alerted[key] {
+			s.alerted[key] = true
+			s.alerter.Alert(chain, fmt.Sprintf("top-up deferred by the maintenance until %s: %s",
+				m.End.Format(time.RFC3339), m.Reason))
+		}
+		return fmt.Errorf("%w until %s: %s", ErrMaintenance, m.End.Format(time.RFC3339), m.Reason)
+	}
+
+	schedule := s.schedules[chain]
+	if schedule == nil || len(schedule.windows) == 0 {
+		return nil
+	}
+	local := now.In(schedule.location)
+	for _, window := range schedule.windows {
+		if window.matches(local) {
+			return nil
+		}
+	}
+
+	return ErrOutsideWindow
+}
+
+// Maintenances returns the maintenances of all chains that are not over, sorted by start.
+func (s *Scheduler) Maintenances() []Maintenance {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.maintenances(time.Now())
+}
+
+func (s *Scheduler) maintenances(now time.Time) []Maintenance {
+	all := make([]Maintenance, 0)
+	for _, schedule := range s.schedules {
+		all = append(all, schedule.maintenance...)
+	}
+	all = append(all, s.added...)
+
+	current := make([]Maintenance, 0, len(all))
+	for _, m := range all {
+		if now.Before(m.End) {
+			current = append(current, m)
+		}
+	}
+	sort.Slice(current, func(i, j int) bool {
+		return current[i].Start.Before(current[j].Start)
+	})
+
+	return current
+}
+
+// AddMaintenance adds a maintenance of a chain and returns it with its id.
+func (s *Scheduler) AddMaintenance(m Maintenance) (Maintenance, error) {
+	if m.Chain == "" {
+		return m, fmt.Errorf("the chain of the maintenance is missing")
+	}
+	if !m.End.After(m.Start) {
+		return m, fmt.Errorf("maintenance ends before it starts")
+	}
+	if !m.End.After(time.Now()) {
+		return m, fmt.Errorf("maintenance is already over")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	m.Id = strconv.FormatInt(time.Now().UnixNano(), 36)
+	s.prune(time.Now())
+	s.added = append(s.added, m)
+	s.save()
+	log.Infof("Maintenance %s added on chain %s from %s to %s: %s", m.Id, m.Chain, m.Start.Format(time.RFC3339),
+		m.End.Format(time.RFC3339), m.Reason)
+
+	return m, nil
+}
+
+// RemoveMaintenance removes a maintenance added with the admin api. It returns false if there is
+// no such maintenance.
+func (s *Scheduler) RemoveMaintenance(id string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i, m := range s.added {
+		if m.Id == id {
+			s.added = append(s.added[:i], s.added[i+1:]...)
+			s.save()
+			log.Infof("Maintenance %s removed on chain %s", id, m.Chain)
+			return true
+		}
+	}
+
+	return false
+}
+
+// prune drops the maintenances that are over. The caller must hold the lock.
+func (s *Scheduler) prune(now time.Time) {
+	kept := make([]Maintenance, 0, len(s.added))
+	for _, m := range s.added {
+		if now.Before(m.End) {
+			kept = append(kept, m)
+		}
+	}
+	s.added = kept
+}
+
+func (s *Scheduler) save() {
+	if err := store.Save(s.filePath, s.added); err != nil {
+		log.Errorf("Failed to save maintenances to %s, err = %s", s.filePath, err)
+	}
+}
+
+// cronExpr is a parsed cron expression. Each field is a bit set of the values it matches.
+type cronExpr struct {
+	minute, hour, dom, month, dow uint64
+	// As in cron, when both the day of month and the day of week are restricted, a day matching
+	// either one matches.
+	domStar, dowStar bool
+}
+
+// allMinutes is the minute field of an expression that matches every minute.
+const allMinutes = uint64(1)<<60 - 1
+
+var (
+	monthNames = map[string]int{"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6, "JUL": 7,
+		"AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12}
+	dayNames = map[string]int{"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6}
+)
+
+func parseCron(s string) (*cronExpr, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	expr := &cronExpr{
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+	var err error
+	if expr.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("minute: %w", err)
+	}
+	if expr.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("hour: %w", err)
+	}
+	if expr.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("day of month: %w", err)
+	}
+	if expr.month, err = parseCronField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("month: %w", err)
+	}
+	// 7 is Sunday too.
+	if expr.dow, err = parseCronField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, fmt.Errorf("day of week: %w", err)
+	}
+	if expr.dow&(1<<7) != 0 {
+		expr.dow |= 1
+	}
+
+	return expr, nil
+}
+
+// parseCronField parses a comma separated list of "*", values and ranges, each with an optional
+// "/step".
+func parseCronField(s string, min, max int, names map[string]int) (uint64, error) {
+	value := func(v string) (int, error) {
+		if n, ok := names[strings.ToUpper(v)]; ok {
+			return n, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("invalid value %q, expected %d-%d", v, min, max)
+		}
+		return n, nil
+	}
+
+	bits := uint64(0)
+	for _, part := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if from, err = value(bounds[0]); err != nil {
+				return 0, err
+			}
+			to = from
+			if step > 1 {
+				to = max
+			}
+			if len(bounds) == 2 {
+				if to, err = value(bounds[1]); err != nil {
+					return 0, err
+				}
+			}
+			if to < from {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (e *cronExpr) matches(t time.Time) bool {
+	if e.minute&(1<<uint(t.Minute())) == 0 || e.hour&(1<<uint(t.Hour())) == 0 ||
+		e.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dom := e.dom&(1<<uint(t.Day())) != 0
+	dow := e.dow&(1<<uint(t.Weekday())) != 0
+	if e.domStar || e.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
@@ -0,0 +1,141 @@
+package funding
+
+import (
+	"testing"
+	"time"
+)
+
+// bits returns the bit set of a cron field matching values.
+func bits(values ...int) uint64 {
+	b := uint64(0)
+	for _, v := range values {
+		b |= 1 << uint(v)
+	}
+
+	return b
+}
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    string
+		min, max int
+		names    map[string]int
+		want     uint64
+		wantErr  bool
+	}{
+		{name: "any", field: "*", min: 0, max: 6, want: bits(0, 1, 2, 3, 4, 5, 6)},
+		{name: "value", field: "5", min: 0, max: 59, want: bits(5)},
+		{name: "list", field: "1,15,30", min: 1, max: 31, want: bits(1, 15, 30)},
+		{name: "range", field: "9-16", min: 0, max: 23, want: bits(9, 10, 11, 12, 13, 14, 15, 16)},
+		{name: "step", field: "*/15", min: 0, max: 59, want: bits(0, 15, 30, 45)},
+		{name: "step from a value", field: "5/20", min: 0, max: 59, want: bits(5, 25, 45)},
+		{name: "range with a step", field: "1-10/3", min: 1, max: 31, want: bits(1, 4, 7, 10)},
+		{name: "list of ranges", field: "0-2,22-23", min: 0, max: 23, want: bits(0, 1, 2, 22, 23)},
+		{name: "month names", field: "JAN,jun-Aug", min: 1, max: 12, names: monthNames, want: bits(1, 6, 7, 8)},
+		{name: "day names", field: "MON-FRI", min: 0, max: 7, names: dayNames, want: bits(1, 2, 3, 4, 5)},
+		{name: "Sunday as 7", field: "7", min: 0, max: 7, names: dayNames, want: bits(7)},
+		{name: "below the minimum", field: "0", min: 1, max: 31, wantErr: true},
+		{name: "above the maximum", field: "24", min: 0, max: 23, wantErr: true},
+		{name: "reversed range", field: "16-9", min: 0, max: 23, wantErr: true},
+		{name: "zero step", field: "*/0", min: 0, max: 59, wantErr: true},
+		{name: "unknown name", field: "MON", min: 1, max: 12, names: monthNames, wantErr: true},
+		{name: "empty", field: "", min: 0, max: 59, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCronField(tt.field, tt.min, tt.max, tt.names)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %b, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %b, want %b", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 2024-06-02 is a Sunday.
+	sunday := time.Date(2024, 6, 2, 10, 30, 0, 0, time.UTC)
+	monday := sunday.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name string
+		expr string
+		time time.Time
+		want bool
+	}{
+		{name: "every minute", expr: "* * * * *", time: sunday, want: true},
+		{name: "within the hours", expr: "* 9-16 * * *", time: sunday, want: true},
+		{name: "outside the hours", expr: "* 9-16 * * *", time: sunday.Add(time.Hour * 7), want: false},
+		{name: "weekday names on a weekday", expr: "* * * * MON-FRI", time: monday, want: true},
+		{name: "weekday names on a Sunday", expr: "* * * * MON-FRI", time: sunday, want: false},
+		{name: "Sunday as 0", expr: "* * * * 0", time: sunday, want: true},
+		{name: "Sunday as 7", expr: "* * * * 7", time: sunday, want: true},
+		{name: "Sunday in a range to 7", expr: "* * * * 5-7", time: sunday, want: true},
+		{name: "month name", expr: "* * * JUN *", time: sunday, want: true},
+		{name: "other month", expr: "* * * JUL *", time: sunday, want: false},
+		{name: "minute step", expr: "*/15 * * * *", time: sunday, want: true},
+		{name: "minute step missed", expr: "*/20 * * * *", time: sunday, want: false},
+		{name: "day of month only", expr: "* * 2 * *", time: sunday, want: true},
+		{name: "day of month with any weekday", expr: "* * 3 * *", time: sunday, want: false},
+		// When both day fields are restricted, either one matches.
+		{name: "both days, day of month matches", expr: "* * 2 * MON", time: sunday, want: true},
+		{name: "both days, day of week matches", expr: "* * 15 * SUN", time: sunday, want: true},
+		{name: "both days, neither matches", expr: "* * 15 * MON", time: sunday, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := expr.matches(tt.time); got != tt.want {
+				t.Fatalf("%q matches %s = %v, want %v", tt.expr, tt.time, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ScheduleCfg
+		wantErr bool
+	}{
+		{name: "no window", cfg: ScheduleCfg{}},
+		{name: "business hours", cfg: ScheduleCfg{Windows: []string{"* 9-16 * * MON-FRI"}, Timezone: "Europe/Paris"}},
+		{name: "whole minute range", cfg: ScheduleCfg{Windows: []string{"0-59 2 * * *"}}},
+		// The watchers check every 30 minutes and could miss these windows.
+		{name: "single minute", cfg: ScheduleCfg{Windows: []string{"0 9 * * *"}}, wantErr: true},
+		{name: "quarter hour", cfg: ScheduleCfg{Windows: []string{"0-14 * * * *"}}, wantErr: true},
+		{name: "one of the windows too narrow", cfg: ScheduleCfg{Windows: []string{"* 9 * * *", "*/5 10 * * *"}}, wantErr: true},
+		{name: "too few fields", cfg: ScheduleCfg{Windows: []string{"* 9-16 * *"}}, wantErr: true},
+		{name: "unknown timezone", cfg: ScheduleCfg{Timezone: "Mars/Olympus"}, wantErr: true},
+		{
+			name: "maintenance ending before it starts",
+			cfg: ScheduleCfg{Maintenance: []Maintenance{{
+				Start: time.Date(2024, 6, 2, 10, 0, 0, 0, time.UTC),
+				End:   time.Date(2024, 6, 2, 9, 0, 0, 0, time.UTC),
+			}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, want an error = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
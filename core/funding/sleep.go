@@ -0,0 +1,20 @@
+package funding
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep waits for d or until ctx is done, so that a stopped watcher does not sleep through its
+// next cycle. It returns false if ctx is done.
+func Sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
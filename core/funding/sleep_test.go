@@ -0,0 +1,39 @@
+package funding
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSleep(t *testing.T) {
+	tests := []struct {
+		name string
+		d    time.Duration
+		// cancelAfter cancels the context after it, never if negative.
+		cancelAfter time.Duration
+		want        bool
+	}{
+		{name: "elapsed", d: time.Millisecond, cancelAfter: -1, want: true},
+		{name: "cancelled before", d: time.Hour, cancelAfter: 0},
+		{name: "cancelled while sleeping", d: time.Hour, cancelAfter: 10 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelAfter >= 0 {
+				time.AfterFunc(tt.cancelAfter, cancel)
+			}
+
+			start := time.Now()
+			if got := Sleep(ctx, tt.d); got != tt.want {
+				t.Fatalf("got %t, want %t", got, tt.want)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Fatalf("slept %s", elapsed)
+			}
+		})
+	}
+}
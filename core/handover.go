@@ -0,0 +1,109 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isStateFile returns true for the files of the data dir that a leader hands over to the next
+// one: the limiter, nonces, pending transfers and burn readings, and the ledger of transfers.
+func isStateFile(name string) bool {
+	return strings.HasSuffix(name, ".json") || name == ledgerFile
+}
+
+// stateFiles returns the names of the state files of dataDir, except the exclude ones.
+func stateFiles(dataDir string, exclude ...string) ([]string, error) {
+	excluded := make(map[string]bool)
+	for _, path := range exclude {
+		if abs, err := filepath.Abs(path); err == nil {
+			excluded[abs] = true
+		}
+	}
+
+	entries, err := os.ReadDir(dataDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isStateFile(entry.Name()) {
+			continue
+		}
+		if abs, err := filepath.Abs(filepath.Join(dataDir, entry.Name())); err == nil && excluded[abs] {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+
+	return names, nil
+}
+
+// snapshotState returns the state files of dataDir, except the exclude ones, as a JSON object of
+// file name to content. JSON sorts the names, so the same files always give the same snapshot.
+func snapshotState(dataDir string, exclude ...string) ([]byte, error) {
+	names, err := stateFiles(dataDir, exclude...)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+	for _, name := range names {
+		bz, err := os.ReadFile(filepath.Join(dataDir, name))
+		if err != nil {
+			return nil, err
+		}
+		files[name] = bz
+	}
+
+	return json.Marshal(files)
+}
+
+// restoreState replaces the state files of dataDir with the ones of a snapshot. Local state files
+// that are not in the snapshot are stale and removed. A nil snapshot, when no leader ever handed
+// over, leaves the local files untouched.
+func restoreState(dataDir string, snapshot []byte, exclude ...string) error {
+	if snapshot == nil {
+		return nil
+	}
+
+	files := make(map[string][]byte)
+	if err := json.Unmarshal(snapshot, &files); err != nil {
+		return fmt.Errorf("invalid handover state: %w", err)
+	}
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return err
+	}
+
+	for name, bz := range files {
+		if filepath.Base(name) != name || !isStateFile(name) {
+			return fmt.Errorf("invalid file %q in handover state", name)
+		}
+
+		path := filepath.Join(dataDir, name)
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, bz, 0600); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+	}
+
+	local, err := stateFiles(dataDir, exclude...)
+	if err != nil {
+		return err
+	}
+	for _, name := range local {
+		if _, ok := files[name]; !ok {
+			if err := os.Remove(filepath.Join(dataDir, name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
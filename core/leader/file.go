@@ -0,0 +1,105 @@
+package leader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sisu-network/sisu-account-funding/core/store"
+)
+
+type fileLease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+	State   []byte    `json:"state,omitempty"`
+}
+
+// FileBackend keeps the lease in a JSON file on a shared filesystem. Every access holds an
+// exclusive flock on a lock file next to it, so that two instances never read and write the lease
+// at the same time.
+type FileBackend struct {
+	path string
+}
+
+func NewFileBackend(path string) *FileBackend {
+	return &FileBackend{path: path}
+}
+
+// update loads the lease, lets fn change it and saves it if fn returns true.
+func (b *FileBackend) update(fn func(lease *fileLease) bool) error {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
+		return err
+	}
+	unlock, err := lockFile(b.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("cannot lock %s: %w", b.path, err)
+	}
+	defer unlock()
+
+	lease := &fileLease{}
+	if err := store.Load(b.path, lease); err != nil {
+		return err
+	}
+	if !fn(lease) {
+		return nil
+	}
+
+	return store.Save(b.path, lease)
+}
+
+func (b *FileBackend) TryAcquire(holder string, ttl time.Duration) (bool, error) {
+	acquired := false
+	err := b.update(func(lease *fileLease) bool {
+		now := time.Now()
+		if lease.Holder != holder && now.Before(lease.Expires) {
+			return false
+		}
+
+		lease.Holder = holder
+		lease.Expires = now.Add(ttl)
+		acquired = true
+		return true
+	})
+
+	return acquired && err == nil, err
+}
+
+func (b *FileBackend) Release(holder string) error {
+	return b.update(func(lease *fileLease) bool {
+		if lease.Holder != holder {
+			return false
+		}
+
+		lease.Expires = time.Time{}
+		return true
+	})
+}
+
+func (b *FileBackend) SaveState(holder string, state []byte) error {
+	saved := false
+	err := b.update(func(lease *fileLease) bool {
+		if lease.Holder != holder || !time.Now().Before(lease.Expires) {
+			return false
+		}
+
+		lease.State = state
+		saved = true
+		return true
+	})
+	if err == nil && !saved {
+		return ErrNotLeader
+	}
+
+	return err
+}
+
+func (b *FileBackend) LoadState() ([]byte, error) {
+	var state []byte
+	err := b.update(func(lease *fileLease) bool {
+		state = lease.State
+		return false
+	})
+
+	return state, err
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package leader
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive flock on path, waiting for other holders, and returns the function
+// that releases it.
+func lockFile(path string) (func(), error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
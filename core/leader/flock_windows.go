@@ -0,0 +1,7 @@
+package leader
+
+import "fmt"
+
+func lockFile(path string) (func(), error) {
+	return nil, fmt.Errorf("the file backend is not supported on windows, use the sql backend")
+}
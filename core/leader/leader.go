@@ -0,0 +1,266 @@
+package leader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sisu-network/lib/log"
+	"go.uber.org/atomic"
+)
+
+const (
+	BackendFile = "file"
+	BackendSql  = "sql"
+)
+
+var ErrNotLeader = errors.New("this instance is a standby, another funder is the leader")
+
+// Cfg configures the election of the funder that sends transactions when several instances run
+// for redundancy. An empty backend means that a single instance runs and it is always the leader.
+type Cfg struct {
+	Backend string `toml:"backend" json:"backend"`
+	// Id identifies this instance in the lease, the default is the hostname and the process id.
+	Id string `toml:"id" json:"id"`
+	// Ttl is how long the lease of the leader lasts without being renewed. It is renewed every
+	// third of the ttl.
+	Ttl time.Duration `toml:"ttl" json:"ttl"`
+
+	// Path is the lease file of the file backend. It must be on a filesystem shared by all the
+	// instances that supports flock.
+	Path string `toml:"path" json:"path"`
+
+	// Driver and Dsn open the database of the sql backend, Name is the row of the lease so that
+	// several groups of funders can share a database.
+	Driver string `toml:"driver" json:"driver"`
+	Dsn    string `toml:"dsn" json:"dsn"`
+	Name   string `toml:"name" json:"name"`
+}
+
+func (cfg Cfg) Enabled() bool {
+	return cfg.Backend != ""
+}
+
+func (cfg Cfg) WithDefaults() Cfg {
+	if cfg.Id == "" {
+		hostname, _ := os.Hostname()
+		cfg.Id = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if cfg.Ttl == 0 {
+		cfg.Ttl = time.Second * 30
+	}
+	if cfg.Driver == "" {
+		cfg.Driver = "mysql"
+	}
+	if cfg.Name == "" {
+		cfg.Name = "funder"
+	}
+
+	return cfg
+}
+
+// Backend stores the lease of the leader and the state it hands over to the next leader. All the
+// instances must use the same backend.
+type Backend interface {
+	// TryAcquire takes the lease for holder for ttl, or extends it if holder already has it. It
+	// returns false if another holder has a lease that is not expired.
+	TryAcquire(holder string, ttl time.Duration) (bool, error)
+	// Release gives up the lease if holder has it, so that a standby takes over right away.
+	Release(holder string) error
+	// SaveState stores the handover state, only if holder has the lease.
+	SaveState(holder string, state []byte) error
+	// LoadState returns the last handover state saved, or nil if none was.
+	LoadState() ([]byte, error)
+}
+
+func NewBackend(cfg Cfg) (Backend, error) {
+	switch cfg.Backend {
+	case BackendFile:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("the file backend needs a lease path")
+		}
+		return NewFileBackend(cfg.Path), nil
+
+	case BackendSql:
+		if cfg.Dsn == "" {
+			return nil, fmt.Errorf("the sql backend needs a dsn")
+		}
+		return NewSqlBackend(cfg.Driver, cfg.Dsn, cfg.Name)
+
+	default:
+		return nil, fmt.Errorf("unknown leader backend %q, expected %s or %s", cfg.Backend, BackendFile, BackendSql)
+	}
+}
+
+// Elector campaigns for the lease in the background. The instance that holds it is the leader and
+// is the only one allowed to send transactions, the others are standbys that keep monitoring.
+type Elector struct {
+	lock    sync.Mutex
+	backend Backend
+	id      string
+	ttl     time.Duration
+
+	// deadline is when the lease of this instance may expire if it is not renewed. It is measured
+	// from before the renewal so that the instance steps down before any standby can take over.
+	deadline time.Time
+	leader   bool
+	stop     atomic.Bool
+
+	publishLock sync.Mutex
+	// published is the last handover state saved to the backend.
+	published []byte
+
+	// snapshot returns the handover state of the leader, it is saved on every renewal.
+	snapshot func() ([]byte, error)
+	// onElected is called with the state handed over by the previous leader when this instance
+	// becomes the leader, Allow already returns nil. It steps down if onElected fails. onDemoted is
+	// called when it loses the lease.
+	onElected func(state []byte) error
+	onDemoted func()
+}
+
+func NewElector(backend Backend, id string, ttl time.Duration, snapshot func() ([]byte, error),
+	onElected func(state []byte) error, onDemoted func()) *Elector {
+	return &Elector{
+		backend:   backend,
+		id:        id,
+		ttl:       ttl,
+		snapshot:  snapshot,
+		onElected: onElected,
+		onDemoted: onDemoted,
+	}
+}
+
+func (e *Elector) Start() {
+	log.Infof("Starting leader election as %s, lease ttl = %s", e.id, e.ttl)
+	go e.loop()
+}
+
+// Stop stops campaigning and releases the lease if this instance holds it.
+func (e *Elector) Stop() {
+	e.stop.Store(true)
+
+	if !e.IsLeader() {
+		return
+	}
+	e.publish()
+	e.stepDown()
+}
+
+// Allow returns nil while this instance holds a lease that cannot have expired yet.
+func (e *Elector) Allow() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if !e.leader || !time.Now().Before(e.deadline) {
+		return ErrNotLeader
+	}
+
+	return nil
+}
+
+func (e *Elector) IsLeader() bool {
+	return e.Allow() == nil
+}
+
+func (e *Elector) loop() {
+	for {
+		if e.stop.Load() {
+			return
+		}
+
+		e.campaign()
+
+		time.Sleep(e.ttl / 3)
+	}
+}
+
+func (e *Elector) campaign() {
+	start := time.Now()
+	acquired, err := e.backend.TryAcquire(e.id, e.ttl)
+	if e.stop.Load() {
+		return
+	}
+
+	e.lock.Lock()
+	wasLeader := e.leader
+	if err != nil {
+		log.Errorf("Failed to renew the leader lease, err = %s", err)
+		// Keep leading until the lease may have expired, a standby cannot take over before.
+		acquired = wasLeader && time.Now().Before(e.deadline)
+	} else if acquired {
+		e.deadline = start.Add(e.ttl)
+	}
+	// A new leader only leads once it has loaded the state of the previous one.
+	e.leader = acquired && wasLeader
+	e.lock.Unlock()
+
+	switch {
+	case acquired && !wasLeader:
+		state, err := e.backend.LoadState()
+		if err != nil {
+			// Without the state of the previous leader, in-flight transactions could be sent again.
+			log.Errorf("Failed to load the handover state, stepping down, err = %s", err)
+			e.stepDown()
+			return
+		}
+
+		// onElected starts the watchers of the leader, they must be allowed to send from their first
+		// cycle. The state is not published until they run on the state handed over.
+		e.publishLock.Lock()
+		e.published = state
+		e.lock.Lock()
+		e.leader = true
+		e.lock.Unlock()
+		err = e.onElected(state)
+		e.publishLock.Unlock()
+		if err != nil {
+			log.Errorf("Failed to take over as the leader, stepping down, err = %s", err)
+			e.stepDown()
+			return
+		}
+		log.Infof("%s is now the leader", e.id)
+
+	case !acquired && wasLeader:
+		log.Warnf("%s lost the leader lease and is now a standby", e.id)
+		e.onDemoted()
+
+	case acquired:
+		e.publish()
+	}
+}
+
+func (e *Elector) stepDown() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.leader = false
+	e.deadline = time.Time{}
+	if err := e.backend.Release(e.id); err != nil {
+		log.Errorf("Failed to release the leader lease, err = %s", err)
+	}
+}
+
+// publish saves the handover state if it changed since the last time.
+func (e *Elector) publish() {
+	e.publishLock.Lock()
+	defer e.publishLock.Unlock()
+
+	state, err := e.snapshot()
+	if err != nil {
+		log.Errorf("Failed to snapshot the handover state, err = %s", err)
+		return
+	}
+	if bytes.Equal(state, e.published) {
+		return
+	}
+
+	if err := e.backend.SaveState(e.id, state); err != nil {
+		log.Errorf("Failed to save the handover state, err = %s", err)
+		return
+	}
+	e.published = state
+}
@@ -0,0 +1,282 @@
+package leader
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testBackends are the backends run by TestBackendLease and TestBackendHandover.
+var testBackends = []struct {
+	name string
+	new  func(t *testing.T) Backend
+}{
+	{
+		name: "file",
+		new: func(t *testing.T) Backend {
+			return NewFileBackend(filepath.Join(t.TempDir(), "lease.json"))
+		},
+	},
+}
+
+const testTtl = time.Millisecond * 100
+
+// leaseStep runs op for holder: acquire, release, save or load. wait sleeps past the ttl of the
+// leases taken so far.
+type leaseStep struct {
+	op     string
+	holder string
+	state  string
+	// want is the expected result of acquire, or the state expected by load.
+	want    bool
+	wantErr error
+}
+
+func runSteps(t *testing.T, backend Backend, steps []leaseStep) {
+	t.Helper()
+
+	for i, step := range steps {
+		switch step.op {
+		case "acquire":
+			acquired, err := backend.TryAcquire(step.holder, testTtl)
+			if err != nil {
+				t.Fatalf("step %d: %s", i, err)
+			}
+			if acquired != step.want {
+				t.Fatalf("step %d: %s acquired = %v, want %v", i, step.holder, acquired, step.want)
+			}
+		case "release":
+			if err := backend.Release(step.holder); err != nil {
+				t.Fatalf("step %d: %s", i, err)
+			}
+		case "wait":
+			time.Sleep(testTtl + time.Millisecond*20)
+		case "save":
+			if err := backend.SaveState(step.holder, []byte(step.state)); !errors.Is(err, step.wantErr) {
+				t.Fatalf("step %d: %s saving got err %v, want %v", i, step.holder, err, step.wantErr)
+			}
+		case "load":
+			state, err := backend.LoadState()
+			if err != nil {
+				t.Fatalf("step %d: %s", i, err)
+			}
+			if string(state) != step.state {
+				t.Fatalf("step %d: got state %q, want %q", i, state, step.state)
+			}
+		}
+	}
+}
+
+func TestBackendLease(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []leaseStep
+	}{
+		{
+			name:  "free lease",
+			steps: []leaseStep{{op: "acquire", holder: "a", want: true}},
+		},
+		{
+			name: "renewal",
+			steps: []leaseStep{
+				{op: "acquire", holder: "a", want: true},
+				{op: "acquire", holder: "a", want: true},
+				{op: "acquire", holder: "b"},
+			},
+		},
+		{
+			name: "held by another",
+			steps: []leaseStep{
+				{op: "acquire", holder: "a", want: true},
+				{op: "acquire", holder: "b"},
+				{op: "acquire", holder: "a", want: true},
+			},
+		},
+		{
+			name: "expired",
+			steps: []leaseStep{
+				{op: "acquire", holder: "a", want: true},
+				{op: "wait"},
+				{op: "acquire", holder: "b", want: true},
+				{op: "acquire", holder: "a"},
+			},
+		},
+		{
+			name: "renewed after the expiry",
+			steps: []leaseStep{
+				{op: "acquire", holder: "a", want: true},
+				{op: "wait"},
+				{op: "acquire", holder: "a", want: true},
+				{op: "acquire", holder: "b"},
+			},
+		},
+		{
+			name: "released",
+			steps: []leaseStep{
+				{op: "acquire", holder: "a", want: true},
+				{op: "release", holder: "a"},
+				{op: "acquire", holder: "b", want: true},
+			},
+		},
+		{
+			name: "released by another",
+			steps: []leaseStep{
+				{op: "acquire", holder: "a", want: true},
+				{op: "release", holder: "b"},
+				{op: "acquire", holder: "b"},
+			},
+		},
+	}
+
+	for _, backend := range testBackends {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.name, func(t *testing.T) {
+				runSteps(t, backend.new(t), tt.steps)
+			})
+		}
+	}
+}
+
+func TestBackendHandover(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []leaseStep
+	}{
+		{
+			name:  "no state yet",
+			steps: []leaseStep{{op: "load"}},
+		},
+		{
+			name: "state of the leader",
+			steps: []leaseStep{
+				{op: "acquire", holder: "a", want: true},
+				{op: "save", holder: "a", state: "s1"},
+				{op: "save", holder: "b", state: "other", wantErr: ErrNotLeader},
+				{op: "load", state: "s1"},
+			},
+		},
+		{
+			name: "handed over after the expiry",
+			steps: []leaseStep{
+				{op: "acquire", holder: "a", want: true},
+				{op: "save", holder: "a", state: "s1"},
+				{op: "wait"},
+				{op: "save", holder: "a", state: "late", wantErr: ErrNotLeader},
+				{op: "acquire", holder: "b", want: true},
+				{op: "load", state: "s1"},
+				{op: "save", holder: "b", state: "s2"},
+				{op: "load", state: "s2"},
+			},
+		},
+		{
+			name: "handed over after a release",
+			steps: []leaseStep{
+				{op: "acquire", holder: "a", want: true},
+				{op: "save", holder: "a", state: "s1"},
+				{op: "release", holder: "a"},
+				{op: "save", holder: "a", state: "late", wantErr: ErrNotLeader},
+				{op: "acquire", holder: "b", want: true},
+				{op: "load", state: "s1"},
+			},
+		},
+	}
+
+	for _, backend := range testBackends {
+		for _, tt := range tests {
+			t.Run(backend.name+"/"+tt.name, func(t *testing.T) {
+				runSteps(t, backend.new(t), tt.steps)
+			})
+		}
+	}
+}
+
+func TestElectorCampaign(t *testing.T) {
+	tests := []struct {
+		name string
+		// other holds the lease when the elector campaigns.
+		other       bool
+		electionErr error
+		leader      bool
+	}{
+		{name: "elected", leader: true},
+		{name: "lease held by another", other: true},
+		{name: "takeover failed", electionErr: errors.New("cannot restore the state")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := NewFileBackend(filepath.Join(t.TempDir(), "lease.json"))
+			runSteps(t, backend, []leaseStep{
+				{op: "acquire", holder: "previous", want: true},
+				{op: "save", holder: "previous", state: "handover"},
+				{op: "release", holder: "previous"},
+			})
+			if tt.other {
+				runSteps(t, backend, []leaseStep{{op: "acquire", holder: "other", want: true}})
+			}
+
+			elected := 0
+			var e *Elector
+			e = NewElector(backend, "a", time.Minute, func() ([]byte, error) {
+				return []byte("handover"), nil
+			}, func(state []byte) error {
+				elected++
+				// The watchers started by onElected must be allowed to send from their first cycle.
+				if err := e.Allow(); err != nil {
+					t.Errorf("not allowed to send during the takeover, err = %s", err)
+				}
+				if !bytes.Equal(state, []byte("handover")) {
+					t.Errorf("got state %q, want the state of the previous leader", state)
+				}
+				return tt.electionErr
+			}, func() {
+				t.Error("demoted without being the leader")
+			})
+
+			e.campaign()
+
+			if leader := e.IsLeader(); leader != tt.leader {
+				t.Fatalf("leader = %v, want %v", leader, tt.leader)
+			}
+			if wantElected := !tt.other; (elected == 1) != wantElected {
+				t.Fatalf("onElected called %d times", elected)
+			}
+			if tt.electionErr != nil {
+				// The lease is released so that a standby takes over right away.
+				runSteps(t, backend, []leaseStep{{op: "acquire", holder: "other", want: true}})
+			}
+		})
+	}
+}
+
+func TestElectorLosesLease(t *testing.T) {
+	backend := NewFileBackend(filepath.Join(t.TempDir(), "lease.json"))
+	demoted := 0
+	e := NewElector(backend, "a", testTtl, func() ([]byte, error) {
+		return []byte("state"), nil
+	}, func(state []byte) error {
+		return nil
+	}, func() {
+		demoted++
+	})
+
+	e.campaign()
+	if !e.IsLeader() {
+		t.Fatal("not elected")
+	}
+
+	// The leader stops being allowed to send when its lease may have expired, before a standby
+	// can take over.
+	runSteps(t, backend, []leaseStep{{op: "wait"}})
+	if err := e.Allow(); !errors.Is(err, ErrNotLeader) {
+		t.Fatalf("got %v after the expiry, want ErrNotLeader", err)
+	}
+
+	runSteps(t, backend, []leaseStep{{op: "acquire", holder: "b", want: true}})
+	e.campaign()
+	if e.IsLeader() || demoted != 1 {
+		t.Fatalf("leader = %v and demoted %d times after losing the lease", e.IsLeader(), demoted)
+	}
+}
@@ -0,0 +1,92 @@
+package leader
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// SqlBackend keeps the lease in a row of a shared database. Expiry times are set from the clock of
+// the instances, so their clocks must be synchronized to well under the lease ttl. The queries are
+// plain SQL with ? placeholders, the mysql driver is built in.
+type SqlBackend struct {
+	db   *sql.DB
+	name string
+}
+
+func NewSqlBackend(driver, dsn, name string) (*SqlBackend, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS funder_leases (
+		name VARCHAR(64) NOT NULL PRIMARY KEY,
+		holder VARCHAR(255) NOT NULL,
+		expires_at BIGINT NOT NULL,
+		state MEDIUMBLOB
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("cannot create the lease table: %w", err)
+	}
+
+	return &SqlBackend{db: db, name: name}, nil
+}
+
+func (b *SqlBackend) TryAcquire(holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	res, err := b.db.Exec("UPDATE funder_leases SET holder = ?, expires_at = ? WHERE name = ? AND (holder = ? OR expires_at <= ?)",
+		holder, now.Add(ttl).UnixMilli(), b.name, holder, now.UnixMilli())
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		return true, nil
+	}
+
+	// Nothing was updated, either the lease row does not exist yet, another holder has it or the
+	// update did not change anything because this holder renewed it within the same millisecond.
+	current, expiresAt := "", int64(0)
+	err = b.db.QueryRow("SELECT holder, expires_at FROM funder_leases WHERE name = ?", b.name).Scan(&current, &expiresAt)
+	if err == sql.ErrNoRows {
+		_, err = b.db.Exec("INSERT INTO funder_leases (name, holder, expires_at) VALUES (?, ?, ?)",
+			b.name, holder, now.Add(ttl).UnixMilli())
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return current == holder && expiresAt > now.UnixMilli(), nil
+}
+
+func (b *SqlBackend) Release(holder string) error {
+	_, err := b.db.Exec("UPDATE funder_leases SET expires_at = 0 WHERE name = ? AND holder = ?", b.name, holder)
+	return err
+}
+
+func (b *SqlBackend) SaveState(holder string, state []byte) error {
+	res, err := b.db.Exec("UPDATE funder_leases SET state = ? WHERE name = ? AND holder = ? AND expires_at > ?",
+		state, b.name, holder, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotLeader
+	}
+
+	return nil
+}
+
+func (b *SqlBackend) LoadState() ([]byte, error) {
+	var state []byte
+	err := b.db.QueryRow("SELECT state FROM funder_leases WHERE name = ?", b.name).Scan(&state)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return state, err
+}
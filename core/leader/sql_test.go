@@ -0,0 +1,29 @@
+//go:build cgo
+
+package leader
+
+import (
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// The sql backend runs against sqlite, whose driver needs cgo.
+func init() {
+	testBackends = append(testBackends, struct {
+		name string
+		new  func(t *testing.T) Backend
+	}{
+		name: "sql",
+		new: func(t *testing.T) Backend {
+			backend, err := NewSqlBackend("sqlite3", filepath.Join(t.TempDir(), "lease.db"), "funder")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { backend.db.Close() })
+
+			return backend
+		},
+	})
+}
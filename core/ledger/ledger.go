@@ -0,0 +1,94 @@
+package ledger
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry is a transfer sent by the funder.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Chain  string    `json:"chain"`
+	TxHash string    `json:"tx_hash"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	// Amount is a decimal string in the smallest unit of the chain.
+	Amount string `json:"amount"`
+	// Label tells what the transfer was for, e.g. the funded target or "stake".
+	Label string `json:"label,omitempty"`
+}
+
+// Ledger is the append-only record of the transfers sent by the funder, one JSON entry per line.
+type Ledger struct {
+	lock     sync.Mutex
+	filePath string
+}
+
+func NewLedger(filePath string) *Ledger {
+	return &Ledger{filePath: filePath}
+}
+
+// Record appends an entry and syncs it to disk, so that a transfer is never missing from the
+// ledger after a crash.
+func (l *Ledger) Record(entry Entry) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	bz, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.filePath), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(bz, '\n')); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// Entries returns the entries of chain recorded in [from, to).
+func (l *Ledger) Entries(chain string, from, to time.Time) ([]Entry, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	entries := make([]Entry, 0)
+	file, err := os.Open(l.filePath)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+
+		if entry.Chain == chain && !entry.Time.Before(from) && entry.Time.Before(to) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, scanner.Err()
+}
@@ -0,0 +1,77 @@
+package lisk
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	// VersionV5 talks to Lisk Service v2 in front of a Lisk SDK v5 node.
+	VersionV5 = "v5"
+	// VersionV6 talks to the JSON-RPC endpoint of a Lisk SDK v6 node.
+	VersionV6 = "v6"
+)
+
+var (
+	// ErrAccountNotFound is returned when an account has not been initialized on chain yet.
+	ErrAccountNotFound = errors.New("account not found")
+	// ErrNetworkMismatch is returned when the node serves another network than the configured one.
+	ErrNetworkMismatch = errors.New("network mismatch")
+)
+
+// transfer is a token transfer in a version independent form.
+type transfer struct {
+	Nonce           uint64
+	Fee             uint64
+	Amount          uint64
+	Recipient       []byte
+	Data            string
+	SenderPublicKey []byte
+	Signatures      [][]byte
+}
+
+type feeParams struct {
+	MinFeePerByte uint64
+	// Estimates per byte for the low, medium and high priorities. They are zero when the network
+	// does not provide estimates.
+	Low    uint64
+	Medium uint64
+	High   uint64
+	// BaseFee is the extra fee of a transfer on top of the size based fee.
+	BaseFee uint64
+}
+
+// Backend is the part of the Lisk watcher that depends on the version of the Lisk network. It lets
+// the watcher run against a Lisk node or an in-memory fake in tests.
+type Backend interface {
+	// GetBalance returns the spendable balance of an account or ErrAccountNotFound.
+	GetBalance(address string) (*big.Int, error)
+	GetNonce(address string) (uint64, error)
+	GetFeeParams() (*feeParams, error)
+	// GetInitializationFee returns the extra fee to pay when the recipient account does not exist.
+	GetInitializationFee() (uint64, error)
+	// EncodeTransfer returns the binary encoding of a transfer including its signatures.
+	EncodeTransfer(t *transfer) ([]byte, error)
+	// SigningBytes returns the bytes that the sender signs for a transfer.
+	SigningBytes(t *transfer) ([]byte, error)
+	// Submit sends a signed transaction and returns its id.
+	Submit(signed []byte) (string, error)
+	// GetConfirmations returns the number of blocks that include or build on the block of the
+	// transaction, or 0 if the transaction is not included yet.
+	GetConfirmations(hash string) (uint64, error)
+	// CheckNetwork checks that the node serves the configured network and returns
+	// ErrNetworkMismatch if it does not.
+	CheckNetwork() error
+}
+
+func newBackend(chain string, url string, cfg Cfg) (Backend, error) {
+	switch cfg.Version {
+	case VersionV5:
+		return newV5Backend(chain, url, cfg)
+	case VersionV6:
+		return newV6Backend(chain, url, cfg)
+	default:
+		return nil, fmt.Errorf("unknown lisk version %s", cfg.Version)
+	}
+}
@@ -0,0 +1,319 @@
+package lisk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	liskcrypto "github.com/sisu-network/deyes/chains/lisk/crypto"
+	lisktypes "github.com/sisu-network/deyes/chains/lisk/types"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	v5TokenModuleId  = uint32(2)
+	v5TransferAsset  = uint32(0)
+	v5TransferFeeKey = "2:0"
+)
+
+// v5Backend talks to Lisk Service v2, the REST service of the Lisk SDK v5 networks. Every error
+// response of the service is returned as a ServiceError.
+type v5Backend struct {
+	chain     string
+	url       string
+	cfg       Cfg
+	networkId string
+}
+
+func newV5Backend(chain string, url string, cfg Cfg) (*v5Backend, error) {
+	networkId, err := cfg.networkId(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v5Backend{
+		chain:     chain,
+		url:       url,
+		cfg:       cfg,
+		networkId: networkId,
+	}, nil
+}
+
+func (b *v5Backend) get(endpoint string, params map[string]string) ([]byte, error) {
+	keys := reflect.ValueOf(params).MapKeys()
+	req, err := http.NewRequest("GET", b.url+endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	q := req.URL.Query()
+	for _, key := range keys {
+		q.Add(key.Interface().(string), params[key.Interface().(string)])
+	}
+
+	req.URL.RawQuery = q.Encode()
+	response, err := http.Get(req.URL.String())
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch data %s: %w", endpoint, err)
+	}
+	defer response.Body.Close()
+
+	return readResponse(response)
+}
+
+func (b *v5Backend) post(endpoint string, body interface{}) ([]byte, error) {
+	bz, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := http.Post(b.url+endpoint, "application/json", bytes.NewBuffer(bz))
+	if err != nil {
+		return nil, fmt.Errorf("cannot post data %s: %w", endpoint, err)
+	}
+	defer response.Body.Close()
+
+	return readResponse(response)
+}
+
+func readResponse(response *http.Response) ([]byte, error) {
+	responseData, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, newServiceError(response.StatusCode, responseData)
+	}
+
+	return responseData, nil
+}
+
+// getAccount returns the account of address or ErrAccountNotFound.
+func (b *v5Backend) getAccount(address string) (*GetAccountsData, error) {
+	bz, err := b.get("/accounts", map[string]string{
+		"address": address,
+		"limit":   "10",
+		"offset":  "0",
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := &GetAccountsResponse{}
+	if err := json.Unmarshal(bz, res); err != nil {
+		return nil, err
+	}
+
+	// Some versions of Lisk Service report errors in the body with a 200 status.
+	if res.Error {
+		err := &ServiceError{StatusCode: http.StatusOK, Message: res.Message}
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrAccountNotFound
+		}
+		return nil, err
+	}
+
+	if len(res.Data) == 0 {
+		return nil, ErrAccountNotFound
+	}
+
+	return &res.Data[0], nil
+}
+
+// CheckNetwork compares the network id with the one reported by Lisk Service. A service that does
+// not report it is trusted.
+func (b *v5Backend) CheckNetwork() error {
+	bz, err := b.get("/network/status", map[string]string{})
+	if err != nil {
+		return err
+	}
+
+	res := &struct {
+		Data struct {
+			NetworkIdentifier string `json:"networkIdentifier"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(bz, res); err != nil {
+		return err
+	}
+	if res.Data.NetworkIdentifier != "" && !strings.EqualFold(res.Data.NetworkIdentifier, b.networkId) {
+		return fmt.Errorf("%w: network id %s does not match the service network id %s", ErrNetworkMismatch,
+			b.networkId, res.Data.NetworkIdentifier)
+	}
+
+	return nil
+}
+
+func (b *v5Backend) GetBalance(address string) (*big.Int, error) {
+	account, err := b.getAccount(address)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, ok := new(big.Int).SetString(account.Summary.Balance, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid balance %q", account.Summary.Balance)
+	}
+
+	return balance, nil
+}
+
+func (b *v5Backend) GetNonce(address string) (uint64, error) {
+	account, err := b.getAccount(address)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(account.Sequence.Nonce, 10, 64)
+}
+
+func (b *v5Backend) GetFeeParams() (*feeParams, error) {
+	bz, err := b.get("/fees", map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &GetFeesResponse{}
+	if err := json.Unmarshal(bz, res); err != nil {
+		return nil, err
+	}
+
+	params := &feeParams{
+		MinFeePerByte: res.Data.MinFeePerByte,
+		Low:           res.Data.FeeEstimatePerByte.Low,
+		Medium:        res.Data.FeeEstimatePerByte.Medium,
+		High:          res.Data.FeeEstimatePerByte.High,
+	}
+	if fee, ok := res.Data.BaseFeeById[v5TransferFeeKey]; ok {
+		if params.BaseFee, err = strconv.ParseUint(fee, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
+	return params, nil
+}
+
+// GetInitializationFee returns 0, v5 networks do not charge for creating an account.
+func (b *v5Backend) GetInitializationFee() (uint64, error) {
+	return 0, nil
+}
+
+func (b *v5Backend) EncodeTransfer(t *transfer) ([]byte, error) {
+	moduleId := v5TokenModuleId
+	assetId := v5TransferAsset
+	amount := t.Amount
+	data := t.Data
+	nonce := t.Nonce
+	fee := t.Fee
+
+	asset, err := proto.Marshal(&lisktypes.AssetMessage{
+		Amount:           &amount,
+		RecipientAddress: t.Recipient,
+		Data:             &data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(&lisktypes.TransactionMessage{
+		ModuleID:        &moduleId,
+		AssetID:         &assetId,
+		Fee:             &fee,
+		Asset:           asset,
+		Nonce:           &nonce,
+		SenderPublicKey: t.SenderPublicKey,
+		Signatures:      t.Signatures,
+	})
+}
+
+func (b *v5Backend) SigningBytes(t *transfer) ([]byte, error) {
+	unsigned := *t
+	unsigned.Signatures = nil
+	bz, err := b.EncodeTransfer(&unsigned)
+	if err != nil {
+		return nil, err
+	}
+
+	return liskcrypto.GetSigningBytes(b.networkId, bz)
+}
+
+func (b *v5Backend) Submit(signed []byte) (string, error) {
+	bz, err := b.post("/transactions", map[string]string{
+		"transaction": hex.EncodeToString(signed),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	res := &lisktypes.TransactionResponse{}
+	if err := json.Unmarshal(bz, res); err != nil {
+		return "", err
+	}
+	if res.TransactionId == "" {
+		return "", &ServiceError{StatusCode: http.StatusOK, Message: res.Message}
+	}
+
+	return res.TransactionId, nil
+}
+
+func (b *v5Backend) blockNumber() (uint64, error) {
+	bz, err := b.get("/blocks", map[string]string{
+		"limit": "1",
+		"sort":  "height:desc",
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	res := &lisktypes.ResponseBlock{}
+	if err := json.Unmarshal(bz, res); err != nil {
+		return 0, err
+	}
+	if len(res.Data) == 0 {
+		return 0, &ServiceError{StatusCode: http.StatusOK, Message: "no block"}
+	}
+
+	return res.Data[0].Height, nil
+}
+
+func (b *v5Backend) GetConfirmations(hash string) (uint64, error) {
+	bz, err := b.get("/transactions", map[string]string{
+		"transactionId": hash,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	res := &lisktypes.ResponseTransaction{}
+	if err := json.Unmarshal(bz, res); err != nil {
+		return 0, err
+	}
+
+	if len(res.Data) == 0 || res.Data[0].IsPending || res.Data[0].Block == nil {
+		return 0, nil
+	}
+
+	height, err := b.blockNumber()
+	if err != nil {
+		return 0, err
+	}
+
+	included := uint64(res.Data[0].Block.Height)
+	if height < included {
+		return 0, nil
+	}
+
+	return height - included + 1, nil
+}
@@ -0,0 +1,153 @@
+package lisk
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newV5StandIn returns a Lisk Service v2 that answers every request with status and body.
+func newV5StandIn(t *testing.T, status int, body string) *v5Backend {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	backend, err := newV5Backend("lisk-testnet", server.URL, Cfg{}.withDefaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return backend
+}
+
+func TestV5Errors(t *testing.T) {
+	calls := []struct {
+		name string
+		call func(b *v5Backend) error
+	}{
+		{name: "GetBalance", call: func(b *v5Backend) error {
+			_, err := b.GetBalance("lskabc")
+			return err
+		}},
+		{name: "GetNonce", call: func(b *v5Backend) error {
+			_, err := b.GetNonce("lskabc")
+			return err
+		}},
+		{name: "GetFeeParams", call: func(b *v5Backend) error {
+			_, err := b.GetFeeParams()
+			return err
+		}},
+		{name: "Submit", call: func(b *v5Backend) error {
+			_, err := b.Submit([]byte{1, 2, 3})
+			return err
+		}},
+		{name: "GetConfirmations", call: func(b *v5Backend) error {
+			_, err := b.GetConfirmations("abcd")
+			return err
+		}},
+	}
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		err    error
+	}{
+		{name: "bad request", status: http.StatusBadRequest, body: `{"error":true,"message":"Invalid transaction"}`, err: ErrBadRequest},
+		{name: "rate limited", status: http.StatusTooManyRequests, body: "slow down", err: ErrRateLimited},
+		{name: "unavailable", status: http.StatusServiceUnavailable, body: "", err: ErrUnavailable},
+		{name: "bad gateway", status: http.StatusBadGateway, body: "<html>", err: ErrUnavailable},
+		{name: "server error", status: http.StatusInternalServerError, body: `{"message":"boom"}`, err: ErrServerError},
+	}
+
+	for _, call := range calls {
+		for _, tt := range tests {
+			t.Run(call.name+"/"+tt.name, func(t *testing.T) {
+				err := call.call(newV5StandIn(t, tt.status, tt.body))
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got %v, want %v", err, tt.err)
+				}
+				serviceErr := &ServiceError{}
+				if !errors.As(err, &serviceErr) || serviceErr.StatusCode != tt.status {
+					t.Fatalf("got %v, want a service error with status %d", err, tt.status)
+				}
+			})
+		}
+	}
+}
+
+func TestV5AccountNotFound(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{name: "404", status: http.StatusNotFound, body: `{"error":true,"message":"Data not found"}`},
+		{name: "error in a 200", status: http.StatusOK, body: `{"error":true,"message":"Data not found"}`},
+		{name: "no data", status: http.StatusOK, body: `{"data":[]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newV5StandIn(t, tt.status, tt.body)
+			if _, err := backend.GetBalance("lskabc"); !errors.Is(err, ErrAccountNotFound) {
+				t.Fatalf("GetBalance: got %v, want ErrAccountNotFound", err)
+			}
+			if _, err := backend.GetNonce("lskabc"); !errors.Is(err, ErrAccountNotFound) {
+				t.Fatalf("GetNonce: got %v, want ErrAccountNotFound", err)
+			}
+		})
+	}
+}
+
+func TestV5Submit(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		id   string
+		err  error
+	}{
+		{name: "accepted", body: `{"message":"Transaction was successfully submitted","transactionId":"abcd"}`, id: "abcd"},
+		{name: "rejected in a 200", body: `{"message":"Transaction was rejected"}`, err: ErrUnknownError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := newV5StandIn(t, http.StatusOK, tt.body).Submit([]byte{1})
+			if id != tt.id || !errors.Is(err, tt.err) || (err != nil) != (tt.err != nil) {
+				t.Fatalf("got id %q, err %v, want id %q, err %v", id, err, tt.id, tt.err)
+			}
+		})
+	}
+}
+
+func TestV5CheckNetwork(t *testing.T) {
+	testnet := "15f0dacc1060e91818224a94286b13aa04279c640bd5d6f193182031d133df7c"
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		err    error
+	}{
+		{name: "same network", status: http.StatusOK, body: `{"data":{"networkIdentifier":"` + testnet + `"}}`},
+		{name: "other network", status: http.StatusOK, body: `{"data":{"networkIdentifier":"4c09e6a781fc4c7bdb936ee815de8f94190f8a7519becd9de2081832be309a99"}}`, err: ErrNetworkMismatch},
+		{name: "not reported", status: http.StatusOK, body: `{"data":{}}`},
+		{name: "unavailable", status: http.StatusServiceUnavailable, err: ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := newV5StandIn(t, tt.status, tt.body)
+			backend.networkId = testnet
+
+			err := backend.CheckNetwork()
+			if !errors.Is(err, tt.err) || (err != nil) != (tt.err != nil) {
+				t.Fatalf("got err %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
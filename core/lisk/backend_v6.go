@@ -0,0 +1,416 @@
+package lisk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	v6TokenModule     = "token"
+	v6TransferCommand = "transfer"
+	v6TxTag           = "LSK_TX_"
+
+	// v6BlocksPerCall is the number of blocks fetched at once when looking for the block of a
+	// transaction, v6MaxSearchDepth how far below the last block it is looked for by default.
+	v6BlocksPerCall  = uint64(100)
+	v6MaxSearchDepth = uint64(1000)
+)
+
+// v6Backend talks to the JSON-RPC endpoint of a Lisk SDK v6 node.
+type v6Backend struct {
+	chain   string
+	url     string
+	cfg     Cfg
+	tokenId []byte
+	client  *http.Client
+
+	lock    sync.Mutex
+	chainId []byte
+	// heights caches the height of the blocks including the transactions.
+	heights map[string]uint64
+}
+
+func newV6Backend(chain string, url string, cfg Cfg) (*v6Backend, error) {
+	tokenId, err := cfg.tokenId(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v6Backend{
+		chain:   chain,
+		url:     url,
+		cfg:     cfg,
+		tokenId: tokenId,
+		client:  &http.Client{Timeout: time.Second * 30},
+		heights: make(map[string]uint64),
+	}, nil
+}
+
+type rpcRequest struct {
+	JsonRpc string      `json:"jsonrpc"`
+	Id      int         `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+func (e *rpcError) Unwrap() error {
+	switch {
+	case strings.Contains(strings.ToLower(e.Message), "does not exist") ||
+		strings.Contains(strings.ToLower(e.Message), "not found"):
+		return ErrNotFound
+	case e.Code == -32600 || e.Code == -32601 || e.Code == -32602:
+		return ErrBadRequest
+	case e.Code == -32603:
+		return ErrServerError
+	default:
+		return ErrUnknownError
+	}
+}
+
+func (b *v6Backend) call(method string, params interface{}, result interface{}) error {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+
+	body, err := json.Marshal(&rpcRequest{JsonRpc: "2.0", Id: 1, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	res, err := b.client.Post(b.url, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	bz, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	rpcRes := &rpcResponse{}
+	if err := json.Unmarshal(bz, rpcRes); err != nil {
+		if res.StatusCode != http.StatusOK {
+			return newServiceError(res.StatusCode, bz)
+		}
+		return fmt.Errorf("invalid response for %s: %w", method, err)
+	}
+	if rpcRes.Error != nil {
+		return rpcRes.Error
+	}
+
+	return json.Unmarshal(rpcRes.Result, result)
+}
+
+func (b *v6Backend) GetBalance(address string) (*big.Int, error) {
+	exists := &struct {
+		Exists bool `json:"exists"`
+	}{}
+	err := b.call("token_hasUserAccount", map[string]string{
+		"address": address,
+		"tokenID": hex.EncodeToString(b.tokenId),
+	}, exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists.Exists {
+		return nil, ErrAccountNotFound
+	}
+
+	res := &struct {
+		AvailableBalance string `json:"availableBalance"`
+	}{}
+	err = b.call("token_getBalance", map[string]string{
+		"address": address,
+		"tokenID": hex.EncodeToString(b.tokenId),
+	}, res)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, ok := new(big.Int).SetString(res.AvailableBalance, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid balance %q", res.AvailableBalance)
+	}
+
+	return balance, nil
+}
+
+func (b *v6Backend) GetNonce(address string) (uint64, error) {
+	res := &struct {
+		Nonce string `json:"nonce"`
+	}{}
+	if err := b.call("auth_getAuthAccount", map[string]string{"address": address}, res); err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(res.Nonce, 10, 64)
+}
+
+// GetInitializationFee returns the extra fee paid by the sender of a transfer to an account that
+// does not hold the token yet.
+func (b *v6Backend) GetInitializationFee() (uint64, error) {
+	res := &struct {
+		UserAccount string `json:"userAccount"`
+	}{}
+	if err := b.call("token_getInitializationFees", nil, res); err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(res.UserAccount, 10, 64)
+}
+
+func (b *v6Backend) GetFeeParams() (*feeParams, error) {
+	res := &struct {
+		MinFeePerByte uint64 `json:"minFeePerByte"`
+	}{}
+	if err := b.call("fee_getMinFeePerByte", nil, res); err != nil {
+		return nil, err
+	}
+
+	return &feeParams{MinFeePerByte: res.MinFeePerByte}, nil
+}
+
+// EncodeTransfer encodes the transfer with lisk-codec, which is the protobuf wire format where every
+// field of the schema is always written.
+func (b *v6Backend) EncodeTransfer(t *transfer) ([]byte, error) {
+	params := protowire.AppendTag(nil, 1, protowire.BytesType)
+	params = protowire.AppendBytes(params, b.tokenId)
+	params = protowire.AppendTag(params, 2, protowire.VarintType)
+	params = protowire.AppendVarint(params, t.Amount)
+	params = protowire.AppendTag(params, 3, protowire.BytesType)
+	params = protowire.AppendBytes(params, t.Recipient)
+	params = protowire.AppendTag(params, 4, protowire.BytesType)
+	params = protowire.AppendString(params, t.Data)
+
+	tx := protowire.AppendTag(nil, 1, protowire.BytesType)
+	tx = protowire.AppendString(tx, v6TokenModule)
+	tx = protowire.AppendTag(tx, 2, protowire.BytesType)
+	tx = protowire.AppendString(tx, v6TransferCommand)
+	tx = protowire.AppendTag(tx, 3, protowire.VarintType)
+	tx = protowire.AppendVarint(tx, t.Nonce)
+	tx = protowire.AppendTag(tx, 4, protowire.VarintType)
+	tx = protowire.AppendVarint(tx, t.Fee)
+	tx = protowire.AppendTag(tx, 5, protowire.BytesType)
+	tx = protowire.AppendBytes(tx, t.SenderPublicKey)
+	tx = protowire.AppendTag(tx, 6, protowire.BytesType)
+	tx = protowire.AppendBytes(tx, params)
+	for _, signature := range t.Signatures {
+		tx = protowire.AppendTag(tx, 7, protowire.BytesType)
+		tx = protowire.AppendBytes(tx, signature)
+	}
+
+	return tx, nil
+}
+
+func (b *v6Backend) CheckNetwork() error {
+	_, err := b.getChainId()
+	return err
+}
+
+// getChainId returns the chain id of the network. The first call checks the configured chain id
+// and fee token against the node, signing for the wrong network would only be rejected later.
+func (b *v6Backend) getChainId() ([]byte, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.chainId != nil {
+		return b.chainId, nil
+	}
+
+	res := &struct {
+		ChainId string `json:"chainID"`
+	}{}
+	if err := b.call("system_getNodeInfo", nil, res); err != nil {
+		return nil, err
+	}
+	if b.cfg.ChainId != "" && !strings.EqualFold(b.cfg.ChainId, res.ChainId) {
+		return nil, fmt.Errorf("%w: configured chain id %s does not match the node chain id %s",
+			ErrNetworkMismatch, b.cfg.ChainId, res.ChainId)
+	}
+
+	if b.cfg.FeeTokenId != "" {
+		feeToken := &struct {
+			TokenId string `json:"tokenID"`
+		}{}
+		if err := b.call("fee_getFeeTokenID", nil, feeToken); err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(b.cfg.FeeTokenId, feeToken.TokenId) {
+			return nil, fmt.Errorf("%w: configured fee token %s does not match the node fee token %s",
+				ErrNetworkMismatch, b.cfg.FeeTokenId, feeToken.TokenId)
+		}
+	}
+
+	chainId, err := decodeId(res.ChainId, 4)
+	if err != nil {
+		return nil, err
+	}
+	b.chainId = chainId
+
+	return chainId, nil
+}
+
+// SigningBytes returns the hash of the tagged transaction, Lisk v6 signs the hash instead of the
+// transaction itself.
+func (b *v6Backend) SigningBytes(t *transfer) ([]byte, error) {
+	chainId, err := b.getChainId()
+	if err != nil {
+		return nil, err
+	}
+
+	unsigned := *t
+	unsigned.Signatures = nil
+	bz, err := b.EncodeTransfer(&unsigned)
+	if err != nil {
+		return nil, err
+	}
+
+	tagged := append([]byte(v6TxTag), chainId...)
+	hash := sha256.Sum256(append(tagged, bz...))
+
+	return hash[:], nil
+}
+
+func (b *v6Backend) Submit(signed []byte) (string, error) {
+	res := &struct {
+		TransactionId string `json:"transactionId"`
+	}{}
+	err := b.call("txpool_postTransaction", map[string]string{
+		"transaction": hex.EncodeToString(signed),
+	}, res)
+	if err != nil {
+		return "", err
+	}
+
+	return res.TransactionId, nil
+}
+
+func (b *v6Backend) GetConfirmations(hash string) (uint64, error) {
+	tx := &struct {
+		Id string `json:"id"`
+	}{}
+	if err := b.call("chain_getTransactionByID", map[string]string{"id": hash}, tx); err != nil {
+		// The transaction is not found while it is not in a block.
+		if errors.Is(err, ErrNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	last := &v6Block{}
+	if err := b.call("chain_getLastBlock", nil, last); err != nil {
+		return 0, err
+	}
+	height := last.Header.Height
+
+	included, found, err := b.findBlock(hash, height)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		// The transaction is deeper than the search, it has at least that many confirmations.
+		return b.searchDepth(), nil
+	}
+	if height < included {
+		return 0, nil
+	}
+
+	confirmations := height - included + 1
+	if confirmations >= b.cfg.Confirmations {
+		b.lock.Lock()
+		delete(b.heights, hash)
+		b.lock.Unlock()
+	}
+
+	return confirmations, nil
+}
+
+type v6Block struct {
+	Header struct {
+		Height uint64 `json:"height"`
+	} `json:"header"`
+	Transactions []struct {
+		Id string `json:"id"`
+	} `json:"transactions"`
+}
+
+// searchDepth returns how many blocks below the last one a transaction is looked for, enough to
+// see it reach the configured confirmations.
+func (b *v6Backend) searchDepth() uint64 {
+	if b.cfg.Confirmations > v6MaxSearchDepth {
+		return b.cfg.Confirmations
+	}
+
+	return v6MaxSearchDepth
+}
+
+// findBlock returns the height of the block including the transaction hash. The node does not
+// index transactions by block, so the blocks are searched down from height, up to searchDepth
+// blocks deep.
+func (b *v6Backend) findBlock(hash string, height uint64) (uint64, bool, error) {
+	b.lock.Lock()
+	included, ok := b.heights[hash]
+	b.lock.Unlock()
+	if ok {
+		return included, true, nil
+	}
+
+	for to := height; to+b.searchDepth() > height; {
+		from := uint64(0)
+		if to >= v6BlocksPerCall {
+			from = to - v6BlocksPerCall + 1
+		}
+
+		blocks := make([]*v6Block, 0)
+		err := b.call("chain_getBlocksByHeightBetween", map[string]uint64{"from": from, "to": to}, &blocks)
+		if err != nil {
+			return 0, false, err
+		}
+		for _, block := range blocks {
+			for _, tx := range block.Transactions {
+				if tx.Id == hash {
+					b.lock.Lock()
+					b.heights[hash] = block.Header.Height
+					b.lock.Unlock()
+
+					return block.Header.Height, true, nil
+				}
+			}
+		}
+
+		if from == 0 {
+			break
+		}
+		to = from - 1
+	}
+
+	return 0, false, nil
+}
@@ -0,0 +1,237 @@
+package lisk
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	liskcrypto "github.com/sisu-network/deyes/chains/lisk/crypto"
+)
+
+// v6StandIn is a Lisk v6 node JSON-RPC endpoint serving the methods used by the v6 backend.
+type v6StandIn struct {
+	lock       sync.Mutex
+	chainId    string
+	feeTokenId string
+	// blocks are the ids of the transactions of each block, by height from 0.
+	blocks [][]string
+	calls  map[string]int
+}
+
+func newV6StandIn(t *testing.T) (*v6StandIn, string) {
+	s := &v6StandIn{
+		chainId:    "04000000",
+		feeTokenId: "0400000000000000",
+		blocks:     [][]string{{}},
+		calls:      make(map[string]int),
+	}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+
+	return s, server.URL
+}
+
+// addBlocks adds n blocks, the first including txs.
+func (s *v6StandIn) addBlocks(n int, txs ...string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i := 0; i < n; i++ {
+		s.blocks = append(s.blocks, txs)
+		txs = []string{}
+	}
+}
+
+func (s *v6StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.calls[req.Method]++
+
+	var result interface{}
+	var rpcErr *rpcError
+	switch req.Method {
+	case "system_getNodeInfo":
+		result = map[string]string{"chainID": s.chainId}
+
+	case "fee_getFeeTokenID":
+		result = map[string]string{"tokenID": s.feeTokenId}
+
+	case "chain_getTransactionByID":
+		params := &struct {
+			Id string `json:"id"`
+		}{}
+		json.Unmarshal(req.Params, params)
+		rpcErr = &rpcError{Code: -32603, Message: "Transaction with id " + params.Id + " does not exist"}
+		for _, txs := range s.blocks {
+			for _, tx := range txs {
+				if tx == params.Id {
+					result, rpcErr = map[string]string{"id": tx}, nil
+				}
+			}
+		}
+
+	case "chain_getLastBlock":
+		result = s.block(uint64(len(s.blocks) - 1))
+
+	case "chain_getBlocksByHeightBetween":
+		params := &struct {
+			From uint64 `json:"from"`
+			To   uint64 `json:"to"`
+		}{}
+		json.Unmarshal(req.Params, params)
+		blocks := make([]interface{}, 0)
+		for height := params.From; height <= params.To && height < uint64(len(s.blocks)); height++ {
+			blocks = append(blocks, s.block(height))
+		}
+		result = blocks
+
+	default:
+		rpcErr = &rpcError{Code: -32601, Message: "method not found"}
+	}
+
+	res := map[string]interface{}{"jsonrpc": "2.0", "id": 1}
+	if rpcErr != nil {
+		res["error"] = rpcErr
+	} else {
+		res["result"] = result
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// block returns the JSON of the block at height. The caller must hold the lock.
+func (s *v6StandIn) block(height uint64) interface{} {
+	txs := make([]map[string]string, 0)
+	for _, tx := range s.blocks[height] {
+		txs = append(txs, map[string]string{"id": tx})
+	}
+
+	return map[string]interface{}{
+		"header":       map[string]uint64{"height": height},
+		"transactions": txs,
+	}
+}
+
+func TestV6GetConfirmations(t *testing.T) {
+	tests := []struct {
+		name string
+		// before and after are the number of blocks before and after the block of the transaction.
+		before        int
+		after         int
+		included      bool
+		confirmations uint64
+		cfg           Cfg
+	}{
+		{name: "not included", before: 10},
+		{name: "in the last block", before: 10, included: true, confirmations: 1},
+		{name: "buried", before: 10, after: 5, included: true, confirmations: 6},
+		{name: "deeper than a call", before: 10, after: 250, included: true, confirmations: 251},
+		{name: "deeper than the search", before: 10, after: 1200, included: true, confirmations: v6MaxSearchDepth},
+		{
+			name:          "search reaches the configured confirmations",
+			before:        10,
+			after:         1200,
+			included:      true,
+			confirmations: 1201,
+			cfg:           Cfg{Confirmations: 1500},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn, url := newV6StandIn(t)
+			standIn.addBlocks(tt.before)
+			if tt.included {
+				standIn.addBlocks(tt.after+1, "abcd")
+			}
+
+			cfg := tt.cfg
+			cfg.Version = VersionV6
+			backend, err := newV6Backend("lisk-mainnet", url, cfg.withDefaults())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			confirmations, err := backend.GetConfirmations("abcd")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if confirmations != tt.confirmations {
+				t.Fatalf("got %d confirmations, want %d", confirmations, tt.confirmations)
+			}
+		})
+	}
+}
+
+func TestV6GetConfirmationsAfterRestart(t *testing.T) {
+	standIn, url := newV6StandIn(t)
+	standIn.addBlocks(3)
+	standIn.addBlocks(1, "abcd")
+
+	cfg := Cfg{Version: VersionV6, Confirmations: 5}.withDefaults()
+	backend, err := newV6Backend("lisk-mainnet", url, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmations, err := backend.GetConfirmations("abcd"); err != nil || confirmations != 1 {
+		t.Fatalf("got %d confirmations, err = %v", confirmations, err)
+	}
+
+	// A new backend, as after a restart, counts from the block of the transaction and not from
+	// when it first sees it.
+	standIn.addBlocks(3)
+	backend, err = newV6Backend("lisk-mainnet", url, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmations, err := backend.GetConfirmations("abcd"); err != nil || confirmations != 4 {
+		t.Fatalf("got %d confirmations after restart, err = %v", confirmations, err)
+	}
+}
+
+func TestNewWatcherChecksNetwork(t *testing.T) {
+	pubkey := liskcrypto.GetPublicKeyFromSecret(testMnemonic)
+
+	tests := []struct {
+		name string
+		cfg  Cfg
+		down bool
+		err  error
+	}{
+		{name: "chain id from the node", cfg: Cfg{}},
+		{name: "matching ids", cfg: Cfg{ChainId: "04000000", FeeTokenId: "0400000000000000"}},
+		{name: "other chain id", cfg: Cfg{ChainId: "04000001"}, err: ErrNetworkMismatch},
+		{name: "other fee token", cfg: Cfg{FeeTokenId: "0400000100000000"}, err: ErrNetworkMismatch},
+		{name: "node down", cfg: Cfg{ChainId: "04000001"}, down: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, url := newV6StandIn(t)
+			if tt.down {
+				url = "http://127.0.0.1:1"
+			}
+
+			cfg := tt.cfg
+			cfg.Version = VersionV6
+			w, err := NewWatcher(testMnemonic, "lisk-mainnet", url, cfg, pubkey, "", nil, nil, nil, nil, nil, nil)
+			if !errors.Is(err, tt.err) || (err != nil) != (tt.err != nil) {
+				t.Fatalf("got err %v, want %v", err, tt.err)
+			}
+			if err == nil && w == nil {
+				t.Fatal("no watcher")
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package lisk
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	lisktypes "github.com/sisu-network/deyes/chains/lisk/types"
+)
+
+const (
+	DefaultConfirmations  = 1
+	DefaultPendingTimeout = time.Minute * 10
+	DefaultMinFeePerByte  = uint64(1000)
+	DefaultFeePriority    = "low"
+	DefaultMaxFee         = uint64(10_000_000) // 0.1 LSK
+
+	DefaultInitAmount          = uint64(10_000_000) // 0.1 LSK
+	DefaultMinRemainingBalance = uint64(5_000_000)  // 0.05 LSK
+)
+
+// Token ids of LSK on the Lisk v6 networks.
+var v6DefaultTokenIds = map[string]string{
+	"lisk-mainnet": "0000000000000000",
+	"lisk-testnet": "0100000000000000",
+}
+
+// Cfg holds the Lisk specific settings of a chain.
+type Cfg struct {
+	// Version is the Lisk SDK version of the network, v5 (default) or v6. The rpc of a v5 chain is
+	// the Lisk Service v2 api, the rpc of a v6 chain is the JSON-RPC endpoint of a node.
+	Version string `toml:"version" json:"version"`
+	// NetworkId is the hex encoded network identifier signed by v5 transactions. It defaults to the
+	// network ids known by deyes.
+	NetworkId string `toml:"network_id" json:"network_id"`
+	// ChainId is the hex encoded chain id signed by v6 transactions. It is read from the node when
+	// empty and checked against the node otherwise.
+	ChainId string `toml:"chain_id" json:"chain_id"`
+	// TokenId is the hex encoded id of the token to fund on v6 networks. It defaults to LSK.
+	TokenId string `toml:"token_id" json:"token_id"`
+	// FeeTokenId is the hex encoded id of the token that pays the fees on v6 networks. When set it is
+	// checked against the node so that the faucet does not fund a sidechain with the wrong token.
+	FeeTokenId string `toml:"fee_token_id" json:"fee_token_id"`
+
+	// Confirmations is the number of blocks a funding transaction needs before it is considered
+	// final and a new top-up can be sent.
+	Confirmations uint64 `toml:"confirmations" json:"confirmations"`
+	// PendingTimeout is how long we wait for a funding transaction to be included before giving
+	// up on it.
+	PendingTimeout time.Duration `toml:"pending_timeout" json:"pending_timeout"`
+
+	// The fee of a transaction is its size multiplied by the fee per byte. The fee per byte is the
+	// largest of MinFeePerByte, the network minimum and the network estimate for FeePriority (low,
+	// medium or high), multiplied by FeeMultiplier. Transfers whose fee exceeds MaxFee are not sent.
+	MinFeePerByte uint64  `toml:"min_fee_per_byte" json:"min_fee_per_byte"`
+	FeePriority   string  `toml:"fee_priority" json:"fee_priority"`
+	FeeMultiplier float64 `toml:"fee_multiplier" json:"fee_multiplier"`
+	MaxFee        uint64  `toml:"max_fee" json:"max_fee"`
+
+	// InitAmount is sent to the watched account when it does not exist on chain yet. It is raised
+	// to MinRemainingBalance, the minimum balance the network lets an account keep.
+	InitAmount          uint64 `toml:"init_amount" json:"init_amount"`
+	MinRemainingBalance uint64 `toml:"min_remaining_balance" json:"min_remaining_balance"`
+}
+
+func (cfg Cfg) withDefaults() Cfg {
+	if cfg.Version == "" {
+		cfg.Version = VersionV5
+	}
+	if cfg.Confirmations == 0 {
+		cfg.Confirmations = DefaultConfirmations
+	}
+	if cfg.PendingTimeout == 0 {
+		cfg.PendingTimeout = DefaultPendingTimeout
+	}
+	if cfg.MinFeePerByte == 0 {
+		cfg.MinFeePerByte = DefaultMinFeePerByte
+	}
+	if cfg.FeePriority == "" {
+		cfg.FeePriority = DefaultFeePriority
+	}
+	if cfg.FeeMultiplier == 0 {
+		cfg.FeeMultiplier = 1
+	}
+	if cfg.MaxFee == 0 {
+		cfg.MaxFee = DefaultMaxFee
+	}
+	if cfg.InitAmount == 0 {
+		cfg.InitAmount = DefaultInitAmount
+	}
+	if cfg.MinRemainingBalance == 0 {
+		cfg.MinRemainingBalance = DefaultMinRemainingBalance
+	}
+	if cfg.InitAmount < cfg.MinRemainingBalance {
+		cfg.InitAmount = cfg.MinRemainingBalance
+	}
+
+	return cfg
+}
+
+// IsCustomNetwork returns true if the config describes its own network, e.g. a devnet or a
+// sidechain unknown to the chain library.
+func (cfg Cfg) IsCustomNetwork() bool {
+	return cfg.NetworkId != "" || cfg.ChainId != ""
+}
+
+// Validate checks the config of chain and that every id needed to sign a transfer is known.
+func (cfg Cfg) Validate(chain string) error {
+	switch cfg.Version {
+	case "", VersionV5:
+		if _, err := cfg.networkId(chain); err != nil {
+			return err
+		}
+
+	case VersionV6:
+		if cfg.ChainId != "" {
+			if _, err := decodeId(cfg.ChainId, 4); err != nil {
+				return fmt.Errorf("invalid chain id: %w", err)
+			}
+		}
+		if _, err := cfg.tokenId(chain); err != nil {
+			return err
+		}
+		if cfg.FeeTokenId != "" {
+			if _, err := decodeId(cfg.FeeTokenId, 8); err != nil {
+				return fmt.Errorf("invalid fee token id: %w", err)
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown lisk version %s", cfg.Version)
+	}
+
+	switch cfg.FeePriority {
+	case "", "low", "medium", "high":
+	default:
+		return fmt.Errorf("unknown fee priority %s", cfg.FeePriority)
+	}
+
+	// A multiplier below 1 would pay less than the network minimum and every transfer would be
+	// rejected. 0 is the default multiplier of 1.
+	if cfg.FeeMultiplier != 0 && cfg.FeeMultiplier < 1 {
+		return fmt.Errorf("fee multiplier %v is below 1", cfg.FeeMultiplier)
+	}
+
+	return nil
+}
+
+// networkId returns the v5 network id of chain, from the config or from the ids known by deyes.
+func (cfg Cfg) networkId(chain string) (string, error) {
+	networkId := cfg.NetworkId
+	if networkId == "" {
+		networkId = lisktypes.NetworkId[chain]
+	}
+	if networkId == "" {
+		return "", fmt.Errorf("network id of chain %s is unknown, set network_id in the config", chain)
+	}
+
+	if _, err := decodeId(networkId, 32); err != nil {
+		return "", fmt.Errorf("invalid network id: %w", err)
+	}
+
+	return networkId, nil
+}
+
+// tokenId returns the v6 token id of chain, from the config or from the LSK token ids.
+func (cfg Cfg) tokenId(chain string) ([]byte, error) {
+	tokenId := cfg.TokenId
+	if tokenId == "" {
+		tokenId = v6DefaultTokenIds[chain]
+	}
+	if tokenId == "" {
+		return nil, fmt.Errorf("token id of chain %s is unknown, set token_id in the config", chain)
+	}
+
+	bz, err := decodeId(tokenId, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token id: %w", err)
+	}
+
+	return bz, nil
+}
+
+func decodeId(id string, length int) ([]byte, error) {
+	bz, err := hex.DecodeString(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(bz) != length {
+		return nil, fmt.Errorf("%s is %d bytes long instead of %d", id, len(bz), length)
+	}
+
+	return bz, nil
+}
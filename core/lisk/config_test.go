@@ -0,0 +1,46 @@
+package lisk
+
+import "testing"
+
+func TestCfgValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		chain string
+		cfg   Cfg
+		err   bool
+	}{
+		{name: "v5 known network", chain: "lisk-testnet", cfg: Cfg{}},
+		{name: "v5 unknown network", chain: "lisk-devnet", cfg: Cfg{}, err: true},
+		{
+			name:  "v5 custom network",
+			chain: "lisk-devnet",
+			cfg:   Cfg{NetworkId: "15f0dacc1060e91818224a94286b13aa04279c640bd5d6f193182031d133df7c"},
+		},
+		{name: "v5 short network id", chain: "lisk-devnet", cfg: Cfg{NetworkId: "15f0dacc"}, err: true},
+		{name: "v6 default token", chain: "lisk-mainnet", cfg: Cfg{Version: VersionV6}},
+		{name: "v6 unknown token", chain: "lisk-devnet", cfg: Cfg{Version: VersionV6}, err: true},
+		{
+			name:  "v6 sidechain",
+			chain: "lisk-devnet",
+			cfg:   Cfg{Version: VersionV6, ChainId: "04000001", TokenId: "0400000100000000", FeeTokenId: "0400000000000000"},
+		},
+		{name: "v6 invalid chain id", chain: "lisk-mainnet", cfg: Cfg{Version: VersionV6, ChainId: "0400"}, err: true},
+		{name: "v6 invalid fee token", chain: "lisk-mainnet", cfg: Cfg{Version: VersionV6, FeeTokenId: "zz"}, err: true},
+		{name: "unknown version", chain: "lisk-testnet", cfg: Cfg{Version: "v7"}, err: true},
+		{name: "unknown priority", chain: "lisk-testnet", cfg: Cfg{FeePriority: "urgent"}, err: true},
+		{name: "default multiplier", chain: "lisk-testnet", cfg: Cfg{FeeMultiplier: 0}},
+		{name: "multiplier of 1", chain: "lisk-testnet", cfg: Cfg{FeeMultiplier: 1}},
+		{name: "multiplier above 1", chain: "lisk-testnet", cfg: Cfg{FeeMultiplier: 1.5}},
+		{name: "multiplier below 1", chain: "lisk-testnet", cfg: Cfg{FeeMultiplier: 0.5}, err: true},
+		{name: "negative multiplier", chain: "lisk-testnet", cfg: Cfg{FeeMultiplier: -1}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate(tt.chain)
+			if (err != nil) != tt.err {
+				t.Fatalf("got err %v, want err %t", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package lisk
+
+import (
+	"time"
+
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/store"
+)
+
+var PendingPollTime = time.Second * 10
+
+// pendingTx is a funding transaction that has been sent but is not final yet.
+type pendingTx struct {
+	Hash   string    `json:"hash"`
+	Amount uint64    `json:"amount"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+func (w *watcher) loadPending() {
+	pending := &pendingTx{}
+	if err := store.Load(w.pendingFile, pending); err != nil {
+		log.Errorf("Failed to load pending transaction from %s, err = %s", w.pendingFile, err)
+		return
+	}
+
+	if pending.Hash != "" {
+		log.Infof("Resuming tracking of transaction %s on chain %s", pending.Hash, w.chain)
+		w.pending = pending
+	}
+}
+
+func (w *watcher) setPending(pending *pendingTx) {
+	w.pending = pending
+	if pending == nil {
+		pending = &pendingTx{}
+	}
+
+	if err := store.Save(w.pendingFile, pending); err != nil {
+		log.Errorf("Failed to save pending transaction to %s, err = %s", w.pendingFile, err)
+	}
+}
+
+// checkPending polls the in-flight funding transaction and returns true once there is no
+// transaction in flight anymore: it is confirmed, or the node still reports it as not included
+// after PendingTimeout.
+func (w *watcher) checkPending() bool {
+	if w.pending == nil {
+		return true
+	}
+
+	// The transaction stays pending while its status is unknown, even past the timeout. Giving up
+	// on a transaction that may be included would fund the account twice.
+	confirmations, err := w.backend.GetConfirmations(w.pending.Hash)
+	if err != nil {
+		log.Errorf("Failed to get transaction %s on chain %s, err = %s", w.pending.Hash, w.chain, err)
+		return false
+	}
+
+	switch {
+	case confirmations >= w.cfg.Confirmations:
+		log.Infof("Transaction %s on chain %s is confirmed with %d confirmations",
+			w.pending.Hash, w.chain, confirmations)
+		w.setPending(nil)
+		return true
+
+	case confirmations == 0 && time.Since(w.pending.SentAt) > w.cfg.PendingTimeout:
+		log.Errorf("Transaction %s on chain %s was not included after %s, giving up on it",
+			w.pending.Hash, w.chain, w.cfg.PendingTimeout)
+		w.setPending(nil)
+		return true
+	}
+
+	log.Verbosef("Transaction %s on chain %s has %d/%d confirmations", w.pending.Hash, w.chain,
+		confirmations, w.cfg.Confirmations)
+
+	return false
+}
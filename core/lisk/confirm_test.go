@@ -0,0 +1,99 @@
+package lisk
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	liskcrypto "github.com/sisu-network/deyes/chains/lisk/crypto"
+)
+
+const testMnemonic = "test test test test test test test test test test test junk"
+
+func TestCheckPending(t *testing.T) {
+	tests := []struct {
+		name string
+		// sentAgo is how long ago the pending transaction was sent.
+		sentAgo time.Duration
+		// included submits the pending transaction to the backend, followed by blocks.
+		included bool
+		blocks   uint64
+		err      error
+		done     bool
+	}{
+		{name: "not included yet", sentAgo: time.Minute},
+		{name: "not included after the timeout", sentAgo: time.Hour, done: true},
+		{name: "included", sentAgo: time.Minute, included: true, blocks: 2, done: true},
+		{name: "included with too few confirmations", sentAgo: time.Hour, included: true},
+		{name: "node error", sentAgo: time.Minute, err: ErrUnavailable},
+		{name: "node error after the timeout", sentAgo: time.Hour, err: ErrUnavailable},
+		{name: "rate limited after the timeout", sentAgo: time.Hour, err: fmt.Errorf("%w", ErrRateLimited)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := NewFakeBackend()
+			w := &watcher{
+				chain:       "lisk-testnet",
+				cfg:         Cfg{Confirmations: 3}.withDefaults(),
+				backend:     backend,
+				pendingFile: filepath.Join(t.TempDir(), "pending.json"),
+			}
+
+			hash := "f00d"
+			if tt.included {
+				hash = submitTestTransfer(t, backend)
+				backend.AddBlocks(tt.blocks)
+			}
+			w.setPending(&pendingTx{Hash: hash, Amount: 1, SentAt: time.Now().Add(-tt.sentAgo)})
+			backend.SetErr(tt.err)
+
+			if done := w.checkPending(); done != tt.done {
+				t.Fatalf("got done %t, want %t", done, tt.done)
+			}
+			if (w.pending == nil) != tt.done {
+				t.Fatalf("pending is %v", w.pending)
+			}
+
+			// The pending transaction survives a restart until it is done.
+			w.pending = nil
+			w.loadPending()
+			if (w.pending == nil) != tt.done {
+				t.Fatalf("pending after restart is %v", w.pending)
+			}
+		})
+	}
+}
+
+// submitTestTransfer submits a transfer from a funded account to backend and returns its id.
+func submitTestTransfer(t *testing.T, backend *FakeBackend) string {
+	t.Helper()
+
+	pubKey := liskcrypto.GetPublicKeyFromSecret(testMnemonic)
+	backend.SetBalance(liskcrypto.GetLisk32AddressFromPublickey(pubKey), big.NewInt(1_000_000_000))
+	recipient, err := hex.DecodeString(liskcrypto.GetAddressFromPublicKey(pubKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := &transfer{Amount: 1, Fee: 1_000_000, Recipient: recipient, SenderPublicKey: pubKey}
+	signingBytes, err := backend.SigningBytes(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Signatures = [][]byte{liskcrypto.SignMessage(signingBytes, liskcrypto.GetPrivateKeyFromSecret(testMnemonic))}
+	signed, err := backend.EncodeTransfer(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := backend.Submit(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
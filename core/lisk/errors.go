@@ -0,0 +1,59 @@
+package lisk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Errors returned by Lisk Service and the Lisk node, matched with errors.Is.
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrNotFound     = errors.New("not found")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServerError  = errors.New("server error")
+	ErrUnavailable  = errors.New("service unavailable")
+	ErrUnknownError = errors.New("unknown error")
+)
+
+// ServiceError is an error response of Lisk Service.
+type ServiceError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *ServiceError) Error() string {
+	return fmt.Sprintf("lisk service error, status = %d, message = %s", e.StatusCode, e.Message)
+}
+
+func (e *ServiceError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusBadRequest:
+		return ErrBadRequest
+	case e.StatusCode == http.StatusNotFound || e.Message == "Data not found":
+		return ErrNotFound
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode == http.StatusServiceUnavailable || e.StatusCode == http.StatusGatewayTimeout ||
+		e.StatusCode == http.StatusBadGateway:
+		return ErrUnavailable
+	case e.StatusCode >= http.StatusInternalServerError:
+		return ErrServerError
+	default:
+		return ErrUnknownError
+	}
+}
+
+// newServiceError builds the error of a Lisk Service response. Lisk Service puts the reason in the
+// message field of a JSON body, but proxies in front of it may return anything.
+func newServiceError(statusCode int, body []byte) error {
+	res := &struct {
+		Message string `json:"message"`
+	}{}
+	if err := json.Unmarshal(body, res); err != nil || res.Message == "" {
+		res.Message = http.StatusText(statusCode)
+	}
+
+	return &ServiceError{StatusCode: statusCode, Message: res.Message}
+}
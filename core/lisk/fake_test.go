@@ -0,0 +1,229 @@
+package lisk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+
+	liskcrypto "github.com/sisu-network/deyes/chains/lisk/crypto"
+)
+
+// FakeBackend is an in-memory Backend to test the watcher without a Lisk node. Transfers are
+// encoded as JSON. A submitted transfer must be signed by its sender with the next nonce and be
+// covered by its balance; it is applied at once and included in the next block.
+type FakeBackend struct {
+	lock     sync.Mutex
+	accounts map[string]*fakeAccount
+	fees     feeParams
+	initFee  uint64
+	// heights are the heights of the blocks including the submitted transfers.
+	heights   map[string]uint64
+	height    uint64
+	submitted []FakeTransfer
+	err       error
+}
+
+type fakeAccount struct {
+	balance *big.Int
+	nonce   uint64
+}
+
+// FakeTransfer is a transfer submitted to a FakeBackend. Addresses are lisk32.
+type FakeTransfer struct {
+	Id        string
+	Sender    string
+	Recipient string
+	Amount    uint64
+	Fee       uint64
+	Nonce     uint64
+}
+
+func NewFakeBackend() *FakeBackend {
+	return &FakeBackend{
+		accounts: make(map[string]*fakeAccount),
+		// The minimum fee of the Lisk mainnet.
+		fees:    feeParams{MinFeePerByte: 1000},
+		initFee: 5000000,
+		heights: make(map[string]uint64),
+	}
+}
+
+// SetBalance creates the account of the lisk32 address if needed and sets its balance.
+func (b *FakeBackend) SetBalance(address string, balance *big.Int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.account(address).balance = new(big.Int).Set(balance)
+}
+
+// SetErr makes every call fail with err, e.g. ErrUnavailable. A nil err restores the backend.
+func (b *FakeBackend) SetErr(err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.err = err
+}
+
+// AddBlocks adds n blocks on top of the submitted transfers.
+func (b *FakeBackend) AddBlocks(n uint64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.height += n
+}
+
+// Submitted returns the transfers submitted so far.
+func (b *FakeBackend) Submitted() []FakeTransfer {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return append([]FakeTransfer{}, b.submitted...)
+}
+
+func (b *FakeBackend) GetBalance(address string) (*big.Int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	account, ok := b.accounts[address]
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+
+	return new(big.Int).Set(account.balance), nil
+}
+
+func (b *FakeBackend) GetNonce(address string) (uint64, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.err != nil {
+		return 0, b.err
+	}
+	if account, ok := b.accounts[address]; ok {
+		return account.nonce, nil
+	}
+
+	return 0, nil
+}
+
+func (b *FakeBackend) GetFeeParams() (*feeParams, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	fees := b.fees
+	return &fees, b.err
+}
+
+func (b *FakeBackend) GetInitializationFee() (uint64, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.initFee, b.err
+}
+
+func (b *FakeBackend) EncodeTransfer(t *transfer) ([]byte, error) {
+	return json.Marshal(t)
+}
+
+func (b *FakeBackend) SigningBytes(t *transfer) ([]byte, error) {
+	unsigned := *t
+	unsigned.Signatures = nil
+
+	return json.Marshal(&unsigned)
+}
+
+func (b *FakeBackend) Submit(signed []byte) (string, error) {
+	t := &transfer{}
+	if err := json.Unmarshal(signed, t); err != nil {
+		return "", fmt.Errorf("invalid transfer: %w", err)
+	}
+	signingBytes, err := b.SigningBytes(t)
+	if err != nil {
+		return "", err
+	}
+	if len(t.Signatures) != 1 || !liskcrypto.VerifyMessage(signingBytes, t.Signatures[0], t.SenderPublicKey) {
+		return "", fmt.Errorf("invalid signature")
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.err != nil {
+		return "", b.err
+	}
+
+	sender := liskcrypto.GetLisk32AddressFromPublickey(t.SenderPublicKey)
+	from, ok := b.accounts[sender]
+	if !ok {
+		return "", ErrAccountNotFound
+	}
+	if t.Nonce != from.nonce {
+		return "", fmt.Errorf("invalid nonce %d, expected %d", t.Nonce, from.nonce)
+	}
+	if minFee := uint64(len(signed)) * b.fees.MinFeePerByte; t.Fee < minFee {
+		return "", fmt.Errorf("fee %d is below the min fee %d", t.Fee, minFee)
+	}
+	cost := new(big.Int).SetUint64(t.Amount)
+	cost.Add(cost, new(big.Int).SetUint64(t.Fee))
+	if from.balance.Cmp(cost) < 0 {
+		return "", fmt.Errorf("insufficient balance %s, need %s", from.balance, cost)
+	}
+
+	recipient := liskcrypto.AddressToLisk32(t.Recipient)
+	to := b.account(recipient)
+	from.balance = new(big.Int).Sub(from.balance, cost)
+	from.nonce++
+	to.balance = new(big.Int).Add(to.balance, new(big.Int).SetUint64(t.Amount))
+
+	hash := sha256.Sum256(signed)
+	id := hex.EncodeToString(hash[:])
+	b.height++
+	b.heights[id] = b.height
+	b.submitted = append(b.submitted, FakeTransfer{
+		Id:        id,
+		Sender:    sender,
+		Recipient: recipient,
+		Amount:    t.Amount,
+		Fee:       t.Fee,
+		Nonce:     t.Nonce,
+	})
+
+	return id, nil
+}
+
+func (b *FakeBackend) GetConfirmations(hash string) (uint64, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.err != nil {
+		return 0, b.err
+	}
+	height, ok := b.heights[hash]
+	if !ok {
+		return 0, nil
+	}
+
+	return b.height - height + 1, nil
+}
+
+func (b *FakeBackend) CheckNetwork() error {
+	return nil
+}
+
+// account returns the account of address, created empty if needed. The caller must hold the lock.
+func (b *FakeBackend) account(address string) *fakeAccount {
+	account, ok := b.accounts[address]
+	if !ok {
+		account = &fakeAccount{balance: big.NewInt(0)}
+		b.accounts[address] = account
+	}
+
+	return account
+}
@@ -0,0 +1,79 @@
+package lisk
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/sisu-network/lib/log"
+)
+
+// signatureLength is the size of an ed25519 signature, used as a placeholder when measuring the
+// size of a transaction before it is signed.
+const signatureLength = 64
+
+// getFeeParams returns the fee per byte and the base fee to use for the next transfer. The network
+// values are best effort, the configured minimum is used if the node does not expose them.
+func (w *watcher) getFeeParams() (uint64, uint64) {
+	feePerByte := w.cfg.MinFeePerByte
+	networkMin := uint64(0)
+	baseFee := uint64(0)
+
+	params, err := w.backend.GetFeeParams()
+	if err != nil {
+		log.Verbosef("Cannot get fee estimate on chain %s, using min fee per byte %d, err = %s",
+			w.chain, feePerByte, err)
+	} else {
+		estimate := params.Low
+		switch w.cfg.FeePriority {
+		case "medium":
+			estimate = params.Medium
+		case "high":
+			estimate = params.High
+		}
+
+		networkMin = params.MinFeePerByte
+		if params.MinFeePerByte > feePerByte {
+			feePerByte = params.MinFeePerByte
+		}
+		if estimate > feePerByte {
+			feePerByte = estimate
+		}
+		baseFee = params.BaseFee
+	}
+
+	// The multiplier is validated, but the fee never goes below the network minimum.
+	feePerByte = uint64(math.Ceil(float64(feePerByte) * w.cfg.FeeMultiplier))
+	if feePerByte < networkMin {
+		feePerByte = networkMin
+	}
+
+	return feePerByte, baseFee
+}
+
+// computeFee returns the size based fee of an unsigned transfer plus extraFee. The size depends on
+// the encoding of the fee itself, so the fee is recomputed until the size stops changing.
+func (w *watcher) computeFee(t *transfer, extraFee uint64) (uint64, error) {
+	feePerByte, baseFee := w.getFeeParams()
+	baseFee += extraFee
+
+	measured := *t
+	measured.Signatures = [][]byte{make([]byte, signatureLength)}
+	for i := 0; i < 5; i++ {
+		bz, err := w.backend.EncodeTransfer(&measured)
+		if err != nil {
+			return 0, err
+		}
+
+		fee := uint64(len(bz))*feePerByte + baseFee
+		if fee == measured.Fee {
+			break
+		}
+		measured.Fee = fee
+	}
+
+	if measured.Fee > w.cfg.MaxFee {
+		return 0, fmt.Errorf("fee %d is above the max fee %d", measured.Fee, w.cfg.MaxFee)
+	}
+
+	return measured.Fee, nil
+}
@@ -0,0 +1,74 @@
+package lisk
+
+import (
+	"testing"
+
+	liskcrypto "github.com/sisu-network/deyes/chains/lisk/crypto"
+)
+
+func TestComputeFee(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Cfg
+		network feeParams
+		// perByte is the expected fee per byte of the transfer.
+		perByte  uint64
+		extraFee uint64
+		err      bool
+	}{
+		{name: "network minimum", network: feeParams{MinFeePerByte: 1000}, perByte: 1000},
+		{name: "configured minimum", cfg: Cfg{MinFeePerByte: 2000}, network: feeParams{MinFeePerByte: 1000}, perByte: 2000},
+		{name: "low estimate", network: feeParams{MinFeePerByte: 1000, Low: 1500}, perByte: 1500},
+		{
+			name:    "high priority",
+			cfg:     Cfg{FeePriority: "high"},
+			network: feeParams{MinFeePerByte: 1000, Low: 1500, Medium: 2000, High: 3000},
+			perByte: 3000,
+		},
+		{name: "multiplier", cfg: Cfg{FeeMultiplier: 1.5}, network: feeParams{MinFeePerByte: 1000}, perByte: 1500},
+		{
+			name:    "multiplier below 1 is clamped to the network minimum",
+			cfg:     Cfg{FeeMultiplier: 0.5, MinFeePerByte: 1},
+			network: feeParams{MinFeePerByte: 1000},
+			perByte: 1000,
+		},
+		{name: "base fee", network: feeParams{MinFeePerByte: 1000, BaseFee: 7}, perByte: 1000, extraFee: 7},
+		{name: "max fee", cfg: Cfg{MaxFee: 1000}, network: feeParams{MinFeePerByte: 1000}, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := NewFakeBackend()
+			backend.fees = tt.network
+			w := &watcher{chain: "lisk-testnet", cfg: tt.cfg.withDefaults(), backend: backend}
+
+			tx := &transfer{
+				Nonce:           1,
+				Amount:          1_000_000_000,
+				Recipient:       make([]byte, 20),
+				SenderPublicKey: liskcrypto.GetPublicKeyFromSecret(testMnemonic),
+			}
+			fee, err := w.computeFee(tx, 0)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got fee %d", fee)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The fee pays for the signed transfer, which encodes the fee itself.
+			tx.Fee = fee
+			tx.Signatures = [][]byte{make([]byte, signatureLength)}
+			signed, err := backend.EncodeTransfer(tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := uint64(len(signed))*tt.perByte + tt.extraFee; fee != want {
+				t.Fatalf("got fee %d, want %d for %d bytes", fee, want, len(signed))
+			}
+		})
+	}
+}
@@ -19,3 +19,19 @@ type GetAccountsSummary struct {
 type GetAccountsSequence struct {
 	Nonce string `json:"nonce,omitempty"`
 }
+
+type GetFeesResponse struct {
+	Data GetFeesData `json:"data,omitempty"`
+}
+
+type GetFeesData struct {
+	FeeEstimatePerByte GetFeesEstimate   `json:"feeEstimatePerByte,omitempty"`
+	BaseFeeById        map[string]string `json:"baseFeeById,omitempty"`
+	MinFeePerByte      uint64            `json:"minFeePerByte,omitempty"`
+}
+
+type GetFeesEstimate struct {
+	Low    uint64 `json:"low"`
+	Medium uint64 `json:"medium"`
+	High   uint64 `json:"high"`
+}
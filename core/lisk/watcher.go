@@ -1,208 +1,314 @@
 package lisk
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"math/big"
-	"net/http"
-	"reflect"
-	"strconv"
+	"sync"
 	"time"
 
-	"go.uber.org/atomic"
-	"google.golang.org/protobuf/proto"
-
-	deyeslisk "github.com/sisu-network/deyes/chains/lisk"
 	liskcrypto "github.com/sisu-network/deyes/chains/lisk/crypto"
-	lisktypes "github.com/sisu-network/deyes/chains/lisk/types"
-	"github.com/sisu-network/deyes/config"
 
 	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
 )
 
 var (
-	SleepTime     = time.Second * 60 * 30
-	FundThreshold = new(big.Int).SetInt64(10 * 100_000_000)
-	FundAmount    = uint64(10 * 100_000_000)
+	SleepTime = time.Second * 60 * 30
+
+	// DefaultPolicy funds 10 LSK whenever the balance drops below 10 LSK.
+	DefaultPolicy = funding.PolicyCfg{
+		Mode:      funding.ModeFixed,
+		Threshold: "1000000000",
+		Amount:    "1000000000",
+	}
 )
 
 type watcher struct {
-	chain     string
-	mnemonic  string
-	url       string
-	pubkey    []byte
-	watchAddr string
-	stop      atomic.Bool
+	chain       string
+	mnemonic    string
+	url         string
+	cfg         Cfg
+	backend     Backend
+	pubkey      []byte
+	watchAddr   string
+	policy      *funding.Policy
+	burn        *funding.BurnTracker
+	limiter     *funding.Limiter
+	ledger      *ledger.Ledger
+	outflow     *funding.OutflowMonitor
+	audit       *audit.Trail
+	pendingFile string
+	pending     *pendingTx
+	ctx         context.Context
+	cancel      context.CancelFunc
+	done        sync.WaitGroup
 }
 
-func NewWatcher(mnemonic string, chain string, url string, pubkey []byte) *watcher {
-	fmt.Println("Lisk32 = ", liskcrypto.GetLisk32AddressFromPublickey(pubkey))
+func NewWatcher(mnemonic string, chain string, url string, cfg Cfg, pubkey []byte, pendingFile string,
+	policy *funding.Policy, burn *funding.BurnTracker, limiter *funding.Limiter, ledger *ledger.Ledger,
+	outflow *funding.OutflowMonitor, audit *audit.Trail) (*watcher, error) {
+	backend, err := newBackend(chain, url, cfg.withDefaults())
+	if err != nil {
+		return nil, err
+	}
+
+	// Signing for another network would only be rejected when funding. A node that cannot be
+	// reached does not fail the startup, v6 nodes are checked again before signing.
+	if err := backend.CheckNetwork(); errors.Is(err, ErrNetworkMismatch) {
+		return nil, fmt.Errorf("lisk network of chain %s: %w", chain, err)
+	} else if err != nil {
+		log.Warnf("Cannot check the lisk network of chain %s, err = %s", chain, err)
+	}
+
+	return NewWatcherWithBackend(mnemonic, chain, url, cfg, backend, pubkey, pendingFile, policy, burn,
+		limiter, ledger, outflow, audit), nil
+}
+
+// NewWatcherWithBackend returns a watcher that talks to the network through backend, e.g. an
+// in-memory fake in tests.
+func NewWatcherWithBackend(mnemonic string, chain string, url string, cfg Cfg, backend Backend,
+	pubkey []byte, pendingFile string, policy *funding.Policy, burn *funding.BurnTracker,
+	limiter *funding.Limiter, ledger *ledger.Ledger, outflow *funding.OutflowMonitor,
+	audit *audit.Trail) *watcher {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &watcher{
-		mnemonic:  mnemonic,
-		chain:     chain,
-		url:       url,
-		pubkey:    pubkey,
-		watchAddr: liskcrypto.GetLisk32AddressFromPublickey(pubkey),
-		stop:      *atomic.NewBool(false),
+		mnemonic:    mnemonic,
+		chain:       chain,
+		url:         url,
+		cfg:         cfg.withDefaults(),
+		backend:     backend,
+		pubkey:      pubkey,
+		watchAddr:   liskcrypto.GetLisk32AddressFromPublickey(pubkey),
+		policy:      policy,
+		burn:        burn,
+		limiter:     limiter,
+		ledger:      ledger,
+		outflow:     outflow,
+		audit:       audit,
+		pendingFile: pendingFile,
+		ctx:         ctx,
+		cancel:      cancel,
 	}
 }
 
 func (w *watcher) Start() {
-	log.Infof("Starting watcher for chain %s, watch address = %s", w.chain, w.watchAddr)
+	log.Infof("Starting watcher for chain %s (lisk %s), watch address = %s", w.chain, w.cfg.Version,
+		w.watchAddr)
+	w.loadPending()
+
+	w.done.Add(1)
 	go w.loop()
 }
 
+// Stop stops the watcher and waits for the cycle in progress to finish.
 func (w *watcher) Stop() {
-	w.stop.Store(true)
+	w.cancel()
+	w.done.Wait()
 }
 
 func (w *watcher) loop() {
+	defer w.done.Done()
+
 	for {
-		if w.stop.Load() {
+		if w.ctx.Err() != nil {
 			break
 		}
 
-		bz, err := w.get("/accounts", map[string]string{
-			"address": w.watchAddr,
-			"limit":   "10",
-			"offset":  "0",
-		})
+		// Do not fund again while the previous top-up is not final, the balance may not reflect it
+		// yet.
+		if !w.checkPending() {
+			funding.Sleep(w.ctx, PendingPollTime)
+			continue
+		}
 
-		if err != nil {
-			log.Errorf("Cannot get accounts info, err = ", err)
-		} else {
-			res := &GetAccountsResponse{}
-			err = json.Unmarshal(bz, res)
-			switch {
-			case err != nil:
-				log.Errorf("Failed to get unmarshal get accounts response, err = ", err)
-
-			case res.Error:
-				if res.Message == "Data not found" {
-					log.Info("Funding a small amount for the account to be created.")
-					w.fundSisu(w.mnemonic, w.pubkey, 10_000_000, "")
-				}
-
-			case len(res.Data) > 0:
-				balance, ok := new(big.Int).SetString(res.Data[0].Summary.Balance, 10)
-				if ok {
-					if balance.Cmp(FundThreshold) < 0 {
-						w.fundSisu(w.mnemonic, w.pubkey, FundAmount, "")
-					}
-				}
+		w.checkFaucet()
+
+		balance, err := w.backend.GetBalance(w.watchAddr)
+		record := audit.Record{Address: w.watchAddr, Threshold: w.policy.Threshold().String()}
+		switch {
+		case errors.Is(err, ErrAccountNotFound):
+			log.Infof("Account %s does not exist on chain %s, funding %d to initialize it", w.watchAddr,
+				w.chain, w.cfg.InitAmount)
+			record.Balance = "0"
+			record.Reason = "account does not exist"
+			w.fund(new(big.Int).SetUint64(w.cfg.InitAmount), true, record)
+
+		case errors.Is(err, ErrRateLimited) || errors.Is(err, ErrUnavailable):
+			log.Warnf("Lisk node of chain %s is not available, err = %s", w.chain, err)
+			w.audit.Unavailable(record, err)
+
+		case err != nil:
+			log.Errorf("Cannot get balance on chain %s, err = %s", w.chain, err)
+			w.audit.Unavailable(record, err)
+
+		default:
+			w.burn.Observe(balance)
+			shouldFund, reason := w.policy.ShouldFund(balance, w.burn)
+			record.Balance = balance.String()
+			record.Reason = reason
+			if amount := w.policy.Amount(balance); shouldFund && amount.Sign() > 0 {
+				log.Infof("Funding chain %s, reason: %s", w.chain, reason)
+				w.fund(amount, false, record)
+			} else {
+				record.Decision = audit.Skip
+				w.audit.Record(record)
 			}
 		}
 
-		time.Sleep(SleepTime)
+		if !funding.Sleep(w.ctx, SleepTime) {
+			return
+		}
 	}
 }
 
-func (w *watcher) get(endpoint string, params map[string]string) ([]byte, error) {
-	keys := reflect.ValueOf(params).MapKeys()
-	req, err := http.NewRequest("GET", w.url+endpoint, nil)
-	if err != nil {
-		return nil, err
+// checkFaucet reports the faucet balance and nonce to the outflow monitor.
+func (w *watcher) checkFaucet() {
+	if !w.outflow.Enabled() {
+		return
 	}
 
-	q := req.URL.Query()
-	for _, key := range keys {
-		q.Add(key.Interface().(string), params[key.Interface().(string)])
+	lisk32 := liskcrypto.GetLisk32AddressFromPublickey(liskcrypto.GetPublicKeyFromSecret(w.mnemonic))
+	balance, err := w.backend.GetBalance(lisk32)
+	if err != nil {
+		log.Errorf("Cannot get faucet balance on chain %s, err = %s", w.chain, err)
+		return
+	}
+	nonce, err := w.backend.GetNonce(lisk32)
+	if err != nil {
+		log.Errorf("Cannot get faucet nonce on chain %s, err = %s", w.chain, err)
+		return
 	}
 
-	req.URL.RawQuery = q.Encode()
-	response, err := http.Get(req.URL.String())
-	if response == nil {
-		return nil, fmt.Errorf("cannot fetch data " + endpoint)
+	w.outflow.Observe(funding.FaucetState{
+		Address:  lisk32,
+		Balance:  balance,
+		Nonce:    nonce,
+		HasNonce: true,
+	})
+}
+
+// fund sends amount to the watched account if the spending limits of the chain allow it.
+// initialize is true when the account does not exist on chain yet.
+func (w *watcher) fund(amount *big.Int, initialize bool, record audit.Record) {
+	record.Amount = amount.String()
+	if !amount.IsUint64() {
+		log.Errorf("Funding amount %s is out of range on chain %s", amount, w.chain)
+		w.audit.Failed(record, fmt.Errorf("amount %s is out of range", amount))
+		return
 	}
 
-	responseData, err := ioutil.ReadAll(response.Body)
+	reservation, err := w.limiter.Reserve(w.chain, w.watchAddr, amount)
 	if err != nil {
-		return nil, err
+		log.Errorf("Cannot fund chain %s, err = %s", w.chain, err)
+		w.audit.Refused(record, err)
+		return
 	}
 
-	return responseData, err
+	txHash, err := w.fundSisu(w.mnemonic, w.pubkey, amount.Uint64(), "", initialize)
+	if err != nil {
+		reservation.Release()
+		log.Errorf("Failed to fund chain %s, err = %s", w.chain, err)
+		w.audit.Failed(record, err)
+		return
+	}
+	reservation.Commit()
+	w.audit.Funded(record, txHash)
+
+	w.burn.RecordTopUp()
+	w.setPending(&pendingTx{
+		Hash:   txHash,
+		Amount: amount.Uint64(),
+		SentAt: time.Now(),
+	})
 }
 
-func (w *watcher) fundSisu(mnemonic string, mpcPubKey []byte, amount uint64, data string) error {
-	log.Info("Funding sisu....")
-	deyesChainCfg := config.Chain{Chain: w.chain, Rpcs: []string{w.url}}
-
-	client := deyeslisk.NewLiskClient(deyesChainCfg)
+// fundSisu sends amount from the faucet to the account of mpcPubKey and returns the transaction
+// hash.
+func (w *watcher) fundSisu(mnemonic string, mpcPubKey []byte, amount uint64, data string,
+	initialize bool) (string, error) {
 	mpcAddr := liskcrypto.GetAddressFromPublicKey(mpcPubKey)
-	log.Verbose("Funding LSK for mpc address = ", mpcAddr)
-
-	receiver := mpcAddr
-
-	moduleId := uint32(2)
-	assetId := uint32(0)
+	log.Verbosef("Funding LSK for mpc address = %s on chain %s", mpcAddr, w.chain)
 
 	privateKey := liskcrypto.GetPrivateKeyFromSecret(mnemonic)
 	faucetPubKey := liskcrypto.GetPublicKeyFromSecret(mnemonic)
 
 	lisk32 := liskcrypto.GetLisk32AddressFromPublickey(faucetPubKey)
 	log.Verbosef("Lisk32 of the faucet = %s", lisk32)
-	acc, err := client.GetAccount(lisk32)
+	nonce, err := w.backend.GetNonce(lisk32)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
-	nonce, err := strconv.ParseUint(acc.Sequence.Nonce, 10, 64)
+	recipientAddress, err := hex.DecodeString(mpcAddr)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
-	recipientAddress, err := hex.DecodeString(receiver)
-	if err != nil {
-		panic(err)
+	tx := &transfer{
+		Nonce:           nonce,
+		Amount:          amount,
+		Recipient:       recipientAddress,
+		Data:            data,
+		SenderPublicKey: faucetPubKey,
 	}
 
-	fee := uint64(500_000)
-	assetPb := &lisktypes.AssetMessage{
-		Amount:           &amount,
-		RecipientAddress: recipientAddress,
-		Data:             &data,
+	initFee := uint64(0)
+	if initialize {
+		initFee, err = w.backend.GetInitializationFee()
+		if err != nil {
+			return "", fmt.Errorf("Failed to get account initialization fee, err = %s", err)
+		}
 	}
 
-	asset, err := proto.Marshal(assetPb)
-	tx := &lisktypes.TransactionMessage{
-		ModuleID:        &moduleId,
-		AssetID:         &assetId,
-		Fee:             &fee,
-		Asset:           asset,
-		Nonce:           &nonce,
-		SenderPublicKey: faucetPubKey,
-	}
-	bz, err := proto.Marshal(tx)
+	tx.Fee, err = w.computeFee(tx, initFee)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
+	log.Verbosef("Lisk fee = %d on chain %s", tx.Fee, w.chain)
 
-	bytesToSign, err := liskcrypto.GetSigningBytes(lisktypes.NetworkId[w.chain], bz)
+	bytesToSign, err := w.backend.SigningBytes(tx)
 	if err != nil {
-		return fmt.Errorf("Failed to get lisk bytes to sign, err = %s", err)
+		return "", fmt.Errorf("Failed to get lisk bytes to sign, err = %s", err)
 	}
 
 	signature := liskcrypto.SignMessage(bytesToSign, privateKey)
 	tx.Signatures = [][]byte{signature}
-	signedBz, err := proto.Marshal(tx)
+	signedBz, err := w.backend.EncodeTransfer(tx)
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
 	hash := sha256.Sum256(signedBz)
 	log.Verbosef("Calculated hash = %s", hex.EncodeToString(hash[:]))
-	log.Infof("Funding Sisu from account %s to account %s= ", lisk32,
-		liskcrypto.GetLisk32AddressFromPublickey(mpcPubKey))
+	log.Infof("Funding Sisu from account %s to account %s on chain %s", lisk32,
+		liskcrypto.GetLisk32AddressFromPublickey(mpcPubKey), w.chain)
 
-	txHash, err := client.CreateTransaction(hex.EncodeToString(signedBz))
+	txHash, err := w.backend.Submit(signedBz)
 	if err != nil {
-		panic(err)
+		return "", err
+	}
+	if txHash == "" {
+		return "", fmt.Errorf("Lisk node did not return a transaction id")
 	}
 
-	log.Info("Lisk txHash = ", txHash)
+	log.Infof("Lisk txHash = %s on chain %s", txHash, w.chain)
+	w.outflow.Expect(new(big.Int).SetUint64(amount), new(big.Int).SetUint64(tx.Fee))
+	if err := w.ledger.Record(ledger.Entry{
+		Chain:  w.chain,
+		TxHash: txHash,
+		From:   lisk32,
+		To:     liskcrypto.GetLisk32AddressFromPublickey(mpcPubKey),
+		Amount: fmt.Sprintf("%d", amount),
+		Label:  "mpc",
+	}); err != nil {
+		log.Errorf("Failed to record transfer %s on chain %s, err = %s", txHash, w.chain, err)
+	}
 
-	return nil
+	return txHash, nil
 }
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/alert"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
+	"github.com/sisu-network/sisu-account-funding/core/reconcile"
+)
+
+// ledgerFile is the name of the ledger of the funder transfers in the data dir.
+const ledgerFile = "ledger.jsonl"
+
+// Reconcile runs the reconcile command: it matches the faucet transactions of each chain with the
+// ledger over a period and writes a CSV or JSON report. Unknown outflows are alerted.
+func Reconcile(args []string) {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	chainList := flags.String("chains", "", "comma separated chains to reconcile, all chains when empty")
+	fromFlag := flags.String("from", "", "start date YYYY-MM-DD, the first day of the current month by default")
+	toFlag := flags.String("to", "", "end date YYYY-MM-DD (exclusive), now by default")
+	format := flags.String("format", "csv", "report format, csv or json")
+	out := flags.String("out", "", "report file, stdout when empty")
+	flags.Parse(args)
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := now
+	var err error
+	if *fromFlag != "" {
+		if from, err = time.Parse("2006-01-02", *fromFlag); err != nil {
+			panic(fmt.Errorf("invalid from date: %w", err))
+		}
+	}
+	if *toFlag != "" {
+		if to, err = time.Parse("2006-01-02", *toFlag); err != nil {
+			panic(fmt.Errorf("invalid to date: %w", err))
+		}
+	}
+	if *format != "csv" && *format != "json" {
+		panic(fmt.Errorf("unknown report format %s", *format))
+	}
+
+	mnemonic := readMnemonic()
+	fmt.Println()
+	cfg := loadChainConfig("chains.toml")
+	records := ledger.NewLedger(filepath.Join(cfg.DataDir, ledgerFile))
+	alerter := alert.NewAlerter(cfg.AlertWebhook)
+
+	chains := make([]string, 0)
+	if *chainList != "" {
+		chains = strings.Split(*chainList, ",")
+	} else {
+		for chain := range cfg.Chains {
+			chains = append(chains, chain)
+		}
+		sort.Strings(chains)
+	}
+
+	reports := make([]*reconcile.Report, 0, len(chains))
+	for _, chain := range chains {
+		chainCfg, ok := cfg.Chains[chain]
+		if !ok {
+			panic(fmt.Errorf("chain %s is not in chains.toml", chain))
+		}
+
+		report, err := reconcileChain(mnemonic, chain, chainCfg, records, from, to)
+		if err != nil {
+			log.Errorf("Cannot reconcile chain %s, err = %s", chain, err)
+			continue
+		}
+		if report == nil {
+			continue
+		}
+
+		if report.UnknownOutflow > 0 {
+			alerter.Alert(chain, fmt.Sprintf("%d transactions of faucet %s are not in the ledger, the faucet key may have leaked",
+				report.UnknownOutflow, report.Address))
+		}
+		log.Infof("Chain %s: recorded = %s, spent = %s, matched = %d, mismatched = %d, failed = %d, unknown = %d, missing = %d",
+			chain, report.Recorded, report.Spent, report.Matched, report.Mismatched, report.Failed,
+			report.UnknownOutflow, report.MissingOnChain)
+		reports = append(reports, report)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if *format == "json" {
+		err = reconcile.WriteJSON(w, reports)
+	} else {
+		err = reconcile.WriteCSV(w, reports)
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+// reconcileChain returns the report of a chain, or nil if its family does not support
+// reconciliation.
+func reconcileChain(mnemonic, chain string, chainCfg ChainCfg, records *ledger.Ledger,
+	from, to time.Time) (*reconcile.Report, error) {
+	family, err := familyOf(chain, chainCfg)
+	if err != nil {
+		return nil, err
+	}
+	if family.NewScanner == nil || family.FaucetAddress == nil {
+		log.Warnf("Reconciliation is not supported for chain %s of family %s", chain, family.Name)
+		return nil, nil
+	}
+
+	address, err := family.FaucetAddress(mnemonic, chain, chainCfg)
+	if err != nil {
+		return nil, err
+	}
+	scanner, err := family.NewScanner(chain, chainCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	transfers, err := scanner.Outgoing(address, from, to)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := records.Entries(chain, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return reconcile.Reconcile(chain, address, from, to, entries, transfers), nil
+}
@@ -0,0 +1,45 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// WriteJSON writes the reports as a JSON array.
+func WriteJSON(w io.Writer, reports []*Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(reports)
+}
+
+// WriteCSV writes one row per transaction of the reports, followed by one total row per report.
+func WriteCSV(w io.Writer, reports []*Report) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"chain", "status", "time", "tx_hash", "to", "label", "recorded_amount",
+		"chain_amount", "fee"})
+	if err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		for _, item := range report.Items {
+			err := writer.Write([]string{report.Chain, item.Status, item.Time.UTC().Format(time.RFC3339),
+				item.TxHash, item.To, item.Label, item.RecordedAmount, item.ChainAmount, item.Fee})
+			if err != nil {
+				return err
+			}
+		}
+
+		err := writer.Write([]string{report.Chain, "total", report.To.UTC().Format(time.RFC3339), "",
+			report.Address, "", report.Recorded, report.Spent, report.Fees})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,185 @@
+package reconcile
+
+import (
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
+)
+
+// Transfer is a transaction sent by the faucet, as seen on chain.
+type Transfer struct {
+	TxHash string
+	From   string
+	To     string
+	// Amount and Fee are decimal strings in the smallest unit of the chain.
+	Amount string
+	Fee    string
+	Time   time.Time
+	// Failed is true for transactions that were included but reverted. They still paid the fee.
+	Failed bool
+}
+
+// Scanner lists the transactions sent by an address.
+type Scanner interface {
+	// Outgoing returns the transactions sent by address in [from, to).
+	Outgoing(address string, from, to time.Time) ([]Transfer, error)
+}
+
+// The status of a reconciled transaction.
+const (
+	StatusMatched = "matched"
+	// StatusAmountMismatch is a recorded transaction that moved another amount than recorded.
+	StatusAmountMismatch = "amount_mismatch"
+	// StatusFailed is a recorded transaction that was included but failed.
+	StatusFailed = "failed"
+	// StatusUnknownOutflow is a transaction of the faucet that the funder did not send, the faucet
+	// key may have leaked.
+	StatusUnknownOutflow = "unknown_outflow"
+	// StatusMissingOnChain is a recorded transaction that is not on chain. It was dropped or is
+	// included after the end of the period.
+	StatusMissingOnChain = "missing_on_chain"
+)
+
+// Item is a transaction of the report.
+type Item struct {
+	Status         string    `json:"status"`
+	TxHash         string    `json:"tx_hash"`
+	To             string    `json:"to"`
+	Label          string    `json:"label,omitempty"`
+	RecordedAmount string    `json:"recorded_amount,omitempty"`
+	ChainAmount    string    `json:"chain_amount,omitempty"`
+	Fee            string    `json:"fee,omitempty"`
+	Time           time.Time `json:"time"`
+}
+
+// Report is the reconciliation of the faucet of a chain over a period.
+type Report struct {
+	Chain   string    `json:"chain"`
+	Address string    `json:"address"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+
+	// Recorded is the total amount in the ledger. Spent is the total amount that left the faucet
+	// on chain, including the fees and the unknown outflows.
+	Recorded string `json:"recorded"`
+	Spent    string `json:"spent"`
+	Fees     string `json:"fees"`
+
+	Matched        int `json:"matched"`
+	Mismatched     int `json:"mismatched"`
+	Failed         int `json:"failed"`
+	UnknownOutflow int `json:"unknown_outflow"`
+	MissingOnChain int `json:"missing_on_chain"`
+
+	Items []Item `json:"items"`
+}
+
+// Ok returns true if every transaction of the period matches.
+func (r *Report) Ok() bool {
+	return r.Mismatched == 0 && r.Failed == 0 && r.UnknownOutflow == 0 && r.MissingOnChain == 0
+}
+
+// Reconcile matches the ledger entries of a chain with the transactions of its faucet by hash.
+func Reconcile(chain, address string, from, to time.Time, entries []ledger.Entry, transfers []Transfer) *Report {
+	report := &Report{
+		Chain:   chain,
+		Address: address,
+		From:    from,
+		To:      to,
+		Items:   make([]Item, 0, len(transfers)),
+	}
+
+	recorded := make(map[string]ledger.Entry)
+	recordedTotal := big.NewInt(0)
+	for _, entry := range entries {
+		recorded[normalizeHash(entry.TxHash)] = entry
+		recordedTotal.Add(recordedTotal, parseAmount(entry.Amount))
+	}
+
+	spent, fees := big.NewInt(0), big.NewInt(0)
+	seen := make(map[string]bool)
+	for _, transfer := range transfers {
+		hash := normalizeHash(transfer.TxHash)
+		seen[hash] = true
+
+		fee := parseAmount(transfer.Fee)
+		fees.Add(fees, fee)
+		spent.Add(spent, fee)
+		if !transfer.Failed {
+			spent.Add(spent, parseAmount(transfer.Amount))
+		}
+
+		item := Item{
+			TxHash:      transfer.TxHash,
+			To:          transfer.To,
+			ChainAmount: transfer.Amount,
+			Fee:         transfer.Fee,
+			Time:        transfer.Time,
+		}
+
+		entry, ok := recorded[hash]
+		switch {
+		case !ok:
+			item.Status = StatusUnknownOutflow
+			report.UnknownOutflow++
+		case transfer.Failed:
+			item.Status = StatusFailed
+			report.Failed++
+		case parseAmount(entry.Amount).Cmp(parseAmount(transfer.Amount)) != 0:
+			item.Status = StatusAmountMismatch
+			report.Mismatched++
+		default:
+			item.Status = StatusMatched
+			report.Matched++
+		}
+		if ok {
+			item.Label = entry.Label
+			item.RecordedAmount = entry.Amount
+		}
+
+		report.Items = append(report.Items, item)
+	}
+
+	for _, entry := range entries {
+		if seen[normalizeHash(entry.TxHash)] {
+			continue
+		}
+
+		report.MissingOnChain++
+		report.Items = append(report.Items, Item{
+			Status:         StatusMissingOnChain,
+			TxHash:         entry.TxHash,
+			To:             entry.To,
+			Label:          entry.Label,
+			RecordedAmount: entry.Amount,
+			Time:           entry.Time,
+		})
+	}
+
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		return report.Items[i].Time.Before(report.Items[j].Time)
+	})
+
+	report.Recorded = recordedTotal.String()
+	report.Spent = spent.String()
+	report.Fees = fees.String()
+
+	return report
+}
+
+func normalizeHash(hash string) string {
+	return strings.TrimPrefix(strings.ToLower(hash), "0x")
+}
+
+// parseAmount returns 0 for empty or invalid amounts, which then show up as mismatches.
+func parseAmount(s string) *big.Int {
+	amount, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return big.NewInt(0)
+	}
+
+	return amount
+}
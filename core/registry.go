@@ -0,0 +1,143 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/eth"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
+	"github.com/sisu-network/sisu-account-funding/core/reconcile"
+)
+
+// WatcherEnv is what a family needs to build the watcher of a chain.
+type WatcherEnv struct {
+	Mnemonic string
+	Chain    string
+	Cfg      ChainCfg
+	DataDir  string
+	// Pubkey is the MPC pubkey of the key type of the family.
+	Pubkey  []byte
+	Limiter *funding.Limiter
+	Ledger  *ledger.Ledger
+	// Nonces is shared by the watchers of every EVM chain.
+	Nonces  *eth.NonceManager
+	Outflow *funding.OutflowMonitor
+	Audit   *audit.Trail
+}
+
+// Family describes how to fund the chains of a chain family.
+type Family struct {
+	Name string
+	// KeyType is the type of the MPC key funded on the chains of the family.
+	KeyType string
+	// Detect returns true for the chains of the family. It is only used for chains that do not set
+	// their family in chains.toml.
+	Detect func(chain string, cfg ChainCfg) bool
+	// Validate checks the family specific config of a chain.
+	Validate func(chain string, cfg ChainCfg) error
+	// Address returns the funded address of the MPC pubkey on a chain.
+	Address func(chain string, cfg ChainCfg, pubkey []byte) (string, error)
+	// NewWatcher builds the watcher of a chain.
+	NewWatcher func(env *WatcherEnv) (Watcher, error)
+	// ExpectTimeout is how long a transfer of the family may take to show up in the faucet balance,
+	// 0 for the one of the outflow config.
+	ExpectTimeout time.Duration
+
+	// FaucetAddress and NewScanner are needed to reconcile the chains of the family. They are nil
+	// for families that do not support reconciliation yet.
+	FaucetAddress func(mnemonic string, chain string, cfg ChainCfg) (string, error)
+	NewScanner    func(chain string, cfg ChainCfg) (reconcile.Scanner, error)
+}
+
+var (
+	families = make(map[string]*Family)
+	// familyNames keeps the registration order so that detection is deterministic.
+	familyNames = make([]string, 0)
+)
+
+// RegisterFamily adds a chain family to the registry. It panics if the family is already
+// registered.
+func RegisterFamily(family *Family) {
+	if _, ok := families[family.Name]; ok {
+		panic(fmt.Errorf("family %s is already registered", family.Name))
+	}
+
+	families[family.Name] = family
+	familyNames = append(familyNames, family.Name)
+}
+
+// familyOf returns the family of a chain, either the one set in chains.toml or the single
+// registered family that detects it.
+func familyOf(chain string, cfg ChainCfg) (*Family, error) {
+	if cfg.Family != "" {
+		family, ok := families[cfg.Family]
+		if !ok {
+			return nil, fmt.Errorf("unknown family %q for chain %s, known families are %s", cfg.Family, chain,
+				strings.Join(familyNames, ", "))
+		}
+		return family, nil
+	}
+
+	matches := make([]string, 0)
+	for _, name := range familyNames {
+		if families[name].Detect(chain, cfg) {
+			matches = append(matches, name)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("unknown chain %s, set its family in chains.toml, known families are %s",
+			chain, strings.Join(familyNames, ", "))
+	case 1:
+		return families[matches[0]], nil
+	default:
+		sort.Strings(matches)
+		return nil, fmt.Errorf("chain %s matches the families %s, set its family in chains.toml", chain,
+			strings.Join(matches, ", "))
+	}
+}
+
+// newWatcher validates the config of a chain and builds its watcher.
+func newWatcher(env *WatcherEnv, pubkeys map[string][]byte) (Watcher, error) {
+	family, err := familyOf(env.Chain, env.Cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(env.Cfg.Rpcs) == 0 {
+		return nil, fmt.Errorf("no rpc for chain %s", env.Chain)
+	}
+	if err := env.Cfg.Schedule.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid schedule for chain %s: %w", env.Chain, err)
+	}
+	if err := env.Cfg.Approval.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid approval for chain %s: %w", env.Chain, err)
+	}
+	if err := family.Validate(env.Chain, env.Cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s config for chain %s: %w", family.Name, env.Chain, err)
+	}
+
+	env.Pubkey = pubkeys[family.KeyType]
+	if len(env.Pubkey) == 0 {
+		return nil, fmt.Errorf("no %s pubkey for chain %s", family.KeyType, env.Chain)
+	}
+
+	address, err := family.Address(env.Chain, env.Cfg, env.Pubkey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot derive the address for chain %s: %w", env.Chain, err)
+	}
+
+	watcher, err := family.NewWatcher(env)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create watcher for chain %s: %w", env.Chain, err)
+	}
+	log.Infof("Chain %s is funded as %s, address = %s", env.Chain, family.Name, address)
+
+	return watcher, nil
+}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sisu-network/lib/log"
+)
+
+// Reload reads chains.toml again and applies it without asking for the mnemonic: added chains are
+// started, removed ones stopped and changed ones rebuilt. Chains that did not change keep running.
+// If the new config is invalid, an error is returned and nothing changes.
+func (f *Funder) Reload() error {
+	cfg, err := readChainConfig(f.cfgPath)
+	if err != nil {
+		return fmt.Errorf("cannot read %s: %w", f.cfgPath, err)
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.watchers == nil {
+		return fmt.Errorf("the watchers are not running")
+	}
+
+	old := f.cfg
+	if fields := restartFields(old, cfg); len(fields) > 0 {
+		return fmt.Errorf("%s cannot change without a restart", strings.Join(fields, ", "))
+	}
+
+	// A change of the outflow detection rebuilds every chain, their monitors are built with it.
+	outflowChanged := !reflect.DeepEqual(old.Outflow, cfg.Outflow)
+	added, removed, changed := make([]string, 0), make([]string, 0), make([]string, 0)
+	for chain, chainCfg := range cfg.Chains {
+		oldCfg, ok := old.Chains[chain]
+		switch {
+		case !ok:
+			added = append(added, chain)
+		case outflowChanged || !reflect.DeepEqual(oldCfg, chainCfg):
+			changed = append(changed, chain)
+		}
+	}
+	for chain := range old.Chains {
+		if _, ok := cfg.Chains[chain]; !ok {
+			removed = append(removed, chain)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+
+	// Build the new watchers before touching the running ones, so that an invalid chain config
+	// leaves everything as it was.
+	built := make(map[string]Watcher)
+	for _, chain := range append(append([]string{}, added...), changed...) {
+		watcher, err := f.newChainWatcher(chain, cfg.Chains[chain], cfg.Outflow)
+		if err != nil {
+			return err
+		}
+		built[chain] = watcher
+	}
+	if err := f.limiter.SetLimits(cfg.Limits, chainLimits(cfg)); err != nil {
+		return err
+	}
+	if err := f.scheduler.SetSchedules(chainSchedules(cfg)); err != nil {
+		return err
+	}
+	if err := f.approvals.SetRules(chainApprovals(cfg)); err != nil {
+		return err
+	}
+
+	for _, chain := range removed {
+		f.watchers[chain].Stop()
+		delete(f.watchers, chain)
+		log.Infof("Reload: chain %s removed", chain)
+	}
+	// Stop returns once the old watcher has exited, so that it never runs next to its replacement.
+	for _, chain := range changed {
+		f.watchers[chain].Stop()
+		f.watchers[chain] = built[chain]
+		built[chain].Start()
+
+		fields := chainCfgDiff(old.Chains[chain], cfg.Chains[chain])
+		if outflowChanged {
+			fields = append(fields, "outflow")
+		}
+		log.Infof("Reload: chain %s changed: %s", chain, strings.Join(fields, ", "))
+	}
+	for _, chain := range added {
+		f.watchers[chain] = built[chain]
+		built[chain].Start()
+		log.Infof("Reload: chain %s added", chain)
+	}
+	if !reflect.DeepEqual(old.Limits, cfg.Limits) {
+		log.Infof("Reload: global limits changed")
+	}
+	if len(added)+len(removed)+len(changed) == 0 {
+		log.Infof("Reload: no chain changed")
+	}
+
+	f.cfg = cfg
+
+	return nil
+}
+
+// restartFields returns the settings that differ between two configs and that are only applied
+// at startup.
+func restartFields(old, cfg *ChainsCfg) []string {
+	fields := make([]string, 0)
+	if old.DataDir != cfg.DataDir {
+		fields = append(fields, "data_dir")
+	}
+	if old.AlertWebhook != cfg.AlertWebhook {
+		fields = append(fields, "alert_webhook")
+	}
+	if old.ConfigWatch != cfg.ConfigWatch {
+		fields = append(fields, "config_watch")
+	}
+	if !reflect.DeepEqual(old.Leader, cfg.Leader) {
+		fields = append(fields, "leader")
+	}
+	if !reflect.DeepEqual(old.Admin, cfg.Admin) {
+		fields = append(fields, "admin")
+	}
+	if !reflect.DeepEqual(old.Approvals, cfg.Approvals) {
+		fields = append(fields, "approvals")
+	}
+	if old.Audit != cfg.Audit {
+		fields = append(fields, "audit")
+	}
+
+	return fields
+}
+
+// chainCfgDiff returns the toml names of the sections of a chain config that differ.
+func chainCfgDiff(old, cfg ChainCfg) []string {
+	fields := make([]string, 0)
+	oldValue, value := reflect.ValueOf(old), reflect.ValueOf(cfg)
+	for i := 0; i < value.NumField(); i++ {
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), value.Field(i).Interface()) {
+			fields = append(fields, value.Type().Field(i).Tag.Get("toml"))
+		}
+	}
+
+	return fields
+}
+
+// watchConfig reloads chains.toml whenever its modification time changes.
+func (f *Funder) watchConfig(interval time.Duration) {
+	modTime := time.Time{}
+	if info, err := os.Stat(f.cfgPath); err == nil {
+		modTime = info.ModTime()
+	}
+
+	for {
+		time.Sleep(interval)
+		if f.stop.Load() {
+			return
+		}
+
+		info, err := os.Stat(f.cfgPath)
+		if err != nil {
+			log.Errorf("Cannot stat %s, err = %s", f.cfgPath, err)
+			continue
+		}
+		if info.ModTime().Equal(modTime) {
+			continue
+		}
+		modTime = info.ModTime()
+
+		log.Infof("%s changed, reloading it", f.cfgPath)
+		if err := f.Reload(); err != nil {
+			log.Errorf("Failed to reload %s, keeping the current config, err = %s", f.cfgPath, err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sisu-network/sisu-account-funding/core/alert"
+)
+
+const testFamily = "test"
+
+// testEvents records the starts and exits of the watchers of the test family.
+var testEvents = &watcherEvents{running: make(map[string]int)}
+
+func init() {
+	RegisterFamily(&Family{
+		Name:    testFamily,
+		KeyType: testFamily,
+		Detect: func(chain string, cfg ChainCfg) bool {
+			return false
+		},
+		Validate: func(chain string, cfg ChainCfg) error {
+			return nil
+		},
+		Address: func(chain string, cfg ChainCfg, pubkey []byte) (string, error) {
+			return chain, nil
+		},
+		NewWatcher: func(env *WatcherEnv) (Watcher, error) {
+			return &eventWatcher{name: env.Chain + " " + env.Cfg.Rpcs[0], chain: env.Chain,
+				events: testEvents, stop: make(chan struct{})}, nil
+		},
+	})
+}
+
+type watcherEvents struct {
+	lock    sync.Mutex
+	running map[string]int
+	events  []string
+	// overlaps are the watchers started while another watcher of their chain was running.
+	overlaps []string
+}
+
+func (e *watcherEvents) record(chain, event string, delta int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.running[chain] += delta
+	if e.running[chain] > 1 {
+		e.overlaps = append(e.overlaps, event)
+	}
+	e.events = append(e.events, event)
+}
+
+func (e *watcherEvents) reset() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.running = make(map[string]int)
+	e.events = nil
+	e.overlaps = nil
+}
+
+// eventWatcher is a watcher whose cycle takes a while to finish once it is stopped.
+type eventWatcher struct {
+	name   string
+	chain  string
+	events *watcherEvents
+	stop   chan struct{}
+	done   sync.WaitGroup
+}
+
+func (w *eventWatcher) Start() {
+	w.events.record(w.chain, "start "+w.name, 1)
+	w.done.Add(1)
+	go func() {
+		defer w.done.Done()
+		<-w.stop
+		time.Sleep(20 * time.Millisecond)
+		w.events.record(w.chain, "exit "+w.name, -1)
+	}()
+}
+
+func (w *eventWatcher) Stop() {
+	close(w.stop)
+	w.done.Wait()
+}
+
+func writeTestChains(t *testing.T, cfgPath, dataDir string, chains map[string]string) {
+	t.Helper()
+
+	bz := fmt.Sprintf("data_dir = %q\n", dataDir)
+	for chain, rpc := range chains {
+		bz += fmt.Sprintf("[chains.%s]\nfamily = %q\nrpcs = [%q]\n", chain, testFamily, rpc)
+	}
+	if err := os.WriteFile(cfgPath, []byte(bz), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadReplacesWatchers(t *testing.T) {
+	initial := map[string]string{"chaina": "http://a1", "chainb": "http://b1"}
+
+	tests := []struct {
+		name   string
+		chains map[string]string
+		events []string
+	}{
+		{
+			name:   "changed chain",
+			chains: map[string]string{"chaina": "http://a2", "chainb": "http://b1"},
+			events: []string{"exit chaina http://a1", "start chaina http://a2"},
+		},
+		{
+			name:   "removed chain",
+			chains: map[string]string{"chaina": "http://a1"},
+			events: []string{"exit chainb http://b1"},
+		},
+		{
+			name:   "added chain",
+			chains: map[string]string{"chaina": "http://a1", "chainb": "http://b1", "chainc": "http://c1"},
+			events: []string{"start chainc http://c1"},
+		},
+		{name: "unchanged", chains: initial, events: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			cfgPath := filepath.Join(dataDir, "chains.toml")
+			writeTestChains(t, cfgPath, dataDir, initial)
+			cfg, err := readChainConfig(cfgPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := &Funder{
+				cfgPath: cfgPath,
+				cfg:     cfg,
+				alerter: alert.NewAlerter(""),
+				pubkeys: map[string][]byte{testFamily: {1}},
+			}
+			if err := f.start(true); err != nil {
+				t.Fatal(err)
+			}
+			defer f.Stop()
+			testEvents.reset()
+
+			writeTestChains(t, cfgPath, dataDir, tt.chains)
+			if err := f.Reload(); err != nil {
+				t.Fatal(err)
+			}
+
+			testEvents.lock.Lock()
+			events, overlaps := append([]string{}, testEvents.events...), testEvents.overlaps
+			testEvents.lock.Unlock()
+			if len(overlaps) > 0 {
+				t.Fatalf("started while the previous watcher was running: %s", strings.Join(overlaps, ", "))
+			}
+			if !reflect.DeepEqual(events, tt.events) {
+				t.Fatalf("got events %v, want %v", events, tt.events)
+			}
+		})
+	}
+}
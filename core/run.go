@@ -5,31 +5,49 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/BurntSushi/toml"
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	ethcrypto "github.com/ethereum/go-ethereum/crypto"
 	libchain "github.com/sisu-network/lib/chain"
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/admin"
+	"github.com/sisu-network/sisu-account-funding/core/alert"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
 	"github.com/sisu-network/sisu-account-funding/core/eth"
-	"github.com/sisu-network/sisu-account-funding/core/lisk"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/leader"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
 	"github.com/sisu-network/sisu-account-funding/core/types"
+	"go.uber.org/atomic"
 	"golang.org/x/term"
 	"google.golang.org/grpc"
 )
 
 func loadChainConfig(filePath string) *ChainsCfg {
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	cfg, err := readChainConfig(filePath)
+	if err != nil {
 		panic(err)
 	}
 
+	return cfg
+}
+
+func readChainConfig(filePath string) (*ChainsCfg, error) {
 	cfg := new(ChainsCfg)
-	_, err := toml.DecodeFile(filePath, &cfg)
-	if err != nil {
-		panic(err)
+	if _, err := toml.DecodeFile(filePath, &cfg); err != nil {
+		return nil, err
 	}
 
-	return cfg
+	if cfg.DataDir == "" {
+		cfg.DataDir = "data"
+	}
+
+	return cfg, nil
 }
 
 func loadVaults(filePath string) ([]*Vault, error) {
@@ -77,6 +95,72 @@ func getEthAccount(pubkeys map[string][]byte) ethcommon.Address {
 	return ethcrypto.PubkeyToAddress(*pubKey)
 }
 
+// newEthTargets returns the MPC account followed by the extra targets configured for the chain.
+func newEthTargets(dataDir string, chain string, chainCfg ChainCfg, mpcAddr ethcommon.Address) ([]*eth.Target, error) {
+	targetCfgs := append([]eth.TargetCfg{{
+		Address: mpcAddr.String(),
+		Label:   "mpc",
+	}}, chainCfg.Targets...)
+
+	chainPolicy := chainCfg.Funding.WithDefaults(eth.DefaultPolicy)
+
+	targets := make([]*eth.Target, 0, len(targetCfgs))
+	for _, targetCfg := range targetCfgs {
+		if !ethcommon.IsHexAddress(targetCfg.Address) {
+			return nil, fmt.Errorf("invalid target address %q on chain %s", targetCfg.Address, chain)
+		}
+		addr := ethcommon.HexToAddress(targetCfg.Address)
+		label := targetCfg.Label
+		if label == "" {
+			label = addr.String()
+		}
+
+		policy, err := funding.NewPolicy(targetCfg.PolicyCfg, chainPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid funding policy for %s on chain %s: %w", label, chain, err)
+		}
+
+		gas, err := eth.NewGasPolicy(chainCfg.Gas, policy.Threshold())
+		if err != nil {
+			return nil, fmt.Errorf("invalid gas config for chain %s: %w", chain, err)
+		}
+
+		burnFile := filepath.Join(dataDir, fmt.Sprintf("burn_%s_%s.json", chain, strings.ToLower(addr.Hex())))
+		if len(targets) == 0 {
+			// The history of the MPC account used to be kept per chain only.
+			if err := migrateFile(filepath.Join(dataDir, fmt.Sprintf("burn_%s.json", chain)), burnFile); err != nil {
+				return nil, fmt.Errorf("cannot migrate the balance history of chain %s: %w", chain, err)
+			}
+		}
+		targets = append(targets, &eth.Target{
+			Label:   label,
+			Address: addr,
+			Policy:  policy,
+			Burn:    funding.NewBurnTracker(burnFile, policy.BurnWindow()),
+			Gas:     gas,
+		})
+	}
+
+	return targets, nil
+}
+
+// migrateFile renames the file oldPath to newPath, unless newPath already exists or there is no
+// file at oldPath.
+func migrateFile(oldPath, newPath string) error {
+	if _, err := os.Stat(newPath); !os.IsNotExist(err) {
+		return err
+	}
+	if _, err := os.Stat(oldPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	log.Infof("Moving %s to %s", oldPath, newPath)
+	return os.Rename(oldPath, newPath)
+}
+
 func readMnemonic() string {
 	fmt.Print("Enter mnemonic: ")
 
@@ -88,27 +172,264 @@ func readMnemonic() string {
 	return string(bytePassword)
 }
 
-func Run() {
+// Funder runs the watchers of all the chains. When leader election is enabled, it runs them as a
+// standby that only monitors until this instance is elected, and rebuilds them from the state
+// handed over by the previous leader whenever its role changes.
+type Funder struct {
+	lock     sync.Mutex
+	mnemonic string
+	pubkeys  map[string][]byte
+	cfgPath  string
+	cfg      *ChainsCfg
+	alerter  alert.Alerter
+	audit    *audit.Log
+	elector  *leader.Elector
+	admin    *admin.Server
+	stop     atomic.Bool
+
+	// leading, limiter, scheduler, approvals, nonces, transfers and watchers are rebuilt by start.
+	leading   bool
+	limiter   *funding.Limiter
+	scheduler *funding.Scheduler
+	approvals *funding.Approvals
+	nonces    *eth.NonceManager
+	transfers *ledger.Ledger
+	watchers  map[string]Watcher
+}
+
+func Run() *Funder {
 	mnemonic := readMnemonic()
-	cfg := loadChainConfig("chains.toml")
+	cfgPath := "chains.toml"
+	cfg := loadChainConfig(cfgPath)
 	pubkeys := getPubkeys("0.0.0.0:9090")
 
+	f := &Funder{
+		mnemonic: mnemonic,
+		pubkeys:  pubkeys,
+		cfgPath:  cfgPath,
+		cfg:      cfg,
+		alerter:  alert.NewAlerter(cfg.AlertWebhook),
+		audit:    audit.NewLog(cfg.Audit, cfg.DataDir),
+	}
+
+	if !cfg.Leader.Enabled() {
+		if err := f.start(true); err != nil {
+			panic(err)
+		}
+	} else {
+		leaderCfg := cfg.Leader.WithDefaults()
+		backend, err := leader.NewBackend(leaderCfg)
+		if err != nil {
+			panic(err)
+		}
+		f.elector = leader.NewElector(backend, leaderCfg.Id, leaderCfg.Ttl, f.snapshot, f.onElected,
+			f.onDemoted)
+
+		// Standbys build the same watchers as the leader, so a bad chain config fails the startup of
+		// every instance.
+		if err := f.start(false); err != nil {
+			panic(err)
+		}
+		f.elector.Start()
+	}
+
+	if cfg.Admin.Listen != "" {
+		f.admin = admin.NewServer(cfg.Admin)
+		f.registerAdmin()
+		f.admin.Start()
+	}
+
+	if cfg.ConfigWatch > 0 {
+		go f.watchConfig(cfg.ConfigWatch)
+	}
+
+	return f
+}
+
+// Stop stops the watchers and hands over to a standby if this instance is the leader.
+func (f *Funder) Stop() {
+	f.stop.Store(true)
+	if f.admin != nil {
+		f.admin.Stop()
+	}
+	if f.elector != nil {
+		f.elector.Stop()
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.stopWatchers()
+}
+
+// start builds the watchers of every chain from the state in the data dir and starts them.
+func (f *Funder) start(leading bool) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	cfg := f.cfg
+	limiter, err := funding.NewLimiter(filepath.Join(cfg.DataDir, "limiter.json"), cfg.Limits,
+		chainLimits(cfg), f.alerter)
+	if err != nil {
+		return err
+	}
+	if f.elector != nil {
+		// The watchers of a standby never send, even once the elector made this instance the leader
+		// and until onElected restarts them on the state handed over.
+		limiter.AddGate(funding.GateFunc(func(chain string) error {
+			if !leading {
+				return leader.ErrNotLeader
+			}
+			return f.elector.Allow()
+		}))
+	}
+	scheduler, err := funding.NewScheduler(filepath.Join(cfg.DataDir, "maintenance.json"),
+		chainSchedules(cfg), f.alerter)
+	if err != nil {
+		return err
+	}
+	limiter.AddGate(scheduler)
+	approvals, err := funding.NewApprovals(filepath.Join(cfg.DataDir, "approvals.json"), cfg.Approvals,
+		chainApprovals(cfg), f.alerter)
+	if err != nil {
+		return err
+	}
+	limiter.SetApprovals(approvals)
+	nonces, err := eth.NewNonceManager(filepath.Join(cfg.DataDir, "nonces.json"))
+	if err != nil {
+		return err
+	}
+
+	f.leading = leading
+	f.limiter = limiter
+	f.scheduler = scheduler
+	f.approvals = approvals
+	f.nonces = nonces
+	f.transfers = ledger.NewLedger(filepath.Join(cfg.DataDir, ledgerFile))
+
+	// Build every watcher before starting any, so that a bad chain config fails without funding
+	// anything.
+	watchers := make(map[string]Watcher)
 	for chain, chainCfg := range cfg.Chains {
-		if libchain.IsETHBasedChain(chain) {
-			// 04cbf8c5562928f81495a55c12f89836cf744c1adcc43c45c33e97571603b979bcead13fb7f33e7cc01d2a632337725b84942d3998c933b08e5a34be8df7794e05
-			// is the test ecdsa pubkey. Use hex.DecodeString to get its bytes
-			sisuAccount := getEthAccount(pubkeys)
-			watcher := eth.NewWatcher(mnemonic, chain, chainCfg.Rpcs, sisuAccount.String())
-			watcher.Start()
+		watcher, err := f.newChainWatcher(chain, chainCfg, cfg.Outflow)
+		if err != nil {
+			return err
 		}
+		watchers[chain] = watcher
+	}
+
+	for _, watcher := range watchers {
+		watcher.Start()
+	}
+	f.watchers = watchers
+
+	return nil
+}
+
+// newChainWatcher builds the watcher of a chain with the shared state built by start. The caller
+// must hold the lock.
+func (f *Funder) newChainWatcher(chain string, chainCfg ChainCfg, outflowCfg funding.OutflowCfg) (Watcher, error) {
+	// The transfers of the leader would look like unexpected outflows to a standby.
+	if !f.leading {
+		outflowCfg.Disabled = true
+	}
+	if chainCfg.ExpectTimeout > 0 {
+		outflowCfg.ExpectTimeout = chainCfg.ExpectTimeout
+	} else if family, err := familyOf(chain, chainCfg); err == nil && family.ExpectTimeout > 0 {
+		outflowCfg.ExpectTimeout = family.ExpectTimeout
+	}
+	rpc := ""
+	if len(chainCfg.Rpcs) > 0 {
+		rpc = chainCfg.Rpcs[0]
+	}
+
+	return newWatcher(&WatcherEnv{
+		Mnemonic: f.mnemonic,
+		Chain:    chain,
+		Cfg:      chainCfg,
+		DataDir:  f.cfg.DataDir,
+		Limiter:  f.limiter,
+		Ledger:   f.transfers,
+		Nonces:   f.nonces,
+		Outflow:  funding.NewOutflowMonitor(chain, outflowCfg, f.limiter, f.alerter),
+		Audit:    f.audit.Trail(chain, rpc),
+	}, f.pubkeys)
+}
+
+func chainLimits(cfg *ChainsCfg) map[string]funding.LimitCfg {
+	limits := make(map[string]funding.LimitCfg)
+	for chain, chainCfg := range cfg.Chains {
+		limits[chain] = chainCfg.Limits
+	}
+
+	return limits
+}
+
+func chainSchedules(cfg *ChainsCfg) map[string]funding.ScheduleCfg {
+	schedules := make(map[string]funding.ScheduleCfg)
+	for chain, chainCfg := range cfg.Chains {
+		schedules[chain] = chainCfg.Schedule
+	}
+
+	return schedules
+}
+
+func chainApprovals(cfg *ChainsCfg) map[string]funding.ApprovalCfg {
+	approvals := make(map[string]funding.ApprovalCfg)
+	for chain, chainCfg := range cfg.Chains {
+		approvals[chain] = chainCfg.Approval
+	}
+
+	return approvals
+}
 
-		// Use 7cbb424e0dffad3104e29c6febe3abd899b2d2b972475dabd9fbe6b62f9af2ff as hex of sample test
-		// eddsa pubkey. Use hex.DecodeString to get its bytes
-		if libchain.IsLiskChain(chain) {
-			// edPubkey, _ := hex.DecodeString("7cbb424e0dffad3104e29c6febe3abd899b2d2b972475dabd9fbe6b62f9af2ff")
-			edPubkey := pubkeys[libchain.KEY_TYPE_EDDSA]
-			watcher := lisk.NewWatcher(mnemonic, chain, chainCfg.Rpcs[0], edPubkey)
-			watcher.Start()
+// stopWatchers stops the running watchers and waits for them to exit, start rebuilds the state
+// they use. The caller must hold the lock.
+func (f *Funder) stopWatchers() {
+	var wg sync.WaitGroup
+	for _, watcher := range f.watchers {
+		wg.Add(1)
+		go func(watcher Watcher) {
+			defer wg.Done()
+			watcher.Stop()
+		}(watcher)
+	}
+	wg.Wait()
+	f.watchers = nil
+}
+
+// snapshot returns the state handed over to the next leader.
+func (f *Funder) snapshot() ([]byte, error) {
+	return snapshotState(f.cfg.DataDir, f.cfg.Leader.Path)
+}
+
+// onElected replaces the state of the standby with the one of the previous leader and restarts
+// the watchers as the leader.
+func (f *Funder) onElected(state []byte) error {
+	f.lock.Lock()
+	f.stopWatchers()
+	err := restoreState(f.cfg.DataDir, state, f.cfg.Leader.Path)
+	f.lock.Unlock()
+
+	if err == nil {
+		err = f.start(true)
+	}
+	if err != nil {
+		if err := f.start(false); err != nil {
+			log.Errorf("Failed to restart the watchers as a standby, err = %s", err)
 		}
+		return err
+	}
+
+	return nil
+}
+
+func (f *Funder) onDemoted() {
+	f.lock.Lock()
+	f.stopWatchers()
+	f.lock.Unlock()
+
+	if err := f.start(false); err != nil {
+		log.Errorf("Failed to restart the watchers as a standby, err = %s", err)
 	}
 }
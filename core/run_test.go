@@ -0,0 +1,115 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/sisu-network/sisu-account-funding/core/eth"
+)
+
+func TestNewEthTargetsMigratesBurnFile(t *testing.T) {
+	mpc := ethcommon.HexToAddress("0x8a3B1c1A7d5d1C8bB2e4D8E2D9E0B3e8A1f4c2D1")
+	relayer := ethcommon.HexToAddress("0x1111111111111111111111111111111111111111")
+	chainCfg := ChainCfg{Targets: []eth.TargetCfg{{Address: relayer.String(), Label: "relayer"}}}
+	legacy := `[{"time":"2022-01-01T00:00:00Z","balance":"1000","top_up":false}]`
+
+	tests := []struct {
+		name string
+		// files are written to the data dir before the targets are built.
+		files map[string]string
+		// want are the contents of the data dir after.
+		want map[string]string
+	}{
+		{
+			name:  "legacy file is moved to the mpc target",
+			files: map[string]string{"burn_ganache1.json": legacy},
+			want:  map[string]string{burnName("ganache1", mpc): legacy},
+		},
+		{
+			name: "existing file is kept",
+			files: map[string]string{
+				"burn_ganache1.json":      legacy,
+				burnName("ganache1", mpc): "[]",
+			},
+			want: map[string]string{
+				"burn_ganache1.json":      legacy,
+				burnName("ganache1", mpc): "[]",
+			},
+		},
+		{
+			name:  "other chains are left alone",
+			files: map[string]string{"burn_ganache2.json": legacy},
+			want:  map[string]string{"burn_ganache2.json": legacy},
+		},
+		{name: "no history", want: map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dataDir, name), []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			targets, err := newEthTargets(dataDir, "ganache1", chainCfg, mpc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(targets) != 2 || targets[0].Label != "mpc" || targets[1].Label != "relayer" {
+				t.Fatalf("unexpected targets %v", targets)
+			}
+
+			entries, err := os.ReadDir(dataDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string)
+			for _, entry := range entries {
+				bz, err := os.ReadFile(filepath.Join(dataDir, entry.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got[entry.Name()] = string(bz)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got files %v, want %v", got, tt.want)
+			}
+			for name, content := range tt.want {
+				if got[name] != content {
+					t.Errorf("file %s: got %q, want %q", name, got[name], content)
+				}
+			}
+		})
+	}
+}
+
+func burnName(chain string, addr ethcommon.Address) string {
+	return "burn_" + chain + "_" + strings.ToLower(addr.Hex()) + ".json"
+}
+
+func TestStopWatchers(t *testing.T) {
+	events := &watcherEvents{running: make(map[string]int)}
+	watchers := make(map[string]Watcher)
+	for _, chain := range []string{"chaina", "chainb", "chainc"} {
+		w := &eventWatcher{name: chain, chain: chain, events: events, stop: make(chan struct{})}
+		w.Start()
+		watchers[chain] = w
+	}
+
+	f := &Funder{watchers: watchers}
+	f.stopWatchers()
+
+	for chain, running := range events.running {
+		if running != 0 {
+			t.Errorf("watcher of %s is still running", chain)
+		}
+	}
+	if f.watchers != nil {
+		t.Fatal("the stopped watchers are kept")
+	}
+}
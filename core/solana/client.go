@@ -0,0 +1,157 @@
+package solana
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Client is the part of the Solana JSON-RPC api used by the watcher. It lets the watcher run
+// against a validator, solana-test-validator or a mock in tests.
+type Client interface {
+	GetBalance(address string) (uint64, error)
+	GetMinimumBalanceForRentExemption(dataLength uint64) (uint64, error)
+	GetLatestBlockhash() ([]byte, error)
+	GetFeeForMessage(message []byte) (uint64, error)
+	SendTransaction(tx []byte) (string, error)
+	// GetSignatureStatus returns the confirmation status of a transaction, or "" if unknown.
+	GetSignatureStatus(signature string) (string, error)
+}
+
+type rpcClient struct {
+	url        string
+	commitment string
+	client     *http.Client
+}
+
+func NewClient(url string, cfg Cfg) Client {
+	return &rpcClient{
+		url:        url,
+		commitment: cfg.withDefaults().Commitment,
+		client:     &http.Client{Timeout: time.Second * 30},
+	}
+}
+
+func (c *rpcClient) call(method string, params []interface{}, result interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := c.client.Post(c.url, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	bz, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	rpcRes := &struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}{}
+	if err := json.Unmarshal(bz, rpcRes); err != nil {
+		return fmt.Errorf("%s failed with status %d", method, res.StatusCode)
+	}
+	if rpcRes.Error != nil {
+		return fmt.Errorf("%s failed, code = %d, message = %s", method, rpcRes.Error.Code, rpcRes.Error.Message)
+	}
+
+	return json.Unmarshal(rpcRes.Result, result)
+}
+
+func (c *rpcClient) config() map[string]string {
+	return map[string]string{"commitment": c.commitment}
+}
+
+func (c *rpcClient) GetBalance(address string) (uint64, error) {
+	res := &struct {
+		Value uint64 `json:"value"`
+	}{}
+	err := c.call("getBalance", []interface{}{address, c.config()}, res)
+
+	return res.Value, err
+}
+
+func (c *rpcClient) GetMinimumBalanceForRentExemption(dataLength uint64) (uint64, error) {
+	var res uint64
+	err := c.call("getMinimumBalanceForRentExemption", []interface{}{dataLength, c.config()}, &res)
+
+	return res, err
+}
+
+func (c *rpcClient) GetLatestBlockhash() ([]byte, error) {
+	res := &struct {
+		Value struct {
+			Blockhash string `json:"blockhash"`
+		} `json:"value"`
+	}{}
+	if err := c.call("getLatestBlockhash", []interface{}{c.config()}, res); err != nil {
+		return nil, err
+	}
+
+	return decodeBase58(res.Value.Blockhash, 32)
+}
+
+func (c *rpcClient) GetFeeForMessage(message []byte) (uint64, error) {
+	res := &struct {
+		Value *uint64 `json:"value"`
+	}{}
+	err := c.call("getFeeForMessage", []interface{}{base64.StdEncoding.EncodeToString(message), c.config()}, res)
+	if err != nil {
+		return 0, err
+	}
+	if res.Value == nil {
+		return 0, fmt.Errorf("blockhash of the message expired")
+	}
+
+	return *res.Value, nil
+}
+
+func (c *rpcClient) SendTransaction(tx []byte) (string, error) {
+	var signature string
+	err := c.call("sendTransaction", []interface{}{
+		base64.StdEncoding.EncodeToString(tx),
+		map[string]string{"encoding": "base64", "preflightCommitment": c.commitment},
+	}, &signature)
+
+	return signature, err
+}
+
+func (c *rpcClient) GetSignatureStatus(signature string) (string, error) {
+	res := &struct {
+		Value []*struct {
+			ConfirmationStatus string          `json:"confirmationStatus"`
+			Err                json.RawMessage `json:"err"`
+		} `json:"value"`
+	}{}
+	err := c.call("getSignatureStatuses", []interface{}{[]string{signature},
+		map[string]bool{"searchTransactionHistory": true}}, res)
+	if err != nil {
+		return "", err
+	}
+
+	if len(res.Value) == 0 || res.Value[0] == nil {
+		return "", nil
+	}
+	if len(res.Value[0].Err) > 0 && string(res.Value[0].Err) != "null" {
+		return "", fmt.Errorf("transaction %s failed: %s", signature, string(res.Value[0].Err))
+	}
+
+	return res.Value[0].ConfirmationStatus, nil
+}
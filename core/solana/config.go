@@ -0,0 +1,40 @@
+package solana
+
+import "fmt"
+
+const (
+	DefaultCommitment = "confirmed"
+	// DefaultFee is the fee of a transaction with one signature, used when the node cannot price
+	// the transaction.
+	DefaultFee = uint64(5000)
+)
+
+// commitments ranks the commitment levels, a transaction at a level also satisfies the lower ones.
+var commitments = map[string]int{
+	"processed": 1,
+	"confirmed": 2,
+	"finalized": 3,
+}
+
+// Cfg holds the Solana specific settings of a chain.
+type Cfg struct {
+	// Commitment is the commitment level used to read balances and confirm transfers.
+	Commitment string `toml:"commitment" json:"commitment"`
+}
+
+func (cfg Cfg) withDefaults() Cfg {
+	if cfg.Commitment == "" {
+		cfg.Commitment = DefaultCommitment
+	}
+
+	return cfg
+}
+
+func (cfg Cfg) Validate() error {
+	cfg = cfg.withDefaults()
+	if commitments[cfg.Commitment] == 0 {
+		return fmt.Errorf("unknown commitment %q", cfg.Commitment)
+	}
+
+	return nil
+}
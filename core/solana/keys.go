@@ -0,0 +1,44 @@
+package solana
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/cosmos/go-bip39"
+)
+
+// GetAddress returns the Solana address of an ed25519 public key, which is the base58 encoding of
+// the key itself.
+func GetAddress(pubkey []byte) string {
+	return base58.Encode(pubkey)
+}
+
+// getPrivateKey derives the faucet key from the mnemonic with the m/44'/501'/0'/0' path used by the
+// Solana wallets. ed25519 only supports hardened derivation (SLIP-0010).
+func getPrivateKey(mnemonic string) (ed25519.PrivateKey, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha512.New, []byte("ed25519 seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := sum[:32], sum[32:]
+
+	for _, index := range []uint32{44, 501, 0, 0} {
+		data := make([]byte, 37)
+		copy(data[1:33], key)
+		binary.BigEndian.PutUint32(data[33:], index|0x80000000)
+
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+		key, chainCode = sum[:32], sum[32:]
+	}
+
+	return ed25519.NewKeyFromSeed(key), nil
+}
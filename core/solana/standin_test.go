@@ -0,0 +1,243 @@
+package solana
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+)
+
+// rentExemptMinimum is the rent exemption minimum of an account without data on mainnet.
+const rentExemptMinimum = uint64(890_880)
+
+// standIn is a Solana JSON-RPC endpoint with in-memory balances. It verifies the signature of the
+// system transfers sent to it and applies them at once, and refuses a transfer that creates an
+// account below the rent exemption minimum or leaves the sender below it.
+type standIn struct {
+	lock      sync.Mutex
+	balances  map[string]uint64
+	blockhash []byte
+	fee       uint64
+	// status is the commitment reached by the transfers.
+	status string
+	// transfers are the transfers applied, by signature.
+	transfers map[string]standInTransfer
+}
+
+type standInTransfer struct {
+	From     string
+	To       string
+	Lamports uint64
+}
+
+func newStandIn(t *testing.T) (*standIn, string) {
+	s := &standIn{
+		balances:  make(map[string]uint64),
+		blockhash: make([]byte, 32),
+		fee:       DefaultFee,
+		status:    "finalized",
+		transfers: make(map[string]standInTransfer),
+	}
+	s.blockhash[0] = 7
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+
+	return s, server.URL
+}
+
+func (s *standIn) setBalance(address string, lamports uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.balances[address] = lamports
+}
+
+func (s *standIn) setStatus(status string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.status = status
+}
+
+func (s *standIn) balance(address string) uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.balances[address]
+}
+
+func (s *standIn) sent() []standInTransfer {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sent := make([]standInTransfer, 0, len(s.transfers))
+	for _, transfer := range s.transfers {
+		sent = append(sent, transfer)
+	}
+
+	return sent
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result interface{}
+	var err error
+	switch req.Method {
+	case "getBalance":
+		address := ""
+		json.Unmarshal(req.Params[0], &address)
+		result = map[string]uint64{"value": s.balances[address]}
+
+	case "getMinimumBalanceForRentExemption":
+		dataLength := uint64(0)
+		json.Unmarshal(req.Params[0], &dataLength)
+		// 128 bytes of account metadata plus the data, at 6960 lamports per byte.
+		result = (128 + dataLength) * 6960
+
+	case "getLatestBlockhash":
+		result = map[string]interface{}{"value": map[string]string{"blockhash": base58.Encode(s.blockhash)}}
+
+	case "getFeeForMessage":
+		result = map[string]uint64{"value": s.fee}
+
+	case "sendTransaction":
+		encoded := ""
+		json.Unmarshal(req.Params[0], &encoded)
+		result, err = s.send(encoded)
+
+	case "getSignatureStatuses":
+		signatures := make([]string, 0)
+		json.Unmarshal(req.Params[0], &signatures)
+		statuses := make([]interface{}, 0)
+		for _, signature := range signatures {
+			if _, ok := s.transfers[signature]; ok {
+				statuses = append(statuses, map[string]interface{}{"confirmationStatus": s.status, "err": nil})
+			} else {
+				statuses = append(statuses, nil)
+			}
+		}
+		result = map[string]interface{}{"value": statuses}
+
+	default:
+		err = fmt.Errorf("Method not found")
+	}
+
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{"code": -32002, "message": err.Error()},
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"result": result})
+}
+
+// send decodes, verifies and applies a system transfer. The caller must hold the lock.
+func (s *standIn) send(encoded string) (string, error) {
+	tx, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	r := &reader{bz: tx}
+	if n := r.compactU16(); n != 1 {
+		return "", fmt.Errorf("got %d signatures, want 1", n)
+	}
+	signature := r.next(64)
+	msg := r.bz[r.pos:]
+
+	header := r.next(3)
+	if header[0] != 1 || header[1] != 0 || header[2] != 1 {
+		return "", fmt.Errorf("unexpected message header %v", header)
+	}
+	keys := make([][]byte, r.compactU16())
+	for i := range keys {
+		keys[i] = r.next(32)
+	}
+	blockhash := r.next(32)
+	if string(blockhash) != string(s.blockhash) {
+		return "", fmt.Errorf("Blockhash not found")
+	}
+	if n := r.compactU16(); n != 1 {
+		return "", fmt.Errorf("got %d instructions, want 1", n)
+	}
+	program := keys[r.next(1)[0]]
+	accounts := r.next(r.compactU16())
+	data := r.next(r.compactU16())
+	if r.err != nil {
+		return "", r.err
+	}
+
+	if string(program) != string(systemProgramId) || len(accounts) != 2 || len(data) != 12 ||
+		binary.LittleEndian.Uint32(data[:4]) != systemTransferInstruction {
+		return "", fmt.Errorf("not a system transfer")
+	}
+	if !ed25519.Verify(keys[0], msg, signature) {
+		return "", fmt.Errorf("Transaction signature verification failure")
+	}
+
+	from, to := base58.Encode(keys[accounts[0]]), base58.Encode(keys[accounts[1]])
+	lamports := binary.LittleEndian.Uint64(data[4:])
+	if s.balances[from] < lamports+s.fee {
+		return "", fmt.Errorf("Attempt to debit an account but found no record of a prior credit")
+	}
+	if left := s.balances[from] - lamports - s.fee; left > 0 && left < rentExemptMinimum {
+		return "", fmt.Errorf("Transaction results in an account (0) with insufficient funds for rent")
+	}
+	if s.balances[to]+lamports < rentExemptMinimum {
+		return "", fmt.Errorf("Transaction results in an account (1) with insufficient funds for rent")
+	}
+
+	s.balances[from] -= lamports + s.fee
+	s.balances[to] += lamports
+	sig := base58.Encode(signature)
+	s.transfers[sig] = standInTransfer{From: from, To: to, Lamports: lamports}
+
+	return sig, nil
+}
+
+// reader reads a Solana wire encoding.
+type reader struct {
+	bz  []byte
+	pos int
+	err error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil || r.pos+n > len(r.bz) {
+		r.err = fmt.Errorf("truncated transaction")
+		return make([]byte, n)
+	}
+	bz := r.bz[r.pos : r.pos+n]
+	r.pos += n
+
+	return bz
+}
+
+func (r *reader) compactU16() int {
+	n := 0
+	for shift := 0; ; shift += 7 {
+		b := r.next(1)[0]
+		n |= int(b&0x7f) << shift
+		if b&0x80 == 0 || r.err != nil {
+			return n
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package solana
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+)
+
+// systemProgramId is the address of the system program, 32 zero bytes.
+var systemProgramId = make([]byte, 32)
+
+const systemTransferInstruction = uint32(2)
+
+func decodeBase58(s string, length int) ([]byte, error) {
+	bz := base58.Decode(s)
+	if len(bz) != length {
+		return nil, fmt.Errorf("invalid base58 value %q", s)
+	}
+
+	return bz, nil
+}
+
+// appendCompactU16 appends the "shortvec" length encoding used by Solana.
+func appendCompactU16(bz []byte, n int) []byte {
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 {
+			return append(bz, b)
+		}
+		bz = append(bz, b|0x80)
+	}
+}
+
+// transferMessage returns the legacy message of a system transfer of lamports from sender to
+// recipient.
+func transferMessage(from, to []byte, lamports uint64, blockhash []byte) []byte {
+	// One signer (the sender), no read-only signer and one read-only account (the system program).
+	msg := []byte{1, 0, 1}
+
+	msg = appendCompactU16(msg, 3)
+	msg = append(msg, from...)
+	msg = append(msg, to...)
+	msg = append(msg, systemProgramId...)
+
+	msg = append(msg, blockhash...)
+
+	data := make([]byte, 12)
+	binary.LittleEndian.PutUint32(data[:4], systemTransferInstruction)
+	binary.LittleEndian.PutUint64(data[4:], lamports)
+
+	msg = appendCompactU16(msg, 1)
+	msg = append(msg, 2) // program id index
+	msg = appendCompactU16(msg, 2)
+	msg = append(msg, 0, 1) // from, to
+	msg = appendCompactU16(msg, len(data))
+	msg = append(msg, data...)
+
+	return msg
+}
+
+// signTransaction returns a transaction with the message signed by key.
+func signTransaction(msg []byte, key ed25519.PrivateKey) ([]byte, []byte) {
+	signature := ed25519.Sign(key, msg)
+
+	tx := appendCompactU16(nil, 1)
+	tx = append(tx, signature...)
+	tx = append(tx, msg...)
+
+	return tx, signature
+}
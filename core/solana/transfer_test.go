@@ -0,0 +1,64 @@
+package solana
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestAppendCompactU16(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []byte
+	}{
+		{n: 0, want: []byte{0x00}},
+		{n: 0x7f, want: []byte{0x7f}},
+		{n: 0x80, want: []byte{0x80, 0x01}},
+		{n: 0x3fff, want: []byte{0xff, 0x7f}},
+		{n: 0x4000, want: []byte{0x80, 0x80, 0x01}},
+		{n: 0xffff, want: []byte{0xff, 0xff, 0x03}},
+	}
+
+	for _, tt := range tests {
+		got := appendCompactU16(nil, tt.n)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("appendCompactU16(%d): got %x, want %x", tt.n, got, tt.want)
+		}
+		if n := (&reader{bz: got}).compactU16(); n != tt.n {
+			t.Errorf("decoded %x as %d, want %d", got, n, tt.n)
+		}
+	}
+}
+
+func TestTransferMessage(t *testing.T) {
+	from := bytes.Repeat([]byte{1}, 32)
+	to := bytes.Repeat([]byte{2}, 32)
+	blockhash := bytes.Repeat([]byte{3}, 32)
+	msg := transferMessage(from, to, 890_880, blockhash)
+
+	// Header, 3 accounts, blockhash and one instruction of 1 + 1 + 2 + 1 + 12 bytes.
+	if len(msg) != 3+1+3*32+32+1+17 {
+		t.Fatalf("got message length %d", len(msg))
+	}
+	if !bytes.Equal(msg[:4], []byte{1, 0, 1, 3}) {
+		t.Fatalf("got header %x", msg[:4])
+	}
+	if !bytes.Equal(msg[4:36], from) || !bytes.Equal(msg[36:68], to) || !bytes.Equal(msg[68:100], systemProgramId) {
+		t.Fatal("accounts are not in signer, recipient, program order")
+	}
+	if !bytes.Equal(msg[100:132], blockhash) {
+		t.Fatal("blockhash is not after the accounts")
+	}
+
+	instruction := msg[132:]
+	if !bytes.Equal(instruction[:6], []byte{1, 2, 2, 0, 1, 12}) {
+		t.Fatalf("got instruction header %x", instruction[:6])
+	}
+	data := instruction[6:]
+	if binary.LittleEndian.Uint32(data[:4]) != systemTransferInstruction {
+		t.Fatalf("got instruction %x", data[:4])
+	}
+	if lamports := binary.LittleEndian.Uint64(data[4:]); lamports != 890_880 {
+		t.Fatalf("got %d lamports", lamports)
+	}
+}
@@ -0,0 +1,271 @@
+package solana
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
+)
+
+var (
+	SleepTime = time.Second * 60 * 30
+	// ConfirmTimeout is how long the watcher waits for a transfer to reach the commitment level.
+	ConfirmTimeout  = time.Second * 90
+	ConfirmPollTime = time.Second * 2
+
+	// DefaultPolicy funds 0.2 SOL whenever the balance drops below 0.1 SOL.
+	DefaultPolicy = funding.PolicyCfg{
+		Mode:      funding.ModeFixed,
+		Threshold: "100000000",
+		Amount:    "200000000",
+	}
+)
+
+type watcher struct {
+	mnemonic  string
+	chain     string
+	cfg       Cfg
+	client    Client
+	watchAddr string
+	watchKey  []byte
+	policy    *funding.Policy
+	burn      *funding.BurnTracker
+	limiter   *funding.Limiter
+	ledger    *ledger.Ledger
+	outflow   *funding.OutflowMonitor
+	audit     *audit.Trail
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      sync.WaitGroup
+}
+
+func NewWatcher(mnemonic string, chain string, client Client, cfg Cfg, pubkey []byte,
+	policy *funding.Policy, burn *funding.BurnTracker, limiter *funding.Limiter, ledger *ledger.Ledger,
+	outflow *funding.OutflowMonitor, audit *audit.Trail) (*watcher, error) {
+	if len(pubkey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid eddsa pubkey length %d", len(pubkey))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &watcher{
+		mnemonic:  mnemonic,
+		chain:     chain,
+		cfg:       cfg.withDefaults(),
+		client:    client,
+		watchAddr: GetAddress(pubkey),
+		watchKey:  pubkey,
+		policy:    policy,
+		burn:      burn,
+		limiter:   limiter,
+		ledger:    ledger,
+		outflow:   outflow,
+		audit:     audit,
+		ctx:       ctx,
+		cancel:    cancel,
+	}, nil
+}
+
+func (w *watcher) Start() {
+	log.Infof("Starting watcher for chain %s, watch address = %s", w.chain, w.watchAddr)
+	w.done.Add(1)
+	go w.loop()
+}
+
+// Stop stops the watcher and waits for the cycle in progress to finish.
+func (w *watcher) Stop() {
+	w.cancel()
+	w.done.Wait()
+}
+
+func (w *watcher) loop() {
+	defer w.done.Done()
+
+	for {
+		if w.ctx.Err() != nil {
+			return
+		}
+
+		w.checkFaucet()
+
+		lamports, err := w.client.GetBalance(w.watchAddr)
+		if err != nil {
+			log.Errorf("Failed to get balance on chain %s, err = %s", w.chain, err)
+			w.audit.Unavailable(audit.Record{Address: w.watchAddr, Threshold: w.policy.Threshold().String()}, err)
+		} else {
+			balance := new(big.Int).SetUint64(lamports)
+			log.Verbosef("Balance in lamports: %s on chain %s", balance, w.chain)
+
+			w.burn.Observe(balance)
+			shouldFund, reason := w.policy.ShouldFund(balance, w.burn)
+			record := audit.Record{
+				Address:   w.watchAddr,
+				Balance:   balance.String(),
+				Threshold: w.policy.Threshold().String(),
+				Reason:    reason,
+			}
+			if amount := w.policy.Amount(balance); shouldFund && amount.Sign() > 0 {
+				log.Infof("Funding chain %s, reason: %s", w.chain, reason)
+				w.fund(lamports, amount, record)
+			} else {
+				record.Decision = audit.Skip
+				w.audit.Record(record)
+			}
+		}
+
+		if !funding.Sleep(w.ctx, SleepTime) {
+			return
+		}
+	}
+}
+
+// checkFaucet reports the faucet balance to the outflow monitor. Solana accounts have no nonce,
+// so only the balance is compared.
+func (w *watcher) checkFaucet() {
+	if !w.outflow.Enabled() {
+		return
+	}
+
+	privKey, err := getPrivateKey(w.mnemonic)
+	if err != nil {
+		log.Errorf("Failed to get faucet key on chain %s, err = %s", w.chain, err)
+		return
+	}
+	faucetAddr := GetAddress(privKey.Public().(ed25519.PublicKey))
+
+	lamports, err := w.client.GetBalance(faucetAddr)
+	if err != nil {
+		log.Errorf("Failed to get faucet balance on chain %s, err = %s", w.chain, err)
+		return
+	}
+	w.outflow.Observe(funding.FaucetState{Address: faucetAddr, Balance: new(big.Int).SetUint64(lamports)})
+}
+
+func (w *watcher) fund(balance uint64, amount *big.Int, record audit.Record) {
+	record.Amount = amount.String()
+	if !amount.IsUint64() {
+		log.Errorf("Funding amount %s is out of range on chain %s", amount, w.chain)
+		w.audit.Failed(record, fmt.Errorf("amount %s is out of range", amount))
+		return
+	}
+
+	// An account is only created by a transfer that leaves it rent exempt.
+	rentExempt, err := w.client.GetMinimumBalanceForRentExemption(0)
+	if err != nil {
+		log.Errorf("Cannot get rent exemption minimum on chain %s, err = %s", w.chain, err)
+		w.audit.Failed(record, fmt.Errorf("cannot get rent exemption minimum: %w", err))
+		return
+	}
+	if balance+amount.Uint64() < rentExempt {
+		log.Infof("Raising funding amount on chain %s from %s to the rent exemption minimum %d",
+			w.chain, amount, rentExempt-balance)
+		amount = new(big.Int).SetUint64(rentExempt - balance)
+		record.Amount = amount.String()
+	}
+
+	reservation, err := w.limiter.Reserve(w.chain, w.watchAddr, amount)
+	if err != nil {
+		log.Errorf("Cannot fund chain %s, err = %s", w.chain, err)
+		w.audit.Refused(record, err)
+		return
+	}
+
+	signature, err := w.transfer(amount.Uint64(), rentExempt)
+	if err != nil {
+		reservation.Release()
+		log.Errorf("Failed to fund chain %s, err = %s", w.chain, err)
+		w.audit.Failed(record, err)
+		return
+	}
+	reservation.Commit()
+
+	w.burn.RecordTopUp()
+	log.Infof("Solana signature = %s on chain %s", signature, w.chain)
+	w.audit.Funded(record, signature)
+
+	if err := w.waitForConfirmation(signature); err != nil {
+		log.Errorf("Transfer %s on chain %s is not confirmed, err = %s", signature, w.chain, err)
+	}
+}
+
+// transfer sends lamports from the faucet to the watched address. The faucet must stay rent
+// exempt after paying the amount and the fee.
+func (w *watcher) transfer(lamports uint64, rentExempt uint64) (string, error) {
+	privKey, err := getPrivateKey(w.mnemonic)
+	if err != nil {
+		return "", err
+	}
+	faucetKey := privKey.Public().(ed25519.PublicKey)
+	faucetAddr := GetAddress(faucetKey)
+
+	faucetBalance, err := w.client.GetBalance(faucetAddr)
+	if err != nil {
+		return "", err
+	}
+
+	blockhash, err := w.client.GetLatestBlockhash()
+	if err != nil {
+		return "", err
+	}
+
+	msg := transferMessage(faucetKey, w.watchKey, lamports, blockhash)
+	fee, err := w.client.GetFeeForMessage(msg)
+	if err != nil {
+		log.Warnf("Cannot get fee on chain %s, using %d lamports, err = %s", w.chain, DefaultFee, err)
+		fee = DefaultFee
+	}
+
+	if faucetBalance < lamports+fee+rentExempt {
+		return "", fmt.Errorf("insufficient faucet balance %d, need %d plus fee %d and rent exemption %d",
+			faucetBalance, lamports, fee, rentExempt)
+	}
+
+	log.Infof("Funding %d lamports from %s to %s on chain %s, fee = %d", lamports, faucetAddr,
+		w.watchAddr, w.chain, fee)
+	tx, _ := signTransaction(msg, privKey)
+
+	signature, err := w.client.SendTransaction(tx)
+	if err != nil {
+		return "", err
+	}
+	w.outflow.Expect(new(big.Int).SetUint64(lamports), new(big.Int).SetUint64(fee))
+
+	if err := w.ledger.Record(ledger.Entry{
+		Chain:  w.chain,
+		TxHash: signature,
+		From:   faucetAddr,
+		To:     w.watchAddr,
+		Amount: fmt.Sprintf("%d", lamports),
+		Label:  "mpc",
+	}); err != nil {
+		log.Errorf("Failed to record transfer %s on chain %s, err = %s", signature, w.chain, err)
+	}
+
+	return signature, nil
+}
+
+func (w *watcher) waitForConfirmation(signature string) error {
+	deadline := time.Now().Add(ConfirmTimeout)
+	for time.Now().Before(deadline) {
+		status, err := w.client.GetSignatureStatus(signature)
+		if err != nil {
+			return err
+		}
+		if commitments[status] >= commitments[w.cfg.Commitment] && status != "" {
+			log.Infof("Transfer %s on chain %s is %s", signature, w.chain, status)
+			return nil
+		}
+
+		if !funding.Sleep(w.ctx, ConfirmPollTime) {
+			return fmt.Errorf("watcher stopped while waiting for commitment %s", w.cfg.Commitment)
+		}
+	}
+
+	return fmt.Errorf("timed out after %s", ConfirmTimeout)
+}
@@ -0,0 +1,178 @@
+package solana
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sisu-network/sisu-account-funding/core/alert"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// newTestWatcher returns a watcher talking to the stand-in at url, and the address of its faucet.
+func newTestWatcher(t *testing.T, url string) (*watcher, string) {
+	t.Helper()
+
+	dataDir := t.TempDir()
+	mpcKey := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, 32))
+	policy, err := funding.NewPolicy(funding.PolicyCfg{}, DefaultPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerter := alert.NewAlerter("")
+	limiter, err := funding.NewLimiter(filepath.Join(dataDir, "limiter.json"), funding.GlobalLimitCfg{}, nil, alerter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Cfg{}
+	w, err := NewWatcher(testMnemonic, "solana-devnet", NewClient(url, cfg), cfg, mpcKey.Public().(ed25519.PublicKey),
+		policy, funding.NewBurnTracker(filepath.Join(dataDir, "burn.json"), 0), limiter,
+		ledger.NewLedger(filepath.Join(dataDir, "ledger.jsonl")),
+		funding.NewOutflowMonitor("solana-devnet", funding.OutflowCfg{Disabled: true}, limiter, alerter),
+		(*audit.Log)(nil).Trail("solana-devnet", url))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privKey, err := getPrivateKey(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w, GetAddress(privKey.Public().(ed25519.PublicKey))
+}
+
+func TestFund(t *testing.T) {
+	tests := []struct {
+		name    string
+		faucet  uint64
+		balance uint64
+		amount  int64
+		// sent is the amount transferred, 0 if nothing is sent.
+		sent uint64
+	}{
+		{
+			name:    "new account is raised to the rent exemption minimum",
+			faucet:  10_000_000_000,
+			balance: 0,
+			amount:  100_000,
+			sent:    rentExemptMinimum,
+		},
+		{
+			name:    "account below the minimum is raised to it",
+			faucet:  10_000_000_000,
+			balance: 500_000,
+			amount:  100_000,
+			sent:    rentExemptMinimum - 500_000,
+		},
+		{
+			name:    "amount above the minimum is kept",
+			faucet:  10_000_000_000,
+			balance: 0,
+			amount:  1_000_000,
+			sent:    1_000_000,
+		},
+		{
+			name:    "rent exempt account gets the amount",
+			faucet:  10_000_000_000,
+			balance: 1_000_000,
+			amount:  10,
+			sent:    10,
+		},
+		{
+			name:    "faucet must stay rent exempt",
+			faucet:  1_000_000,
+			balance: 0,
+			amount:  100_000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn, url := newStandIn(t)
+			w, faucet := newTestWatcher(t, url)
+			standIn.setBalance(faucet, tt.faucet)
+			standIn.setBalance(w.watchAddr, tt.balance)
+
+			w.fund(tt.balance, big.NewInt(tt.amount), audit.Record{Address: w.watchAddr})
+
+			sent := standIn.sent()
+			if tt.sent == 0 {
+				if len(sent) != 0 {
+					t.Fatalf("got transfers %v, want none", sent)
+				}
+				return
+			}
+			if len(sent) != 1 {
+				t.Fatalf("got %d transfers, want 1", len(sent))
+			}
+			if sent[0].From != faucet || sent[0].To != w.watchAddr || sent[0].Lamports != tt.sent {
+				t.Fatalf("got transfer %+v, want %d lamports from %s to %s", sent[0], tt.sent, faucet, w.watchAddr)
+			}
+			if balance := standIn.balance(w.watchAddr); balance != tt.balance+tt.sent {
+				t.Fatalf("got mpc balance %d, want %d", balance, tt.balance+tt.sent)
+			}
+			if balance := standIn.balance(faucet); balance != tt.faucet-tt.sent-DefaultFee {
+				t.Fatalf("got faucet balance %d, want %d", balance, tt.faucet-tt.sent-DefaultFee)
+			}
+		})
+	}
+}
+
+func TestStop(t *testing.T) {
+	tests := []struct {
+		name string
+		// status is the commitment reached by the top-up.
+		status string
+	}{
+		{
+			name:   "watcher sleeping until its next cycle",
+			status: "finalized",
+		},
+		{
+			name:   "watcher waiting for the commitment of its top-up",
+			status: "processed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn, url := newStandIn(t)
+			standIn.setStatus(tt.status)
+			w, faucet := newTestWatcher(t, url)
+			standIn.setBalance(faucet, 10_000_000_000)
+
+			w.Start()
+			deadline := time.Now().Add(5 * time.Second)
+			for len(standIn.sent()) == 0 {
+				if time.Now().After(deadline) {
+					t.Fatal("the watcher did not fund the empty mpc address")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			// Stop interrupts the sleep or the wait for the commitment.
+			stopped := make(chan struct{})
+			go func() {
+				w.Stop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Stop did not return")
+			}
+
+			// A stopped watcher can be stopped again.
+			w.Stop()
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Load reads the JSON file at filePath into v. A missing file is not an error and leaves v
+// untouched so that callers can start from their zero state on the first run.
+func Load(filePath string, v interface{}) error {
+	dat, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(dat, v)
+}
+
+// Save writes v as JSON to filePath. The data is written to a temporary file first and then
+// renamed so that a crash never leaves a half written state file behind.
+func Save(filePath string, v interface{}) error {
+	dat, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		return err
+	}
+
+	tmp := filePath + ".tmp"
+	if err := os.WriteFile(tmp, dat, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filePath)
+}
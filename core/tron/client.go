@@ -0,0 +1,195 @@
+package tron
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrTxNotFound is returned when the node does not know the requested transaction, e.g. it was not
+// included yet.
+var ErrTxNotFound = fmt.Errorf("transaction not found")
+
+// Account is the state of an account relevant to funding.
+type Account struct {
+	// Exists is false for addresses that never received TRX. Funding them also pays the account
+	// creation fee.
+	Exists  bool
+	Balance int64 // in sun
+}
+
+// Resources are the bandwidth and energy of an account.
+type Resources struct {
+	FreeNetLimit int64 `json:"freeNetLimit"`
+	FreeNetUsed  int64 `json:"freeNetUsed"`
+	NetLimit     int64 `json:"NetLimit"`
+	NetUsed      int64 `json:"NetUsed"`
+	EnergyLimit  int64 `json:"EnergyLimit"`
+	EnergyUsed   int64 `json:"EnergyUsed"`
+}
+
+// Bandwidth returns the free and staked bandwidth left.
+func (r *Resources) Bandwidth() int64 {
+	return r.FreeNetLimit - r.FreeNetUsed + r.NetLimit - r.NetUsed
+}
+
+// Energy returns the staked energy left.
+func (r *Resources) Energy() int64 {
+	return r.EnergyLimit - r.EnergyUsed
+}
+
+// Block is the latest block, referenced by new transactions.
+type Block struct {
+	Number    int64
+	Id        []byte
+	Timestamp int64 // in milliseconds
+}
+
+// Client is the part of the TronGrid HTTP api used by the watcher. It lets the watcher run against
+// TronGrid, a full node http api or a mocked api.
+type Client interface {
+	GetAccount(address string) (*Account, error)
+	GetResources(address string) (*Resources, error)
+	GetNowBlock() (*Block, error)
+	// GetTxBlock returns the number of the block including the transaction, or ErrTxNotFound.
+	GetTxBlock(txId string) (int64, error)
+	// Broadcast submits a signed transaction.
+	Broadcast(tx []byte) error
+}
+
+type httpClient struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func NewClient(url string, cfg Cfg) Client {
+	return &httpClient{
+		url:    strings.TrimSuffix(url, "/"),
+		apiKey: cfg.ApiKey,
+		client: &http.Client{Timeout: time.Second * 30},
+	}
+}
+
+func (c *httpClient) post(path string, body interface{}, result interface{}) error {
+	bz, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", c.url+path, bytes.NewReader(bz))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("TRON-PRO-API-KEY", c.apiKey)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	bz, err = io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s failed with status %d: %s", path, res.StatusCode, string(bz))
+	}
+
+	return json.Unmarshal(bz, result)
+}
+
+func (c *httpClient) GetAccount(address string) (*Account, error) {
+	res := &struct {
+		Address string `json:"address"`
+		Balance int64  `json:"balance"`
+	}{}
+	err := c.post("/wallet/getaccount", map[string]interface{}{"address": address, "visible": true}, res)
+	if err != nil {
+		return nil, err
+	}
+
+	// The api returns an empty object for unknown accounts.
+	return &Account{Exists: res.Address != "", Balance: res.Balance}, nil
+}
+
+func (c *httpClient) GetResources(address string) (*Resources, error) {
+	res := &Resources{}
+	err := c.post("/wallet/getaccountresource", map[string]interface{}{"address": address, "visible": true}, res)
+
+	return res, err
+}
+
+func (c *httpClient) GetNowBlock() (*Block, error) {
+	res := &struct {
+		BlockId     string `json:"blockID"`
+		BlockHeader struct {
+			RawData struct {
+				Number    int64 `json:"number"`
+				Timestamp int64 `json:"timestamp"`
+			} `json:"raw_data"`
+		} `json:"block_header"`
+	}{}
+	if err := c.post("/wallet/getnowblock", map[string]interface{}{}, res); err != nil {
+		return nil, err
+	}
+
+	id, err := hex.DecodeString(res.BlockId)
+	if err != nil || len(id) != 32 {
+		return nil, fmt.Errorf("invalid block id %q", res.BlockId)
+	}
+
+	return &Block{
+		Number:    res.BlockHeader.RawData.Number,
+		Id:        id,
+		Timestamp: res.BlockHeader.RawData.Timestamp,
+	}, nil
+}
+
+func (c *httpClient) GetTxBlock(txId string) (int64, error) {
+	res := &struct {
+		Id          string `json:"id"`
+		BlockNumber int64  `json:"blockNumber"`
+	}{}
+	if err := c.post("/wallet/gettransactioninfobyid", map[string]string{"value": txId}, res); err != nil {
+		return 0, err
+	}
+
+	// The api returns an empty object until the transaction is included.
+	if res.Id == "" {
+		return 0, ErrTxNotFound
+	}
+
+	return res.BlockNumber, nil
+}
+
+func (c *httpClient) Broadcast(tx []byte) error {
+	res := &struct {
+		Result  bool   `json:"result"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}{}
+	if err := c.post("/wallet/broadcasthex", map[string]string{"transaction": hex.EncodeToString(tx)}, res); err != nil {
+		return err
+	}
+
+	if !res.Result {
+		// The message is usually hex encoded.
+		message := res.Message
+		if bz, err := hex.DecodeString(message); err == nil {
+			message = string(bz)
+		}
+		return fmt.Errorf("transaction rejected, code = %s, message = %s", res.Code, message)
+	}
+
+	return nil
+}
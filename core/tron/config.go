@@ -0,0 +1,76 @@
+package tron
+
+import (
+	"fmt"
+	"math/big"
+)
+
+var (
+	// Chains are the Tron chains known to the funder.
+	Chains = map[string]bool{
+		"tron-mainnet": true,
+		"tron-shasta":  true,
+		"tron-nile":    true,
+	}
+)
+
+func IsTronChain(chain string) bool {
+	return Chains[chain]
+}
+
+const (
+	ResourceBandwidth = "bandwidth"
+	ResourceEnergy    = "energy"
+)
+
+// Cfg holds the Tron specific settings of a chain.
+type Cfg struct {
+	// ApiKey is sent in the TRON-PRO-API-KEY header required by TronGrid.
+	ApiKey string `toml:"api_key" json:"api_key"`
+
+	// When FreezeAmount is set, the faucet stakes FreezeAmount sun for Resource and delegates the
+	// resource to the watched address whenever its available resource drops below MinResource.
+	FreezeAmount string `toml:"freeze_amount" json:"freeze_amount"`
+	Resource     string `toml:"resource" json:"resource"`
+	MinResource  int64  `toml:"min_resource" json:"min_resource"`
+}
+
+func (cfg Cfg) withDefaults() Cfg {
+	if cfg.Resource == "" {
+		cfg.Resource = ResourceEnergy
+	}
+
+	return cfg
+}
+
+func (cfg Cfg) Validate() error {
+	cfg = cfg.withDefaults()
+	switch cfg.Resource {
+	case ResourceBandwidth, ResourceEnergy:
+	default:
+		return fmt.Errorf("unknown resource %s", cfg.Resource)
+	}
+
+	if _, err := cfg.freezeAmount(); err != nil {
+		return err
+	}
+	if cfg.MinResource < 0 {
+		return fmt.Errorf("invalid min_resource %d", cfg.MinResource)
+	}
+
+	return nil
+}
+
+// freezeAmount returns the amount to stake in sun, 0 if freezing is disabled.
+func (cfg Cfg) freezeAmount() (int64, error) {
+	if cfg.FreezeAmount == "" {
+		return 0, nil
+	}
+
+	amount, ok := new(big.Int).SetString(cfg.FreezeAmount, 10)
+	if !ok || amount.Sign() < 0 || !amount.IsInt64() {
+		return 0, fmt.Errorf("invalid freeze amount %q", cfg.FreezeAmount)
+	}
+
+	return amount.Int64(), nil
+}
@@ -0,0 +1,63 @@
+package tron
+
+import (
+	"time"
+
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/store"
+)
+
+// pendingStake is a stake that has been sent but not delegated yet. The resources of the watched
+// address do not count it until then.
+type pendingStake struct {
+	TxId   string    `json:"txid"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+func (w *watcher) loadPending() {
+	pending := &pendingStake{}
+	if err := store.Load(w.pendingFile, pending); err != nil {
+		log.Errorf("Failed to load pending stake from %s, err = %s", w.pendingFile, err)
+		return
+	}
+
+	if pending.TxId != "" {
+		log.Infof("Resuming delegation of stake %s on chain %s", pending.TxId, w.chain)
+		w.pending = pending
+	}
+}
+
+func (w *watcher) setPending(pending *pendingStake) {
+	w.pending = pending
+	if pending == nil {
+		pending = &pendingStake{}
+	}
+
+	if err := store.Save(w.pendingFile, pending); err != nil {
+		log.Errorf("Failed to save pending stake to %s, err = %s", w.pendingFile, err)
+	}
+}
+
+// checkPending delegates the pending stake once it is included, or forgets it once it can no
+// longer be. A transaction expires a minute after the block it references, twice that leaves room
+// for the clock of the node.
+func (w *watcher) checkPending() {
+	_, err := w.client.GetTxBlock(w.pending.TxId)
+	switch {
+	case err == ErrTxNotFound:
+		if time.Since(w.pending.SentAt) > 2*Expiration {
+			log.Errorf("Stake %s expired on chain %s", w.pending.TxId, w.chain)
+			w.setPending(nil)
+		}
+	case err != nil:
+		log.Errorf("Failed to get stake %s on chain %s, err = %s", w.pending.TxId, w.chain, err)
+	default:
+		txId, err := w.delegate()
+		if err != nil {
+			log.Errorf("Failed to delegate stake %s on chain %s, err = %s", w.pending.TxId, w.chain, err)
+			return
+		}
+		log.Infof("Tron delegation txId = %s of stake %s on chain %s", txId, w.pending.TxId, w.chain)
+		w.setPending(nil)
+	}
+}
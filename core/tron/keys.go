@@ -0,0 +1,71 @@
+package tron
+
+import (
+	"crypto/ecdsa"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/cosmos/go-bip39"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+// addressPrefix is the first byte of every Tron address.
+const addressPrefix = byte(0x41)
+
+// addressBytes returns the 21 bytes address of an ECDSA public key: the Ethereum address of the key
+// prefixed with 0x41.
+func addressBytes(pubKey *ecdsa.PublicKey) []byte {
+	return append([]byte{addressPrefix}, ethcrypto.PubkeyToAddress(*pubKey).Bytes()...)
+}
+
+// GetAddress returns the base58check Tron address of an uncompressed ECDSA public key, e.g. the Sisu
+// MPC key.
+func GetAddress(pubkey []byte) (string, error) {
+	pubKey, err := ethcrypto.UnmarshalPubkey(pubkey)
+	if err != nil {
+		return "", err
+	}
+
+	return encodeAddress(addressBytes(pubKey)), nil
+}
+
+func encodeAddress(bz []byte) string {
+	return base58.CheckEncode(bz[1:], bz[0])
+}
+
+// getPrivateKey derives the faucet key from the mnemonic with the m/44'/195'/0'/0/0 path used by
+// the Tron wallets.
+func getPrivateKey(mnemonic string) (*ecdsa.PrivateKey, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// The network params only matter for the serialization of extended keys.
+	key, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		return nil, err
+	}
+
+	path := []uint32{
+		hdkeychain.HardenedKeyStart + 44,
+		hdkeychain.HardenedKeyStart + 195,
+		hdkeychain.HardenedKeyStart,
+		0,
+		0,
+	}
+	for _, n := range path {
+		key, err = key.Derive(n)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	privKey, err := key.ECPrivKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return privKey.ToECDSA(), nil
+}
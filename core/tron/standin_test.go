@@ -0,0 +1,387 @@
+package tron
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// resourcePerTrx is the bandwidth or energy the stand-in grants per delegated TRX.
+const resourcePerTrx = 10
+
+// standIn is a TronGrid stand-in serving the wallet endpoints used by the watcher. It checks the
+// signature and block reference of the broadcast transactions and applies their contract at once.
+type standIn struct {
+	lock      sync.Mutex
+	block     Block
+	accounts  map[string]*standInAccount
+	resources map[string]*Resources
+	// frozen and delegated are the sun staked and delegated by address and resource.
+	frozen    map[string]map[string]int64
+	delegated map[string]map[string]int64
+	contracts []standInContract
+	// included holds the block number of the applied transactions by id.
+	included map[string]int64
+	// While holdStakes is set, stakes are accepted but only applied by include, as if they were
+	// waiting to be included. dropped counts the held stakes that were discarded.
+	holdStakes bool
+	held       []string
+	dropped    int
+}
+
+type standInAccount struct {
+	Balance int64
+}
+
+// standInContract is a contract applied by the stand-in. Addresses are base58.
+type standInContract struct {
+	Type     uint64
+	Owner    string
+	To       string
+	Amount   int64
+	Resource string
+}
+
+func newStandIn(t *testing.T) (*standIn, string) {
+	s := &standIn{
+		block:     Block{Number: 50_000_123, Id: bytes.Repeat([]byte{7}, 32), Timestamp: 1_700_000_000_000},
+		accounts:  make(map[string]*standInAccount),
+		resources: make(map[string]*Resources),
+		frozen:    make(map[string]map[string]int64),
+		delegated: make(map[string]map[string]int64),
+		included:  make(map[string]int64),
+	}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+
+	return s, server.URL
+}
+
+func (s *standIn) setBalance(address string, balance int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.accounts[address] = &standInAccount{Balance: balance}
+}
+
+func (s *standIn) balance(address string) int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if account, ok := s.accounts[address]; ok {
+		return account.Balance
+	}
+
+	return 0
+}
+
+func (s *standIn) setResources(address string, resources Resources) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.resources[address] = &resources
+}
+
+func (s *standIn) setHoldStakes(hold bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.holdStakes = hold
+}
+
+// include applies the held stakes.
+func (s *standIn) include() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.holdStakes = false
+	for _, txHex := range s.held {
+		if err := s.broadcast(txHex); err != nil {
+			return err
+		}
+	}
+	s.held = nil
+
+	return nil
+}
+
+// drop discards the held stakes, as if they expired.
+func (s *standIn) drop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.holdStakes = false
+	s.dropped += len(s.held)
+	s.held = nil
+}
+
+// stakeCount returns the number of stakes accepted, whether they were applied, held or dropped.
+func (s *standIn) stakeCount() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	count := len(s.held) + s.dropped
+	for _, contract := range s.contracts {
+		if contract.Type == freezeBalanceV2Contract {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (s *standIn) applied() []standInContract {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]standInContract{}, s.contracts...)
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := &struct {
+		Address     string `json:"address"`
+		Transaction string `json:"transaction"`
+		Value       string `json:"value"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var res interface{}
+	switch r.URL.Path {
+	case "/wallet/getaccount":
+		res = map[string]interface{}{}
+		if account, ok := s.accounts[req.Address]; ok {
+			res = map[string]interface{}{"address": req.Address, "balance": account.Balance}
+		}
+
+	case "/wallet/getaccountresource":
+		res = &Resources{}
+		if resources, ok := s.resources[req.Address]; ok {
+			res = resources
+		}
+
+	case "/wallet/gettransactioninfobyid":
+		res = map[string]interface{}{}
+		if number, ok := s.included[req.Value]; ok {
+			res = map[string]interface{}{"id": req.Value, "blockNumber": number}
+		}
+
+	case "/wallet/getnowblock":
+		res = map[string]interface{}{
+			"blockID": hex.EncodeToString(s.block.Id),
+			"block_header": map[string]interface{}{
+				"raw_data": map[string]int64{"number": s.block.Number, "timestamp": s.block.Timestamp},
+			},
+		}
+
+	case "/wallet/broadcasthex":
+		res = map[string]bool{"result": true}
+		if err := s.broadcast(req.Transaction); err != nil {
+			res = map[string]interface{}{
+				"result":  false,
+				"code":    "CONTRACT_VALIDATE_ERROR",
+				"message": hex.EncodeToString([]byte(err.Error())),
+			}
+		}
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	json.NewEncoder(w).Encode(res)
+}
+
+// broadcast verifies and applies a transaction. The caller must hold the lock.
+func (s *standIn) broadcast(txHex string) error {
+	bz, err := hex.DecodeString(txHex)
+	if err != nil {
+		return err
+	}
+	tx, err := decodeMessage(bz)
+	if err != nil {
+		return err
+	}
+	raw, signature := tx.bytes(1), tx.bytes(2)
+
+	txId := sha256.Sum256(raw)
+	pubKey, err := ethcrypto.SigToPub(txId[:], signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	signer := encodeAddress(addressBytes(pubKey))
+
+	rawData, err := decodeMessage(raw)
+	if err != nil {
+		return err
+	}
+	number := make([]byte, 8)
+	binary.BigEndian.PutUint64(number, uint64(s.block.Number))
+	if !bytes.Equal(rawData.bytes(1), number[6:]) || !bytes.Equal(rawData.bytes(4), s.block.Id[8:16]) {
+		return fmt.Errorf("TaPos check error")
+	}
+	if int64(rawData.uint(8)) <= s.block.Timestamp {
+		return fmt.Errorf("transaction expired")
+	}
+
+	c, err := decodeMessage(rawData.bytes(11))
+	if err != nil {
+		return err
+	}
+	param, err := decodeMessage(c.bytes(2))
+	if err != nil {
+		return err
+	}
+	value, err := decodeMessage(param.bytes(2))
+	if err != nil {
+		return err
+	}
+
+	if len(value.bytes(1)) != 21 {
+		return fmt.Errorf("invalid owner address")
+	}
+	contract := standInContract{Type: c.uint(1), Owner: encodeAddress(value.bytes(1))}
+	if contract.Owner != signer {
+		return fmt.Errorf("signed by %s instead of the owner %s", signer, contract.Owner)
+	}
+	owner, ok := s.accounts[contract.Owner]
+	if !ok {
+		return fmt.Errorf("account %s does not exist", contract.Owner)
+	}
+
+	switch contract.Type {
+	case transferContract:
+		contract.To, contract.Amount = encodeAddress(value.bytes(2)), int64(value.uint(3))
+		if !strings.HasSuffix(string(param.bytes(1)), ".TransferContract") {
+			return fmt.Errorf("unexpected type url %s", param.bytes(1))
+		}
+		if owner.Balance < contract.Amount {
+			return fmt.Errorf("balance is not sufficient")
+		}
+		if _, ok := s.accounts[contract.To]; !ok {
+			s.accounts[contract.To] = &standInAccount{}
+		}
+		owner.Balance -= contract.Amount
+		s.accounts[contract.To].Balance += contract.Amount
+
+	case freezeBalanceV2Contract:
+		contract.Amount, contract.Resource = int64(value.uint(2)), resourceName(value.uint(3))
+		if !strings.HasSuffix(string(param.bytes(1)), ".FreezeBalanceV2Contract") {
+			return fmt.Errorf("unexpected type url %s", param.bytes(1))
+		}
+		if owner.Balance < contract.Amount {
+			return fmt.Errorf("frozenBalance must be less than or equal to accountBalance")
+		}
+		if s.holdStakes {
+			s.held = append(s.held, txHex)
+			return nil
+		}
+		owner.Balance -= contract.Amount
+		add(s.frozen, contract.Owner, contract.Resource, contract.Amount)
+
+	case delegateResourceContract:
+		contract.Resource, contract.Amount = resourceName(value.uint(2)), int64(value.uint(3))
+		contract.To = encodeAddress(value.bytes(4))
+		if !strings.HasSuffix(string(param.bytes(1)), ".DelegateResourceContract") {
+			return fmt.Errorf("unexpected type url %s", param.bytes(1))
+		}
+		if s.frozen[contract.Owner][contract.Resource]-s.delegated[contract.Owner][contract.Resource] < contract.Amount {
+			return fmt.Errorf("delegateBalance must be less than or equal to available FreezeV2 balance")
+		}
+		add(s.delegated, contract.Owner, contract.Resource, contract.Amount)
+		resources, ok := s.resources[contract.To]
+		if !ok {
+			resources = &Resources{}
+			s.resources[contract.To] = resources
+		}
+		if contract.Resource == ResourceEnergy {
+			resources.EnergyLimit += contract.Amount / 1_000_000 * resourcePerTrx
+		} else {
+			resources.NetLimit += contract.Amount / 1_000_000 * resourcePerTrx
+		}
+
+	default:
+		return fmt.Errorf("unsupported contract type %d", contract.Type)
+	}
+
+	s.contracts = append(s.contracts, contract)
+	s.included[hex.EncodeToString(txId[:])] = s.block.Number
+	return nil
+}
+
+func add(amounts map[string]map[string]int64, address, resource string, amount int64) {
+	if amounts[address] == nil {
+		amounts[address] = make(map[string]int64)
+	}
+	amounts[address][resource] += amount
+}
+
+func resourceName(code uint64) string {
+	for name, c := range resourceCodes {
+		if c == code {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("resource %d", code)
+}
+
+// message is a decoded protobuf message, the last value of each field.
+type message map[protowire.Number]interface{}
+
+func decodeMessage(bz []byte) (message, error) {
+	m := make(message)
+	for len(bz) > 0 {
+		field, typ, n := protowire.ConsumeTag(bz)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		bz = bz[n:]
+
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(bz)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			m[field], bz = v, bz[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(bz)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			m[field], bz = v, bz[n:]
+		default:
+			return nil, fmt.Errorf("unexpected wire type %d of field %d", typ, field)
+		}
+	}
+
+	return m, nil
+}
+
+func (m message) bytes(field protowire.Number) []byte {
+	bz, _ := m[field].([]byte)
+	return bz
+}
+
+// uint returns a varint field, 0 when it is omitted.
+func (m message) uint(field protowire.Number) uint64 {
+	n, _ := m[field].(uint64)
+	return n
+}
@@ -0,0 +1,94 @@
+package tron
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The contract types and their Any type urls, from the java-tron protocol.
+const (
+	transferContract         = 1
+	freezeBalanceV2Contract  = 54
+	delegateResourceContract = 57
+
+	typeUrlPrefix = "type.googleapis.com/protocol."
+
+	// Expiration is how long a transaction can wait to be included.
+	Expiration = time.Minute
+)
+
+var resourceCodes = map[string]uint64{
+	ResourceBandwidth: 0,
+	ResourceEnergy:    1,
+}
+
+// The transactions are encoded by hand with protowire so that the faucet builds and signs them
+// itself instead of signing what an api returns.
+
+func appendBytes(bz []byte, field protowire.Number, value []byte) []byte {
+	bz = protowire.AppendTag(bz, field, protowire.BytesType)
+	return protowire.AppendBytes(bz, value)
+}
+
+func appendUint(bz []byte, field protowire.Number, n uint64) []byte {
+	if n == 0 {
+		return bz
+	}
+	bz = protowire.AppendTag(bz, field, protowire.VarintType)
+	return protowire.AppendVarint(bz, n)
+}
+
+func encodeTransfer(owner, to []byte, amount int64) []byte {
+	contract := appendBytes(nil, 1, owner)
+	contract = appendBytes(contract, 2, to)
+	return appendUint(contract, 3, uint64(amount))
+}
+
+func encodeFreeze(owner []byte, amount int64, resource string) []byte {
+	contract := appendBytes(nil, 1, owner)
+	contract = appendUint(contract, 2, uint64(amount))
+	return appendUint(contract, 3, resourceCodes[resource])
+}
+
+func encodeDelegate(owner, receiver []byte, amount int64, resource string) []byte {
+	contract := appendBytes(nil, 1, owner)
+	contract = appendUint(contract, 2, resourceCodes[resource])
+	contract = appendUint(contract, 3, uint64(amount))
+	return appendBytes(contract, 4, receiver)
+}
+
+// rawTransaction returns the raw data of a transaction with a single contract, referencing block.
+func rawTransaction(contractType uint64, name string, contract []byte, block *Block) []byte {
+	param := appendBytes(nil, 1, []byte(typeUrlPrefix+name))
+	param = appendBytes(param, 2, contract)
+	c := appendUint(nil, 1, contractType)
+	c = appendBytes(c, 2, param)
+
+	number := make([]byte, 8)
+	binary.BigEndian.PutUint64(number, uint64(block.Number))
+
+	raw := appendBytes(nil, 1, number[6:8])
+	raw = appendBytes(raw, 4, block.Id[8:16])
+	raw = appendUint(raw, 8, uint64(block.Timestamp+Expiration.Milliseconds()))
+	raw = appendBytes(raw, 11, c)
+	return appendUint(raw, 14, uint64(block.Timestamp))
+}
+
+// signTransaction returns the signed transaction and its id, the sha256 of the raw data.
+func signTransaction(raw []byte, key *ecdsa.PrivateKey) ([]byte, []byte, error) {
+	txId := sha256.Sum256(raw)
+	signature, err := ethcrypto.Sign(txId[:], key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx := appendBytes(nil, 1, raw)
+	tx = appendBytes(tx, 2, signature)
+
+	return tx, txId[:], nil
+}
@@ -0,0 +1,131 @@
+package tron
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestEncodeContracts(t *testing.T) {
+	owner := append([]byte{addressPrefix}, bytes.Repeat([]byte{0x11}, 20)...)
+	receiver := append([]byte{addressPrefix}, bytes.Repeat([]byte{0x22}, 20)...)
+	ownerHex, receiverHex := hex.EncodeToString(owner), hex.EncodeToString(receiver)
+
+	tests := []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{
+			name: "transfer",
+			got:  encodeTransfer(owner, receiver, 1_000_000),
+			want: "0a15" + ownerHex + "1215" + receiverHex + "18c0843d",
+		},
+		{
+			name: "freeze for energy",
+			got:  encodeFreeze(owner, 1_000_000, ResourceEnergy),
+			want: "0a15" + ownerHex + "10c0843d" + "1801",
+		},
+		{
+			// Bandwidth is the default value of the resource field, which proto3 omits.
+			name: "freeze for bandwidth",
+			got:  encodeFreeze(owner, 1_000_000, ResourceBandwidth),
+			want: "0a15" + ownerHex + "10c0843d",
+		},
+		{
+			name: "delegate energy",
+			got:  encodeDelegate(owner, receiver, 1_000_000, ResourceEnergy),
+			want: "0a15" + ownerHex + "1001" + "18c0843d" + "2215" + receiverHex,
+		},
+		{
+			name: "delegate bandwidth",
+			got:  encodeDelegate(owner, receiver, 1_000_000, ResourceBandwidth),
+			want: "0a15" + ownerHex + "18c0843d" + "2215" + receiverHex,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hex.EncodeToString(tt.got); got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRawTransaction(t *testing.T) {
+	owner := append([]byte{addressPrefix}, bytes.Repeat([]byte{0x11}, 20)...)
+	block := &Block{Number: 0x2faf0a1b, Id: bytes.Repeat([]byte{0xab}, 32), Timestamp: 1_700_000_000_000}
+	contract := encodeFreeze(owner, 1_000_000, ResourceEnergy)
+
+	raw, err := decodeMessage(rawTransaction(freezeBalanceV2Contract, "FreezeBalanceV2Contract", contract, block))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := raw.bytes(1); !bytes.Equal(got, []byte{0x0a, 0x1b}) {
+		t.Errorf("got ref block bytes %x, want 0a1b", got)
+	}
+	if got := raw.bytes(4); !bytes.Equal(got, block.Id[8:16]) {
+		t.Errorf("got ref block hash %x", got)
+	}
+	if got := raw.uint(8); got != uint64(block.Timestamp+Expiration.Milliseconds()) {
+		t.Errorf("got expiration %d", got)
+	}
+	if got := raw.uint(14); got != uint64(block.Timestamp) {
+		t.Errorf("got timestamp %d", got)
+	}
+
+	c, err := decodeMessage(raw.bytes(11))
+	if err != nil {
+		t.Fatal(err)
+	}
+	param, err := decodeMessage(c.bytes(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.uint(1) != freezeBalanceV2Contract {
+		t.Errorf("got contract type %d", c.uint(1))
+	}
+	if got := string(param.bytes(1)); got != "type.googleapis.com/protocol.FreezeBalanceV2Contract" {
+		t.Errorf("got type url %s", got)
+	}
+	if !bytes.Equal(param.bytes(2), contract) {
+		t.Errorf("got contract %x", param.bytes(2))
+	}
+}
+
+func TestSignTransaction(t *testing.T) {
+	key, err := ethcrypto.ToECDSA(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := []byte(strings.Repeat("raw data", 4))
+
+	tx, txId, err := signTransaction(raw, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash := sha256.Sum256(raw); !bytes.Equal(txId, hash[:]) {
+		t.Fatalf("got tx id %x, want the sha256 of the raw data", txId)
+	}
+
+	decoded, err := decodeMessage(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded.bytes(1), raw) {
+		t.Fatal("the raw data is not the first field")
+	}
+	pubKey, err := ethcrypto.SigToPub(txId, decoded.bytes(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(addressBytes(pubKey), addressBytes(&key.PublicKey)) {
+		t.Fatal("the signature does not recover the signer")
+	}
+}
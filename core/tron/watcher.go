@@ -0,0 +1,374 @@
+package tron
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/sisu-network/lib/log"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
+)
+
+var (
+	SleepTime = time.Second * 60 * 30
+	// StakeWaitTime is how long the watcher waits for a stake to be included before delegating it.
+	// Blocks are produced every 3 seconds.
+	StakeWaitTime = time.Second * 6
+
+	// DefaultPolicy funds 200 TRX whenever the balance drops below 100 TRX.
+	DefaultPolicy = funding.PolicyCfg{
+		Mode:      funding.ModeFixed,
+		Threshold: "100000000",
+		Amount:    "200000000",
+	}
+)
+
+// maxTxFee is the most TRX a transaction of the faucet burns: the bandwidth of a transfer when
+// the free bandwidth is used up, and the 1.1 TRX of a transfer that creates an account.
+const maxTxFee = int64(1_100_000)
+
+type watcher struct {
+	mnemonic     string
+	chain        string
+	cfg          Cfg
+	client       Client
+	watchAddr    string
+	watchRaw     []byte
+	freezeAmount int64
+	policy       *funding.Policy
+	burn         *funding.BurnTracker
+	limiter      *funding.Limiter
+	ledger       *ledger.Ledger
+	outflow      *funding.OutflowMonitor
+	audit        *audit.Trail
+	// pending is the last stake until it is delegated. It is saved to pendingFile so that a restart
+	// or the next leader does not stake again in the meantime.
+	pending     *pendingStake
+	pendingFile string
+	ctx         context.Context
+	cancel      context.CancelFunc
+	done        sync.WaitGroup
+}
+
+func NewWatcher(mnemonic string, chain string, client Client, cfg Cfg, pubkey []byte, pendingFile string,
+	policy *funding.Policy, burn *funding.BurnTracker, limiter *funding.Limiter, ledger *ledger.Ledger,
+	outflow *funding.OutflowMonitor, audit *audit.Trail) (*watcher, error) {
+	cfg = cfg.withDefaults()
+	freezeAmount, err := cfg.freezeAmount()
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, err := ethcrypto.UnmarshalPubkey(pubkey)
+	if err != nil {
+		return nil, err
+	}
+	watchRaw := addressBytes(pubKey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &watcher{
+		mnemonic:     mnemonic,
+		chain:        chain,
+		cfg:          cfg,
+		client:       client,
+		watchAddr:    encodeAddress(watchRaw),
+		watchRaw:     watchRaw,
+		freezeAmount: freezeAmount,
+		policy:       policy,
+		burn:         burn,
+		limiter:      limiter,
+		ledger:       ledger,
+		outflow:      outflow,
+		audit:        audit,
+		pendingFile:  pendingFile,
+		ctx:          ctx,
+		cancel:       cancel,
+	}, nil
+}
+
+func (w *watcher) Start() {
+	log.Infof("Starting watcher for chain %s, watch address = %s", w.chain, w.watchAddr)
+	w.loadPending()
+
+	w.done.Add(1)
+	go w.loop()
+}
+
+// Stop stops the watcher and waits for the cycle in progress to finish.
+func (w *watcher) Stop() {
+	w.cancel()
+	w.done.Wait()
+}
+
+func (w *watcher) loop() {
+	defer w.done.Done()
+
+	for {
+		if w.ctx.Err() != nil {
+			return
+		}
+
+		w.checkFaucet()
+
+		account, err := w.client.GetAccount(w.watchAddr)
+		if err != nil {
+			log.Errorf("Failed to get account on chain %s, err = %s", w.chain, err)
+			w.audit.Unavailable(audit.Record{Address: w.watchAddr, Threshold: w.policy.Threshold().String()}, err)
+		} else {
+			balance := big.NewInt(account.Balance)
+			log.Verbosef("Balance in sun: %s on chain %s", balance, w.chain)
+
+			w.burn.Observe(balance)
+			shouldFund, reason := w.policy.ShouldFund(balance, w.burn)
+			record := audit.Record{
+				Address:   w.watchAddr,
+				Balance:   balance.String(),
+				Threshold: w.policy.Threshold().String(),
+				Reason:    reason,
+			}
+			if amount := w.policy.Amount(balance); shouldFund && amount.Sign() > 0 {
+				log.Infof("Funding chain %s, reason: %s", w.chain, reason)
+				w.fund(amount, record)
+			} else {
+				record.Decision = audit.Skip
+				w.audit.Record(record)
+			}
+		}
+
+		w.checkResources()
+
+		if !funding.Sleep(w.ctx, SleepTime) {
+			return
+		}
+	}
+}
+
+// checkFaucet reports the faucet balance to the outflow monitor. Tron accounts have no nonce, so
+// only the balance is compared.
+func (w *watcher) checkFaucet() {
+	if !w.outflow.Enabled() {
+		return
+	}
+
+	privKey, err := getPrivateKey(w.mnemonic)
+	if err != nil {
+		log.Errorf("Failed to get faucet key on chain %s, err = %s", w.chain, err)
+		return
+	}
+	faucetAddr := encodeAddress(addressBytes(&privKey.PublicKey))
+
+	account, err := w.client.GetAccount(faucetAddr)
+	if err != nil {
+		log.Errorf("Failed to get faucet account on chain %s, err = %s", w.chain, err)
+		return
+	}
+	w.outflow.Observe(funding.FaucetState{Address: faucetAddr, Balance: big.NewInt(account.Balance)})
+}
+
+// checkResources stakes TRX for the watched address when its bandwidth or energy runs low, so that
+// its transactions do not burn TRX. A stake that is not delegated yet is delegated instead.
+func (w *watcher) checkResources() {
+	if w.pending != nil {
+		w.checkPending()
+		return
+	}
+
+	resources, err := w.client.GetResources(w.watchAddr)
+	if err != nil {
+		log.Errorf("Failed to get resources on chain %s, err = %s", w.chain, err)
+		return
+	}
+	log.Verbosef("Bandwidth = %d, energy = %d on chain %s", resources.Bandwidth(), resources.Energy(), w.chain)
+
+	available := resources.Energy()
+	if w.cfg.Resource == ResourceBandwidth {
+		available = resources.Bandwidth()
+	}
+	if available >= w.cfg.MinResource {
+		return
+	}
+
+	if w.freezeAmount == 0 {
+		log.Warnf("Available %s %d is below %d on chain %s, transactions will burn TRX", w.cfg.Resource,
+			available, w.cfg.MinResource, w.chain)
+		return
+	}
+
+	record := audit.Record{
+		Address: w.watchAddr,
+		Label:   "stake",
+		Amount:  fmt.Sprintf("%d", w.freezeAmount),
+		Reason: fmt.Sprintf("available %s %d is below %d", w.cfg.Resource, available,
+			w.cfg.MinResource),
+	}
+	reservation, err := w.limiter.Reserve(w.chain, w.watchAddr, big.NewInt(w.freezeAmount))
+	if err != nil {
+		log.Errorf("Cannot stake for chain %s, err = %s", w.chain, err)
+		w.audit.Refused(record, err)
+		return
+	}
+	txId, err := w.freeze()
+	// The stake is spent once it is sent, even if the delegation fails.
+	if txId != "" {
+		reservation.Commit()
+	}
+	if err != nil {
+		if txId == "" {
+			reservation.Release()
+		}
+		log.Errorf("Failed to stake for %s on chain %s, err = %s", w.cfg.Resource, w.chain, err)
+		w.audit.Failed(record, err)
+		return
+	}
+	w.audit.Funded(record, txId)
+}
+
+func (w *watcher) fund(amount *big.Int, record audit.Record) {
+	record.Amount = amount.String()
+	if !amount.IsInt64() {
+		log.Errorf("Funding amount %s is out of range on chain %s", amount, w.chain)
+		w.audit.Failed(record, fmt.Errorf("amount %s is out of range", amount))
+		return
+	}
+
+	reservation, err := w.limiter.Reserve(w.chain, w.watchAddr, amount)
+	if err != nil {
+		log.Errorf("Cannot fund chain %s, err = %s", w.chain, err)
+		w.audit.Refused(record, err)
+		return
+	}
+
+	txId, err := w.transfer(amount.Int64())
+	if err != nil {
+		reservation.Release()
+		log.Errorf("Failed to fund chain %s, err = %s", w.chain, err)
+		w.audit.Failed(record, err)
+		return
+	}
+	reservation.Commit()
+
+	w.burn.RecordTopUp()
+	log.Infof("Tron txId = %s on chain %s", txId, w.chain)
+	w.audit.Funded(record, txId)
+}
+
+// faucet returns the faucet key and address after checking that it holds at least amount sun.
+func (w *watcher) faucet(amount int64) (*ecdsa.PrivateKey, []byte, error) {
+	privKey, err := getPrivateKey(w.mnemonic)
+	if err != nil {
+		return nil, nil, err
+	}
+	faucetRaw := addressBytes(&privKey.PublicKey)
+
+	account, err := w.client.GetAccount(encodeAddress(faucetRaw))
+	if err != nil {
+		return nil, nil, err
+	}
+	if account.Balance < amount {
+		return nil, nil, fmt.Errorf("insufficient faucet balance %d, need %d", account.Balance, amount)
+	}
+
+	return privKey, faucetRaw, nil
+}
+
+// send signs and broadcasts a transaction with a single contract, records it in the ledger and
+// returns its id.
+func (w *watcher) send(privKey *ecdsa.PrivateKey, contractType uint64, name string, contract []byte,
+	amount int64, label string) (string, error) {
+	block, err := w.client.GetNowBlock()
+	if err != nil {
+		return "", err
+	}
+
+	tx, txId, err := signTransaction(rawTransaction(contractType, name, contract, block), privKey)
+	if err != nil {
+		return "", err
+	}
+
+	if err := w.client.Broadcast(tx); err != nil {
+		return "", err
+	}
+	w.outflow.Expect(big.NewInt(amount), big.NewInt(maxTxFee))
+
+	// Staked TRX stays with the faucet, the other contracts go to the watched address.
+	from, to := encodeAddress(addressBytes(&privKey.PublicKey)), w.watchAddr
+	if contractType == freezeBalanceV2Contract {
+		to = from
+	}
+
+	txHash := hex.EncodeToString(txId)
+	if err := w.ledger.Record(ledger.Entry{
+		Chain:  w.chain,
+		TxHash: txHash,
+		From:   from,
+		To:     to,
+		Amount: fmt.Sprintf("%d", amount),
+		Label:  label,
+	}); err != nil {
+		log.Errorf("Failed to record transfer %s on chain %s, err = %s", txHash, w.chain, err)
+	}
+
+	return txHash, nil
+}
+
+// transfer sends amount sun from the faucet to the watched address.
+func (w *watcher) transfer(amount int64) (string, error) {
+	privKey, faucetRaw, err := w.faucet(amount)
+	if err != nil {
+		return "", err
+	}
+
+	log.Infof("Funding %d sun from %s to %s on chain %s", amount, encodeAddress(faucetRaw), w.watchAddr, w.chain)
+	return w.send(privKey, transferContract, "TransferContract", encodeTransfer(faucetRaw, w.watchRaw, amount), amount, "mpc")
+}
+
+// freeze stakes the freeze amount from the faucet and delegates the resource to the watched
+// address. It returns the id of the delegation, or of the stake if only the stake was sent. A stake
+// that is not delegated stays pending until checkPending delegates it.
+func (w *watcher) freeze() (string, error) {
+	privKey, faucetRaw, err := w.faucet(w.freezeAmount)
+	if err != nil {
+		return "", err
+	}
+
+	log.Infof("Staking %d sun for %s on chain %s", w.freezeAmount, w.cfg.Resource, w.chain)
+	stakeTxId, err := w.send(privKey, freezeBalanceV2Contract, "FreezeBalanceV2Contract",
+		encodeFreeze(faucetRaw, w.freezeAmount, w.cfg.Resource), w.freezeAmount, "stake")
+	if err != nil {
+		return "", err
+	}
+	log.Infof("Tron stake txId = %s on chain %s", stakeTxId, w.chain)
+	w.setPending(&pendingStake{TxId: stakeTxId, SentAt: time.Now()})
+
+	if !funding.Sleep(w.ctx, StakeWaitTime) {
+		return stakeTxId, fmt.Errorf("staked but stopped before delegating")
+	}
+
+	txId, err := w.delegate()
+	if err != nil {
+		return stakeTxId, fmt.Errorf("staked but failed to delegate, err = %w", err)
+	}
+	log.Infof("Tron delegation txId = %s on chain %s", txId, w.chain)
+	w.setPending(nil)
+
+	return txId, nil
+}
+
+// delegate delegates the resource of the freeze amount staked by the faucet to the watched address.
+func (w *watcher) delegate() (string, error) {
+	privKey, err := getPrivateKey(w.mnemonic)
+	if err != nil {
+		return "", err
+	}
+	faucetRaw := addressBytes(&privKey.PublicKey)
+
+	return w.send(privKey, delegateResourceContract, "DelegateResourceContract",
+		encodeDelegate(faucetRaw, w.watchRaw, w.freezeAmount, w.cfg.Resource), 0, "delegate")
+}
@@ -0,0 +1,268 @@
+package tron
+
+import (
+	"bytes"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/sisu-network/sisu-account-funding/core/alert"
+	"github.com/sisu-network/sisu-account-funding/core/audit"
+	"github.com/sisu-network/sisu-account-funding/core/funding"
+	"github.com/sisu-network/sisu-account-funding/core/ledger"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// newTestWatcher returns a watcher talking to the stand-in at url, and the address of its faucet.
+// Its state is kept in dataDir.
+func newTestWatcher(t *testing.T, url string, cfg Cfg, dataDir string) (*watcher, string) {
+	t.Helper()
+
+	mpcKey, err := ethcrypto.ToECDSA(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := funding.NewPolicy(funding.PolicyCfg{}, DefaultPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerter := alert.NewAlerter("")
+	limiter, err := funding.NewLimiter(filepath.Join(dataDir, "limiter.json"), funding.GlobalLimitCfg{}, nil, alerter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewWatcher(testMnemonic, "tron-nile", NewClient(url, cfg), cfg, ethcrypto.FromECDSAPub(&mpcKey.PublicKey),
+		filepath.Join(dataDir, "pending.json"), policy, funding.NewBurnTracker(filepath.Join(dataDir, "burn.json"), 0), limiter,
+		ledger.NewLedger(filepath.Join(dataDir, "ledger.jsonl")),
+		funding.NewOutflowMonitor("tron-nile", funding.OutflowCfg{Disabled: true}, limiter, alerter),
+		(*audit.Log)(nil).Trail("tron-nile", url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.loadPending()
+
+	privKey, err := getPrivateKey(testMnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w, encodeAddress(addressBytes(&privKey.PublicKey))
+}
+
+func TestFund(t *testing.T) {
+	tests := []struct {
+		name   string
+		faucet int64
+		amount int64
+		sent   bool
+	}{
+		{name: "funded", faucet: 1_000_000_000, amount: 200_000_000, sent: true},
+		{name: "insufficient faucet", faucet: 100_000_000, amount: 200_000_000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn, url := newStandIn(t)
+			w, faucet := newTestWatcher(t, url, Cfg{}, t.TempDir())
+			standIn.setBalance(faucet, tt.faucet)
+
+			w.fund(big.NewInt(tt.amount), audit.Record{Address: w.watchAddr})
+
+			contracts := standIn.applied()
+			if !tt.sent {
+				if len(contracts) != 0 {
+					t.Fatalf("got contracts %v, want none", contracts)
+				}
+				return
+			}
+			want := standInContract{Type: transferContract, Owner: faucet, To: w.watchAddr, Amount: tt.amount}
+			if len(contracts) != 1 || contracts[0] != want {
+				t.Fatalf("got contracts %v, want %v", contracts, want)
+			}
+			if balance := standIn.balance(w.watchAddr); balance != tt.amount {
+				t.Fatalf("got mpc balance %d, want %d", balance, tt.amount)
+			}
+		})
+	}
+}
+
+func TestCheckResources(t *testing.T) {
+	defer func(wait time.Duration) { StakeWaitTime = wait }(StakeWaitTime)
+	StakeWaitTime = 0
+
+	tests := []struct {
+		name      string
+		cfg       Cfg
+		resources Resources
+		faucet    int64
+		// staked is the resource expected to be staked and delegated, "" if none.
+		staked string
+	}{
+		{
+			name:      "enough energy",
+			cfg:       Cfg{FreezeAmount: "100000000", MinResource: 1000},
+			resources: Resources{EnergyLimit: 5000, EnergyUsed: 3000},
+			faucet:    1_000_000_000,
+		},
+		{
+			name:      "energy below the minimum",
+			cfg:       Cfg{FreezeAmount: "100000000", MinResource: 1000},
+			resources: Resources{EnergyLimit: 5000, EnergyUsed: 4500},
+			faucet:    1_000_000_000,
+			staked:    ResourceEnergy,
+		},
+		{
+			name:      "bandwidth below the minimum",
+			cfg:       Cfg{FreezeAmount: "100000000", Resource: ResourceBandwidth, MinResource: 1000},
+			resources: Resources{FreeNetLimit: 600, FreeNetUsed: 300, EnergyLimit: 5000},
+			faucet:    1_000_000_000,
+			staked:    ResourceBandwidth,
+		},
+		{
+			name:      "staking disabled",
+			cfg:       Cfg{MinResource: 1000},
+			resources: Resources{},
+			faucet:    1_000_000_000,
+		},
+		{
+			name:      "insufficient faucet",
+			cfg:       Cfg{FreezeAmount: "100000000", MinResource: 1000},
+			resources: Resources{},
+			faucet:    10_000_000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			standIn, url := newStandIn(t)
+			w, faucet := newTestWatcher(t, url, tt.cfg, t.TempDir())
+			standIn.setBalance(faucet, tt.faucet)
+			standIn.setResources(w.watchAddr, tt.resources)
+
+			w.checkResources()
+
+			contracts := standIn.applied()
+			if tt.staked == "" {
+				if len(contracts) != 0 {
+					t.Fatalf("got contracts %v, want none", contracts)
+				}
+				return
+			}
+
+			want := []standInContract{
+				{Type: freezeBalanceV2Contract, Owner: faucet, Amount: 100_000_000, Resource: tt.staked},
+				{Type: delegateResourceContract, Owner: faucet, To: w.watchAddr, Amount: 100_000_000, Resource: tt.staked},
+			}
+			if len(contracts) != len(want) || contracts[0] != want[0] || contracts[1] != want[1] {
+				t.Fatalf("got contracts %v, want %v", contracts, want)
+			}
+
+			resources, err := w.client.GetResources(w.watchAddr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			available := resources.Energy()
+			if tt.staked == ResourceBandwidth {
+				available = resources.Bandwidth()
+			}
+			if available < tt.cfg.MinResource {
+				t.Fatalf("got %s %d after the delegation, want at least %d", tt.staked, available, tt.cfg.MinResource)
+			}
+		})
+	}
+}
+
+func TestCheckResourcesPendingStake(t *testing.T) {
+	defer func(wait time.Duration) { StakeWaitTime = wait }(StakeWaitTime)
+
+	tests := []struct {
+		name string
+		// hold keeps the first stake from being included until the include step.
+		hold bool
+		// stop stops the watcher while it waits to delegate the first stake.
+		stop bool
+		// steps run in order: check runs checkResources, include and drop end the hold of the
+		// stake, expire makes the pending stake older than its expiration and restart rebuilds the
+		// watcher from its data dir.
+		steps  []string
+		stakes int
+	}{
+		{
+			name:   "stake included late",
+			hold:   true,
+			steps:  []string{"check", "check", "include", "check", "check"},
+			stakes: 1,
+		},
+		{
+			name:   "stake included after a restart",
+			hold:   true,
+			steps:  []string{"check", "restart", "check", "include", "restart", "check"},
+			stakes: 1,
+		},
+		{
+			name:   "stopped before delegating",
+			stop:   true,
+			steps:  []string{"check", "restart", "check", "check"},
+			stakes: 1,
+		},
+		{
+			name:   "stake dropped",
+			hold:   true,
+			steps:  []string{"check", "drop", "check", "expire", "check", "check"},
+			stakes: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			StakeWaitTime = 0
+			cfg := Cfg{FreezeAmount: "100000000", MinResource: 1000}
+			standIn, url := newStandIn(t)
+			dataDir := t.TempDir()
+			w, faucet := newTestWatcher(t, url, cfg, dataDir)
+			standIn.setBalance(faucet, 1_000_000_000)
+			standIn.setHoldStakes(tt.hold)
+			if tt.stop {
+				StakeWaitTime = time.Hour
+				w.cancel()
+			}
+
+			for _, step := range tt.steps {
+				switch step {
+				case "check":
+					w.checkResources()
+				case "include":
+					if err := standIn.include(); err != nil {
+						t.Fatal(err)
+					}
+				case "drop":
+					standIn.drop()
+				case "expire":
+					w.setPending(&pendingStake{TxId: w.pending.TxId, SentAt: time.Now().Add(-3 * Expiration)})
+				case "restart":
+					StakeWaitTime = 0
+					w, _ = newTestWatcher(t, url, cfg, dataDir)
+				}
+			}
+
+			if count := standIn.stakeCount(); count != tt.stakes {
+				t.Fatalf("got %d stakes, want %d", count, tt.stakes)
+			}
+			if w.pending != nil {
+				t.Fatalf("stake %s is still pending", w.pending.TxId)
+			}
+			want := []standInContract{
+				{Type: freezeBalanceV2Contract, Owner: faucet, Amount: 100_000_000, Resource: ResourceEnergy},
+				{Type: delegateResourceContract, Owner: faucet, To: w.watchAddr, Amount: 100_000_000, Resource: ResourceEnergy},
+			}
+			contracts := standIn.applied()
+			if len(contracts) != len(want) || contracts[0] != want[0] || contracts[1] != want[1] {
+				t.Fatalf("got contracts %v, want %v", contracts, want)
+			}
+		})
+	}
+}
@@ -2,5 +2,7 @@ package core
 
 type Watcher interface {
 	Start()
+	// Stop stops the watcher and returns once its loop has exited, so that it no longer uses the
+	// state it was built with.
 	Stop()
 }
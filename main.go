@@ -5,13 +5,30 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/sisu-network/lib/log"
 	"github.com/sisu-network/sisu-account-funding/core"
 )
 
 func main() {
-	core.Run()
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		core.Reconcile(os.Args[2:])
+		return
+	}
 
-	c := make(chan os.Signal)
-	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
-	<-c
+	funder := core.Run()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range c {
+		if sig != syscall.SIGHUP {
+			break
+		}
+
+		log.Info("SIGHUP received, reloading chains.toml")
+		if err := funder.Reload(); err != nil {
+			log.Errorf("Failed to reload chains.toml, keeping the current config, err = %s", err)
+		}
+	}
+
+	funder.Stop()
 }